@@ -1,24 +1,49 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/leguminosa/profile-open-portal/generated"
 	"github.com/leguminosa/profile-open-portal/handler"
 	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/repository"
+	repositoryFederation "github.com/leguminosa/profile-open-portal/repository/federation"
+	repositoryOIDC "github.com/leguminosa/profile-open-portal/repository/oidc"
+	repositoryRefreshToken "github.com/leguminosa/profile-open-portal/repository/refreshtoken"
+	repositoryRevocation "github.com/leguminosa/profile-open-portal/repository/revocation"
+	repositoryThrottle "github.com/leguminosa/profile-open-portal/repository/throttle"
+	repositoryTwoFactor "github.com/leguminosa/profile-open-portal/repository/twofactor"
 	repositoryUser "github.com/leguminosa/profile-open-portal/repository/user"
+	repositoryVerification "github.com/leguminosa/profile-open-portal/repository/verification"
+	"github.com/leguminosa/profile-open-portal/tools"
 	"github.com/leguminosa/profile-open-portal/tools/auth"
+	"github.com/leguminosa/profile-open-portal/tools/blocklist"
 	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/leguminosa/profile-open-portal/tools/email"
+	"github.com/leguminosa/profile-open-portal/tools/federation"
 	"github.com/leguminosa/profile-open-portal/tools/jwtx"
+	"github.com/leguminosa/profile-open-portal/tools/phonenumber"
+	"github.com/leguminosa/profile-open-portal/tools/ratelimit"
+	"github.com/leguminosa/profile-open-portal/tools/sms"
+	"github.com/leguminosa/profile-open-portal/tools/totp"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
 	_ "github.com/lib/pq"
 )
 
 func main() {
 	e := echo.New()
+	e.IPExtractor = newIPExtractor()
 
 	server := newServer()
+	e.Use(newRateLimitMiddleware(server.Auth))
 	generated.RegisterHandlers(e, server)
 
 	e.Logger.Fatal(e.Start(":1323"))
@@ -49,30 +74,547 @@ func newServer() *handler.Server {
 		panic(err)
 	}
 
-	// tools layer
-	hashClient := crxpto.NewBcrypt()
-	jwtClient := jwtx.NewSigningMethodRS256(jwtx.NewSigningMethodRS256Options{
-		PrivateKey: privKey,
-		PublicKey:  pubKey,
-	})
-	authClient := auth.New(auth.NewAuthOptions{
-		JWT: jwtClient,
-	})
+	// get retired public keys that still verify tokens issued before a key rotation
+	verificationKeys := verificationKeysFromEnv("JWT_VERIFICATION_KEYS")
+
+	// get token lifetimes from environment variable, empty value falls back to the default
+	accessTokenTTL := durationFromEnv("ACCESS_TOKEN_TTL")
+	refreshTokenTTL := durationFromEnv("REFRESH_TOKEN_TTL")
+
+	// get the region of phone numbers written without country code, empty value falls back to the default
+	defaultPhoneRegion := phoneRegionFromEnv("DEFAULT_PHONE_REGION")
 
 	// repository layer
 	userRepo := repositoryUser.New(repositoryUser.NewRepositoryOptions{
 		DB: db,
 	})
+	refreshTokenRepo := repositoryRefreshToken.New(repositoryRefreshToken.NewRepositoryOptions{
+		DB: db,
+	})
+	revocationRepo := newRevocationRepository(db)
+	verificationRepo := repositoryVerification.New(repositoryVerification.NewRepositoryOptions{
+		DB: db,
+	})
+	twoFactorRepo := repositoryTwoFactor.New(repositoryTwoFactor.NewRepositoryOptions{
+		DB: db,
+	})
+	throttleRepo := newThrottleRepository(db)
+	oidcRepo := repositoryOIDC.New(repositoryOIDC.NewRepositoryOptions{
+		DB: db,
+	})
+	federationRepo := repositoryFederation.New(repositoryFederation.NewRepositoryOptions{
+		DB: db,
+	})
+
+	// tools layer
+	hashClient := newPasswordHash()
+	passwordBlocklist := newPasswordBlocklist()
+	passwordPolicy := newPasswordPolicy()
+	randomClient := crxpto.NewRandom()
+	smsSender := newSMSSender()
+	emailSender := newEmailSender()
+	signer := newSigner()
+	identityProviders := newIdentityProviders()
+	totpClient := totp.New(totp.NewTOTPOptions{
+		Issuer: os.Getenv("TOTP_ISSUER"),
+	})
+	jwtClient, err := jwtx.NewSigningMethod(jwtx.NewSigningMethodOptions{
+		Algorithm:        os.Getenv("JWT_ALGORITHM"),
+		PrivateKey:       privKey,
+		PublicKey:        pubKey,
+		KeyID:            os.Getenv("JWT_KEY_ID"),
+		VerificationKeys: verificationKeys,
+		Issuer:           os.Getenv("JWT_ISSUER"),
+		Audience:         listFromEnv("JWT_AUDIENCE"),
+		TTL:              accessTokenTTL,
+	})
+	if err != nil {
+		panic(err)
+	}
+	authClient := auth.New(auth.NewAuthOptions{
+		JWT:                  jwtClient,
+		UserRepository:       userRepo,
+		RevocationRepository: revocationRepo,
+	})
 
 	// module layer
 	userModule := moduleUser.New(moduleUser.NewUserModuleOptions{
-		UserRepository: userRepo,
-		Hash:           hashClient,
-		JWT:            jwtClient,
+		UserRepository:         userRepo,
+		RefreshTokenRepository: refreshTokenRepo,
+		RevocationRepository:   revocationRepo,
+		VerificationRepository: verificationRepo,
+		TwoFactorRepository:    twoFactorRepo,
+		ThrottleRepository:     throttleRepo,
+		OIDCRepository:         oidcRepo,
+		FederationRepository:   federationRepo,
+		PasswordBlocklist:      passwordBlocklist,
+		PasswordPolicy:         passwordPolicy,
+		Hash:                   hashClient,
+		JWT:                    jwtClient,
+		Random:                 randomClient,
+		SMSSender:              smsSender,
+		EmailSender:            emailSender,
+		Signer:                 signer,
+		TOTP:                   totpClient,
+		IdentityProviders:      identityProviders,
+		AccessTokenTTL:         accessTokenTTL,
+		RefreshTokenTTL:        refreshTokenTTL,
+		DefaultPhoneRegion:     defaultPhoneRegion,
+		EmailVerificationURL:   os.Getenv("EMAIL_VERIFICATION_URL"),
+		MagicLinkURL:           os.Getenv("MAGIC_LINK_URL"),
+		AuthorizationLoginURL:  os.Getenv("AUTHORIZATION_LOGIN_URL"),
 	})
 
 	return handler.NewServer(handler.NewServerOptions{
 		UserModule: userModule,
 		Auth:       authClient,
+		JWT:        jwtClient,
+		Issuer:     os.Getenv("JWT_ISSUER"),
+	})
+}
+
+// newRevocationRepository picks the revocation store from REVOCATION_STORE environment variable.
+// "memory" keeps revoked tokens in process, anything else stores them in postgres.
+func newRevocationRepository(db *sql.DB) repository.RevocationRepositoryInterface {
+	if os.Getenv("REVOCATION_STORE") == "memory" {
+		return repositoryRevocation.NewMemory()
+	}
+
+	return repositoryRevocation.New(repositoryRevocation.NewRepositoryOptions{
+		DB: db,
+	})
+}
+
+// newThrottleRepository picks the store of failed logins from LOGIN_THROTTLE_STORE environment variable.
+// "memory" keeps the counters in process, anything else stores them in postgres.
+func newThrottleRepository(db *sql.DB) repository.ThrottleRepositoryInterface {
+	if os.Getenv("LOGIN_THROTTLE_STORE") == "memory" {
+		return repositoryThrottle.NewMemory()
+	}
+
+	return repositoryThrottle.New(repositoryThrottle.NewRepositoryOptions{
+		DB: db,
 	})
 }
+
+// newIPExtractor decides where the client ip failed logins are counted under comes from.
+// X-Forwarded-For is only trusted when TRUST_X_FORWARDED_FOR is "true", meaning the app runs behind a proxy
+// that sets it, otherwise anyone could pick a new ip on every attempt.
+func newIPExtractor() echo.IPExtractor {
+	if os.Getenv("TRUST_X_FORWARDED_FOR") == "true" {
+		return echo.ExtractIPFromXFFHeader()
+	}
+
+	return echo.ExtractIPDirect()
+}
+
+// newRateLimitMiddleware limits requests of every route, with stricter limits on routes that are costly or easy to abuse.
+// Buckets are kept in process, so each instance enforces the limits on its own.
+func newRateLimitMiddleware(authClient tools.AuthInterface) echo.MiddlewareFunc {
+	middleware, err := ratelimit.Middleware(ratelimit.MiddlewareOptions{
+		Limiter: ratelimit.NewMemory(),
+		Rules: []ratelimit.Rule{
+			{
+				Method: http.MethodPost,
+				Path:   "/register",
+				Limit: tools.RateLimit{
+					Limit:  10,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/register/activation",
+				Limit: tools.RateLimit{
+					Limit:  20,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/login/otp/start",
+				Limit: tools.RateLimit{
+					Limit:  20,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/password/forgot",
+				Limit: tools.RateLimit{
+					Limit:  20,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/login/magic",
+				Limit: tools.RateLimit{
+					Limit:  20,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/login/federated",
+				Limit: tools.RateLimit{
+					Limit:  20,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/v1/profile/phone/verification",
+				Limit: tools.RateLimit{
+					Limit:  20,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByIP,
+			},
+			{
+				Method: http.MethodPost,
+				Path:   "/v1/profile/email/verification",
+				Limit: tools.RateLimit{
+					Limit:  5,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByAuthenticatedUser(authClient),
+			},
+			{
+				Method: http.MethodPut,
+				Path:   "/v1/profile",
+				Limit: tools.RateLimit{
+					Limit:  30,
+					Period: time.Hour,
+				},
+				Key: ratelimit.KeyByAuthenticatedUser(authClient),
+			},
+		},
+		Default: &ratelimit.Rule{
+			Limit: tools.RateLimit{
+				Limit:  300,
+				Period: time.Minute,
+			},
+			Key: ratelimit.KeyByIP,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return middleware
+}
+
+// newIdentityProviders returns the providers users can log in with, each configured only when its client id is set:
+// GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET for Google, MICROSOFT_CLIENT_ID, MICROSOFT_CLIENT_SECRET, and
+// MICROSOFT_TENANT_ID for Microsoft. Every provider sends users back to
+// FEDERATED_LOGIN_REDIRECT_URL, which must be registered at each of them.
+func newIdentityProviders() map[string]tools.IdentityProviderInterface {
+	var (
+		redirectURL = os.Getenv("FEDERATED_LOGIN_REDIRECT_URL")
+		providers   = map[string]tools.IdentityProviderInterface{}
+	)
+
+	if clientID := os.Getenv("GOOGLE_CLIENT_ID"); clientID != "" {
+		provider, err := federation.NewProvider(federation.NewProviderOptions{
+			Issuer:       "https://accounts.google.com",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+		})
+		if err != nil {
+			panic(err)
+		}
+		providers["google"] = provider
+	}
+
+	if clientID := os.Getenv("MICROSOFT_CLIENT_ID"); clientID != "" {
+		// the issuer of the multi-tenant endpoints is a template that never matches id tokens exactly
+		tenant := os.Getenv("MICROSOFT_TENANT_ID")
+		if tenant == "" {
+			panic(errors.New("MICROSOFT_TENANT_ID is required along with MICROSOFT_CLIENT_ID"))
+		}
+
+		provider, err := federation.NewProvider(federation.NewProviderOptions{
+			Issuer:       "https://login.microsoftonline.com/" + tenant + "/v2.0",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("MICROSOFT_CLIENT_SECRET"),
+			RedirectURL:  redirectURL,
+		})
+		if err != nil {
+			panic(err)
+		}
+		providers["microsoft"] = provider
+	}
+
+	return providers
+}
+
+// newPasswordHash peppers passwords when PASSWORD_PEPPER or the file at PASSWORD_PEPPER_PATH holds a secret,
+// versioned by PASSWORD_PEPPER_VERSION which defaults to "1". Peppers retired by a rotation are listed in
+// PASSWORD_RETIRED_PEPPERS as comma separated "version=path", they keep verifying hashes until users log in again.
+func newPasswordHash() tools.HashInterface {
+	passwordHash := crxpto.NewPasswordHash()
+
+	secret := []byte(os.Getenv("PASSWORD_PEPPER"))
+	if path := os.Getenv("PASSWORD_PEPPER_PATH"); path != "" {
+		var err error
+		secret, err = os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+	}
+	// trailing newline of the file is not part of the secret
+	secret = bytes.TrimSpace(secret)
+	if len(secret) == 0 {
+		return passwordHash
+	}
+
+	version := os.Getenv("PASSWORD_PEPPER_VERSION")
+	if version == "" {
+		version = "1"
+	}
+
+	var retired []crxpto.Pepper
+	for _, entry := range listFromEnv("PASSWORD_RETIRED_PEPPERS") {
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			panic("retired pepper must be in the form of version=path")
+		}
+
+		retiredSecret, err := os.ReadFile(strings.TrimSpace(entry[i+1:]))
+		if err != nil {
+			panic(err)
+		}
+
+		retired = append(retired, crxpto.Pepper{
+			Version: strings.TrimSpace(entry[:i]),
+			Secret:  bytes.TrimSpace(retiredSecret),
+		})
+	}
+
+	peppered, err := crxpto.NewPeppered(crxpto.NewPepperedOptions{
+		Hash: passwordHash,
+		Current: crxpto.Pepper{
+			Version: version,
+			Secret:  secret,
+		},
+		Retired: retired,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return peppered
+}
+
+// newPasswordBlocklist always blocks the embedded list of common passwords. Breached passwords are blocked as well
+// when PWNED_PASSWORDS_DIR points at an offline copy of the Have I Been Pwned ranges, optionally only those seen
+// at least PWNED_PASSWORDS_MIN_COUNT times.
+func newPasswordBlocklist() tools.PasswordBlocklistInterface {
+	lists := blocklist.Multi{
+		blocklist.NewCommon(),
+	}
+
+	if dir := os.Getenv("PWNED_PASSWORDS_DIR"); dir != "" {
+		// a missing range file is taken for a password never breached, so a wrong dir must not go unnoticed
+		info, err := os.Stat(dir)
+		if err != nil {
+			panic(err)
+		}
+		if !info.IsDir() {
+			panic(errors.New("PWNED_PASSWORDS_DIR must be a directory"))
+		}
+
+		var minCount int
+		if value := os.Getenv("PWNED_PASSWORDS_MIN_COUNT"); value != "" {
+			minCount, err = strconv.Atoi(value)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		lists = append(lists, blocklist.NewPwnedRange(blocklist.NewPwnedRangeOptions{
+			Dir:      dir,
+			MinCount: minCount,
+		}))
+	}
+
+	return lists
+}
+
+// newPasswordPolicy reads the password policy from the JSON file at PASSWORD_POLICY_PATH.
+// Without the file, or for fields left out of it, validator.DefaultPasswordPolicy applies.
+func newPasswordPolicy() validator.PasswordPolicy {
+	path := os.Getenv("PASSWORD_POLICY_PATH")
+	if path == "" {
+		return validator.DefaultPasswordPolicy()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	policy, err := validator.ParsePasswordPolicy(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return policy
+}
+
+// newSMSSender returns the sender of text messages. There is no sms provider integrated yet,
+// messages are appended to the file at SMS_LOG_PATH environment variable, or printed to stdout.
+func newSMSSender() tools.SMSSenderInterface {
+	path := os.Getenv("SMS_LOG_PATH")
+	if path == "" {
+		return sms.NewLogSender(os.Stdout)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	return sms.NewLogSender(f)
+}
+
+// newEmailSender returns the sender of emails. Emails are delivered through the smtp server at SMTP_ADDR
+// from SMTP_FROM, authenticating with SMTP_USERNAME and SMTP_PASSWORD when set. Without a server,
+// emails are appended to the file at EMAIL_LOG_PATH environment variable, or printed to stdout.
+func newEmailSender() tools.EmailSenderInterface {
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		sender, err := email.NewSMTPSender(email.NewSMTPSenderOptions{
+			Addr:     addr,
+			From:     os.Getenv("SMTP_FROM"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		return sender
+	}
+
+	path := os.Getenv("EMAIL_LOG_PATH")
+	if path == "" {
+		return email.NewLogSender(os.Stdout)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	return email.NewLogSender(f)
+}
+
+// newSigner signs the links sent to users with the secret at LINK_TOKEN_SECRET or the file at LINK_TOKEN_SECRET_PATH.
+// Rotating the secret invalidates every link that has been sent.
+func newSigner() tools.SignerInterface {
+	secret := []byte(os.Getenv("LINK_TOKEN_SECRET"))
+	if path := os.Getenv("LINK_TOKEN_SECRET_PATH"); path != "" {
+		var err error
+		secret, err = os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// trailing newline of the file is not part of the secret
+	signer, err := crxpto.NewHMACSigner(bytes.TrimSpace(secret))
+	if err != nil {
+		panic(err)
+	}
+
+	return signer
+}
+
+// verificationKeysFromEnv reads comma separated public key paths from environment variable.
+// Each entry is either "path" or "kid=path", kid defaults to the thumbprint of the key
+// and the algorithm is detected from the key type.
+func verificationKeysFromEnv(key string) []jwtx.Key {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var keys []jwtx.Key
+	for _, entry := range strings.Split(value, ",") {
+		var keyID, path string
+		if i := strings.Index(entry, "="); i >= 0 {
+			keyID, path = entry[:i], entry[i+1:]
+		} else {
+			path = entry
+		}
+
+		publicKey, err := os.ReadFile(strings.TrimSpace(path))
+		if err != nil {
+			panic(err)
+		}
+
+		keys = append(keys, jwtx.Key{
+			ID:        strings.TrimSpace(keyID),
+			PublicKey: publicKey,
+		})
+	}
+
+	return keys
+}
+
+// listFromEnv splits comma separated values of environment variable.
+func listFromEnv(key string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+// durationFromEnv parses duration like "15m" or "720h" from environment variable.
+// It returns zero when the variable is not set.
+func durationFromEnv(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return duration
+}
+
+// phoneRegionFromEnv reads an ISO 3166-1 alpha-2 region code like ID from environment variable,
+// refusing to start with a region phone numbers cannot be normalized for.
+func phoneRegionFromEnv(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return ""
+	}
+
+	region, ok := phonenumber.LookupRegion(value)
+	if !ok {
+		panic(key + " is not a supported phone region: " + value)
+	}
+
+	return region.Code
+}
@@ -11,9 +11,242 @@ CREATE TABLE users (
     id              SERIAL                                                  not null
         primary key,
     fullname        VARCHAR                                                 not null,
+    -- E.164 format like +628123456789, so one number is stored and looked up one way only.
+    -- numbers stored before as digits only, like 628123456789, are moved by migrate_phone_number_e164.sql
     phone_number    VARCHAR                                                 not null    unique,
     password        TEXT                                                    not null,
     login_count     INTEGER                     default 0                   not null,
     created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null,
-    updated_at      TIMESTAMP WITH TIME ZONE
+    updated_at      TIMESTAMP WITH TIME ZONE,
+    -- pending until phone_number is verified, only active user can log in, deleted is final
+    status          VARCHAR                     default 'active'            not null
+        check (status in ('pending', 'active', 'suspended', 'deleted')),
+    -- bumped on password change and logout from all devices, jwt carrying an older version is rejected
+    token_version   INTEGER                     default 0                   not null,
+    -- null until user proves owning phone_number with a code sent over sms
+    phone_verified_at       TIMESTAMP WITH TIME ZONE,
+    -- replaces phone_number once verified, phone_number stays the login identifier until then
+    pending_phone_number    VARCHAR,
+    -- optional second login identifier, only set once verified through a signed link sent to it.
+    -- stored lowercased so uniqueness is case-insensitive
+    email                   VARCHAR                                         unique
+        check (email = lower(email)),
+    email_verified_at       TIMESTAMP WITH TIME ZONE,
+    -- replaces email once verified, email stays the login identifier until then
+    pending_email           VARCHAR
 );
+
+-- passwords a user had before, only the latest few are kept to refuse reusing them
+CREATE TABLE password_history (
+    id              SERIAL                                                  not null
+        primary key,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    hashed_password TEXT                                                    not null,
+    -- when the password was replaced
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE INDEX password_history_user_id_idx ON password_history (user_id);
+
+CREATE TABLE roles (
+    name            VARCHAR                                                 not null
+        primary key
+);
+
+CREATE TABLE role_permissions (
+    role            VARCHAR                                                 not null
+        references roles (name) on delete cascade,
+    permission      VARCHAR                                                 not null,
+    primary key (role, permission)
+);
+
+CREATE TABLE user_roles (
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    role            VARCHAR                                                 not null
+        references roles (name) on delete cascade,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null,
+    primary key (user_id, role)
+);
+
+-- every registered user gets "user" role, "admin" role is granted manually
+INSERT INTO roles (name) VALUES ('user'), ('admin');
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'profile:read'),
+    ('user', 'profile:write'),
+    ('admin', 'users:read'),
+    ('admin', 'users:write');
+
+CREATE TABLE refresh_tokens (
+    id              SERIAL                                                  not null
+        primary key,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    token_hash      VARCHAR                                                 not null    unique,
+    family_id       VARCHAR                                                 not null,
+    expires_at      TIMESTAMP WITH TIME ZONE                                not null,
+    revoked_at      TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- expired tokens are purged whenever a new one is issued
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+CREATE TABLE revoked_tokens (
+    token_id        VARCHAR                                                 not null
+        primary key,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    expires_at      TIMESTAMP WITH TIME ZONE                                not null,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+-- expired tokens are purged whenever a token is revoked
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+CREATE TABLE verification_codes (
+    id              SERIAL                                                  not null
+        primary key,
+    phone_number    VARCHAR                                                 not null,
+    -- what the code can be used for, like password_reset
+    purpose         VARCHAR                                                 not null,
+    code_hash       TEXT                                                    not null,
+    expires_at      TIMESTAMP WITH TIME ZONE                                not null,
+    consumed_at     TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE INDEX verification_codes_phone_number_purpose_idx ON verification_codes (phone_number, purpose);
+
+-- expired codes are purged whenever a new one is issued
+CREATE INDEX verification_codes_expires_at_idx ON verification_codes (expires_at);
+
+CREATE TABLE user_totp (
+    user_id         INTEGER                                                 not null
+        primary key
+        references users (id) on delete cascade,
+    -- base32 secret shared with the authenticator app, needed in plain to compute codes
+    secret          VARCHAR                                                 not null,
+    -- two-factor authentication is only enabled once the first code has been confirmed
+    confirmed_at    TIMESTAMP WITH TIME ZONE,
+    -- latest time step used, codes of the same or earlier step are refused to prevent replay
+    last_used_step  BIGINT                      default 0                   not null,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE TABLE totp_recovery_codes (
+    id              SERIAL                                                  not null
+        primary key,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    code_hash       TEXT                                                    not null,
+    used_at         TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE INDEX totp_recovery_codes_user_id_idx ON totp_recovery_codes (user_id);
+
+CREATE TABLE login_challenges (
+    id              SERIAL                                                  not null
+        primary key,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    token_hash      VARCHAR                                                 not null    unique,
+    attempts        INTEGER                     default 0                   not null,
+    expires_at      TIMESTAMP WITH TIME ZONE                                not null,
+    consumed_at     TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+-- expired challenges are purged whenever a new one is issued
+CREATE INDEX login_challenges_expires_at_idx ON login_challenges (expires_at);
+
+CREATE TABLE login_failures (
+    -- what the failures are counted for, like phone:+628123456789 or ip:192.0.2.1
+    key             VARCHAR                                                 not null
+        primary key,
+    failures        INTEGER                                                 not null,
+    last_failed_at  TIMESTAMP WITH TIME ZONE                                not null
+);
+
+-- counters whose last failure is too old to count are purged whenever a login is attempted
+CREATE INDEX login_failures_last_failed_at_idx ON login_failures (last_failed_at);
+
+-- applications allowed to log users in through this service with OpenID Connect, registered by hand:
+-- INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris)
+-- VALUES ('wiki', 'Wiki', '<hex sha256 of the secret>', ARRAY['https://wiki.example.com/callback']);
+CREATE TABLE oauth_clients (
+    -- client_id sent by the application
+    id              VARCHAR                                                 not null
+        primary key,
+    name            VARCHAR                                                 not null,
+    -- null for public clients like a single page app, which rely on PKCE alone
+    secret_hash     VARCHAR,
+    -- a code is only sent to one of these, compared exactly
+    redirect_uris   TEXT[]                                                  not null,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE TABLE authorization_codes (
+    id              SERIAL                                                  not null
+        primary key,
+    client_id       VARCHAR                                                 not null
+        references oauth_clients (id) on delete cascade,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    code_hash       VARCHAR                                                 not null    unique,
+    redirect_uri    VARCHAR                                                 not null,
+    scope           VARCHAR                                                 not null,
+    nonce           VARCHAR                     default ''                  not null,
+    -- PKCE challenge, always S256
+    code_challenge  VARCHAR                                                 not null,
+    expires_at      TIMESTAMP WITH TIME ZONE                                not null,
+    consumed_at     TIMESTAMP WITH TIME ZONE,
+    -- jti of the access token the code was exchanged for, revoked when the code is replayed
+    access_token_id VARCHAR                     default ''                  not null,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+-- expired codes are purged whenever a new one is issued
+CREATE INDEX authorization_codes_expires_at_idx ON authorization_codes (expires_at);
+
+-- accounts of external OpenID Connect providers, like Google or Microsoft, a user can log in with
+CREATE TABLE federated_identities (
+    id              SERIAL                                                  not null
+        primary key,
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    -- name of the provider in configuration, like google
+    provider        VARCHAR                                                 not null,
+    -- subject is only unique per issuer
+    issuer          VARCHAR                                                 not null,
+    subject         VARCHAR                                                 not null,
+    -- email of the account when it was linked, only shown to user
+    email           VARCHAR                     default ''                  not null,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null,
+    unique (issuer, subject)
+);
+
+CREATE INDEX federated_identities_user_id_idx ON federated_identities (user_id);
+
+-- logins sent to an external provider, waiting for user to come back with a code
+CREATE TABLE federated_login_requests (
+    id              SERIAL                                                  not null
+        primary key,
+    state_hash      VARCHAR                                                 not null    unique,
+    provider        VARCHAR                                                 not null,
+    nonce           VARCHAR                                                 not null,
+    -- PKCE verifier sent along with the code, the provider only ever saw its challenge
+    code_verifier   VARCHAR                                                 not null,
+    -- user linking the account from profile, null when logging in
+    user_id         INTEGER
+        references users (id) on delete cascade,
+    expires_at      TIMESTAMP WITH TIME ZONE                                not null,
+    consumed_at     TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null
+);
+
+CREATE INDEX federated_login_requests_expires_at_idx ON federated_login_requests (expires_at);
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"time"
+)
+
+// Fields admin can sort users by, prefixed with "-" for descending order.
+const (
+	UserSortID        = "id"
+	UserSortCreatedAt = "created_at"
+	UserSortFullname  = "fullname"
+)
+
+type (
+	// ListUsersRequest is what admin asks for when listing users, every field is optional.
+	ListUsersRequest struct {
+		PhonePrefix string
+		Status      string
+		// Name matches any part of fullname case insensitively.
+		Name string
+		// CreatedAfter is inclusive while CreatedBefore is exclusive.
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+		Sort          string
+		Cursor        string
+		Limit         int
+	}
+	// ListUsersFilter is a validated ListUsersRequest passed to repository.
+	ListUsersFilter struct {
+		PhonePrefix   string
+		Status        string
+		Name          string
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+		SortField     string
+		Descending    bool
+		// After is nil for the first page.
+		After *UserCursor
+		Limit int
+	}
+	// UserCursor points at the last user of a page, holding the value it was sorted by.
+	UserCursor struct {
+		Sort      string    `json:"s"`
+		ID        int       `json:"i"`
+		CreatedAt time.Time `json:"c,omitempty"`
+		Fullname  string    `json:"f,omitempty"`
+	}
+	ListUsersModuleResponse struct {
+		Users []*User
+		// NextCursor is empty on the last page.
+		NextCursor string
+		Valid      bool
+		Messages   []string
+	}
+)
@@ -0,0 +1,49 @@
+package entity
+
+import (
+	"time"
+)
+
+type (
+	// FederatedIdentity represents federated_identities table, an account of an external OpenID Connect provider
+	// linked to a user. The account is identified by issuer and subject, its email is never used to find the user.
+	FederatedIdentity struct {
+		ID        int       `json:"id" db:"id"`
+		UserID    int       `json:"-" db:"user_id"`
+		Provider  string    `json:"provider" db:"provider"`
+		Issuer    string    `json:"-" db:"issuer"`
+		Subject   string    `json:"-" db:"subject"`
+		Email     string    `json:"email" db:"email"`
+		CreatedAt time.Time `json:"created_at" db:"created_at"`
+	}
+	// FederatedLoginRequest represents federated_login_requests table, a login sent to an external provider
+	// that is completed once user comes back with the state and a code. Only the hash of the state is stored.
+	FederatedLoginRequest struct {
+		ID           int    `json:"-" db:"id"`
+		StateHash    string `json:"-" db:"state_hash"`
+		Provider     string `json:"-" db:"provider"`
+		Nonce        string `json:"-" db:"nonce"`
+		CodeVerifier string `json:"-" db:"code_verifier"`
+		// UserID is the user linking the account, zero when logging in.
+		UserID     int        `json:"-" db:"user_id"`
+		ExpiresAt  time.Time  `json:"-" db:"expires_at"`
+		ConsumedAt *time.Time `json:"-" db:"consumed_at"`
+		CreatedAt  time.Time  `json:"-" db:"created_at"`
+	}
+	StartFederatedLoginModuleResponse struct {
+		// RedirectTo is the login page of the provider.
+		RedirectTo string
+		// State comes back along with the code, the client should check it is the one it started with.
+		State string
+	}
+)
+
+// Linking returns true if the request links an account to a user instead of logging in.
+func (r *FederatedLoginRequest) Linking() bool {
+	return r.UserID != 0
+}
+
+// Expired returns true if user took too long to come back from the provider.
+func (r *FederatedLoginRequest) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFederatedLoginRequest_Linking(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *FederatedLoginRequest
+		want    bool
+	}{
+		{
+			name:    "logging in",
+			request: &FederatedLoginRequest{},
+			want:    false,
+		},
+		{
+			name: "linking",
+			request: &FederatedLoginRequest{
+				UserID: 15,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.request.Linking()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFederatedLoginRequest_Expired(t *testing.T) {
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		request *FederatedLoginRequest
+		want    bool
+	}{
+		{
+			name: "not expired",
+			request: &FederatedLoginRequest{
+				ExpiresAt: now.Add(time.Second),
+			},
+			want: false,
+		},
+		{
+			name: "expired exactly now",
+			request: &FederatedLoginRequest{
+				ExpiresAt: now,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.request.Expired(now)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
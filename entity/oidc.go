@@ -0,0 +1,105 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	// ScopeOpenID must be requested by every client, it is what makes an authorization request OpenID Connect.
+	ScopeOpenID = "openid"
+	// ScopeProfile grants the name of the user on /userinfo.
+	ScopeProfile = "profile"
+	// ScopePhone grants the phone number of the user and whether it is verified on /userinfo.
+	ScopePhone = "phone"
+	// CodeChallengeMethodS256 is the only PKCE method accepted, plain would let an intercepted challenge redeem the code.
+	CodeChallengeMethodS256 = "S256"
+)
+
+type (
+	// OAuthClient represents oauth_clients table, an application allowed to log users in through this service.
+	// A client without secret is a public client, like a single page app, that relies on PKCE alone.
+	// Only the hash of the secret is stored, the plain value is handed to the application once.
+	OAuthClient struct {
+		ID           string    `json:"-" db:"id"`
+		Name         string    `json:"-" db:"name"`
+		SecretHash   string    `json:"-" db:"secret_hash"`
+		RedirectURIs []string  `json:"-" db:"redirect_uris"`
+		CreatedAt    time.Time `json:"-" db:"created_at"`
+	}
+	// AuthorizationCode represents authorization_codes table, a short-lived single-use code
+	// a client exchanges for tokens of the user who approved the authorization request.
+	// Only the hash of the code is stored, the plain value is sent to the client once.
+	AuthorizationCode struct {
+		ID            int        `json:"-" db:"id"`
+		ClientID      string     `json:"-" db:"client_id"`
+		UserID        int        `json:"-" db:"user_id"`
+		CodeHash      string     `json:"-" db:"code_hash"`
+		RedirectURI   string     `json:"-" db:"redirect_uri"`
+		Scope         string     `json:"-" db:"scope"`
+		Nonce         string     `json:"-" db:"nonce"`
+		CodeChallenge string     `json:"-" db:"code_challenge"`
+		ExpiresAt     time.Time  `json:"-" db:"expires_at"`
+		ConsumedAt    *time.Time `json:"-" db:"consumed_at"`
+		// AccessTokenID is the jti of the access token the code was exchanged for,
+		// so the token can be revoked when the code is replayed.
+		AccessTokenID string    `json:"-" db:"access_token_id"`
+		CreatedAt     time.Time `json:"-" db:"created_at"`
+	}
+	// AuthorizationRequest is the query of /authorize as defined by RFC 6749 and RFC 7636.
+	AuthorizationRequest struct {
+		ResponseType        string
+		ClientID            string
+		RedirectURI         string
+		Scope               string
+		State               string
+		Nonce               string
+		CodeChallenge       string
+		CodeChallengeMethod string
+	}
+	// TokenRequest is the form posted to /token to exchange an authorization code.
+	TokenRequest struct {
+		GrantType    string
+		Code         string
+		RedirectURI  string
+		ClientID     string
+		ClientSecret string
+		CodeVerifier string
+	}
+	TokenModuleResponse struct {
+		AccessToken string
+		IDToken     string
+		Scope       string
+	}
+	// UserInfoModuleResponse is the user of an access token along with which of their claims it was granted.
+	UserInfoModuleResponse struct {
+		User    *User
+		Profile bool
+		Phone   bool
+	}
+)
+
+// Public returns true if client has no secret to authenticate with.
+func (c *OAuthClient) Public() bool {
+	return c.SecretHash == ""
+}
+
+// AllowsRedirectURI returns true if the uri is registered for the client.
+// It must match exactly, so a code is never sent anywhere the client did not register.
+func (c *OAuthClient) AllowsRedirectURI(redirectURI string) bool {
+	for _, uri := range c.RedirectURIs {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// Consumed returns true if code has been exchanged for tokens.
+func (a *AuthorizationCode) Consumed() bool {
+	return a.ConsumedAt != nil
+}
+
+// Expired returns true if code is no longer valid at the given time.
+func (a *AuthorizationCode) Expired(now time.Time) bool {
+	return !now.Before(a.ExpiresAt)
+}
@@ -0,0 +1,134 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthClient_Public(t *testing.T) {
+	tests := []struct {
+		name   string
+		client *OAuthClient
+		want   bool
+	}{
+		{
+			name:   "public",
+			client: &OAuthClient{},
+			want:   true,
+		},
+		{
+			name: "confidential",
+			client: &OAuthClient{
+				SecretHash: "hashed-secret",
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.client.Public()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOAuthClient_AllowsRedirectURI(t *testing.T) {
+	client := &OAuthClient{
+		RedirectURIs: []string{
+			"https://wiki.example.com/callback",
+			"http://localhost:3000/callback",
+		},
+	}
+	tests := []struct {
+		name        string
+		redirectURI string
+		want        bool
+	}{
+		{
+			name:        "registered",
+			redirectURI: "http://localhost:3000/callback",
+			want:        true,
+		},
+		{
+			name:        "different path",
+			redirectURI: "https://wiki.example.com/callback/other",
+			want:        false,
+		},
+		{
+			name:        "extra query",
+			redirectURI: "https://wiki.example.com/callback?next=https://evil.example.com",
+			want:        false,
+		},
+		{
+			name:        "empty",
+			redirectURI: "",
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := client.AllowsRedirectURI(tt.redirectURI)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorizationCode_Consumed(t *testing.T) {
+	consumedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name string
+		code *AuthorizationCode
+		want bool
+	}{
+		{
+			name: "not consumed",
+			code: &AuthorizationCode{},
+			want: false,
+		},
+		{
+			name: "consumed",
+			code: &AuthorizationCode{
+				ConsumedAt: &consumedAt,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.code.Consumed()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorizationCode_Expired(t *testing.T) {
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		code *AuthorizationCode
+		want bool
+	}{
+		{
+			name: "not expired",
+			code: &AuthorizationCode{
+				ExpiresAt: now.Add(time.Second),
+			},
+			want: false,
+		},
+		{
+			name: "expired exactly now",
+			code: &AuthorizationCode{
+				ExpiresAt: now,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.code.Expired(now)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package entity
+
+import (
+	"time"
+)
+
+type (
+	// PasswordHistory represents password_history table, a password the user had before.
+	// Kept so that recent passwords cannot be reused.
+	PasswordHistory struct {
+		ID             int       `json:"-" db:"id"`
+		UserID         int       `json:"-" db:"user_id"`
+		HashedPassword string    `json:"-" db:"hashed_password"`
+		CreatedAt      time.Time `json:"-" db:"created_at"`
+	}
+)
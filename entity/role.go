@@ -0,0 +1,17 @@
+package entity
+
+// Roles are stored in user_roles table, their permissions in role_permissions table.
+const (
+	// RoleUser is given to every registered user.
+	RoleUser = "user"
+	// RoleAdmin is only given manually.
+	RoleAdmin = "admin"
+)
+
+// Permissions are what a route requires, granted through roles.
+const (
+	PermissionProfileRead  = "profile:read"
+	PermissionProfileWrite = "profile:write"
+	PermissionUsersRead    = "users:read"
+	PermissionUsersWrite   = "users:write"
+)
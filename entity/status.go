@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"errors"
+)
+
+// Statuses a user goes through, only an active user can log in.
+const (
+	// UserStatusPending is the status every registered user starts with until their phone number is verified.
+	UserStatusPending = "pending"
+	// UserStatusActive is a user who has verified their phone number.
+	UserStatusActive = "active"
+	// UserStatusSuspended is a user blocked by admin until reactivated.
+	UserStatusSuspended = "suspended"
+	// UserStatusDeleted is final, the row is kept so the account can still be audited.
+	UserStatusDeleted = "deleted"
+)
+
+// Internal reasons a user is refused, they are never shown to the user as is.
+var (
+	ErrUserPending   = errors.New("user is pending activation")
+	ErrUserSuspended = errors.New("user is suspended")
+	ErrUserDeleted   = errors.New("user is deleted")
+	ErrUserStatus    = errors.New("user status is unknown")
+)
+
+// CheckUserStatus returns the reason a user with the given status cannot use the service, nil if active.
+func CheckUserStatus(status string) error {
+	switch status {
+	case UserStatusActive:
+		return nil
+	case UserStatusPending:
+		return ErrUserPending
+	case UserStatusSuspended:
+		return ErrUserSuspended
+	case UserStatusDeleted:
+		return ErrUserDeleted
+	default:
+		return ErrUserStatus
+	}
+}
+
+// ObscuredError shows only the public error while keeping the actual reason,
+// errors.Is matches both of them.
+type ObscuredError struct {
+	Public error
+	Reason error
+}
+
+// Obscure hides reason behind public.
+func Obscure(public, reason error) error {
+	return &ObscuredError{
+		Public: public,
+		Reason: reason,
+	}
+}
+
+func (e *ObscuredError) Error() string {
+	return e.Public.Error()
+}
+
+func (e *ObscuredError) Is(target error) bool {
+	return target == e.Public
+}
+
+func (e *ObscuredError) Unwrap() error {
+	return e.Reason
+}
@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUserStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		wantErr error
+	}{
+		{
+			name:    "active",
+			status:  UserStatusActive,
+			wantErr: nil,
+		},
+		{
+			name:    "pending",
+			status:  UserStatusPending,
+			wantErr: ErrUserPending,
+		},
+		{
+			name:    "suspended",
+			status:  UserStatusSuspended,
+			wantErr: ErrUserSuspended,
+		},
+		{
+			name:    "deleted",
+			status:  UserStatusDeleted,
+			wantErr: ErrUserDeleted,
+		},
+		{
+			name:    "unknown",
+			status:  "",
+			wantErr: ErrUserStatus,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckUserStatus(tt.status)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestObscure(t *testing.T) {
+	public := errors.New("login failed")
+	err := Obscure(public, ErrUserSuspended)
+
+	assert.Equal(t, "login failed", err.Error())
+	assert.ErrorIs(t, err, public)
+	assert.ErrorIs(t, err, ErrUserSuspended)
+	assert.NotErrorIs(t, err, ErrUserDeleted)
+}
@@ -0,0 +1,15 @@
+package entity
+
+import (
+	"time"
+)
+
+type (
+	// LoginFailures represents login_failures table, failed login attempts counted under a key
+	// like the phone number or the ip address of the client.
+	LoginFailures struct {
+		Key          string    `json:"-" db:"key"`
+		Failures     int       `json:"-" db:"failures"`
+		LastFailedAt time.Time `json:"-" db:"last_failed_at"`
+	}
+)
@@ -0,0 +1,35 @@
+package entity
+
+import (
+	"time"
+)
+
+type (
+	// RefreshToken represents refresh_tokens table.
+	// Only the hash of the opaque token is stored, the plain value is handed to the client once.
+	RefreshToken struct {
+		ID        int        `json:"-" db:"id"`
+		UserID    int        `json:"-" db:"user_id"`
+		TokenHash string     `json:"-" db:"token_hash"`
+		FamilyID  string     `json:"-" db:"family_id"`
+		ExpiresAt time.Time  `json:"-" db:"expires_at"`
+		RevokedAt *time.Time `json:"-" db:"revoked_at"`
+		CreatedAt time.Time  `json:"-" db:"created_at"`
+	}
+	// RevokedToken represents revoked_tokens table, a jwt that must be rejected before it expires.
+	RevokedToken struct {
+		TokenID   string    `json:"-" db:"token_id"`
+		UserID    int       `json:"-" db:"user_id"`
+		ExpiresAt time.Time `json:"-" db:"expires_at"`
+	}
+)
+
+// Revoked returns true if token has been used or explicitly revoked.
+func (t *RefreshToken) Revoked() bool {
+	return t.RevokedAt != nil
+}
+
+// Expired returns true if token is no longer valid at the given time.
+func (t *RefreshToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
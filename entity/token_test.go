@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshToken_Revoked(t *testing.T) {
+	revokedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name  string
+		token *RefreshToken
+		want  bool
+	}{
+		{
+			name:  "not revoked",
+			token: &RefreshToken{},
+			want:  false,
+		},
+		{
+			name: "revoked",
+			token: &RefreshToken{
+				RevokedAt: &revokedAt,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.token.Revoked()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRefreshToken_Expired(t *testing.T) {
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		token *RefreshToken
+		want  bool
+	}{
+		{
+			name: "not expired",
+			token: &RefreshToken{
+				ExpiresAt: now.Add(time.Second),
+			},
+			want: false,
+		},
+		{
+			name: "expires exactly now",
+			token: &RefreshToken{
+				ExpiresAt: now,
+			},
+			want: true,
+		},
+		{
+			name: "expired",
+			token: &RefreshToken{
+				ExpiresAt: now.Add(-time.Second),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.token.Expired(now)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"time"
+)
+
+type (
+	// TOTP represents user_totp table, the secret shared between user and an authenticator app.
+	TOTP struct {
+		UserID       int        `json:"-" db:"user_id"`
+		Secret       string     `json:"-" db:"secret"`
+		ConfirmedAt  *time.Time `json:"-" db:"confirmed_at"`
+		LastUsedStep int64      `json:"-" db:"last_used_step"`
+		CreatedAt    time.Time  `json:"-" db:"created_at"`
+	}
+	// RecoveryCode represents totp_recovery_codes table, a single-use code replacing the authenticator app once.
+	// Only the hash of the code is stored, the plain value is shown to the user once.
+	RecoveryCode struct {
+		ID        int        `json:"-" db:"id"`
+		UserID    int        `json:"-" db:"user_id"`
+		CodeHash  string     `json:"-" db:"code_hash"`
+		UsedAt    *time.Time `json:"-" db:"used_at"`
+		CreatedAt time.Time  `json:"-" db:"created_at"`
+	}
+	// LoginChallenge represents login_challenges table, proof that a user passed the password step of login.
+	// Only the hash of the token is stored, the plain value is given to the client once.
+	LoginChallenge struct {
+		ID         int        `json:"-" db:"id"`
+		UserID     int        `json:"-" db:"user_id"`
+		TokenHash  string     `json:"-" db:"token_hash"`
+		Attempts   int        `json:"-" db:"attempts"`
+		ExpiresAt  time.Time  `json:"-" db:"expires_at"`
+		ConsumedAt *time.Time `json:"-" db:"consumed_at"`
+		CreatedAt  time.Time  `json:"-" db:"created_at"`
+	}
+	TOTPEnrollmentModuleResponse struct {
+		Secret string
+		URI    string
+	}
+)
+
+// Enabled returns true if the secret has been confirmed with a code from the authenticator app.
+func (t *TOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// Consumed returns true if challenge has been exchanged for a jwt.
+func (c *LoginChallenge) Consumed() bool {
+	return c.ConsumedAt != nil
+}
+
+// Expired returns true if challenge is no longer valid at the given time.
+func (c *LoginChallenge) Expired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
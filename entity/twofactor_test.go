@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTP_Enabled(t *testing.T) {
+	confirmedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name string
+		totp *TOTP
+		want bool
+	}{
+		{
+			name: "not confirmed",
+			totp: &TOTP{},
+			want: false,
+		},
+		{
+			name: "confirmed",
+			totp: &TOTP{
+				ConfirmedAt: &confirmedAt,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.totp.Enabled()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoginChallenge_Consumed(t *testing.T) {
+	consumedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name      string
+		challenge *LoginChallenge
+		want      bool
+	}{
+		{
+			name:      "not consumed",
+			challenge: &LoginChallenge{},
+			want:      false,
+		},
+		{
+			name: "consumed",
+			challenge: &LoginChallenge{
+				ConsumedAt: &consumedAt,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.challenge.Consumed()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoginChallenge_Expired(t *testing.T) {
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		challenge *LoginChallenge
+		want      bool
+	}{
+		{
+			name: "not expired",
+			challenge: &LoginChallenge{
+				ExpiresAt: now.Add(time.Second),
+			},
+			want: false,
+		},
+		{
+			name: "expired exactly now",
+			challenge: &LoginChallenge{
+				ExpiresAt: now,
+			},
+			want: true,
+		},
+		{
+			name: "expired",
+			challenge: &LoginChallenge{
+				ExpiresAt: now.Add(-time.Second),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.challenge.Expired(now)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/leguminosa/profile-open-portal/tools"
@@ -9,13 +10,22 @@ import (
 type (
 	// User represents both users table and return value exposed as api object.
 	User struct {
-		ID             int       `json:"id"             db:"id"`
-		Fullname       string    `json:"fullname"       db:"fullname"`
-		PhoneNumber    string    `json:"phone_number"   db:"phone_number"`
-		HashedPassword string    `json:"-"              db:"password"`
-		LoginCount     int       `json:"-"              db:"login_count"`
-		CreatedAt      time.Time `json:"-"              db:"created_at"`
-		UpdatedAt      time.Time `json:"-"              db:"updated_at"`
+		ID                 int        `json:"id"             db:"id"`
+		Fullname           string     `json:"fullname"       db:"fullname"`
+		PhoneNumber        string     `json:"phone_number"   db:"phone_number"`
+		HashedPassword     string     `json:"-"              db:"password"`
+		LoginCount         int        `json:"-"              db:"login_count"`
+		CreatedAt          time.Time  `json:"-"              db:"created_at"`
+		UpdatedAt          time.Time  `json:"-"              db:"updated_at"`
+		Status             string     `json:"-"              db:"status"`
+		TokenVersion       int        `json:"-"              db:"token_version"`
+		PhoneVerifiedAt    *time.Time `json:"-"              db:"phone_verified_at"`
+		PendingPhoneNumber string     `json:"-"              db:"pending_phone_number"`
+		Email              string     `json:"email"          db:"email"`
+		EmailVerifiedAt    *time.Time `json:"-"              db:"email_verified_at"`
+		PendingEmail       string     `json:"-"              db:"pending_email"`
+		Roles              []string   `json:"-"              db:"-"`
+		Permissions        []string   `json:"-"              db:"-"`
 
 		PlainPassword string `json:"password,omitempty" db:"-"`
 	}
@@ -25,12 +35,33 @@ type (
 		Messages []string
 	}
 	LoginModuleResponse struct {
-		User *User
-		JWT  string
+		User         *User
+		JWT          string
+		RefreshToken string
+		// ChallengeToken is given instead of JWT and RefreshToken when user has two-factor authentication enabled.
+		ChallengeToken string
+	}
+	ChangePasswordModuleResponse struct {
+		Valid    bool
+		Messages []string
+		// JWT and RefreshToken keep the user logged in on the device that changed the password.
+		JWT          string
+		RefreshToken string
+	}
+	// UserSessionState is what decides whether a jwt of the user is still honored.
+	UserSessionState struct {
+		Status       string
+		TokenVersion int
 	}
 	UpdateProfileModuleResponse struct {
+		Valid    bool
+		Messages []string
 		Conflict bool
 		Message  string
+		// PendingPhoneNumber replaces the phone number once it is verified.
+		PendingPhoneNumber string
+		// PendingEmail replaces the email once it is verified.
+		PendingEmail string
 	}
 )
 
@@ -39,6 +70,18 @@ func (u *User) Exist() bool {
 	return u.ID != 0
 }
 
+// PhoneNumberToVerify returns the phone number waiting for verification, empty if there is none.
+// A pending phone number comes first, otherwise the current one until it has been verified.
+func (u *User) PhoneNumberToVerify() string {
+	if u.PendingPhoneNumber != "" {
+		return u.PendingPhoneNumber
+	}
+	if u.PhoneVerifiedAt == nil {
+		return u.PhoneNumber
+	}
+	return ""
+}
+
 // HashPassword fills HashedPassword field using PlainPassword field.
 func (u *User) HashPassword(hash tools.HashInterface) error {
 	hashedPassword, err := hash.HashPassword(u.PlainPassword)
@@ -49,3 +92,24 @@ func (u *User) HashPassword(hash tools.HashInterface) error {
 
 	return nil
 }
+
+// HasPermission returns true if any role of the user grants the permission.
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims returns what identifies the user in jwt along with their roles and permissions,
+// profile data is deliberately left out.
+func (u *User) Claims() tools.Claims {
+	return tools.Claims{
+		Subject:      strconv.Itoa(u.ID),
+		Roles:        u.Roles,
+		Permissions:  u.Permissions,
+		TokenVersion: u.TokenVersion,
+	}
+}
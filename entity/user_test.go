@@ -2,6 +2,7 @@ package entity
 
 import (
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/leguminosa/profile-open-portal/tools"
@@ -37,6 +38,46 @@ func TestUser_Exist(t *testing.T) {
 	}
 }
 
+func TestUser_PhoneNumberToVerify(t *testing.T) {
+	verifiedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name string
+		user *User
+		want string
+	}{
+		{
+			name: "unverified phone number",
+			user: &User{
+				PhoneNumber: "628123456789",
+			},
+			want: "628123456789",
+		},
+		{
+			name: "verified phone number",
+			user: &User{
+				PhoneNumber:     "628123456789",
+				PhoneVerifiedAt: &verifiedAt,
+			},
+			want: "",
+		},
+		{
+			name: "pending phone number",
+			user: &User{
+				PhoneNumber:        "628123456789",
+				PhoneVerifiedAt:    &verifiedAt,
+				PendingPhoneNumber: "628123456780",
+			},
+			want: "628123456780",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.user.PhoneNumberToVerify()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestUser_HashPassword(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -83,3 +124,31 @@ func TestUser_HashPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_HasPermission(t *testing.T) {
+	u := &User{
+		Permissions: []string{PermissionProfileRead, PermissionProfileWrite},
+	}
+
+	assert.True(t, u.HasPermission(PermissionProfileWrite))
+	assert.False(t, u.HasPermission(PermissionUsersRead))
+	assert.False(t, (&User{}).HasPermission(PermissionProfileRead))
+}
+
+func TestUser_Claims(t *testing.T) {
+	u := &User{
+		ID:           15,
+		Fullname:     "John Doe",
+		PhoneNumber:  "628123456789",
+		Roles:        []string{RoleUser},
+		Permissions:  []string{PermissionProfileRead},
+		TokenVersion: 3,
+	}
+
+	assert.Equal(t, tools.Claims{
+		Subject:      "15",
+		Roles:        []string{RoleUser},
+		Permissions:  []string{PermissionProfileRead},
+		TokenVersion: 3,
+	}, u.Claims())
+}
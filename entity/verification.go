@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	// VerificationPurposePasswordReset is a code letting user set a new password without the current one.
+	VerificationPurposePasswordReset = "password_reset"
+	// VerificationPurposePhoneVerification is a code proving user owns the phone number.
+	VerificationPurposePhoneVerification = "phone_verification"
+	// VerificationPurposeLogin is a code letting user log in without a password.
+	VerificationPurposeLogin = "login"
+)
+
+type (
+	// VerificationCode represents verification_codes table, a short-lived single-use code sent to a phone number.
+	// Only the hash of the code is stored, the plain value is sent to the user once.
+	VerificationCode struct {
+		ID          int        `json:"-" db:"id"`
+		PhoneNumber string     `json:"-" db:"phone_number"`
+		Purpose     string     `json:"-" db:"purpose"`
+		CodeHash    string     `json:"-" db:"code_hash"`
+		ExpiresAt   time.Time  `json:"-" db:"expires_at"`
+		ConsumedAt  *time.Time `json:"-" db:"consumed_at"`
+		CreatedAt   time.Time  `json:"-" db:"created_at"`
+	}
+	ResetPasswordModuleResponse struct {
+		Valid    bool
+		Messages []string
+	}
+)
+
+// Consumed returns true if code has been used or replaced by a newer one.
+func (v *VerificationCode) Consumed() bool {
+	return v.ConsumedAt != nil
+}
+
+// Expired returns true if code is no longer valid at the given time.
+func (v *VerificationCode) Expired(now time.Time) bool {
+	return !now.Before(v.ExpiresAt)
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationCode_Consumed(t *testing.T) {
+	consumedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name string
+		code *VerificationCode
+		want bool
+	}{
+		{
+			name: "not consumed",
+			code: &VerificationCode{},
+			want: false,
+		},
+		{
+			name: "consumed",
+			code: &VerificationCode{
+				ConsumedAt: &consumedAt,
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.code.Consumed()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVerificationCode_Expired(t *testing.T) {
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		code *VerificationCode
+		want bool
+	}{
+		{
+			name: "not expired",
+			code: &VerificationCode{
+				ExpiresAt: now.Add(time.Second),
+			},
+			want: false,
+		},
+		{
+			name: "expired exactly now",
+			code: &VerificationCode{
+				ExpiresAt: now,
+			},
+			want: true,
+		},
+		{
+			name: "expired",
+			code: &VerificationCode{
+				ExpiresAt: now.Add(-time.Second),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.code.Expired(now)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
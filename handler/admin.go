@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) GetV1AdminUsers(c echo.Context, params generated.GetV1AdminUsersParams) error {
+	if err := s.Auth.Authorize(c, entity.PermissionUsersRead); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx = c.Request().Context()
+		req = entity.ListUsersRequest{}
+	)
+	if params.Cursor != nil {
+		req.Cursor = *params.Cursor
+	}
+	if params.Limit != nil {
+		req.Limit = *params.Limit
+	}
+	if params.PhonePrefix != nil {
+		req.PhonePrefix = *params.PhonePrefix
+	}
+	if params.Status != nil {
+		req.Status = *params.Status
+	}
+	if params.Name != nil {
+		req.Name = *params.Name
+	}
+	if params.CreatedAfter != nil {
+		req.CreatedAfter = *params.CreatedAfter
+	}
+	if params.CreatedBefore != nil {
+		req.CreatedBefore = *params.CreatedBefore
+	}
+	if params.Sort != nil {
+		req.Sort = *params.Sort
+	}
+
+	result, err := s.UserModule.ListUsers(ctx, req)
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+	if !result.Valid {
+		return helper.BadRequest(c, strings.Join(result.Messages, ", "))
+	}
+
+	resp := generated.ListUsersResponse{
+		Users:      make([]generated.AdminUser, 0, len(result.Users)),
+		NextCursor: optionalString(result.NextCursor),
+	}
+	for _, user := range result.Users {
+		resp.Users = append(resp.Users, adminUser(user))
+	}
+
+	return helper.OK(c, resp)
+}
+
+func (s *Server) GetV1AdminUsersId(c echo.Context, id int64) error {
+	if err := s.Auth.Authorize(c, entity.PermissionUsersRead); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	result, err := s.UserModule.GetUser(ctx, int(id))
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	return helper.OK(c, adminUser(result))
+}
+
+func (s *Server) DeleteV1AdminUsersId(c echo.Context, id int64) error {
+	if err := s.Auth.Authorize(c, entity.PermissionUsersWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx     = c.Request().Context()
+		adminID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.ChangeUserStatus(ctx, adminID, int(id), entity.UserStatusDeleted)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	return helper.OK(c, generated.ManageUserResponse{
+		UserId: id,
+	})
+}
+
+func (s *Server) PostV1AdminUsersIdSuspend(c echo.Context, id int64) error {
+	if err := s.Auth.Authorize(c, entity.PermissionUsersWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx     = c.Request().Context()
+		adminID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.ChangeUserStatus(ctx, adminID, int(id), entity.UserStatusSuspended)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	return helper.OK(c, generated.ManageUserResponse{
+		UserId: id,
+	})
+}
+
+func (s *Server) PostV1AdminUsersIdReactivate(c echo.Context, id int64) error {
+	if err := s.Auth.Authorize(c, entity.PermissionUsersWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx     = c.Request().Context()
+		adminID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.ChangeUserStatus(ctx, adminID, int(id), entity.UserStatusActive)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	return helper.OK(c, generated.ManageUserResponse{
+		UserId: id,
+	})
+}
+
+func (s *Server) PostV1AdminUsersIdUnlock(c echo.Context, id int64) error {
+	if err := s.Auth.Authorize(c, entity.PermissionUsersWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	err := s.UserModule.UnlockUser(ctx, int(id))
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	return helper.OK(c, generated.ManageUserResponse{
+		UserId: id,
+	})
+}
+
+// adminError maps errors of managing a user to their status code.
+func adminError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, moduleUser.ErrUserNotFound):
+		return helper.NotFound(c, err.Error())
+	case errors.Is(err, moduleUser.ErrSelfManagement):
+		return helper.BadRequest(c, err.Error())
+	case errors.Is(err, moduleUser.ErrInvalidStatusTransition):
+		return helper.Conflict(c, err.Error())
+	default:
+		return helper.InternalServerError(c, err.Error())
+	}
+}
+
+// adminUser exposes a user to admin, password hash is never included.
+func adminUser(user *entity.User) generated.AdminUser {
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return generated.AdminUser{
+		Id:          int64(user.ID),
+		Fullname:    user.Fullname,
+		PhoneNumber: user.PhoneNumber,
+		LoginCount:  user.LoginCount,
+		Roles:       roles,
+		Status:      user.Status,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
@@ -0,0 +1,583 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_GetV1AdminUsers(t *testing.T) {
+	s := &Server{}
+	createdAt := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	var (
+		cursor      = "some-cursor"
+		limit       = 10
+		phonePrefix = "62812"
+		status      = "suspended"
+		name        = "doe"
+		sort        = "fullname"
+	)
+	tests := []struct {
+		name        string
+		params      generated.GetV1AdminUsersParams
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error list users",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ListUsers(mockCtx.Request().Context(), entity.ListUsersRequest{}).Return(entity.ListUsersModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "invalid request",
+			params: generated.GetV1AdminUsersParams{
+				Sort: &sort,
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ListUsers(mockCtx.Request().Context(), entity.ListUsersRequest{
+					Sort: "fullname",
+				}).Return(entity.ListUsersModuleResponse{
+					Valid:    false,
+					Messages: []string{"cursor is not valid", "phone prefix must be numeric"},
+				}, nil)
+			},
+			want:    "{\"message\":\"cursor is not valid, phone prefix must be numeric\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			params: generated.GetV1AdminUsersParams{
+				Cursor:        &cursor,
+				Limit:         &limit,
+				PhonePrefix:   &phonePrefix,
+				Status:        &status,
+				Name:          &name,
+				CreatedAfter:  &createdAt,
+				CreatedBefore: &createdAt,
+				Sort:          &sort,
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ListUsers(mockCtx.Request().Context(), entity.ListUsersRequest{
+					PhonePrefix:   "62812",
+					Status:        "suspended",
+					Name:          "doe",
+					CreatedAfter:  createdAt,
+					CreatedBefore: createdAt,
+					Sort:          "fullname",
+					Cursor:        "some-cursor",
+					Limit:         10,
+				}).Return(entity.ListUsersModuleResponse{
+					Users: []*entity.User{
+						{
+							ID:             15,
+							Fullname:       "John Doe",
+							PhoneNumber:    "628123456789",
+							HashedPassword: "hashed something",
+							LoginCount:     3,
+							CreatedAt:      createdAt,
+							UpdatedAt:      createdAt,
+							Status:         entity.UserStatusSuspended,
+							Roles:          []string{"user"},
+						},
+					},
+					NextCursor: "next-cursor",
+					Valid:      true,
+				}, nil)
+			},
+			want:    "{\"next_cursor\":\"next-cursor\",\"users\":[{\"created_at\":\"2023-08-05T12:00:00Z\",\"fullname\":\"John Doe\",\"id\":15,\"login_count\":3,\"phone_number\":\"628123456789\",\"roles\":[\"user\"],\"status\":\"suspended\",\"updated_at\":\"2023-08-05T12:00:00Z\"}]}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetV1AdminUsers(c, tt.params)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_GetV1AdminUsersId(t *testing.T) {
+	s := &Server{}
+	createdAt := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		id          int64
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "user not found",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUser(mockCtx.Request().Context(), 15).Return(nil, moduleUser.ErrUserNotFound)
+			},
+			want:    "{\"message\":\"user not found\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUser(mockCtx.Request().Context(), 15).Return(&entity.User{
+					ID:          15,
+					Fullname:    "John Doe",
+					PhoneNumber: "628123456789",
+					CreatedAt:   createdAt,
+					UpdatedAt:   createdAt,
+					Status:      entity.UserStatusActive,
+				}, nil)
+			},
+			want:    "{\"created_at\":\"2023-08-05T12:00:00Z\",\"fullname\":\"John Doe\",\"id\":15,\"login_count\":0,\"phone_number\":\"628123456789\",\"roles\":[],\"status\":\"active\",\"updated_at\":\"2023-08-05T12:00:00Z\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetV1AdminUsersId(c, tt.id)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_DeleteV1AdminUsersId(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		id          int64
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "delete self",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 15
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 15, 15, entity.UserStatusDeleted).Return(moduleUser.ErrSelfManagement)
+			},
+			want:    "{\"message\":\"admin cannot change the status of their own account\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error delete user",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 1
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 1, 15, entity.UserStatusDeleted).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 1
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 1, 15, entity.UserStatusDeleted).Return(nil)
+			},
+			want:    "{\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.DeleteV1AdminUsersId(c, tt.id)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1AdminUsersIdSuspend(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		id          int64
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "user not found",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 1
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 1, 15, entity.UserStatusSuspended).Return(moduleUser.ErrUserNotFound)
+			},
+			want:    "{\"message\":\"user not found\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 1
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 1, 15, entity.UserStatusSuspended).Return(nil)
+			},
+			want:    "{\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1AdminUsersIdSuspend(c, tt.id)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1AdminUsersIdReactivate(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		id          int64
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "invalid status transition",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 1
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 1, 15, entity.UserStatusActive).Return(fmt.Errorf("%w from deleted to active", moduleUser.ErrInvalidStatusTransition))
+			},
+			want:    "{\"message\":\"cannot change user status from deleted to active\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 1
+				},
+			},
+			id: 15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangeUserStatus(mockCtx.Request().Context(), 1, 15, entity.UserStatusActive).Return(nil)
+			},
+			want:    "{\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1AdminUsersIdReactivate(c, tt.id)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1AdminUsersIdUnlock(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name        string
+		id          int64
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "user not found",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UnlockUser(mockCtx.Request().Context(), 15).Return(moduleUser.ErrUserNotFound)
+			},
+			want:    "{\"message\":\"user not found\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error unlock user",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UnlockUser(mockCtx.Request().Context(), 15).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			id:   15,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionUsersWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UnlockUser(mockCtx.Request().Context(), 15).Return(nil)
+			},
+			want:    "{\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1AdminUsersIdUnlock(c, tt.id)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
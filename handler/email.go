@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostV1ProfileEmailVerification(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.RequestEmailVerification(ctx, userID)
+	if err != nil {
+		return emailVerificationError(c, err)
+	}
+
+	return helper.OK(c, generated.MessageResponse{
+		Message: "verification link has been sent",
+	})
+}
+
+// PostEmailVerificationConfirm needs no session, the token of the link identifies the user.
+func (s *Server) PostEmailVerificationConfirm(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.ConfirmEmailVerificationRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var (
+		userID int
+		email  string
+	)
+	userID, email, err = s.UserModule.ConfirmEmailVerification(ctx, req.Token)
+	if err != nil {
+		return emailVerificationError(c, err)
+	}
+
+	return helper.OK(c, generated.ConfirmEmailVerificationResponse{
+		UserId: int64(userID),
+		Email:  email,
+	})
+}
+
+// emailVerificationError maps errors of verifying an email to their status code.
+func emailVerificationError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, moduleUser.ErrNoPendingEmail),
+		errors.Is(err, moduleUser.ErrInvalidEmailVerification):
+		return helper.BadRequest(c, err.Error())
+	case errors.Is(err, moduleUser.ErrEmailConflict):
+		return helper.Conflict(c, err.Error())
+	default:
+		return helper.InternalServerError(c, err.Error())
+	}
+}
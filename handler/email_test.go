@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PostV1ProfileEmailVerification(t *testing.T) {
+	s := &Server{}
+	loggedIn := &mockEchoContext{
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "no pending email",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RequestEmailVerification(mockCtx.Request().Context(), 15).Return(moduleUser.ErrNoPendingEmail)
+			},
+			want:    "{\"message\":\"no email is waiting for verification\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error request email verification",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RequestEmailVerification(mockCtx.Request().Context(), 15).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RequestEmailVerification(mockCtx.Request().Context(), 15).Return(nil)
+			},
+			want:    "{\"message\":\"verification link has been sent\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1ProfileEmailVerification(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostEmailVerificationConfirm(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.ConfirmEmailVerificationRequest:
+				if v != nil {
+					v.Token = "signed.token"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid link",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmEmailVerification(mockCtx.Request().Context(), "signed.token").Return(0, "", moduleUser.ErrInvalidEmailVerification)
+			},
+			want:    "{\"message\":\"email verification link is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "conflicting email",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmEmailVerification(mockCtx.Request().Context(), "signed.token").Return(0, "", moduleUser.ErrEmailConflict)
+			},
+			want:    "{\"message\":\"email already exist\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error confirm email verification",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmEmailVerification(mockCtx.Request().Context(), "signed.token").Return(0, "", assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmEmailVerification(mockCtx.Request().Context(), "signed.token").Return(15, "john@example.com", nil)
+			},
+			want:    "{\"email\":\"john@example.com\",\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostEmailVerificationConfirm(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
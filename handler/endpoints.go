@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/leguminosa/profile-open-portal/entity"
 	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
 	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
 )
 
@@ -20,21 +24,162 @@ func (s *Server) PostLogin(c echo.Context) error {
 		return helper.BadRequest(c, err.Error())
 	}
 
-	var result entity.LoginModuleResponse
-	result, err = s.UserModule.Login(ctx, &entity.User{
-		PhoneNumber:   req.PhoneNumber,
+	user := &entity.User{
 		PlainPassword: req.Password,
+	}
+	if req.PhoneNumber != nil {
+		user.PhoneNumber = *req.PhoneNumber
+	}
+	if req.Email != nil {
+		user.Email = *req.Email
+	}
+	// user logs in by exactly one identifier, so a mistyped one is never silently replaced by the other
+	if (user.PhoneNumber == "") == (user.Email == "") {
+		return helper.BadRequest(c, "either phone number or email is required")
+	}
+
+	var result entity.LoginModuleResponse
+	result, err = s.UserModule.Login(ctx, user, c.RealIP())
+	var throttled *moduleUser.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return tooManyLoginAttempts(c, throttled)
+	}
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return loginResult(c, result)
+}
+
+// loginResult responds with the new session, or with a challenge token when user still has to complete the second factor.
+func loginResult(c echo.Context, result entity.LoginModuleResponse) error {
+	if result.ChallengeToken != "" {
+		return helper.Accepted(c, generated.LoginChallengeResponse{
+			ChallengeToken: result.ChallengeToken,
+			ExpiresIn:      int(moduleUser.LoginChallengeTTL.Seconds()),
+		})
+	}
+
+	return helper.OK(c, generated.LoginResponse{
+		Jwt:          result.JWT,
+		RefreshToken: result.RefreshToken,
+		UserId:       int64(result.User.ID),
 	})
+}
+
+// tooManyLoginAttempts tells a throttled client how long to wait before the next attempt.
+func tooManyLoginAttempts(c echo.Context, throttled *moduleUser.LoginThrottledError) error {
+	// partial seconds are rounded up so client never retries too early
+	retryAfter := int64(math.Ceil(throttled.RetryAfter.Seconds()))
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+	return helper.TooManyRequests(c, throttled.Error())
+}
+
+func (s *Server) PostTokenRefresh(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.RefreshTokenRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.LoginModuleResponse
+	result, err = s.UserModule.RefreshToken(ctx, req.RefreshToken)
 	if err != nil {
 		return helper.BadRequest(c, err.Error())
 	}
 
 	return helper.OK(c, generated.LoginResponse{
-		Jwt:    result.JWT,
-		UserId: int64(result.User.ID),
+		Jwt:          result.JWT,
+		RefreshToken: result.RefreshToken,
+		UserId:       int64(result.User.ID),
 	})
 }
 
+func (s *Server) PostLogout(c echo.Context) error {
+	if err := s.Auth.Authenticate(c); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.LogoutRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var refreshToken string
+	if req.RefreshToken != nil {
+		refreshToken = *req.RefreshToken
+	}
+
+	err = s.UserModule.Logout(ctx, &entity.RevokedToken{
+		TokenID:   helper.TokenIDFromContext(c),
+		UserID:    userID,
+		ExpiresAt: helper.TokenExpiresAtFromContext(c),
+	}, refreshToken)
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return helper.OK(c, generated.LogoutResponse{
+		UserId: int64(userID),
+	})
+}
+
+func (s *Server) PostLogoutAll(c echo.Context) error {
+	if err := s.Auth.Authenticate(c); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.LogoutAll(ctx, userID)
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return helper.OK(c, generated.LogoutResponse{
+		UserId: int64(userID),
+	})
+}
+
+func (s *Server) GetWellKnownJwksJson(c echo.Context) error {
+	keys := s.JWT.JWKS()
+
+	resp := generated.JWKSResponse{
+		Keys: make([]generated.JSONWebKey, 0, len(keys)),
+	}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, generated.JSONWebKey{
+			Kty: key.KeyType,
+			Use: key.Use,
+			Alg: key.Algorithm,
+			Kid: key.KeyID,
+			N:   optionalString(key.N),
+			E:   optionalString(key.E),
+			Crv: optionalString(key.Curve),
+			X:   optionalString(key.X),
+			Y:   optionalString(key.Y),
+		})
+	}
+
+	// let verifiers cache the key set between rotations
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+
+	return helper.OK(c, resp)
+}
+
 func (s *Server) PostRegister(c echo.Context) error {
 	var (
 		ctx = c.Request().Context()
@@ -64,8 +209,54 @@ func (s *Server) PostRegister(c echo.Context) error {
 	})
 }
 
+func (s *Server) PostRegisterActivation(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.RequestActivationRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	err = s.UserModule.RequestActivation(ctx, req.PhoneNumber)
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	// same response whether the phone number is registered or not
+	return helper.OK(c, generated.MessageResponse{
+		Message: "if the phone number is waiting for activation, a code has been sent",
+	})
+}
+
+func (s *Server) PostRegisterActivationConfirm(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.ActivateUserRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	err = s.UserModule.ActivateUser(ctx, req.PhoneNumber, req.Code)
+	if errors.Is(err, moduleUser.ErrInvalidVerificationCode) {
+		return helper.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return helper.OK(c, generated.MessageResponse{
+		Message: "phone number has been verified, please log in",
+	})
+}
+
 func (s *Server) GetV1Profile(c echo.Context) error {
-	if err := s.Auth.Authenticate(c); err != nil {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileRead); err != nil {
 		return helper.Forbidden(c, err.Error())
 	}
 
@@ -80,13 +271,17 @@ func (s *Server) GetV1Profile(c echo.Context) error {
 	}
 
 	return helper.OK(c, generated.GetProfileResponse{
-		Fullname:    result.Fullname,
-		PhoneNumber: result.PhoneNumber,
+		Fullname:           result.Fullname,
+		PhoneNumber:        result.PhoneNumber,
+		PhoneVerified:      result.PhoneVerifiedAt != nil,
+		PendingPhoneNumber: optionalString(result.PendingPhoneNumber),
+		Email:              optionalString(result.Email),
+		PendingEmail:       optionalString(result.PendingEmail),
 	})
 }
 
 func (s *Server) PutV1Profile(c echo.Context) error {
-	if err := s.Auth.Authenticate(c); err != nil {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
 		return helper.Forbidden(c, err.Error())
 	}
 
@@ -101,20 +296,78 @@ func (s *Server) PutV1Profile(c echo.Context) error {
 		return helper.BadRequest(c, err.Error())
 	}
 
-	var result entity.UpdateProfileModuleResponse
-	result, err = s.UserModule.UpdateProfile(ctx, &entity.User{
+	user := &entity.User{
 		ID:          userID,
 		Fullname:    req.Fullname,
 		PhoneNumber: req.PhoneNumber,
-	})
+	}
+	if req.Email != nil {
+		user.Email = *req.Email
+	}
+
+	var result entity.UpdateProfileModuleResponse
+	result, err = s.UserModule.UpdateProfile(ctx, user)
 	if err != nil {
 		return helper.Forbidden(c, err.Error())
 	}
+	if !result.Valid {
+		return helper.BadRequest(c, strings.Join(result.Messages, ", "))
+	}
 	if result.Conflict {
 		return helper.Conflict(c, result.Message)
 	}
 
 	return helper.OK(c, generated.UpdateProfileResponse{
-		UserId: int64(userID),
+		UserId:             int64(userID),
+		PendingPhoneNumber: optionalString(result.PendingPhoneNumber),
+		PendingEmail:       optionalString(result.PendingEmail),
 	})
 }
+
+func (s *Server) PutV1ProfilePassword(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.ChangePasswordRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.ChangePasswordModuleResponse
+	result, err = s.UserModule.ChangePassword(ctx, userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+	if !result.Valid {
+		return helper.BadRequest(c, strings.Join(result.Messages, ", "))
+	}
+
+	return helper.OK(c, generated.LoginResponse{
+		UserId:       int64(userID),
+		Jwt:          result.JWT,
+		RefreshToken: result.RefreshToken,
+	})
+}
+
+// optionalString leaves empty values out of the response.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// stringValue reads an optional parameter, an absent one is empty.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
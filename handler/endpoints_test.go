@@ -2,16 +2,239 @@ package handler
 
 import (
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
 	"github.com/leguminosa/profile-open-portal/entity"
 	"github.com/leguminosa/profile-open-portal/generated"
 	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
 	"github.com/leguminosa/profile-open-portal/tools"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestServer_PostLogin(t *testing.T) {
+	s := &Server{}
+	phoneNumber := "628123456789"
+	email := "John@Example.com"
+	tests := []struct {
+		name           string
+		mockCtx        *mockEchoContext
+		prepare        func(m *module.MockUserModuleInterface)
+		want           string
+		wantRetryAfter string
+		wantErr        bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "missing phone number and email",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			want:    "{\"message\":\"either phone number or email is required\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "both phone number and email",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.PhoneNumber = &phoneNumber
+							v.Email = &email
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			want:    "{\"message\":\"either phone number or email is required\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error register",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.PhoneNumber = &phoneNumber
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
+					PhoneNumber:   "628123456789",
+					PlainPassword: "Abcde9!",
+				}, "192.0.2.1").Return(entity.LoginModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "throttled",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.PhoneNumber = &phoneNumber
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
+					PhoneNumber:   "628123456789",
+					PlainPassword: "Abcde9!",
+				}, "192.0.2.1").Return(entity.LoginModuleResponse{}, &moduleUser.LoginThrottledError{
+					RetryAfter: time.Millisecond * 1500,
+				})
+			},
+			want:           "{\"message\":\"too many failed login attempts, try again later\"}\n",
+			wantRetryAfter: "2",
+			wantErr:        false,
+		},
+		{
+			name: "two-factor authentication enabled",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.PhoneNumber = &phoneNumber
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
+					PhoneNumber:   "628123456789",
+					PlainPassword: "Abcde9!",
+				}, "192.0.2.1").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 1,
+					},
+					ChallengeToken: "some-challenge-token",
+				}, nil)
+			},
+			want:    "{\"challenge_token\":\"some-challenge-token\",\"expires_in\":300}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.PhoneNumber = &phoneNumber
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
+					PhoneNumber:   "628123456789",
+					PlainPassword: "Abcde9!",
+				}, "192.0.2.1").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID:             1,
+						Fullname:       "John Doe",
+						PhoneNumber:    "628123456789",
+						PlainPassword:  "Abcde9!",
+						HashedPassword: "hashed Abcde9!",
+					},
+					JWT:          "some-jwt",
+					RefreshToken: "some-refresh-token",
+				}, nil)
+			},
+			want:    "{\"jwt\":\"some-jwt\",\"refresh_token\":\"some-refresh-token\",\"user_id\":1}\n",
+			wantErr: false,
+		},
+		{
+			name: "success by email",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LoginRequest:
+						if v != nil {
+							v.Email = &email
+							v.Password = "Abcde9!"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
+					Email:         "John@Example.com",
+					PlainPassword: "Abcde9!",
+				}, "192.0.2.1").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 1,
+					},
+					JWT:          "some-jwt",
+					RefreshToken: "some-refresh-token",
+				}, nil)
+			},
+			want:    "{\"jwt\":\"some-jwt\",\"refresh_token\":\"some-refresh-token\",\"user_id\":1}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLogin(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantRetryAfter, c.Response().Header().Get(echo.HeaderRetryAfter))
+		})
+	}
+}
+
+func TestServer_PostTokenRefresh(t *testing.T) {
 	s := &Server{}
 	tests := []struct {
 		name    string
@@ -31,24 +254,242 @@ func TestServer_PostLogin(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "error register",
+			name: "error refresh token",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.RefreshTokenRequest:
+						if v != nil {
+							v.RefreshToken = "old-refresh-token"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RefreshToken(mockCtx.Request().Context(), "old-refresh-token").
+					Return(entity.LoginModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.RefreshTokenRequest:
+						if v != nil {
+							v.RefreshToken = "old-refresh-token"
+						}
+					}
+					return nil
+				},
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RefreshToken(mockCtx.Request().Context(), "old-refresh-token").
+					Return(entity.LoginModuleResponse{
+						User: &entity.User{
+							ID: 1,
+						},
+						JWT:          "new-jwt",
+						RefreshToken: "new-refresh-token",
+					}, nil)
+			},
+			want:    "{\"jwt\":\"new-jwt\",\"refresh_token\":\"new-refresh-token\",\"user_id\":1}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostTokenRefresh(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostLogout(t *testing.T) {
+	s := &Server{}
+	expiresAt := time.Date(2024, time.January, 1, 0, 15, 0, 0, time.UTC)
+	mockGet := func(key string) interface{} {
+		switch key {
+		case "user_id":
+			return 15
+		case "token_id":
+			return "token-id"
+		case "token_expires_at":
+			return expiresAt
+		}
+		return nil
+	}
+	refreshToken := "refresh-token"
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authenticate",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error logout",
+			mockCtx: &mockEchoContext{
+				mockGet: mockGet,
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Logout(mockCtx.Request().Context(), &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}, "").Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "success without refresh token",
+			mockCtx: &mockEchoContext{
+				mockGet: mockGet,
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Logout(mockCtx.Request().Context(), &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}, "").Return(nil)
+			},
+			want:    "{\"user_id\":15}\n",
+			wantErr: false,
+		},
+		{
+			name: "success with refresh token",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.LogoutRequest:
+						if v != nil {
+							v.RefreshToken = &refreshToken
+						}
+					}
+					return nil
+				},
+				mockGet: mockGet,
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Logout(mockCtx.Request().Context(), &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}, "refresh-token").Return(nil)
+			},
+			want:    "{\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLogout(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostLogoutAll(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authenticate",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error logout all",
 			mockCtx: &mockEchoContext{
-				mockBind: func(i interface{}) error {
-					switch v := i.(type) {
-					case *generated.LoginRequest:
-						if v != nil {
-							v.PhoneNumber = "628123456789"
-							v.Password = "Abcde9!"
-						}
-					}
-					return nil
+				mockGet: func(key string) interface{} {
+					return 15
 				},
 			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+			},
 			prepare: func(m *module.MockUserModuleInterface) {
-				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
-					PhoneNumber:   "628123456789",
-					PlainPassword: "Abcde9!",
-				}).Return(entity.LoginModuleResponse{}, assert.AnError)
+				m.EXPECT().LogoutAll(mockCtx.Request().Context(), 15).Return(assert.AnError)
 			},
 			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
 			wantErr: false,
@@ -56,55 +497,130 @@ func TestServer_PostLogin(t *testing.T) {
 		{
 			name: "success",
 			mockCtx: &mockEchoContext{
-				mockBind: func(i interface{}) error {
-					switch v := i.(type) {
-					case *generated.LoginRequest:
-						if v != nil {
-							v.PhoneNumber = "628123456789"
-							v.Password = "Abcde9!"
-						}
-					}
-					return nil
+				mockGet: func(key string) interface{} {
+					return 15
 				},
 			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+			},
 			prepare: func(m *module.MockUserModuleInterface) {
-				m.EXPECT().Login(mockCtx.Request().Context(), &entity.User{
-					PhoneNumber:   "628123456789",
-					PlainPassword: "Abcde9!",
-				}).Return(entity.LoginModuleResponse{
-					User: &entity.User{
-						ID:             1,
-						Fullname:       "John Doe",
-						PhoneNumber:    "628123456789",
-						PlainPassword:  "Abcde9!",
-						HashedPassword: "hashed Abcde9!",
-					},
-					JWT: "some-jwt",
-				}, nil)
+				m.EXPECT().LogoutAll(mockCtx.Request().Context(), 15).Return(nil)
 			},
-			want:    "{\"jwt\":\"some-jwt\",\"user_id\":1}\n",
+			want:    "{\"user_id\":15}\n",
 			wantErr: false,
 		},
 	}
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
 	mockUserModule := module.NewMockUserModuleInterface(ctrl)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := newMockEchoContext(tt.mockCtx)
 
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
 			if tt.prepare != nil {
 				tt.prepare(mockUserModule)
 			}
 			s.UserModule = mockUserModule
 
-			err := s.PostLogin(c)
+			err := s.PostLogoutAll(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_GetWellKnownJwksJson(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *tools.MockJWTInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "empty key set",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().JWKS().Return(nil)
+			},
+			want:    "{\"keys\":[]}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().JWKS().Return([]tools.JSONWebKey{
+					{
+						KeyType:   "RSA",
+						Use:       "sig",
+						Algorithm: "RS256",
+						KeyID:     "key-1",
+						N:         "modulus",
+						E:         "AQAB",
+					},
+				})
+			},
+			want:    "{\"keys\":[{\"alg\":\"RS256\",\"e\":\"AQAB\",\"kid\":\"key-1\",\"kty\":\"RSA\",\"n\":\"modulus\",\"use\":\"sig\"}]}\n",
+			wantErr: false,
+		},
+		{
+			name: "success elliptic curve keys",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().JWKS().Return([]tools.JSONWebKey{
+					{
+						KeyType:   "EC",
+						Use:       "sig",
+						Algorithm: "ES256",
+						KeyID:     "key-2",
+						Curve:     "P-256",
+						X:         "x-coordinate",
+						Y:         "y-coordinate",
+					},
+					{
+						KeyType:   "OKP",
+						Use:       "sig",
+						Algorithm: "EdDSA",
+						KeyID:     "key-3",
+						Curve:     "Ed25519",
+						X:         "public-key",
+					},
+				})
+			},
+			want:    "{\"keys\":[{\"alg\":\"ES256\",\"crv\":\"P-256\",\"kid\":\"key-2\",\"kty\":\"EC\",\"use\":\"sig\",\"x\":\"x-coordinate\",\"y\":\"y-coordinate\"},{\"alg\":\"EdDSA\",\"crv\":\"Ed25519\",\"kid\":\"key-3\",\"kty\":\"OKP\",\"use\":\"sig\",\"x\":\"public-key\"}]}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockJWT)
+			}
+			s.JWT = mockJWT
+
+			err := s.GetWellKnownJwksJson(c)
 			if !assert.Equal(t, tt.wantErr, err != nil) {
 				return
 			}
 
 			got := c.getResponseBody()
 			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, "public, max-age=300", c.Response().Header().Get(echo.HeaderCacheControl))
 		})
 	}
 }
@@ -246,6 +762,7 @@ func TestServer_PostRegister(t *testing.T) {
 
 func TestServer_GetV1Profile(t *testing.T) {
 	s := &Server{}
+	verifiedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
 	tests := []struct {
 		name        string
 		mockCtx     *mockEchoContext
@@ -257,7 +774,7 @@ func TestServer_GetV1Profile(t *testing.T) {
 		{
 			name: "error authenticate",
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(assert.AnError)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(assert.AnError)
 			},
 			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
 			wantErr: false,
@@ -270,7 +787,7 @@ func TestServer_GetV1Profile(t *testing.T) {
 				},
 			},
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
 			},
 			prepare: func(m *module.MockUserModuleInterface) {
 				m.EXPECT().GetProfile(mockCtx.Request().Context(), 91).Return(nil, assert.AnError)
@@ -286,7 +803,7 @@ func TestServer_GetV1Profile(t *testing.T) {
 				},
 			},
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
 			},
 			prepare: func(m *module.MockUserModuleInterface) {
 				m.EXPECT().GetProfile(mockCtx.Request().Context(), 15).Return(&entity.User{
@@ -295,7 +812,29 @@ func TestServer_GetV1Profile(t *testing.T) {
 					PhoneNumber: "628123456789",
 				}, nil)
 			},
-			want:    "{\"fullname\":\"John Doe\",\"phone_number\":\"628123456789\"}\n",
+			want:    "{\"fullname\":\"John Doe\",\"phone_number\":\"628123456789\",\"phone_verified\":false}\n",
+			wantErr: false,
+		},
+		{
+			name: "success with pending phone number",
+			mockCtx: &mockEchoContext{
+				mockGet: func(key string) interface{} {
+					return 15
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetProfile(mockCtx.Request().Context(), 15).Return(&entity.User{
+					ID:                 15,
+					Fullname:           "John Doe",
+					PhoneNumber:        "628123456789",
+					PhoneVerifiedAt:    &verifiedAt,
+					PendingPhoneNumber: "628123456780",
+				}, nil)
+			},
+			want:    "{\"fullname\":\"John Doe\",\"pending_phone_number\":\"628123456780\",\"phone_number\":\"628123456789\",\"phone_verified\":true}\n",
 			wantErr: false,
 		},
 	}
@@ -341,7 +880,7 @@ func TestServer_PutV1Profile(t *testing.T) {
 		{
 			name: "error authenticate",
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(assert.AnError)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
 			},
 			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
 			wantErr: false,
@@ -354,7 +893,7 @@ func TestServer_PutV1Profile(t *testing.T) {
 				},
 			},
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
 			},
 			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
 			wantErr: false,
@@ -377,7 +916,7 @@ func TestServer_PutV1Profile(t *testing.T) {
 				},
 			},
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
 			},
 			prepare: func(m *module.MockUserModuleInterface) {
 				m.EXPECT().UpdateProfile(mockCtx.Request().Context(), &entity.User{
@@ -389,6 +928,39 @@ func TestServer_PutV1Profile(t *testing.T) {
 			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
 			wantErr: false,
 		},
+		{
+			name: "invalid phone number",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.UpdateProfileRequest:
+						if v != nil {
+							v.PhoneNumber = "62812"
+						}
+					}
+					return nil
+				},
+				mockGet: func(key string) interface{} {
+					return 15
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UpdateProfile(mockCtx.Request().Context(), &entity.User{
+					ID:          15,
+					PhoneNumber: "62812",
+				}).Return(entity.UpdateProfileModuleResponse{
+					Valid: false,
+					Messages: []string{
+						"phone number must have 8-12 digits after country code +62",
+					},
+				}, nil)
+			},
+			want:    "{\"message\":\"phone number must have 8-12 digits after country code +62\"}\n",
+			wantErr: false,
+		},
 		{
 			name: "conflicting phone number",
 			mockCtx: &mockEchoContext{
@@ -407,7 +979,7 @@ func TestServer_PutV1Profile(t *testing.T) {
 				},
 			},
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
 			},
 			prepare: func(m *module.MockUserModuleInterface) {
 				m.EXPECT().UpdateProfile(mockCtx.Request().Context(), &entity.User{
@@ -415,6 +987,8 @@ func TestServer_PutV1Profile(t *testing.T) {
 					Fullname:    "John Doe Updated",
 					PhoneNumber: "628123456799",
 				}).Return(entity.UpdateProfileModuleResponse{
+					Valid:    true,
+					Messages: []string{},
 					Conflict: true,
 					Message:  "phone number already exist",
 				}, nil)
@@ -440,18 +1014,55 @@ func TestServer_PutV1Profile(t *testing.T) {
 				},
 			},
 			prepareAuth: func(m *tools.MockAuthInterface) {
-				m.EXPECT().Authenticate(gomock.Any()).Return(nil)
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
 			},
 			prepare: func(m *module.MockUserModuleInterface) {
 				m.EXPECT().UpdateProfile(mockCtx.Request().Context(), &entity.User{
 					ID:          15,
 					Fullname:    "John Doe Updated",
 					PhoneNumber: "628123456799",
-				}).Return(entity.UpdateProfileModuleResponse{}, nil)
+				}).Return(entity.UpdateProfileModuleResponse{
+					Valid:    true,
+					Messages: []string{},
+				}, nil)
 			},
 			want:    "{\"user_id\":15}\n",
 			wantErr: false,
 		},
+		{
+			name: "success pending phone number",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					switch v := i.(type) {
+					case *generated.UpdateProfileRequest:
+						if v != nil {
+							v.Fullname = "John Doe Updated"
+							v.PhoneNumber = "628123456799"
+						}
+					}
+					return nil
+				},
+				mockGet: func(key string) interface{} {
+					return 15
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UpdateProfile(mockCtx.Request().Context(), &entity.User{
+					ID:          15,
+					Fullname:    "John Doe Updated",
+					PhoneNumber: "628123456799",
+				}).Return(entity.UpdateProfileModuleResponse{
+					Valid:              true,
+					Messages:           []string{},
+					PendingPhoneNumber: "+628123456799",
+				}, nil)
+			},
+			want:    "{\"pending_phone_number\":\"+628123456799\",\"user_id\":15}\n",
+			wantErr: false,
+		},
 	}
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -481,3 +1092,129 @@ func TestServer_PutV1Profile(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_PutV1ProfilePassword(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.ChangePasswordRequest:
+				if v != nil {
+					v.CurrentPassword = "Old@123"
+					v.NewPassword = "New@123"
+				}
+			}
+			return nil
+		},
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error change password",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangePassword(mockCtx.Request().Context(), 15, "Old@123", "New@123").
+					Return(entity.ChangePasswordModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid request",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangePassword(mockCtx.Request().Context(), 15, "Old@123", "New@123").
+					Return(entity.ChangePasswordModuleResponse{
+						Valid: false,
+						Messages: []string{
+							"current password is not correct",
+							"new password must be different from current password",
+						},
+					}, nil)
+			},
+			want:    "{\"message\":\"current password is not correct, new password must be different from current password\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ChangePassword(mockCtx.Request().Context(), 15, "Old@123", "New@123").
+					Return(entity.ChangePasswordModuleResponse{
+						Valid:        true,
+						Messages:     []string{},
+						JWT:          "new jwt token",
+						RefreshToken: "new-token",
+					}, nil)
+			},
+			want:    "{\"jwt\":\"new jwt token\",\"refresh_token\":\"new-token\",\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PutV1ProfilePassword(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
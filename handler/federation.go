@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostLoginFederated(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.StartFederatedLoginRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.StartFederatedLoginModuleResponse
+	result, err = s.UserModule.StartFederatedLogin(ctx, req.Provider, 0)
+	if err != nil {
+		return federationError(c, err)
+	}
+
+	return helper.OK(c, generated.StartFederatedLoginResponse{
+		RedirectTo: result.RedirectTo,
+		State:      result.State,
+	})
+}
+
+func (s *Server) PostLoginFederatedCallback(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.FederatedCallbackRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.LoginModuleResponse
+	result, err = s.UserModule.CompleteFederatedLogin(ctx, req.State, req.Code)
+	if err != nil {
+		return federationError(c, err)
+	}
+
+	return loginResult(c, result)
+}
+
+func (s *Server) GetV1ProfileIdentities(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileRead); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		userID = helper.UserIDFromContext(c)
+	)
+
+	result, err := s.UserModule.ListFederatedIdentities(ctx, userID)
+	if err != nil {
+		return federationError(c, err)
+	}
+
+	resp := generated.ListFederatedIdentitiesResponse{
+		Identities: make([]generated.FederatedIdentity, 0, len(result)),
+	}
+	for _, identity := range result {
+		resp.Identities = append(resp.Identities, federatedIdentity(identity))
+	}
+
+	return helper.OK(c, resp)
+}
+
+func (s *Server) PostV1ProfileIdentities(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.StartFederatedLoginRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.StartFederatedLoginModuleResponse
+	result, err = s.UserModule.StartFederatedLogin(ctx, req.Provider, userID)
+	if err != nil {
+		return federationError(c, err)
+	}
+
+	return helper.OK(c, generated.StartFederatedLoginResponse{
+		RedirectTo: result.RedirectTo,
+		State:      result.State,
+	})
+}
+
+func (s *Server) PostV1ProfileIdentitiesCallback(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.FederatedCallbackRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result *entity.FederatedIdentity
+	result, err = s.UserModule.LinkFederatedIdentity(ctx, userID, req.State, req.Code)
+	if err != nil {
+		return federationError(c, err)
+	}
+
+	return helper.OK(c, federatedIdentity(result))
+}
+
+func (s *Server) DeleteV1ProfileIdentitiesId(c echo.Context, id int64) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.UnlinkFederatedIdentity(ctx, userID, int(id))
+	if err != nil {
+		return federationError(c, err)
+	}
+
+	return helper.OK(c, generated.MessageResponse{
+		Message: "account unlinked",
+	})
+}
+
+func federatedIdentity(identity *entity.FederatedIdentity) generated.FederatedIdentity {
+	return generated.FederatedIdentity{
+		Id:        int64(identity.ID),
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt,
+	}
+}
+
+func federationError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, moduleUser.ErrUnknownIdentityProvider),
+		errors.Is(err, moduleUser.ErrInvalidFederatedLogin),
+		errors.Is(err, moduleUser.ErrFederatedIdentityNotLinked):
+		return helper.BadRequest(c, err.Error())
+	case errors.Is(err, moduleUser.ErrFederatedIdentityConflict):
+		return helper.Conflict(c, err.Error())
+	case errors.Is(err, moduleUser.ErrFederatedIdentityNotFound):
+		return helper.NotFound(c, err.Error())
+	default:
+		return helper.InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,629 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PostLoginFederated(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.StartFederatedLoginRequest:
+				if v != nil {
+					v.Provider = "google"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "unknown provider",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartFederatedLogin(mockCtx.Request().Context(), "google", 0).Return(entity.StartFederatedLoginModuleResponse{}, moduleUser.ErrUnknownIdentityProvider)
+			},
+			want:    "{\"message\":\"identity provider is not supported\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error start federated login",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartFederatedLogin(mockCtx.Request().Context(), "google", 0).Return(entity.StartFederatedLoginModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartFederatedLogin(mockCtx.Request().Context(), "google", 0).Return(entity.StartFederatedLoginModuleResponse{
+					RedirectTo: "https://accounts.google.com/auth",
+					State:      "some-state",
+				}, nil)
+			},
+			want:    "{\"redirect_to\":\"https://accounts.google.com/auth\",\"state\":\"some-state\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLoginFederated(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostLoginFederatedCallback(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.FederatedCallbackRequest:
+				if v != nil {
+					v.State = "some-state"
+					v.Code = "some-code"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid login",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().CompleteFederatedLogin(mockCtx.Request().Context(), "some-state", "some-code").Return(entity.LoginModuleResponse{}, entity.Obscure(moduleUser.ErrInvalidFederatedLogin, assert.AnError))
+			},
+			want:    "{\"message\":\"login with identity provider is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "account not linked",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().CompleteFederatedLogin(mockCtx.Request().Context(), "some-state", "some-code").Return(entity.LoginModuleResponse{}, moduleUser.ErrFederatedIdentityNotLinked)
+			},
+			want:    "{\"message\":\"account of identity provider is not linked to any user\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error complete federated login",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().CompleteFederatedLogin(mockCtx.Request().Context(), "some-state", "some-code").Return(entity.LoginModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "two-factor authentication enabled",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().CompleteFederatedLogin(mockCtx.Request().Context(), "some-state", "some-code").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 15,
+					},
+					ChallengeToken: "some-challenge-token",
+				}, nil)
+			},
+			want:    "{\"challenge_token\":\"some-challenge-token\",\"expires_in\":300}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().CompleteFederatedLogin(mockCtx.Request().Context(), "some-state", "some-code").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 15,
+					},
+					JWT:          "some-jwt",
+					RefreshToken: "some-refresh-token",
+				}, nil)
+			},
+			want:    "{\"jwt\":\"some-jwt\",\"refresh_token\":\"some-refresh-token\",\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLoginFederatedCallback(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_GetV1ProfileIdentities(t *testing.T) {
+	s := &Server{}
+	loggedIn := &mockEchoContext{
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error list federated identities",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ListFederatedIdentities(mockCtx.Request().Context(), 15).Return(nil, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "no linked account",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ListFederatedIdentities(mockCtx.Request().Context(), 15).Return(nil, nil)
+			},
+			want:    "{\"identities\":[]}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ListFederatedIdentities(mockCtx.Request().Context(), 15).Return([]*entity.FederatedIdentity{
+					{
+						ID:        4,
+						UserID:    15,
+						Provider:  "google",
+						Issuer:    "https://accounts.google.com",
+						Subject:   "1076",
+						Email:     "john@example.com",
+						CreatedAt: time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC),
+					},
+				}, nil)
+			},
+			want:    "{\"identities\":[{\"created_at\":\"2023-08-05T12:00:00Z\",\"email\":\"john@example.com\",\"id\":4,\"provider\":\"google\"}]}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetV1ProfileIdentities(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1ProfileIdentities(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.StartFederatedLoginRequest:
+				if v != nil {
+					v.Provider = "google"
+				}
+			}
+			return nil
+		},
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "unknown provider",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartFederatedLogin(mockCtx.Request().Context(), "google", 15).Return(entity.StartFederatedLoginModuleResponse{}, moduleUser.ErrUnknownIdentityProvider)
+			},
+			want:    "{\"message\":\"identity provider is not supported\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartFederatedLogin(mockCtx.Request().Context(), "google", 15).Return(entity.StartFederatedLoginModuleResponse{
+					RedirectTo: "https://accounts.google.com/auth",
+					State:      "some-state",
+				}, nil)
+			},
+			want:    "{\"redirect_to\":\"https://accounts.google.com/auth\",\"state\":\"some-state\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1ProfileIdentities(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1ProfileIdentitiesCallback(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.FederatedCallbackRequest:
+				if v != nil {
+					v.State = "some-state"
+					v.Code = "some-code"
+				}
+			}
+			return nil
+		},
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid login",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LinkFederatedIdentity(mockCtx.Request().Context(), 15, "some-state", "some-code").Return(nil, moduleUser.ErrInvalidFederatedLogin)
+			},
+			want:    "{\"message\":\"login with identity provider is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "linked to another user",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LinkFederatedIdentity(mockCtx.Request().Context(), 15, "some-state", "some-code").Return(nil, moduleUser.ErrFederatedIdentityConflict)
+			},
+			want:    "{\"message\":\"account of identity provider is already linked to another user\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error link federated identity",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LinkFederatedIdentity(mockCtx.Request().Context(), 15, "some-state", "some-code").Return(nil, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LinkFederatedIdentity(mockCtx.Request().Context(), 15, "some-state", "some-code").Return(&entity.FederatedIdentity{
+					ID:        4,
+					UserID:    15,
+					Provider:  "google",
+					Email:     "john@example.com",
+					CreatedAt: time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC),
+				}, nil)
+			},
+			want:    "{\"created_at\":\"2023-08-05T12:00:00Z\",\"email\":\"john@example.com\",\"id\":4,\"provider\":\"google\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1ProfileIdentitiesCallback(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_DeleteV1ProfileIdentitiesId(t *testing.T) {
+	s := &Server{}
+	loggedIn := &mockEchoContext{
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "not found",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UnlinkFederatedIdentity(mockCtx.Request().Context(), 15, 4).Return(moduleUser.ErrFederatedIdentityNotFound)
+			},
+			want:    "{\"message\":\"linked account not found\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error unlink federated identity",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UnlinkFederatedIdentity(mockCtx.Request().Context(), 15, 4).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().UnlinkFederatedIdentity(mockCtx.Request().Context(), 15, 4).Return(nil)
+			},
+			want:    "{\"message\":\"account unlinked\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.DeleteV1ProfileIdentitiesId(c, 4)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostLoginMagic(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.StartMagicLinkLoginRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	err = s.UserModule.StartMagicLinkLogin(ctx, req.Email, c.RealIP())
+	var throttled *moduleUser.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return tooManyLoginAttempts(c, throttled)
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	// same response whether the link is sent or not
+	return helper.OK(c, generated.MessageResponse{
+		Message: "if the email is registered and verified, a login link has been sent",
+	})
+}
+
+func (s *Server) GetLoginMagicToken(c echo.Context, token string) error {
+	ctx := c.Request().Context()
+
+	result, err := s.UserModule.RedeemMagicLink(ctx, token)
+	if errors.Is(err, moduleUser.ErrInvalidMagicLink) {
+		return helper.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return loginResult(c, result)
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PostLoginMagic(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.StartMagicLinkLoginRequest:
+				if v != nil {
+					v.Email = "john@example.com"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name           string
+		mockCtx        *mockEchoContext
+		prepare        func(m *module.MockUserModuleInterface)
+		want           string
+		wantRetryAfter string
+		wantErr        bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "throttled",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartMagicLinkLogin(mockCtx.Request().Context(), "john@example.com", "192.0.2.1").Return(&moduleUser.LoginThrottledError{
+					RetryAfter: time.Minute,
+				})
+			},
+			want:           "{\"message\":\"too many failed login attempts, try again later\"}\n",
+			wantRetryAfter: "60",
+			wantErr:        false,
+		},
+		{
+			name:    "error start magic link login",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartMagicLinkLogin(mockCtx.Request().Context(), "john@example.com", "192.0.2.1").Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartMagicLinkLogin(mockCtx.Request().Context(), "john@example.com", "192.0.2.1").Return(nil)
+			},
+			want:    "{\"message\":\"if the email is registered and verified, a login link has been sent\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLoginMagic(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantRetryAfter, c.Response().Header().Get(echo.HeaderRetryAfter))
+		})
+	}
+}
+
+func TestServer_GetLoginMagicToken(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name    string
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "invalid link",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RedeemMagicLink(mockCtx.Request().Context(), "magic-link-token").Return(entity.LoginModuleResponse{}, moduleUser.ErrInvalidMagicLink)
+			},
+			want:    "{\"message\":\"login link is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "user cannot log in",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RedeemMagicLink(mockCtx.Request().Context(), "magic-link-token").Return(entity.LoginModuleResponse{}, entity.Obscure(moduleUser.ErrInvalidMagicLink, entity.ErrUserSuspended))
+			},
+			want:    "{\"message\":\"login link is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error redeem magic link",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RedeemMagicLink(mockCtx.Request().Context(), "magic-link-token").Return(entity.LoginModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "two-factor authentication enabled",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RedeemMagicLink(mockCtx.Request().Context(), "magic-link-token").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 15,
+					},
+					ChallengeToken: "some-challenge-token",
+				}, nil)
+			},
+			want:    "{\"challenge_token\":\"some-challenge-token\",\"expires_in\":300}\n",
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().RedeemMagicLink(mockCtx.Request().Context(), "magic-link-token").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 15,
+					},
+					JWT:          "some-jwt",
+					RefreshToken: "some-refresh-token",
+				}, nil)
+			},
+			want:    "{\"jwt\":\"some-jwt\",\"refresh_token\":\"some-refresh-token\",\"user_id\":15}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetLoginMagicToken(c, "magic-link-token")
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
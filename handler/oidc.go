@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) GetWellKnownOpenidConfiguration(c echo.Context) error {
+	// issuer identifies the provider and must match iss claim of the id tokens
+	if s.Issuer == "" {
+		return helper.NotFound(c, "openid connect is not configured")
+	}
+
+	issuer := strings.TrimSuffix(s.Issuer, "/")
+
+	// only the active key signs, so its algorithm is the one id tokens are signed with
+	algorithms := []string{}
+	if keys := s.JWT.JWKS(); len(keys) > 0 {
+		algorithms = append(algorithms, keys[0].Algorithm)
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+
+	return helper.OK(c, generated.OpenIDConfigurationResponse{
+		Issuer:                            s.Issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JwksUri:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  algorithms,
+		ScopesSupported:                   []string{entity.ScopeOpenID, entity.ScopeProfile, entity.ScopePhone},
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_post", "client_secret_basic"},
+		GrantTypesSupported:               []string{"authorization_code"},
+		CodeChallengeMethodsSupported:     []string{entity.CodeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "name", "phone_number", "phone_number_verified"},
+	})
+}
+
+func (s *Server) GetAuthorize(c echo.Context, params generated.GetAuthorizeParams) error {
+	ctx := c.Request().Context()
+
+	// the browser never carries the jwt here, user is sent to the login page which posts the request back
+	location, err := s.UserModule.Authorize(ctx, 0, entity.AuthorizationRequest{
+		ResponseType:        stringValue(params.ResponseType),
+		ClientID:            params.ClientId,
+		RedirectURI:         params.RedirectUri,
+		Scope:               stringValue(params.Scope),
+		State:               stringValue(params.State),
+		Nonce:               stringValue(params.Nonce),
+		CodeChallenge:       stringValue(params.CodeChallenge),
+		CodeChallengeMethod: stringValue(params.CodeChallengeMethod),
+	})
+	if errors.Is(err, moduleUser.ErrInvalidOAuthClient) {
+		return helper.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, location)
+}
+
+func (s *Server) PostAuthorize(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileRead); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.AuthorizeRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var location string
+	location, err = s.UserModule.Authorize(ctx, userID, entity.AuthorizationRequest{
+		ResponseType:        stringValue(req.ResponseType),
+		ClientID:            req.ClientId,
+		RedirectURI:         req.RedirectUri,
+		Scope:               stringValue(req.Scope),
+		State:               stringValue(req.State),
+		Nonce:               stringValue(req.Nonce),
+		CodeChallenge:       stringValue(req.CodeChallenge),
+		CodeChallengeMethod: stringValue(req.CodeChallengeMethod),
+	})
+	if errors.Is(err, moduleUser.ErrInvalidOAuthClient) {
+		return helper.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return helper.OK(c, generated.AuthorizeResponse{
+		RedirectTo: location,
+	})
+}
+
+func (s *Server) PostToken(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	// the request is a form as required by RFC 6749, read field by field
+	req := entity.TokenRequest{
+		GrantType:    c.FormValue("grant_type"),
+		Code:         c.FormValue("code"),
+		RedirectURI:  c.FormValue("redirect_uri"),
+		ClientID:     c.FormValue("client_id"),
+		ClientSecret: c.FormValue("client_secret"),
+		CodeVerifier: c.FormValue("code_verifier"),
+	}
+	if clientID, clientSecret, ok := c.Request().BasicAuth(); ok {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before they are put in the header
+		var err error
+		req.ClientID, err = url.QueryUnescape(clientID)
+		if err != nil {
+			return oauthError(c, http.StatusBadRequest, moduleUser.OAuthErrorInvalidRequest, err.Error())
+		}
+		req.ClientSecret, err = url.QueryUnescape(clientSecret)
+		if err != nil {
+			return oauthError(c, http.StatusBadRequest, moduleUser.OAuthErrorInvalidRequest, err.Error())
+		}
+	}
+
+	// tokens must never be cached by anything between client and server
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+
+	result, err := s.UserModule.ExchangeAuthorizationCode(ctx, req)
+	var oauthErr *moduleUser.OAuthError
+	if errors.As(err, &oauthErr) {
+		if oauthErr.Code == moduleUser.OAuthErrorInvalidClient {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="token"`)
+			return oauthError(c, http.StatusUnauthorized, oauthErr.Code, oauthErr.Description)
+		}
+		return oauthError(c, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	return helper.OK(c, generated.TokenResponse{
+		AccessToken: result.AccessToken,
+		TokenType:   "Bearer",
+		IdToken:     result.IDToken,
+		Scope:       result.Scope,
+	})
+}
+
+func (s *Server) GetUserinfo(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	accessToken := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+
+	result, err := s.UserModule.GetUserInfo(ctx, accessToken)
+	if errors.Is(err, moduleUser.ErrInvalidOIDCAccessToken) {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return helper.JSON(c, http.StatusUnauthorized, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	// claims are only shared for the scopes the client was granted
+	resp := generated.UserInfoResponse{
+		Sub: strconv.Itoa(result.User.ID),
+	}
+	if result.Profile {
+		resp.Name = &result.User.Fullname
+	}
+	if result.Phone {
+		phoneNumberVerified := result.User.PhoneVerifiedAt != nil
+		resp.PhoneNumber = &result.User.PhoneNumber
+		resp.PhoneNumberVerified = &phoneNumberVerified
+	}
+
+	return helper.OK(c, resp)
+}
+
+// oauthError responds with an error of the token endpoint in the format defined by RFC 6749 section 5.2.
+func oauthError(c echo.Context, code int, errorCode, description string) error {
+	return helper.JSON(c, code, generated.OAuthErrorResponse{
+		Error:            errorCode,
+		ErrorDescription: optionalString(description),
+	})
+}
@@ -0,0 +1,532 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_GetWellKnownOpenidConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		issuer  string
+		prepare func(m *tools.MockJWTInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "issuer not configured",
+			issuer:  "",
+			want:    "{\"message\":\"openid connect is not configured\"}\n",
+			wantErr: false,
+		},
+		{
+			name:   "success",
+			issuer: "https://portal.example.com/",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().JWKS().Return([]tools.JSONWebKey{
+					{
+						KeyType:   "EC",
+						Use:       "sig",
+						Algorithm: "ES256",
+						KeyID:     "key-2",
+					},
+					{
+						KeyType:   "RSA",
+						Use:       "sig",
+						Algorithm: "RS256",
+						KeyID:     "key-1",
+					},
+				})
+			},
+			want: "{\"authorization_endpoint\":\"https://portal.example.com/authorize\"," +
+				"\"claims_supported\":[\"sub\",\"name\",\"phone_number\",\"phone_number_verified\"]," +
+				"\"code_challenge_methods_supported\":[\"S256\"]," +
+				"\"grant_types_supported\":[\"authorization_code\"]," +
+				"\"id_token_signing_alg_values_supported\":[\"ES256\"]," +
+				"\"issuer\":\"https://portal.example.com/\"," +
+				"\"jwks_uri\":\"https://portal.example.com/.well-known/jwks.json\"," +
+				"\"response_types_supported\":[\"code\"]," +
+				"\"scopes_supported\":[\"openid\",\"profile\",\"phone\"]," +
+				"\"subject_types_supported\":[\"public\"]," +
+				"\"token_endpoint\":\"https://portal.example.com/token\"," +
+				"\"token_endpoint_auth_methods_supported\":[\"none\",\"client_secret_post\",\"client_secret_basic\"]," +
+				"\"userinfo_endpoint\":\"https://portal.example.com/userinfo\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+
+			if tt.prepare != nil {
+				tt.prepare(mockJWT)
+			}
+			s := &Server{
+				JWT:    mockJWT,
+				Issuer: tt.issuer,
+			}
+
+			err := s.GetWellKnownOpenidConfiguration(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_GetAuthorize(t *testing.T) {
+	s := &Server{}
+	state := "some-state"
+	params := generated.GetAuthorizeParams{
+		ClientId:    "wiki",
+		RedirectUri: "https://wiki.example.com/callback",
+		State:       &state,
+	}
+	request := entity.AuthorizationRequest{
+		ClientID:    "wiki",
+		RedirectURI: "https://wiki.example.com/callback",
+		State:       "some-state",
+	}
+	tests := []struct {
+		name         string
+		prepare      func(m *module.MockUserModuleInterface)
+		want         string
+		wantStatus   int
+		wantLocation string
+		wantErr      bool
+	}{
+		{
+			name: "invalid client",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Authorize(mockCtx.Request().Context(), 0, request).Return("", moduleUser.ErrInvalidOAuthClient)
+			},
+			want:       "{\"message\":\"client is not registered or redirect uri is not allowed\"}\n",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    false,
+		},
+		{
+			name: "error authorize",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Authorize(mockCtx.Request().Context(), 0, request).Return("", assert.AnError)
+			},
+			want:       "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantStatus: http.StatusInternalServerError,
+			wantErr:    false,
+		},
+		{
+			name: "success",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Authorize(mockCtx.Request().Context(), 0, request).Return("https://portal.example.com/login/authorize?client_id=wiki", nil)
+			},
+			want:         "",
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://portal.example.com/login/authorize?client_id=wiki",
+			wantErr:      false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetAuthorize(c, params)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantStatus, c.Response().Status)
+			assert.Equal(t, tt.wantLocation, c.Response().Header().Get(echo.HeaderLocation))
+		})
+	}
+}
+
+func TestServer_PostAuthorize(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.AuthorizeRequest:
+				if v != nil {
+					responseType := "code"
+					v.ResponseType = &responseType
+					v.ClientId = "wiki"
+					v.RedirectUri = "https://wiki.example.com/callback"
+				}
+			}
+			return nil
+		},
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	request := entity.AuthorizationRequest{
+		ResponseType: "code",
+		ClientID:     "wiki",
+		RedirectURI:  "https://wiki.example.com/callback",
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authenticate",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid client",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Authorize(mockCtx.Request().Context(), 15, request).Return("", moduleUser.ErrInvalidOAuthClient)
+			},
+			want:    "{\"message\":\"client is not registered or redirect uri is not allowed\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error authorize",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Authorize(mockCtx.Request().Context(), 15, request).Return("", assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileRead).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().Authorize(mockCtx.Request().Context(), 15, request).Return("https://wiki.example.com/callback?code=some-code", nil)
+			},
+			want:    "{\"redirect_to\":\"https://wiki.example.com/callback?code=some-code\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostAuthorize(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostToken(t *testing.T) {
+	s := &Server{}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"some-code"},
+		"redirect_uri":  {"https://wiki.example.com/callback"},
+		"client_id":     {"wiki"},
+		"client_secret": {"some-secret"},
+		"code_verifier": {"some-verifier"},
+	}
+	request := entity.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         "some-code",
+		RedirectURI:  "https://wiki.example.com/callback",
+		ClientID:     "wiki",
+		ClientSecret: "some-secret",
+		CodeVerifier: "some-verifier",
+	}
+	tests := []struct {
+		name                string
+		form                url.Values
+		basicAuth           []string
+		prepare             func(m *module.MockUserModuleInterface)
+		want                string
+		wantStatus          int
+		wantWWWAuthenticate string
+		wantErr             bool
+	}{
+		{
+			name: "malformed basic auth",
+			form: url.Values{
+				"grant_type": {"authorization_code"},
+			},
+			basicAuth:  []string{"wiki", "%zz"},
+			want:       "{\"error\":\"invalid_request\",\"error_description\":\"invalid URL escape \\\"%zz\\\"\"}\n",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    false,
+		},
+		{
+			name: "invalid client",
+			form: form,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ExchangeAuthorizationCode(mockCtx.Request().Context(), request).Return(entity.TokenModuleResponse{}, &moduleUser.OAuthError{
+					Code:        moduleUser.OAuthErrorInvalidClient,
+					Description: "client authentication failed",
+				})
+			},
+			want:                "{\"error\":\"invalid_client\",\"error_description\":\"client authentication failed\"}\n",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: "Basic realm=\"token\"",
+			wantErr:             false,
+		},
+		{
+			name: "invalid grant",
+			form: form,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ExchangeAuthorizationCode(mockCtx.Request().Context(), request).Return(entity.TokenModuleResponse{}, &moduleUser.OAuthError{
+					Code:        moduleUser.OAuthErrorInvalidGrant,
+					Description: "authorization code is not valid",
+				})
+			},
+			want:       "{\"error\":\"invalid_grant\",\"error_description\":\"authorization code is not valid\"}\n",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    false,
+		},
+		{
+			name: "error exchange",
+			form: form,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ExchangeAuthorizationCode(mockCtx.Request().Context(), request).Return(entity.TokenModuleResponse{}, assert.AnError)
+			},
+			want:       "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantStatus: http.StatusInternalServerError,
+			wantErr:    false,
+		},
+		{
+			name: "success with basic auth",
+			form: url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {"some-code"},
+				"redirect_uri":  {"https://wiki.example.com/callback"},
+				"code_verifier": {"some-verifier"},
+			},
+			basicAuth: []string{"wiki", "some-secret"},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ExchangeAuthorizationCode(mockCtx.Request().Context(), request).Return(entity.TokenModuleResponse{
+					AccessToken: "some-access-token",
+					IDToken:     "some-id-token",
+					Scope:       "openid",
+				}, nil)
+			},
+			want:       "{\"access_token\":\"some-access-token\",\"id_token\":\"some-id-token\",\"scope\":\"openid\",\"token_type\":\"Bearer\"}\n",
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name: "success",
+			form: form,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ExchangeAuthorizationCode(mockCtx.Request().Context(), request).Return(entity.TokenModuleResponse{
+					AccessToken: "some-access-token",
+					IDToken:     "some-id-token",
+					Scope:       "openid",
+				}, nil)
+			},
+			want:       "{\"access_token\":\"some-access-token\",\"id_token\":\"some-id-token\",\"scope\":\"openid\",\"token_type\":\"Bearer\"}\n",
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(tt.form.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			if tt.basicAuth != nil {
+				req.SetBasicAuth(tt.basicAuth[0], tt.basicAuth[1])
+			}
+			c.SetRequest(req)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostToken(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantStatus, c.Response().Status)
+			assert.Equal(t, tt.wantWWWAuthenticate, c.Response().Header().Get(echo.HeaderWWWAuthenticate))
+		})
+	}
+}
+
+func TestServer_GetUserinfo(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name                string
+		prepare             func(m *module.MockUserModuleInterface)
+		want                string
+		wantStatus          int
+		wantWWWAuthenticate string
+		wantErr             bool
+	}{
+		{
+			name: "invalid access token",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUserInfo(mockCtx.Request().Context(), "some-access-token").Return(entity.UserInfoModuleResponse{}, moduleUser.ErrInvalidOIDCAccessToken)
+			},
+			want:                "{\"message\":\"access token is not valid\"}\n",
+			wantStatus:          http.StatusUnauthorized,
+			wantWWWAuthenticate: "Bearer error=\"invalid_token\"",
+			wantErr:             false,
+		},
+		{
+			name: "error get user info",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUserInfo(mockCtx.Request().Context(), "some-access-token").Return(entity.UserInfoModuleResponse{}, assert.AnError)
+			},
+			want:       "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantStatus: http.StatusInternalServerError,
+			wantErr:    false,
+		},
+		{
+			name: "openid scope only",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUserInfo(mockCtx.Request().Context(), "some-access-token").Return(entity.UserInfoModuleResponse{
+					User: &entity.User{
+						ID:          15,
+						Fullname:    "John Doe",
+						PhoneNumber: "+628123456789",
+					},
+				}, nil)
+			},
+			want:       "{\"sub\":\"15\"}\n",
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name: "profile scope",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUserInfo(mockCtx.Request().Context(), "some-access-token").Return(entity.UserInfoModuleResponse{
+					User: &entity.User{
+						ID:          15,
+						Fullname:    "John Doe",
+						PhoneNumber: "+628123456789",
+					},
+					Profile: true,
+				}, nil)
+			},
+			want:       "{\"name\":\"John Doe\",\"sub\":\"15\"}\n",
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+		{
+			name: "profile and phone scopes",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetUserInfo(mockCtx.Request().Context(), "some-access-token").Return(entity.UserInfoModuleResponse{
+					User: &entity.User{
+						ID:          15,
+						Fullname:    "John Doe",
+						PhoneNumber: "+628123456789",
+					},
+					Profile: true,
+					Phone:   true,
+				}, nil)
+			},
+			want:       "{\"name\":\"John Doe\",\"phone_number\":\"+628123456789\",\"phone_number_verified\":false,\"sub\":\"15\"}\n",
+			wantStatus: http.StatusOK,
+			wantErr:    false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(nil)
+			c.Request().Header.Set(echo.HeaderAuthorization, "Bearer some-access-token")
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetUserinfo(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantStatus, c.Response().Status)
+			assert.Equal(t, tt.wantWWWAuthenticate, c.Response().Header().Get(echo.HeaderWWWAuthenticate))
+		})
+	}
+}
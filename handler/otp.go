@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostLoginOtpStart(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.StartOTPLoginRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	err = s.UserModule.StartOTPLogin(ctx, req.PhoneNumber, c.RealIP())
+	var throttled *moduleUser.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return tooManyLoginAttempts(c, throttled)
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	// same response whether the code is sent or not
+	return helper.OK(c, generated.MessageResponse{
+		Message: "if the phone number is registered and verified, a login code has been sent",
+	})
+}
+
+func (s *Server) PostLoginOtpVerify(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.VerifyOTPLoginRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.LoginModuleResponse
+	result, err = s.UserModule.VerifyOTPLogin(ctx, req.PhoneNumber, req.Code, c.RealIP())
+	var throttled *moduleUser.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return tooManyLoginAttempts(c, throttled)
+	}
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	return loginResult(c, result)
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PostLoginOtpStart(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.StartOTPLoginRequest:
+				if v != nil {
+					v.PhoneNumber = "628123456789"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name           string
+		mockCtx        *mockEchoContext
+		prepare        func(m *module.MockUserModuleInterface)
+		want           string
+		wantRetryAfter string
+		wantErr        bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "throttled",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartOTPLogin(mockCtx.Request().Context(), "628123456789", "192.0.2.1").Return(&moduleUser.LoginThrottledError{
+					RetryAfter: time.Minute,
+				})
+			},
+			want:           "{\"message\":\"too many failed login attempts, try again later\"}\n",
+			wantRetryAfter: "60",
+			wantErr:        false,
+		},
+		{
+			name:    "error start otp login",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartOTPLogin(mockCtx.Request().Context(), "628123456789", "192.0.2.1").Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().StartOTPLogin(mockCtx.Request().Context(), "628123456789", "192.0.2.1").Return(nil)
+			},
+			want:    "{\"message\":\"if the phone number is registered and verified, a login code has been sent\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLoginOtpStart(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantRetryAfter, c.Response().Header().Get(echo.HeaderRetryAfter))
+		})
+	}
+}
+
+func TestServer_PostLoginOtpVerify(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.VerifyOTPLoginRequest:
+				if v != nil {
+					v.PhoneNumber = "628123456789"
+					v.Code = "123456"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name           string
+		mockCtx        *mockEchoContext
+		prepare        func(m *module.MockUserModuleInterface)
+		want           string
+		wantRetryAfter string
+		wantErr        bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "wrong code",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().VerifyOTPLogin(mockCtx.Request().Context(), "628123456789", "123456", "192.0.2.1").Return(entity.LoginModuleResponse{}, moduleUser.ErrOTPLoginFailed)
+			},
+			want:    "{\"message\":\"phone number or code is not correct\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "throttled",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().VerifyOTPLogin(mockCtx.Request().Context(), "628123456789", "123456", "192.0.2.1").Return(entity.LoginModuleResponse{}, &moduleUser.LoginThrottledError{
+					RetryAfter: time.Millisecond * 1500,
+				})
+			},
+			want:           "{\"message\":\"too many failed login attempts, try again later\"}\n",
+			wantRetryAfter: "2",
+			wantErr:        false,
+		},
+		{
+			name:    "two-factor authentication enabled",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().VerifyOTPLogin(mockCtx.Request().Context(), "628123456789", "123456", "192.0.2.1").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 1,
+					},
+					ChallengeToken: "some-challenge-token",
+				}, nil)
+			},
+			want:    "{\"challenge_token\":\"some-challenge-token\",\"expires_in\":300}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().VerifyOTPLogin(mockCtx.Request().Context(), "628123456789", "123456", "192.0.2.1").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 1,
+					},
+					JWT:          "some-jwt",
+					RefreshToken: "some-refresh-token",
+				}, nil)
+			},
+			want:    "{\"jwt\":\"some-jwt\",\"refresh_token\":\"some-refresh-token\",\"user_id\":1}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLoginOtpVerify(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantRetryAfter, c.Response().Header().Get(echo.HeaderRetryAfter))
+		})
+	}
+}
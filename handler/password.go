@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostPasswordForgot(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.ForgotPasswordRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	err = s.UserModule.ForgotPassword(ctx, req.PhoneNumber)
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+
+	// same response whether the phone number is registered or not
+	return helper.OK(c, generated.MessageResponse{
+		Message: "if the phone number is registered, a reset code has been sent",
+	})
+}
+
+func (s *Server) PostPasswordReset(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.ResetPasswordRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.ResetPasswordModuleResponse
+	result, err = s.UserModule.ResetPassword(ctx, req.PhoneNumber, req.Code, req.NewPassword)
+	if errors.Is(err, moduleUser.ErrInvalidVerificationCode) {
+		return helper.BadRequest(c, err.Error())
+	}
+	if err != nil {
+		return helper.InternalServerError(c, err.Error())
+	}
+	if !result.Valid {
+		return helper.BadRequest(c, strings.Join(result.Messages, ", "))
+	}
+
+	return helper.OK(c, generated.MessageResponse{
+		Message: "password has been reset, please log in again",
+	})
+}
+
+func (s *Server) GetPasswordPolicy(c echo.Context) error {
+	policy := s.UserModule.GetPasswordPolicy(c.Request().Context())
+
+	return helper.OK(c, generated.PasswordPolicyResponse{
+		MinLength:         policy.MinLength,
+		MaxLength:         policy.MaxLength,
+		RequireUppercase:  policy.RequireUppercase,
+		RequireLowercase:  policy.RequireLowercase,
+		RequireNumber:     policy.RequireNumber,
+		RequireSpecial:    policy.RequireSpecial,
+		SpecialCharacters: policy.SpecialCharacters,
+		MinStrength:       policy.MinStrength,
+		HistoryDepth:      policy.HistoryDepth,
+	})
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PostPasswordForgot(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.ForgotPasswordRequest:
+				if v != nil {
+					v.PhoneNumber = "628123456789"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error forgot password",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ForgotPassword(mockCtx.Request().Context(), "628123456789").Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ForgotPassword(mockCtx.Request().Context(), "628123456789").Return(nil)
+			},
+			want:    "{\"message\":\"if the phone number is registered, a reset code has been sent\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostPasswordForgot(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostPasswordReset(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.ResetPasswordRequest:
+				if v != nil {
+					v.PhoneNumber = "628123456789"
+					v.Code = "123456"
+					v.NewPassword = "New@123"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid code",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ResetPassword(mockCtx.Request().Context(), "628123456789", "123456", "New@123").
+					Return(entity.ResetPasswordModuleResponse{}, entity.Obscure(moduleUser.ErrInvalidVerificationCode, entity.ErrUserSuspended))
+			},
+			want:    "{\"message\":\"verification code is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error reset password",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ResetPassword(mockCtx.Request().Context(), "628123456789", "123456", "New@123").
+					Return(entity.ResetPasswordModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid password",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ResetPassword(mockCtx.Request().Context(), "628123456789", "123456", "New@123").
+					Return(entity.ResetPasswordModuleResponse{
+						Valid:    false,
+						Messages: []string{"password must be 6-64 characters"},
+					}, nil)
+			},
+			want:    "{\"message\":\"password must be 6-64 characters\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ResetPassword(mockCtx.Request().Context(), "628123456789", "123456", "New@123").
+					Return(entity.ResetPasswordModuleResponse{
+						Valid:    true,
+						Messages: []string{},
+					}, nil)
+			},
+			want:    "{\"message\":\"password has been reset, please log in again\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostPasswordReset(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_GetPasswordPolicy(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().GetPasswordPolicy(mockCtx.Request().Context()).Return(validator.PasswordPolicy{
+					MinLength:         8,
+					MaxLength:         64,
+					RequireUppercase:  true,
+					RequireNumber:     true,
+					RequireSpecial:    true,
+					SpecialCharacters: "!@#",
+					MinStrength:       2,
+					HistoryDepth:      5,
+				})
+			},
+			want:    "{\"history_depth\":5,\"max_length\":64,\"min_length\":8,\"min_strength\":2,\"require_lowercase\":false,\"require_number\":true,\"require_special\":true,\"require_uppercase\":true,\"special_characters\":\"!@#\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.GetPasswordPolicy(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
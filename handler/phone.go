@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostV1ProfilePhoneVerification(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := s.UserModule.RequestPhoneVerification(ctx, userID)
+	if err != nil {
+		return phoneVerificationError(c, err)
+	}
+
+	return helper.OK(c, generated.MessageResponse{
+		Message: "verification code has been sent",
+	})
+}
+
+func (s *Server) PostV1ProfilePhoneVerificationConfirm(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.ConfirmPhoneVerificationRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var phoneNumber string
+	phoneNumber, err = s.UserModule.ConfirmPhoneVerification(ctx, userID, req.Code)
+	if err != nil {
+		return phoneVerificationError(c, err)
+	}
+
+	return helper.OK(c, generated.ConfirmPhoneVerificationResponse{
+		UserId:      int64(userID),
+		PhoneNumber: phoneNumber,
+	})
+}
+
+// phoneVerificationError maps errors of verifying a phone number to their status code.
+func phoneVerificationError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, moduleUser.ErrPhoneNumberVerified),
+		errors.Is(err, moduleUser.ErrInvalidVerificationCode):
+		return helper.BadRequest(c, err.Error())
+	case errors.Is(err, moduleUser.ErrPhoneNumberConflict):
+		return helper.Conflict(c, err.Error())
+	default:
+		return helper.InternalServerError(c, err.Error())
+	}
+}
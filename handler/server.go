@@ -8,16 +8,24 @@ import (
 type Server struct {
 	UserModule module.UserModuleInterface
 	Auth       tools.AuthInterface
+	JWT        tools.JWTInterface
+	Issuer     string
 }
 
 type NewServerOptions struct {
 	UserModule module.UserModuleInterface
 	Auth       tools.AuthInterface
+	JWT        tools.JWTInterface
+	// Issuer is the iss claim of issued jwt, the base url of OpenID Connect endpoints.
+	// OpenID Connect discovery is not served when it is not set.
+	Issuer string
 }
 
 func NewServer(opts NewServerOptions) *Server {
 	return &Server{
 		UserModule: opts.UserModule,
 		Auth:       opts.Auth,
+		JWT:        opts.JWT,
+		Issuer:     opts.Issuer,
 	}
 }
@@ -15,9 +15,11 @@ func TestNewServer(t *testing.T) {
 
 	mockUserModule := module.NewMockUserModuleInterface(ctrl)
 	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
 
 	assert.NotEmpty(t, NewServer(NewServerOptions{
 		UserModule: mockUserModule,
 		Auth:       mockAuth,
+		JWT:        mockJWT,
 	}))
 }
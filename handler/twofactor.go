@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+func (s *Server) PostLogin2fa(c echo.Context) error {
+	var (
+		ctx = c.Request().Context()
+		req = &generated.LoginTwoFactorRequest{}
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var result entity.LoginModuleResponse
+	result, err = s.UserModule.LoginTwoFactor(ctx, req.ChallengeToken, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return helper.OK(c, generated.LoginResponse{
+		Jwt:          result.JWT,
+		RefreshToken: result.RefreshToken,
+		UserId:       int64(result.User.ID),
+	})
+}
+
+func (s *Server) PostV1Profile2faEnroll(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		userID = helper.UserIDFromContext(c)
+	)
+
+	result, err := s.UserModule.EnrollTOTP(ctx, userID)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return helper.OK(c, generated.TOTPEnrollmentResponse{
+		Secret:     result.Secret,
+		OtpauthUri: result.URI,
+	})
+}
+
+func (s *Server) PostV1Profile2faConfirm(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.TwoFactorCodeRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	var recoveryCodes []string
+	recoveryCodes, err = s.UserModule.ConfirmTOTP(ctx, userID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return helper.OK(c, generated.RecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (s *Server) PostV1Profile2faDisable(c echo.Context) error {
+	if err := s.Auth.Authorize(c, entity.PermissionProfileWrite); err != nil {
+		return helper.Forbidden(c, err.Error())
+	}
+
+	var (
+		ctx    = c.Request().Context()
+		req    = &generated.TwoFactorCodeRequest{}
+		userID = helper.UserIDFromContext(c)
+	)
+
+	err := c.Bind(req)
+	if err != nil {
+		return helper.BadRequest(c, err.Error())
+	}
+
+	err = s.UserModule.DisableTOTP(ctx, userID, req.Code)
+	if err != nil {
+		return twoFactorError(c, err)
+	}
+
+	return helper.OK(c, generated.MessageResponse{
+		Message: "two-factor authentication has been disabled",
+	})
+}
+
+// twoFactorError maps errors of two-factor authentication to their status code.
+func twoFactorError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, moduleUser.ErrInvalidLoginChallenge),
+		errors.Is(err, moduleUser.ErrInvalidTwoFactorCode),
+		errors.Is(err, moduleUser.ErrTwoFactorNotEnrolled),
+		errors.Is(err, moduleUser.ErrTwoFactorNotEnabled):
+		return helper.BadRequest(c, err.Error())
+	case errors.Is(err, moduleUser.ErrTwoFactorEnabled):
+		return helper.Conflict(c, err.Error())
+	default:
+		return helper.InternalServerError(c, err.Error())
+	}
+}
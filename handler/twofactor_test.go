@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/generated"
+	"github.com/leguminosa/profile-open-portal/module"
+	moduleUser "github.com/leguminosa/profile-open-portal/module/user"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_PostLogin2fa(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.LoginTwoFactorRequest:
+				if v != nil {
+					v.ChallengeToken = "some-challenge-token"
+					v.Code = "123456"
+				}
+			}
+			return nil
+		},
+	}
+	tests := []struct {
+		name    string
+		mockCtx *mockEchoContext
+		prepare func(m *module.MockUserModuleInterface)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid challenge",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LoginTwoFactor(mockCtx.Request().Context(), "some-challenge-token", "123456").Return(entity.LoginModuleResponse{}, moduleUser.ErrInvalidLoginChallenge)
+			},
+			want:    "{\"message\":\"login challenge is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid code",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LoginTwoFactor(mockCtx.Request().Context(), "some-challenge-token", "123456").Return(entity.LoginModuleResponse{}, moduleUser.ErrInvalidTwoFactorCode)
+			},
+			want:    "{\"message\":\"two-factor code is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error login two factor",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LoginTwoFactor(mockCtx.Request().Context(), "some-challenge-token", "123456").Return(entity.LoginModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().LoginTwoFactor(mockCtx.Request().Context(), "some-challenge-token", "123456").Return(entity.LoginModuleResponse{
+					User: &entity.User{
+						ID: 1,
+					},
+					JWT:          "some-jwt",
+					RefreshToken: "some-refresh-token",
+				}, nil)
+			},
+			want:    "{\"jwt\":\"some-jwt\",\"refresh_token\":\"some-refresh-token\",\"user_id\":1}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostLogin2fa(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1Profile2faEnroll(t *testing.T) {
+	s := &Server{}
+	loggedIn := &mockEchoContext{
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "already enabled",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().EnrollTOTP(mockCtx.Request().Context(), 15).Return(entity.TOTPEnrollmentModuleResponse{}, moduleUser.ErrTwoFactorEnabled)
+			},
+			want:    "{\"message\":\"two-factor authentication has already been enabled\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error enroll totp",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().EnrollTOTP(mockCtx.Request().Context(), 15).Return(entity.TOTPEnrollmentModuleResponse{}, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: loggedIn,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().EnrollTOTP(mockCtx.Request().Context(), 15).Return(entity.TOTPEnrollmentModuleResponse{
+					Secret: "JBSWY3DPEHPK3PXP",
+					URI:    "otpauth://totp/uri",
+				}, nil)
+			},
+			want:    "{\"otpauth_uri\":\"otpauth://totp/uri\",\"secret\":\"JBSWY3DPEHPK3PXP\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1Profile2faEnroll(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1Profile2faConfirm(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.TwoFactorCodeRequest:
+				if v != nil {
+					v.Code = "123456"
+				}
+			}
+			return nil
+		},
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "not enrolled",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmTOTP(mockCtx.Request().Context(), 15, "123456").Return(nil, moduleUser.ErrTwoFactorNotEnrolled)
+			},
+			want:    "{\"message\":\"two-factor authentication has not been enrolled\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "already enabled",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmTOTP(mockCtx.Request().Context(), 15, "123456").Return(nil, moduleUser.ErrTwoFactorEnabled)
+			},
+			want:    "{\"message\":\"two-factor authentication has already been enabled\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error confirm totp",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmTOTP(mockCtx.Request().Context(), 15, "123456").Return(nil, assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().ConfirmTOTP(mockCtx.Request().Context(), 15, "123456").Return([]string{"01234-56789", "98765-43210"}, nil)
+			},
+			want:    "{\"recovery_codes\":[\"01234-56789\",\"98765-43210\"]}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1Profile2faConfirm(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestServer_PostV1Profile2faDisable(t *testing.T) {
+	s := &Server{}
+	bindRequest := &mockEchoContext{
+		mockBind: func(i interface{}) error {
+			switch v := i.(type) {
+			case *generated.TwoFactorCodeRequest:
+				if v != nil {
+					v.Code = "01234-56789"
+				}
+			}
+			return nil
+		},
+		mockGet: func(key string) interface{} {
+			return 15
+		},
+	}
+	tests := []struct {
+		name        string
+		mockCtx     *mockEchoContext
+		prepareAuth func(m *tools.MockAuthInterface)
+		prepare     func(m *module.MockUserModuleInterface)
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "error authorize",
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name: "error bind",
+			mockCtx: &mockEchoContext{
+				mockBind: func(i interface{}) error {
+					return assert.AnError
+				},
+			},
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "not enabled",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().DisableTOTP(mockCtx.Request().Context(), 15, "01234-56789").Return(moduleUser.ErrTwoFactorNotEnabled)
+			},
+			want:    "{\"message\":\"two-factor authentication is not enabled\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "invalid code",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().DisableTOTP(mockCtx.Request().Context(), 15, "01234-56789").Return(moduleUser.ErrInvalidTwoFactorCode)
+			},
+			want:    "{\"message\":\"two-factor code is not valid\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "error disable totp",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().DisableTOTP(mockCtx.Request().Context(), 15, "01234-56789").Return(assert.AnError)
+			},
+			want:    "{\"message\":\"assert.AnError general error for testing\"}\n",
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			mockCtx: bindRequest,
+			prepareAuth: func(m *tools.MockAuthInterface) {
+				m.EXPECT().Authorize(gomock.Any(), entity.PermissionProfileWrite).Return(nil)
+			},
+			prepare: func(m *module.MockUserModuleInterface) {
+				m.EXPECT().DisableTOTP(mockCtx.Request().Context(), 15, "01234-56789").Return(nil)
+			},
+			want:    "{\"message\":\"two-factor authentication has been disabled\"}\n",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	mockUserModule := module.NewMockUserModuleInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newMockEchoContext(tt.mockCtx)
+
+			if tt.prepareAuth != nil {
+				tt.prepareAuth(mockAuth)
+			}
+			s.Auth = mockAuth
+
+			if tt.prepare != nil {
+				tt.prepare(mockUserModule)
+			}
+			s.UserModule = mockUserModule
+
+			err := s.PostV1Profile2faDisable(c)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
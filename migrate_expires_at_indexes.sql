@@ -0,0 +1,10 @@
+/**
+    Adds the indexes expired rows are purged by to a database created before they existed.
+    Safe to run more than once, only what is missing is created.
+*/
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS verification_codes_expires_at_idx ON verification_codes (expires_at);
+CREATE INDEX IF NOT EXISTS login_challenges_expires_at_idx ON login_challenges (expires_at);
+CREATE INDEX IF NOT EXISTS login_failures_last_failed_at_idx ON login_failures (last_failed_at);
@@ -0,0 +1,44 @@
+/**
+    Moves phone numbers stored before they were normalized, digits only starting with 62 like 628123456789,
+    to E.164 format like +628123456789, the only form they are looked up in. A trunk prefix kept after
+    the country code, like 6208123456789, is dropped the same way the application does.
+    Safe to run more than once, only rows still in the legacy format are touched.
+*/
+
+BEGIN;
+
+-- a number registered again in E.164 format while the legacy row was not found is left as it is,
+-- both users have to be looked at by hand, see the query at the end
+UPDATE users u
+SET phone_number = regexp_replace(u.phone_number, '^620?', '+62')
+WHERE u.phone_number ~ '^62[0-9]+$'
+    AND NOT EXISTS (
+        SELECT 1 FROM users other WHERE other.phone_number = regexp_replace(u.phone_number, '^620?', '+62')
+    );
+
+UPDATE users
+SET pending_phone_number = regexp_replace(pending_phone_number, '^620?', '+62')
+WHERE pending_phone_number ~ '^62[0-9]+$';
+
+UPDATE verification_codes
+SET phone_number = regexp_replace(phone_number, '^620?', '+62')
+WHERE phone_number ~ '^62[0-9]+$';
+
+-- failures counted under both formats are merged into the E.164 key
+INSERT INTO login_failures (key, failures, last_failed_at)
+SELECT regexp_replace(key, '^phone:620?', 'phone:+62'), failures, last_failed_at
+FROM login_failures
+WHERE key ~ '^phone:62[0-9]+$'
+ON CONFLICT (key) DO UPDATE
+SET failures = login_failures.failures + excluded.failures,
+    last_failed_at = greatest(login_failures.last_failed_at, excluded.last_failed_at);
+
+DELETE FROM login_failures WHERE key ~ '^phone:62[0-9]+$';
+
+COMMIT;
+
+-- users still in the legacy format because their number is taken by another user
+SELECT u.id, u.phone_number, other.id AS other_id
+FROM users u
+JOIN users other ON other.phone_number = regexp_replace(u.phone_number, '^620?', '+62')
+WHERE u.phone_number ~ '^62[0-9]+$';
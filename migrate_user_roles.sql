@@ -0,0 +1,45 @@
+/**
+    Adds roles and permissions to a database created before they existed, giving every existing user
+    "user" role the same way registration does. "admin" role is still granted manually.
+    Safe to run more than once, only what is missing is created.
+*/
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS roles (
+    name            VARCHAR                                                 not null
+        primary key
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role            VARCHAR                                                 not null
+        references roles (name) on delete cascade,
+    permission      VARCHAR                                                 not null,
+    primary key (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id         INTEGER                                                 not null
+        references users (id) on delete cascade,
+    role            VARCHAR                                                 not null
+        references roles (name) on delete cascade,
+    created_at      TIMESTAMP WITH TIME ZONE    default CURRENT_TIMESTAMP   not null,
+    primary key (user_id, role)
+);
+
+INSERT INTO roles (name) VALUES ('user'), ('admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'profile:read'),
+    ('user', 'profile:write'),
+    ('admin', 'users:read'),
+    ('admin', 'users:write')
+ON CONFLICT DO NOTHING;
+
+-- users registered before roles existed
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'user' FROM users
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -4,13 +4,47 @@ import (
 	"context"
 
 	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
 )
 
 //go:generate mockgen -source=module/module.go -destination=module/module.mock.gen.go -package=module
 
 type UserModuleInterface interface {
 	Register(ctx context.Context, user *entity.User) (entity.RegisterModuleResponse, error)
-	Login(ctx context.Context, user *entity.User) (entity.LoginModuleResponse, error)
+	Login(ctx context.Context, user *entity.User, clientIP string) (entity.LoginModuleResponse, error)
+	StartOTPLogin(ctx context.Context, phoneNumber, clientIP string) error
+	VerifyOTPLogin(ctx context.Context, phoneNumber, code, clientIP string) (entity.LoginModuleResponse, error)
+	StartMagicLinkLogin(ctx context.Context, email, clientIP string) error
+	RedeemMagicLink(ctx context.Context, token string) (entity.LoginModuleResponse, error)
+	LoginTwoFactor(ctx context.Context, challengeToken, code string) (entity.LoginModuleResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (entity.LoginModuleResponse, error)
+	Logout(ctx context.Context, token *entity.RevokedToken, refreshToken string) error
+	LogoutAll(ctx context.Context, userID int) error
 	GetProfile(ctx context.Context, userID int) (*entity.User, error)
 	UpdateProfile(ctx context.Context, user *entity.User) (entity.UpdateProfileModuleResponse, error)
+	RequestPhoneVerification(ctx context.Context, userID int) error
+	ConfirmPhoneVerification(ctx context.Context, userID int, code string) (string, error)
+	RequestActivation(ctx context.Context, phoneNumber string) error
+	ActivateUser(ctx context.Context, phoneNumber, code string) error
+	RequestEmailVerification(ctx context.Context, userID int) error
+	ConfirmEmailVerification(ctx context.Context, token string) (int, string, error)
+	EnrollTOTP(ctx context.Context, userID int) (entity.TOTPEnrollmentModuleResponse, error)
+	ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int, code string) error
+	ListUsers(ctx context.Context, req entity.ListUsersRequest) (entity.ListUsersModuleResponse, error)
+	GetUser(ctx context.Context, userID int) (*entity.User, error)
+	GetPasswordPolicy(ctx context.Context) validator.PasswordPolicy
+	ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) (entity.ChangePasswordModuleResponse, error)
+	ForgotPassword(ctx context.Context, phoneNumber string) error
+	ResetPassword(ctx context.Context, phoneNumber, code, newPassword string) (entity.ResetPasswordModuleResponse, error)
+	ChangeUserStatus(ctx context.Context, adminID, userID int, status string) error
+	UnlockUser(ctx context.Context, userID int) error
+	Authorize(ctx context.Context, userID int, req entity.AuthorizationRequest) (string, error)
+	ExchangeAuthorizationCode(ctx context.Context, req entity.TokenRequest) (entity.TokenModuleResponse, error)
+	GetUserInfo(ctx context.Context, accessToken string) (entity.UserInfoModuleResponse, error)
+	StartFederatedLogin(ctx context.Context, provider string, userID int) (entity.StartFederatedLoginModuleResponse, error)
+	CompleteFederatedLogin(ctx context.Context, state, code string) (entity.LoginModuleResponse, error)
+	LinkFederatedIdentity(ctx context.Context, userID int, state, code string) (*entity.FederatedIdentity, error)
+	ListFederatedIdentities(ctx context.Context, userID int) ([]*entity.FederatedIdentity, error)
+	UnlinkFederatedIdentity(ctx context.Context, userID, identityID int) error
 }
@@ -10,6 +10,7 @@ import (
 
 	gomock "github.com/golang/mock/gomock"
 	entity "github.com/leguminosa/profile-open-portal/entity"
+	validator "github.com/leguminosa/profile-open-portal/tools/validator"
 )
 
 // MockUserModuleInterface is a mock of UserModuleInterface interface.
@@ -35,6 +36,197 @@ func (m *MockUserModuleInterface) EXPECT() *MockUserModuleInterfaceMockRecorder
 	return m.recorder
 }
 
+// ActivateUser mocks base method.
+func (m *MockUserModuleInterface) ActivateUser(ctx context.Context, phoneNumber, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivateUser", ctx, phoneNumber, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ActivateUser indicates an expected call of ActivateUser.
+func (mr *MockUserModuleInterfaceMockRecorder) ActivateUser(ctx, phoneNumber, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateUser", reflect.TypeOf((*MockUserModuleInterface)(nil).ActivateUser), ctx, phoneNumber, code)
+}
+
+// Authorize mocks base method.
+func (m *MockUserModuleInterface) Authorize(ctx context.Context, userID int, req entity.AuthorizationRequest) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", ctx, userID, req)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockUserModuleInterfaceMockRecorder) Authorize(ctx, userID, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockUserModuleInterface)(nil).Authorize), ctx, userID, req)
+}
+
+// ChangePassword mocks base method.
+func (m *MockUserModuleInterface) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) (entity.ChangePasswordModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, userID, currentPassword, newPassword)
+	ret0, _ := ret[0].(entity.ChangePasswordModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockUserModuleInterfaceMockRecorder) ChangePassword(ctx, userID, currentPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockUserModuleInterface)(nil).ChangePassword), ctx, userID, currentPassword, newPassword)
+}
+
+// ChangeUserStatus mocks base method.
+func (m *MockUserModuleInterface) ChangeUserStatus(ctx context.Context, adminID, userID int, status string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeUserStatus", ctx, adminID, userID, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangeUserStatus indicates an expected call of ChangeUserStatus.
+func (mr *MockUserModuleInterfaceMockRecorder) ChangeUserStatus(ctx, adminID, userID, status interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeUserStatus", reflect.TypeOf((*MockUserModuleInterface)(nil).ChangeUserStatus), ctx, adminID, userID, status)
+}
+
+// CompleteFederatedLogin mocks base method.
+func (m *MockUserModuleInterface) CompleteFederatedLogin(ctx context.Context, state, code string) (entity.LoginModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteFederatedLogin", ctx, state, code)
+	ret0, _ := ret[0].(entity.LoginModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteFederatedLogin indicates an expected call of CompleteFederatedLogin.
+func (mr *MockUserModuleInterfaceMockRecorder) CompleteFederatedLogin(ctx, state, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteFederatedLogin", reflect.TypeOf((*MockUserModuleInterface)(nil).CompleteFederatedLogin), ctx, state, code)
+}
+
+// ConfirmEmailVerification mocks base method.
+func (m *MockUserModuleInterface) ConfirmEmailVerification(ctx context.Context, token string) (int, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmEmailVerification", ctx, token)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ConfirmEmailVerification indicates an expected call of ConfirmEmailVerification.
+func (mr *MockUserModuleInterfaceMockRecorder) ConfirmEmailVerification(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmEmailVerification", reflect.TypeOf((*MockUserModuleInterface)(nil).ConfirmEmailVerification), ctx, token)
+}
+
+// ConfirmPhoneVerification mocks base method.
+func (m *MockUserModuleInterface) ConfirmPhoneVerification(ctx context.Context, userID int, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmPhoneVerification", ctx, userID, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmPhoneVerification indicates an expected call of ConfirmPhoneVerification.
+func (mr *MockUserModuleInterfaceMockRecorder) ConfirmPhoneVerification(ctx, userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmPhoneVerification", reflect.TypeOf((*MockUserModuleInterface)(nil).ConfirmPhoneVerification), ctx, userID, code)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockUserModuleInterface) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userID, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockUserModuleInterfaceMockRecorder) ConfirmTOTP(ctx, userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockUserModuleInterface)(nil).ConfirmTOTP), ctx, userID, code)
+}
+
+// DisableTOTP mocks base method.
+func (m *MockUserModuleInterface) DisableTOTP(ctx context.Context, userID int, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", ctx, userID, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockUserModuleInterfaceMockRecorder) DisableTOTP(ctx, userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockUserModuleInterface)(nil).DisableTOTP), ctx, userID, code)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockUserModuleInterface) EnrollTOTP(ctx context.Context, userID int) (entity.TOTPEnrollmentModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, userID)
+	ret0, _ := ret[0].(entity.TOTPEnrollmentModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockUserModuleInterfaceMockRecorder) EnrollTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockUserModuleInterface)(nil).EnrollTOTP), ctx, userID)
+}
+
+// ExchangeAuthorizationCode mocks base method.
+func (m *MockUserModuleInterface) ExchangeAuthorizationCode(ctx context.Context, req entity.TokenRequest) (entity.TokenModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExchangeAuthorizationCode", ctx, req)
+	ret0, _ := ret[0].(entity.TokenModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExchangeAuthorizationCode indicates an expected call of ExchangeAuthorizationCode.
+func (mr *MockUserModuleInterfaceMockRecorder) ExchangeAuthorizationCode(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExchangeAuthorizationCode", reflect.TypeOf((*MockUserModuleInterface)(nil).ExchangeAuthorizationCode), ctx, req)
+}
+
+// ForgotPassword mocks base method.
+func (m *MockUserModuleInterface) ForgotPassword(ctx context.Context, phoneNumber string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgotPassword", ctx, phoneNumber)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgotPassword indicates an expected call of ForgotPassword.
+func (mr *MockUserModuleInterfaceMockRecorder) ForgotPassword(ctx, phoneNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgotPassword", reflect.TypeOf((*MockUserModuleInterface)(nil).ForgotPassword), ctx, phoneNumber)
+}
+
+// GetPasswordPolicy mocks base method.
+func (m *MockUserModuleInterface) GetPasswordPolicy(ctx context.Context) validator.PasswordPolicy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordPolicy", ctx)
+	ret0, _ := ret[0].(validator.PasswordPolicy)
+	return ret0
+}
+
+// GetPasswordPolicy indicates an expected call of GetPasswordPolicy.
+func (mr *MockUserModuleInterfaceMockRecorder) GetPasswordPolicy(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordPolicy", reflect.TypeOf((*MockUserModuleInterface)(nil).GetPasswordPolicy), ctx)
+}
+
 // GetProfile mocks base method.
 func (m *MockUserModuleInterface) GetProfile(ctx context.Context, userID int) (*entity.User, error) {
 	m.ctrl.T.Helper()
@@ -50,19 +242,167 @@ func (mr *MockUserModuleInterfaceMockRecorder) GetProfile(ctx, userID interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProfile", reflect.TypeOf((*MockUserModuleInterface)(nil).GetProfile), ctx, userID)
 }
 
+// GetUser mocks base method.
+func (m *MockUserModuleInterface) GetUser(ctx context.Context, userID int) (*entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userID)
+	ret0, _ := ret[0].(*entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockUserModuleInterfaceMockRecorder) GetUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserModuleInterface)(nil).GetUser), ctx, userID)
+}
+
+// GetUserInfo mocks base method.
+func (m *MockUserModuleInterface) GetUserInfo(ctx context.Context, accessToken string) (entity.UserInfoModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserInfo", ctx, accessToken)
+	ret0, _ := ret[0].(entity.UserInfoModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserInfo indicates an expected call of GetUserInfo.
+func (mr *MockUserModuleInterfaceMockRecorder) GetUserInfo(ctx, accessToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserInfo", reflect.TypeOf((*MockUserModuleInterface)(nil).GetUserInfo), ctx, accessToken)
+}
+
+// LinkFederatedIdentity mocks base method.
+func (m *MockUserModuleInterface) LinkFederatedIdentity(ctx context.Context, userID int, state, code string) (*entity.FederatedIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkFederatedIdentity", ctx, userID, state, code)
+	ret0, _ := ret[0].(*entity.FederatedIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LinkFederatedIdentity indicates an expected call of LinkFederatedIdentity.
+func (mr *MockUserModuleInterfaceMockRecorder) LinkFederatedIdentity(ctx, userID, state, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkFederatedIdentity", reflect.TypeOf((*MockUserModuleInterface)(nil).LinkFederatedIdentity), ctx, userID, state, code)
+}
+
+// ListFederatedIdentities mocks base method.
+func (m *MockUserModuleInterface) ListFederatedIdentities(ctx context.Context, userID int) ([]*entity.FederatedIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFederatedIdentities", ctx, userID)
+	ret0, _ := ret[0].([]*entity.FederatedIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFederatedIdentities indicates an expected call of ListFederatedIdentities.
+func (mr *MockUserModuleInterfaceMockRecorder) ListFederatedIdentities(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFederatedIdentities", reflect.TypeOf((*MockUserModuleInterface)(nil).ListFederatedIdentities), ctx, userID)
+}
+
+// ListUsers mocks base method.
+func (m *MockUserModuleInterface) ListUsers(ctx context.Context, req entity.ListUsersRequest) (entity.ListUsersModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, req)
+	ret0, _ := ret[0].(entity.ListUsersModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockUserModuleInterfaceMockRecorder) ListUsers(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockUserModuleInterface)(nil).ListUsers), ctx, req)
+}
+
 // Login mocks base method.
-func (m *MockUserModuleInterface) Login(ctx context.Context, user *entity.User) (entity.LoginModuleResponse, error) {
+func (m *MockUserModuleInterface) Login(ctx context.Context, user *entity.User, clientIP string) (entity.LoginModuleResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Login", ctx, user)
+	ret := m.ctrl.Call(m, "Login", ctx, user, clientIP)
 	ret0, _ := ret[0].(entity.LoginModuleResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Login indicates an expected call of Login.
-func (mr *MockUserModuleInterfaceMockRecorder) Login(ctx, user interface{}) *gomock.Call {
+func (mr *MockUserModuleInterfaceMockRecorder) Login(ctx, user, clientIP interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserModuleInterface)(nil).Login), ctx, user, clientIP)
+}
+
+// LoginTwoFactor mocks base method.
+func (m *MockUserModuleInterface) LoginTwoFactor(ctx context.Context, challengeToken, code string) (entity.LoginModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoginTwoFactor", ctx, challengeToken, code)
+	ret0, _ := ret[0].(entity.LoginModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoginTwoFactor indicates an expected call of LoginTwoFactor.
+func (mr *MockUserModuleInterfaceMockRecorder) LoginTwoFactor(ctx, challengeToken, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginTwoFactor", reflect.TypeOf((*MockUserModuleInterface)(nil).LoginTwoFactor), ctx, challengeToken, code)
+}
+
+// Logout mocks base method.
+func (m *MockUserModuleInterface) Logout(ctx context.Context, token *entity.RevokedToken, refreshToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, token, refreshToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockUserModuleInterfaceMockRecorder) Logout(ctx, token, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockUserModuleInterface)(nil).Logout), ctx, token, refreshToken)
+}
+
+// LogoutAll mocks base method.
+func (m *MockUserModuleInterface) LogoutAll(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogoutAll", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LogoutAll indicates an expected call of LogoutAll.
+func (mr *MockUserModuleInterfaceMockRecorder) LogoutAll(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogoutAll", reflect.TypeOf((*MockUserModuleInterface)(nil).LogoutAll), ctx, userID)
+}
+
+// RedeemMagicLink mocks base method.
+func (m *MockUserModuleInterface) RedeemMagicLink(ctx context.Context, token string) (entity.LoginModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemMagicLink", ctx, token)
+	ret0, _ := ret[0].(entity.LoginModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RedeemMagicLink indicates an expected call of RedeemMagicLink.
+func (mr *MockUserModuleInterfaceMockRecorder) RedeemMagicLink(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemMagicLink", reflect.TypeOf((*MockUserModuleInterface)(nil).RedeemMagicLink), ctx, token)
+}
+
+// RefreshToken mocks base method.
+func (m *MockUserModuleInterface) RefreshToken(ctx context.Context, refreshToken string) (entity.LoginModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshToken", ctx, refreshToken)
+	ret0, _ := ret[0].(entity.LoginModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshToken indicates an expected call of RefreshToken.
+func (mr *MockUserModuleInterfaceMockRecorder) RefreshToken(ctx, refreshToken interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserModuleInterface)(nil).Login), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshToken", reflect.TypeOf((*MockUserModuleInterface)(nil).RefreshToken), ctx, refreshToken)
 }
 
 // Register mocks base method.
@@ -80,6 +420,134 @@ func (mr *MockUserModuleInterfaceMockRecorder) Register(ctx, user interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockUserModuleInterface)(nil).Register), ctx, user)
 }
 
+// RequestActivation mocks base method.
+func (m *MockUserModuleInterface) RequestActivation(ctx context.Context, phoneNumber string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestActivation", ctx, phoneNumber)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestActivation indicates an expected call of RequestActivation.
+func (mr *MockUserModuleInterfaceMockRecorder) RequestActivation(ctx, phoneNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestActivation", reflect.TypeOf((*MockUserModuleInterface)(nil).RequestActivation), ctx, phoneNumber)
+}
+
+// RequestEmailVerification mocks base method.
+func (m *MockUserModuleInterface) RequestEmailVerification(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestEmailVerification", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestEmailVerification indicates an expected call of RequestEmailVerification.
+func (mr *MockUserModuleInterfaceMockRecorder) RequestEmailVerification(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestEmailVerification", reflect.TypeOf((*MockUserModuleInterface)(nil).RequestEmailVerification), ctx, userID)
+}
+
+// RequestPhoneVerification mocks base method.
+func (m *MockUserModuleInterface) RequestPhoneVerification(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestPhoneVerification", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestPhoneVerification indicates an expected call of RequestPhoneVerification.
+func (mr *MockUserModuleInterfaceMockRecorder) RequestPhoneVerification(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestPhoneVerification", reflect.TypeOf((*MockUserModuleInterface)(nil).RequestPhoneVerification), ctx, userID)
+}
+
+// ResetPassword mocks base method.
+func (m *MockUserModuleInterface) ResetPassword(ctx context.Context, phoneNumber, code, newPassword string) (entity.ResetPasswordModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, phoneNumber, code, newPassword)
+	ret0, _ := ret[0].(entity.ResetPasswordModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockUserModuleInterfaceMockRecorder) ResetPassword(ctx, phoneNumber, code, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockUserModuleInterface)(nil).ResetPassword), ctx, phoneNumber, code, newPassword)
+}
+
+// StartFederatedLogin mocks base method.
+func (m *MockUserModuleInterface) StartFederatedLogin(ctx context.Context, provider string, userID int) (entity.StartFederatedLoginModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartFederatedLogin", ctx, provider, userID)
+	ret0, _ := ret[0].(entity.StartFederatedLoginModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartFederatedLogin indicates an expected call of StartFederatedLogin.
+func (mr *MockUserModuleInterfaceMockRecorder) StartFederatedLogin(ctx, provider, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartFederatedLogin", reflect.TypeOf((*MockUserModuleInterface)(nil).StartFederatedLogin), ctx, provider, userID)
+}
+
+// StartMagicLinkLogin mocks base method.
+func (m *MockUserModuleInterface) StartMagicLinkLogin(ctx context.Context, email, clientIP string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartMagicLinkLogin", ctx, email, clientIP)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartMagicLinkLogin indicates an expected call of StartMagicLinkLogin.
+func (mr *MockUserModuleInterfaceMockRecorder) StartMagicLinkLogin(ctx, email, clientIP interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartMagicLinkLogin", reflect.TypeOf((*MockUserModuleInterface)(nil).StartMagicLinkLogin), ctx, email, clientIP)
+}
+
+// StartOTPLogin mocks base method.
+func (m *MockUserModuleInterface) StartOTPLogin(ctx context.Context, phoneNumber, clientIP string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartOTPLogin", ctx, phoneNumber, clientIP)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartOTPLogin indicates an expected call of StartOTPLogin.
+func (mr *MockUserModuleInterfaceMockRecorder) StartOTPLogin(ctx, phoneNumber, clientIP interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartOTPLogin", reflect.TypeOf((*MockUserModuleInterface)(nil).StartOTPLogin), ctx, phoneNumber, clientIP)
+}
+
+// UnlinkFederatedIdentity mocks base method.
+func (m *MockUserModuleInterface) UnlinkFederatedIdentity(ctx context.Context, userID, identityID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlinkFederatedIdentity", ctx, userID, identityID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlinkFederatedIdentity indicates an expected call of UnlinkFederatedIdentity.
+func (mr *MockUserModuleInterfaceMockRecorder) UnlinkFederatedIdentity(ctx, userID, identityID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkFederatedIdentity", reflect.TypeOf((*MockUserModuleInterface)(nil).UnlinkFederatedIdentity), ctx, userID, identityID)
+}
+
+// UnlockUser mocks base method.
+func (m *MockUserModuleInterface) UnlockUser(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockUser indicates an expected call of UnlockUser.
+func (mr *MockUserModuleInterfaceMockRecorder) UnlockUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockUser", reflect.TypeOf((*MockUserModuleInterface)(nil).UnlockUser), ctx, userID)
+}
+
 // UpdateProfile mocks base method.
 func (m *MockUserModuleInterface) UpdateProfile(ctx context.Context, user *entity.User) (entity.UpdateProfileModuleResponse, error) {
 	m.ctrl.T.Helper()
@@ -94,3 +562,18 @@ func (mr *MockUserModuleInterfaceMockRecorder) UpdateProfile(ctx, user interface
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateProfile", reflect.TypeOf((*MockUserModuleInterface)(nil).UpdateProfile), ctx, user)
 }
+
+// VerifyOTPLogin mocks base method.
+func (m *MockUserModuleInterface) VerifyOTPLogin(ctx context.Context, phoneNumber, code, clientIP string) (entity.LoginModuleResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyOTPLogin", ctx, phoneNumber, code, clientIP)
+	ret0, _ := ret[0].(entity.LoginModuleResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyOTPLogin indicates an expected call of VerifyOTPLogin.
+func (mr *MockUserModuleInterfaceMockRecorder) VerifyOTPLogin(ctx, phoneNumber, code, clientIP interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyOTPLogin", reflect.TypeOf((*MockUserModuleInterface)(nil).VerifyOTPLogin), ctx, phoneNumber, code, clientIP)
+}
@@ -0,0 +1,230 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+const (
+	// DefaultListUsersLimit is the page size when admin does not ask for one.
+	DefaultListUsersLimit = 20
+	// MaxListUsersLimit caps the page size to keep the query cheap.
+	MaxListUsersLimit = 100
+	// defaultListUsersSort shows the newest users first.
+	defaultListUsersSort = "-" + entity.UserSortCreatedAt
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrSelfManagement prevents admin from locking themselves out.
+	ErrSelfManagement = errors.New("admin cannot change the status of their own account")
+	// ErrInvalidStatusTransition is returned when a user cannot move to the requested status.
+	ErrInvalidStatusTransition = errors.New("cannot change user status")
+)
+
+// ListUsers returns a page of users matching the request along with the cursor of the next page.
+func (m *UserModule) ListUsers(ctx context.Context, req entity.ListUsersRequest) (entity.ListUsersModuleResponse, error) {
+	var (
+		resp = entity.ListUsersModuleResponse{
+			Users:    []*entity.User{},
+			Valid:    true,
+			Messages: []string{},
+		}
+		filter = entity.ListUsersFilter{
+			Status:        req.Status,
+			Name:          req.Name,
+			CreatedAfter:  req.CreatedAfter,
+			CreatedBefore: req.CreatedBefore,
+			Limit:         req.Limit,
+		}
+		err error
+	)
+
+	// validate request
+	if filter.Limit == 0 {
+		filter.Limit = DefaultListUsersLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxListUsersLimit {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, "limit must be between 1 and 100")
+	}
+
+	sort := req.Sort
+	if sort == "" {
+		sort = defaultListUsersSort
+	}
+	filter.Descending = strings.HasPrefix(sort, "-")
+	filter.SortField = strings.TrimPrefix(sort, "-")
+	switch filter.SortField {
+	case entity.UserSortID, entity.UserSortCreatedAt, entity.UserSortFullname:
+	default:
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, "sort must be one of id, created_at, fullname, optionally prefixed with -")
+	}
+
+	// phone numbers are stored in E.164 format, so the prefix starts with the country code and + is optional
+	phonePrefix := strings.TrimPrefix(req.PhonePrefix, "+")
+	for _, c := range phonePrefix {
+		if c < '0' || c > '9' {
+			resp.Valid = false
+			resp.Messages = append(resp.Messages, "phone prefix must be numeric")
+			break
+		}
+	}
+	if phonePrefix != "" {
+		filter.PhonePrefix = "+" + phonePrefix
+	}
+
+	switch filter.Status {
+	case "", entity.UserStatusPending, entity.UserStatusActive, entity.UserStatusSuspended, entity.UserStatusDeleted:
+	default:
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, "status must be one of pending, active, suspended, deleted")
+	}
+
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && !filter.CreatedAfter.Before(filter.CreatedBefore) {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, "created after must be earlier than created before")
+	}
+
+	if req.Cursor != "" {
+		filter.After, err = decodeUserCursor(req.Cursor)
+		if err != nil || filter.After.Sort != sort {
+			// a cursor is only meaningful for the sort order it was issued with
+			resp.Valid = false
+			resp.Messages = append(resp.Messages, "cursor is not valid")
+		}
+	}
+
+	if !resp.Valid {
+		return resp, nil
+	}
+
+	// fetch one more user to know whether there is a next page
+	limit := filter.Limit
+	filter.Limit++
+	resp.Users, err = m.userRepository.ListUsers(ctx, filter)
+	if err != nil {
+		return resp, err
+	}
+
+	if len(resp.Users) > limit {
+		resp.Users = resp.Users[:limit]
+		resp.NextCursor, err = encodeUserCursor(sort, resp.Users[limit-1])
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
+
+// GetUser returns any user by id regardless of their status.
+func (m *UserModule) GetUser(ctx context.Context, userID int) (*entity.User, error) {
+	user, err := m.userRepository.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Exist() {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// ChangeUserStatus moves a user to the given status following userStatusTransitions.
+// Sessions of a user who can no longer log in are ended right away.
+func (m *UserModule) ChangeUserStatus(ctx context.Context, adminID, userID int, status string) error {
+	if adminID == userID {
+		return ErrSelfManagement
+	}
+
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !canChangeUserStatus(user.Status, status) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidStatusTransition, user.Status, status)
+	}
+
+	var changed bool
+	changed, err = m.userRepository.UpdateUserStatus(ctx, userID, user.Status, status)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		// another request changed the status in between
+		return fmt.Errorf("%w, status has just been changed", ErrInvalidStatusTransition)
+	}
+
+	if status == entity.UserStatusSuspended || status == entity.UserStatusDeleted {
+		return m.LogoutAll(ctx, userID)
+	}
+
+	return nil
+}
+
+// userStatusTransitions lists the statuses a user can move to from each status, deleted is final.
+var userStatusTransitions = map[string][]string{
+	entity.UserStatusPending:   {entity.UserStatusActive, entity.UserStatusDeleted},
+	entity.UserStatusActive:    {entity.UserStatusSuspended, entity.UserStatusDeleted},
+	entity.UserStatusSuspended: {entity.UserStatusActive, entity.UserStatusDeleted},
+}
+
+func canChangeUserStatus(from, to string) bool {
+	for _, status := range userStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeUserCursor returns an opaque cursor pointing at user for the given sort order.
+func encodeUserCursor(sort string, user *entity.User) (string, error) {
+	cursor := entity.UserCursor{
+		Sort: sort,
+		ID:   user.ID,
+	}
+	switch strings.TrimPrefix(sort, "-") {
+	case entity.UserSortCreatedAt:
+		cursor.CreatedAt = user.CreatedAt
+	case entity.UserSortFullname:
+		cursor.Fullname = user.Fullname
+	}
+
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeUserCursor parses a cursor returned by encodeUserCursor.
+func decodeUserCursor(s string) (*entity.UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := &entity.UserCursor{}
+	err = json.Unmarshal(b, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return cursor, nil
+}
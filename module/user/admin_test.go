@@ -0,0 +1,381 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_ListUsers(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	createdAt := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	createdAtCursor, _ := encodeUserCursor("-created_at", &entity.User{ID: 2, CreatedAt: createdAt})
+	fullnameCursor, _ := encodeUserCursor("fullname", &entity.User{ID: 2, Fullname: "Jane Doe"})
+	tests := []struct {
+		name        string
+		req         entity.ListUsersRequest
+		prepareRepo func(m *repository.MockUserRepositoryInterface)
+		want        entity.ListUsersModuleResponse
+		wantErr     bool
+	}{
+		{
+			name: "invalid request",
+			req: entity.ListUsersRequest{
+				PhonePrefix:   "+62-812",
+				Status:        "banned",
+				CreatedAfter:  createdAt,
+				CreatedBefore: createdAt,
+				Sort:          "password",
+				Cursor:        "not a cursor",
+				Limit:         101,
+			},
+			want: entity.ListUsersModuleResponse{
+				Users: []*entity.User{},
+				Valid: false,
+				Messages: []string{
+					"limit must be between 1 and 100",
+					"sort must be one of id, created_at, fullname, optionally prefixed with -",
+					"phone prefix must be numeric",
+					"status must be one of pending, active, suspended, deleted",
+					"created after must be earlier than created before",
+					"cursor is not valid",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cursor of another sort order",
+			req: entity.ListUsersRequest{
+				Sort:   "created_at",
+				Cursor: createdAtCursor,
+			},
+			want: entity.ListUsersModuleResponse{
+				Users:    []*entity.User{},
+				Valid:    false,
+				Messages: []string{"cursor is not valid"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error list users",
+			req:  entity.ListUsersRequest{},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().ListUsers(ctx, entity.ListUsersFilter{
+					SortField:  entity.UserSortCreatedAt,
+					Descending: true,
+					Limit:      21,
+				}).Return(nil, assert.AnError)
+			},
+			want: entity.ListUsersModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "last page",
+			req: entity.ListUsersRequest{
+				PhonePrefix: "62812",
+				Status:      entity.UserStatusActive,
+				Name:        "doe",
+				Sort:        "-created_at",
+				Cursor:      createdAtCursor,
+				Limit:       2,
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().ListUsers(ctx, entity.ListUsersFilter{
+					PhonePrefix: "+62812",
+					Status:      entity.UserStatusActive,
+					Name:        "doe",
+					SortField:   entity.UserSortCreatedAt,
+					Descending:  true,
+					After: &entity.UserCursor{
+						Sort:      "-created_at",
+						ID:        2,
+						CreatedAt: createdAt,
+					},
+					Limit: 3,
+				}).Return([]*entity.User{
+					{ID: 1, Fullname: "John Doe"},
+				}, nil)
+			},
+			want: entity.ListUsersModuleResponse{
+				Users: []*entity.User{
+					{ID: 1, Fullname: "John Doe"},
+				},
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "has next page",
+			req: entity.ListUsersRequest{
+				Sort:  "fullname",
+				Limit: 1,
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().ListUsers(ctx, entity.ListUsersFilter{
+					SortField: entity.UserSortFullname,
+					Limit:     2,
+				}).Return([]*entity.User{
+					{ID: 2, Fullname: "Jane Doe"},
+					{ID: 1, Fullname: "John Doe"},
+				}, nil)
+			},
+			want: entity.ListUsersModuleResponse{
+				Users: []*entity.User{
+					{ID: 2, Fullname: "Jane Doe"},
+				},
+				NextCursor: fullnameCursor,
+				Valid:      true,
+				Messages:   []string{},
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockRepo)
+			}
+			m.userRepository = mockRepo
+
+			got, err := m.ListUsers(ctx, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_GetUser(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	tests := []struct {
+		name        string
+		userID      int
+		prepareRepo func(m *repository.MockUserRepositoryInterface)
+		want        *entity.User
+		wantErr     error
+	}{
+		{
+			name:   "user not found",
+			userID: 15,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:   "user is empty",
+			userID: 15,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{}, nil)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:   "error get user",
+			userID: 15,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "success",
+			userID: 15,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+				}, nil)
+			},
+			want: &entity.User{
+				ID:       15,
+				Fullname: "John Doe",
+			},
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockRepo)
+			}
+			m.userRepository = mockRepo
+
+			got, err := m.GetUser(ctx, tt.userID)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_ChangeUserStatus(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	tests := []struct {
+		name                    string
+		adminID                 int
+		userID                  int
+		status                  string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		wantErr                 error
+	}{
+		{
+			name:    "change own status",
+			adminID: 1,
+			userID:  1,
+			status:  entity.UserStatusSuspended,
+			wantErr: ErrSelfManagement,
+		},
+		{
+			name:    "user not found",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusSuspended,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name:    "deleted user is final",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusActive,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusDeleted}, nil)
+			},
+			wantErr: ErrInvalidStatusTransition,
+		},
+		{
+			name:    "pending user cannot be suspended",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusSuspended,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusPending}, nil)
+			},
+			wantErr: ErrInvalidStatusTransition,
+		},
+		{
+			name:    "error update status",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusSuspended,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusActive}, nil)
+				m.EXPECT().UpdateUserStatus(ctx, 15, entity.UserStatusActive, entity.UserStatusSuspended).Return(false, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:    "status changed concurrently",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusSuspended,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusActive}, nil)
+				m.EXPECT().UpdateUserStatus(ctx, 15, entity.UserStatusActive, entity.UserStatusSuspended).Return(false, nil)
+			},
+			wantErr: ErrInvalidStatusTransition,
+		},
+		{
+			name:    "error revoke sessions",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusSuspended,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusActive}, nil)
+				m.EXPECT().UpdateUserStatus(ctx, 15, entity.UserStatusActive, entity.UserStatusSuspended).Return(true, nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:    "success delete revokes sessions",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusDeleted,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusSuspended}, nil)
+				m.EXPECT().UpdateUserStatus(ctx, 15, entity.UserStatusSuspended, entity.UserStatusDeleted).Return(true, nil)
+				m.EXPECT().IncrementTokenVersion(ctx, 15).Return(nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(nil)
+			},
+		},
+		{
+			name:    "success activate",
+			adminID: 1,
+			userID:  15,
+			status:  entity.UserStatusActive,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{ID: 15, Status: entity.UserStatusPending}, nil)
+				m.EXPECT().UpdateUserStatus(ctx, 15, entity.UserStatusPending, entity.UserStatusActive).Return(true, nil)
+			},
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockRepo)
+			}
+			m.userRepository = mockRepo
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			err := m.ChangeUserStatus(ctx, tt.adminID, tt.userID, tt.status)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUserCursor(t *testing.T) {
+	createdAt := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	s, err := encodeUserCursor("-created_at", &entity.User{
+		ID:        15,
+		Fullname:  "John Doe",
+		CreatedAt: createdAt,
+	})
+	assert.NoError(t, err)
+
+	got, err := decodeUserCursor(s)
+	assert.NoError(t, err)
+	assert.Equal(t, &entity.UserCursor{
+		Sort:      "-created_at",
+		ID:        15,
+		CreatedAt: createdAt,
+	}, got)
+
+	_, err = decodeUserCursor("!")
+	assert.Error(t, err)
+
+	_, err = decodeUserCursor("bm90IGpzb24")
+	assert.Error(t, err)
+}
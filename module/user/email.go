@@ -0,0 +1,136 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+)
+
+const (
+	// EmailVerificationTTL is how long a link sent to verify an email can be used.
+	EmailVerificationTTL = time.Hour * 24
+	// emailVerificationPurpose starts the payload of an email verification link,
+	// so a token signed for anything else is never taken for one.
+	emailVerificationPurpose = "email_verification"
+	// emailVerificationSubject is the subject of the email carrying the verification link.
+	emailVerificationSubject = "Verify your email"
+	// emailVerificationBody is the text of the email, filled with the link and its lifetime in hours.
+	emailVerificationBody = "Open this link to verify your email:\n%s\n\nIt expires in %d hours. If you did not add this email, ignore this message."
+)
+
+var (
+	// ErrNoPendingEmail is returned when user has no email waiting for verification.
+	ErrNoPendingEmail = errors.New("no email is waiting for verification")
+	// ErrEmailConflict is returned when the email has been taken by another user.
+	ErrEmailConflict = errors.New("email already exist")
+	// ErrInvalidEmailVerification obscures whether the link is tampered with, expired, used, or replaced by a newer email.
+	ErrInvalidEmailVerification = errors.New("email verification link is not valid")
+)
+
+// RequestEmailVerification sends a new link to the pending email of user.
+func (m *UserModule) RequestEmailVerification(ctx context.Context, userID int) error {
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.PendingEmail == "" {
+		return ErrNoPendingEmail
+	}
+
+	return m.sendEmailVerification(ctx, user.ID, user.PendingEmail)
+}
+
+// ConfirmEmailVerification verifies the email with the token of a link sent by sendEmailVerification,
+// returning the user and the verified email. The token identifies the user by itself, so the link works
+// on any device. The pending email replaces the current one as login identifier.
+func (m *UserModule) ConfirmEmailVerification(ctx context.Context, token string) (int, string, error) {
+	payload, err := m.signer.Verify(token)
+	if err != nil {
+		return 0, "", ErrInvalidEmailVerification
+	}
+
+	userID, email, ok := parseEmailVerificationPayload(payload)
+	if !ok {
+		return 0, "", ErrInvalidEmailVerification
+	}
+
+	// pending email might have been verified by another user in the meantime
+	var owner *entity.User
+	owner, err = m.userRepository.GetUserByEmail(ctx, email)
+	if err == nil && owner.Exist() && owner.ID != userID {
+		return 0, "", ErrEmailConflict
+	}
+
+	var verified bool
+	verified, err = m.userRepository.VerifyEmail(ctx, userID, email)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// verified by another user between the check above and now
+		return 0, "", ErrEmailConflict
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if !verified {
+		// link has been used, or user changed the pending email after it was sent
+		return 0, "", ErrInvalidEmailVerification
+	}
+
+	return userID, email, nil
+}
+
+// sendEmailVerification sends a link proving user owns the email.
+// Nothing is stored, the link stays valid until it expires or the pending email changes.
+func (m *UserModule) sendEmailVerification(ctx context.Context, userID int, email string) error {
+	token, err := m.signer.Sign(emailVerificationPayload(userID, email), m.timeNow().Add(EmailVerificationTTL))
+	if err != nil {
+		return err
+	}
+
+	var link *url.URL
+	link, err = url.Parse(m.emailVerificationURL)
+	if err != nil {
+		return err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	body := fmt.Sprintf(emailVerificationBody, link.String(), int(EmailVerificationTTL.Hours()))
+	return m.emailSender.Send(ctx, email, emailVerificationSubject, body)
+}
+
+// emailVerificationPayload returns what the link of an email verification carries, like email_verification:1:john@example.com.
+func emailVerificationPayload(userID int, email string) string {
+	return emailVerificationPurpose + ":" + strconv.Itoa(userID) + ":" + email
+}
+
+// parseEmailVerificationPayload returns the user and the email carried by emailVerificationPayload.
+func parseEmailVerificationPayload(payload string) (int, string, bool) {
+	parts := strings.SplitN(payload, ":", 3)
+	if len(parts) != 3 || parts[0] != emailVerificationPurpose || parts[2] == "" {
+		return 0, "", false
+	}
+
+	userID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, "", false
+	}
+
+	return userID, parts[2], true
+}
+
+func (m *UserModule) isEmailExist(ctx context.Context, email string) bool {
+	user, err := m.userRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return false
+	}
+	return user.Exist()
+}
@@ -0,0 +1,277 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_RequestEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		emailVerificationURL: "https://portal.example.com/verify-email?lang=id",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name          string
+		prepareRepo   func(m *repository.MockUserRepositoryInterface)
+		prepareSigner func(m *tools.MockSignerInterface)
+		prepareEmail  func(m *tools.MockEmailSenderInterface)
+		wantErr       error
+	}{
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "no pending email",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:    15,
+					Email: "john@example.com",
+				}, nil)
+			},
+			wantErr: ErrNoPendingEmail,
+		},
+		{
+			name: "error sign",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:           15,
+					PendingEmail: "john@example.com",
+				}, nil)
+			},
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Sign("email_verification:15:john@example.com", now.Add(EmailVerificationTTL)).Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error send",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:           15,
+					PendingEmail: "john@example.com",
+				}, nil)
+			},
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Sign("email_verification:15:john@example.com", now.Add(EmailVerificationTTL)).Return("signed.token", nil)
+			},
+			prepareEmail: func(m *tools.MockEmailSenderInterface) {
+				m.EXPECT().Send(ctx, "john@example.com", gomock.Any(), gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					PendingEmail: "john.doe@example.com",
+				}, nil)
+			},
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Sign("email_verification:15:john.doe@example.com", now.Add(EmailVerificationTTL)).Return("signed.token", nil)
+			},
+			prepareEmail: func(m *tools.MockEmailSenderInterface) {
+				m.EXPECT().Send(ctx, "john.doe@example.com", "Verify your email",
+					"Open this link to verify your email:\nhttps://portal.example.com/verify-email?lang=id&token=signed.token\n\n"+
+						"It expires in 24 hours. If you did not add this email, ignore this message.").Return(nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockSigner := tools.NewMockSignerInterface(ctrl)
+	mockEmail := tools.NewMockEmailSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareSigner != nil {
+				tt.prepareSigner(mockSigner)
+			}
+			m.signer = mockSigner
+
+			if tt.prepareEmail != nil {
+				tt.prepareEmail(mockEmail)
+			}
+			m.emailSender = mockEmail
+
+			err := m.RequestEmailVerification(ctx, 15)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestUserModule_ConfirmEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	tests := []struct {
+		name          string
+		prepareRepo   func(m *repository.MockUserRepositoryInterface)
+		prepareSigner func(m *tools.MockSignerInterface)
+		wantUserID    int
+		wantEmail     string
+		wantErr       error
+	}{
+		{
+			name: "invalid token",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("", assert.AnError)
+			},
+			wantErr: ErrInvalidEmailVerification,
+		},
+		{
+			name: "token signed for another purpose",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("magic_link:15:john@example.com", nil)
+			},
+			wantErr: ErrInvalidEmailVerification,
+		},
+		{
+			name: "email verified by another user",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("email_verification:15:john@example.com", nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:    16,
+					Email: "john@example.com",
+				}, nil)
+			},
+			wantErr: ErrEmailConflict,
+		},
+		{
+			name: "error verify email",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("email_verification:15:john@example.com", nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+				m.EXPECT().VerifyEmail(ctx, 15, "john@example.com").Return(false, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "email verified by another user at the same time",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("email_verification:15:john@example.com", nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+				m.EXPECT().VerifyEmail(ctx, 15, "john@example.com").Return(false, repository.ErrDuplicate)
+			},
+			wantErr: ErrEmailConflict,
+		},
+		{
+			name: "link has been used",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("email_verification:15:john@example.com", nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:    15,
+					Email: "john@example.com",
+				}, nil)
+				m.EXPECT().VerifyEmail(ctx, 15, "john@example.com").Return(false, nil)
+			},
+			wantErr: ErrInvalidEmailVerification,
+		},
+		{
+			name: "success",
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Verify("signed.token").Return("email_verification:15:john@example.com", nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+				m.EXPECT().VerifyEmail(ctx, 15, "john@example.com").Return(true, nil)
+			},
+			wantUserID: 15,
+			wantEmail:  "john@example.com",
+			wantErr:    nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockSigner := tools.NewMockSignerInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareSigner != nil {
+				tt.prepareSigner(mockSigner)
+			}
+			m.signer = mockSigner
+
+			gotUserID, gotEmail, err := m.ConfirmEmailVerification(ctx, "signed.token")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantUserID, gotUserID)
+			assert.Equal(t, tt.wantEmail, gotEmail)
+		})
+	}
+}
+
+func TestParseEmailVerificationPayload(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		wantUserID int
+		wantEmail  string
+		wantOK     bool
+	}{
+		{
+			name:    "missing email",
+			payload: "email_verification:15",
+			wantOK:  false,
+		},
+		{
+			name:    "another purpose",
+			payload: "password_reset:15:john@example.com",
+			wantOK:  false,
+		},
+		{
+			name:    "user id is not a number",
+			payload: "email_verification:john:john@example.com",
+			wantOK:  false,
+		},
+		{
+			name:       "email with colon",
+			payload:    emailVerificationPayload(15, `"john:doe"@example.com`),
+			wantUserID: 15,
+			wantEmail:  `"john:doe"@example.com`,
+			wantOK:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, gotEmail, gotOK := parseEmailVerificationPayload(tt.payload)
+			assert.Equal(t, tt.wantOK, gotOK)
+			assert.Equal(t, tt.wantUserID, gotUserID)
+			assert.Equal(t, tt.wantEmail, gotEmail)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+)
+
+const (
+	// FederatedLoginTTL is how long user has to log in at the provider and come back.
+	FederatedLoginTTL = time.Minute * 10
+	// federatedTokenSize is the number of random bytes of the state, the nonce, and the PKCE verifier.
+	// 32 bytes make a verifier of 43 characters, the shortest RFC 7636 allows.
+	federatedTokenSize = 32
+)
+
+var (
+	// ErrUnknownIdentityProvider is returned for a provider that is not configured.
+	ErrUnknownIdentityProvider = errors.New("identity provider is not supported")
+	// ErrInvalidFederatedLogin obscures whether the state is unknown, expired, or used, the provider refused the code,
+	// or the account belongs to a user who cannot log in.
+	ErrInvalidFederatedLogin = errors.New("login with identity provider is not valid")
+	// ErrFederatedIdentityNotLinked is returned when nobody has linked the account of the provider from their profile.
+	// Accounts are never matched to users by email, the provider may not have verified it.
+	ErrFederatedIdentityNotLinked = errors.New("account of identity provider is not linked to any user")
+	// ErrFederatedIdentityConflict is returned when the account of the provider is already linked to another user.
+	ErrFederatedIdentityConflict = errors.New("account of identity provider is already linked to another user")
+	// ErrFederatedIdentityNotFound is returned when the user has no linked account with the id.
+	ErrFederatedIdentityNotFound = errors.New("linked account not found")
+)
+
+// StartFederatedLogin returns the login page of the provider, which sends user back to its redirect url
+// with the state and a code for CompleteFederatedLogin, or for LinkFederatedIdentity when user id is not zero.
+// The state is bound to what it was started for, a state of logging in cannot link an account and vice versa.
+func (m *UserModule) StartFederatedLogin(ctx context.Context, provider string, userID int) (entity.StartFederatedLoginModuleResponse, error) {
+	var resp entity.StartFederatedLoginModuleResponse
+
+	identityProvider, ok := m.identityProviders[provider]
+	if !ok {
+		return resp, ErrUnknownIdentityProvider
+	}
+
+	state, err := m.random.Token(federatedTokenSize)
+	if err != nil {
+		return resp, err
+	}
+
+	var nonce string
+	nonce, err = m.random.Token(federatedTokenSize)
+	if err != nil {
+		return resp, err
+	}
+
+	// the verifier never leaves this service until the code is redeemed, an intercepted code is useless without it
+	var codeVerifier string
+	codeVerifier, err = m.random.Token(federatedTokenSize)
+	if err != nil {
+		return resp, err
+	}
+
+	err = m.federationRepository.DeleteExpiredLoginRequests(ctx, m.timeNow())
+	if err != nil {
+		return resp, err
+	}
+
+	_, err = m.federationRepository.InsertLoginRequest(ctx, &entity.FederatedLoginRequest{
+		StateHash:    crxpto.SHA256(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		UserID:       userID,
+		ExpiresAt:    m.timeNow().Add(FederatedLoginTTL),
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	resp.RedirectTo, err = identityProvider.AuthCodeURL(ctx, state, nonce, codeChallengeS256(codeVerifier))
+	if err != nil {
+		return resp, err
+	}
+
+	resp.State = state
+	return resp, nil
+}
+
+// CompleteFederatedLogin logs in the user the account of the provider is linked to, with the state
+// and the code the provider sent user back with. It gives the same jwt and refresh token as Login,
+// or a challenge token when user has two-factor authentication.
+func (m *UserModule) CompleteFederatedLogin(ctx context.Context, state, code string) (entity.LoginModuleResponse, error) {
+	var resp entity.LoginModuleResponse
+
+	_, claims, err := m.redeemFederatedLogin(ctx, 0, state, code)
+	if err != nil {
+		return resp, err
+	}
+
+	var identity *entity.FederatedIdentity
+	identity, err = m.federationRepository.GetIdentity(ctx, claims.Issuer, claims.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return resp, ErrFederatedIdentityNotLinked
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	resp.User, err = m.GetUser(ctx, identity.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return resp, ErrInvalidFederatedLogin
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	err = entity.CheckUserStatus(resp.User.Status)
+	if err != nil {
+		log.Printf("complete federated login: refuse user %d: %v", resp.User.ID, err)
+		return resp, entity.Obscure(ErrInvalidFederatedLogin, err)
+	}
+
+	err = m.completeLogin(ctx, &resp)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// LinkFederatedIdentity links the account of the provider to the user who started linking it,
+// with the state and the code the provider sent user back with. Linking an account again is not an error.
+func (m *UserModule) LinkFederatedIdentity(ctx context.Context, userID int, state, code string) (*entity.FederatedIdentity, error) {
+	req, claims, err := m.redeemFederatedLogin(ctx, userID, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var identity *entity.FederatedIdentity
+	identity, err = m.federationRepository.GetIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		if identity.UserID != userID {
+			return nil, ErrFederatedIdentityConflict
+		}
+		return identity, nil
+	}
+
+	identity = &entity.FederatedIdentity{
+		UserID:   userID,
+		Provider: req.Provider,
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	_, err = m.federationRepository.InsertIdentity(ctx, identity)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		return identity, nil
+	}
+
+	// linked by a request running at the same time, which may have been for another user
+	identity, err = m.federationRepository.GetIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity.UserID != userID {
+		return nil, ErrFederatedIdentityConflict
+	}
+
+	return identity, nil
+}
+
+// ListFederatedIdentities returns every account of a provider the user has linked.
+func (m *UserModule) ListFederatedIdentities(ctx context.Context, userID int) ([]*entity.FederatedIdentity, error) {
+	return m.federationRepository.GetIdentitiesByUserID(ctx, userID)
+}
+
+// UnlinkFederatedIdentity stops the account of a provider from logging in as the user.
+func (m *UserModule) UnlinkFederatedIdentity(ctx context.Context, userID, identityID int) error {
+	deleted, err := m.federationRepository.DeleteIdentity(ctx, userID, identityID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFederatedIdentityNotFound
+	}
+
+	return nil
+}
+
+// redeemFederatedLogin consumes the login request of the state, which must have been started by the user id,
+// and exchanges the code for the verified claims of the account at the provider.
+func (m *UserModule) redeemFederatedLogin(ctx context.Context, userID int, state, code string) (*entity.FederatedLoginRequest, *tools.IdentityClaims, error) {
+	// the request is consumed before anything else, a state never gets a second try
+	req, err := m.federationRepository.ConsumeLoginRequest(ctx, crxpto.SHA256(state))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if req.UserID != userID || req.Expired(m.timeNow()) {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+
+	// the provider may have been removed from configuration since the login started
+	identityProvider, ok := m.identityProviders[req.Provider]
+	if !ok {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+
+	var claims *tools.IdentityClaims
+	claims, err = identityProvider.Exchange(ctx, code, req.CodeVerifier)
+	if err != nil {
+		log.Printf("redeem federated login: exchange code with %s for user %d: %v", req.Provider, req.UserID, err)
+		return nil, nil, entity.Obscure(ErrInvalidFederatedLogin, err)
+	}
+
+	// an id token of another login replayed into this one does not carry its nonce
+	if claims.Nonce != req.Nonce {
+		return nil, nil, ErrInvalidFederatedLogin
+	}
+
+	return req, claims, nil
+}
@@ -0,0 +1,812 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_StartFederatedLogin(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	verifier := "dBjftJeZ4CVP-mJ92K1Ugwq6Ap68KTxmCNbx9N3T4Ya"
+	challenge := "0-jU3mxuhm2_pFNlPMSCemHaRsAaJYfGoWAEFO_aVIQ"
+	loginRequest := func() *entity.FederatedLoginRequest {
+		return &entity.FederatedLoginRequest{
+			StateHash:    crxpto.SHA256("some-state"),
+			Provider:     "google",
+			Nonce:        "some-nonce",
+			CodeVerifier: verifier,
+			UserID:       15,
+			ExpiresAt:    now.Add(FederatedLoginTTL),
+		}
+	}
+	tests := []struct {
+		name                  string
+		provider              string
+		prepareRandom         func(m *tools.MockRandomInterface)
+		prepareFederationRepo func(m *repository.MockFederationRepositoryInterface)
+		prepareProvider       func(m *tools.MockIdentityProviderInterface)
+		want                  entity.StartFederatedLoginModuleResponse
+		wantErr               error
+	}{
+		{
+			name:     "unknown provider",
+			provider: "facebook",
+			wantErr:  ErrUnknownIdentityProvider,
+		},
+		{
+			name:     "error generate state",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:     "error generate nonce",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("some-state", nil)
+				m.EXPECT().Token(32).Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:     "error generate code verifier",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("some-state", nil)
+				m.EXPECT().Token(32).Return("some-nonce", nil)
+				m.EXPECT().Token(32).Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:     "error delete expired login requests",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("some-state", nil)
+				m.EXPECT().Token(32).Return("some-nonce", nil)
+				m.EXPECT().Token(32).Return(verifier, nil)
+			},
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredLoginRequests(ctx, now).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:     "error insert login request",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("some-state", nil)
+				m.EXPECT().Token(32).Return("some-nonce", nil)
+				m.EXPECT().Token(32).Return(verifier, nil)
+			},
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredLoginRequests(ctx, now).Return(nil)
+				m.EXPECT().InsertLoginRequest(ctx, loginRequest()).Return(0, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:     "error auth code url",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("some-state", nil)
+				m.EXPECT().Token(32).Return("some-nonce", nil)
+				m.EXPECT().Token(32).Return(verifier, nil)
+			},
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredLoginRequests(ctx, now).Return(nil)
+				m.EXPECT().InsertLoginRequest(ctx, loginRequest()).Return(3, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().AuthCodeURL(ctx, "some-state", "some-nonce", challenge).Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:     "success",
+			provider: "google",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("some-state", nil)
+				m.EXPECT().Token(32).Return("some-nonce", nil)
+				m.EXPECT().Token(32).Return(verifier, nil)
+			},
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredLoginRequests(ctx, now).Return(nil)
+				m.EXPECT().InsertLoginRequest(ctx, loginRequest()).Return(3, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().AuthCodeURL(ctx, "some-state", "some-nonce", challenge).Return("https://accounts.google.com/o/oauth2/v2/auth?state=some-state", nil)
+			},
+			want: entity.StartFederatedLoginModuleResponse{
+				RedirectTo: "https://accounts.google.com/o/oauth2/v2/auth?state=some-state",
+				State:      "some-state",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockFederationRepo := repository.NewMockFederationRepositoryInterface(ctrl)
+	mockProvider := tools.NewMockIdentityProviderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareFederationRepo != nil {
+				tt.prepareFederationRepo(mockFederationRepo)
+			}
+			m.federationRepository = mockFederationRepo
+
+			if tt.prepareProvider != nil {
+				tt.prepareProvider(mockProvider)
+			}
+			m.identityProviders = map[string]tools.IdentityProviderInterface{
+				"google": mockProvider,
+			}
+
+			got, err := m.StartFederatedLogin(ctx, tt.provider, 15)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_CompleteFederatedLogin(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		refreshTokenTTL: time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	loginRequest := func() *entity.FederatedLoginRequest {
+		return &entity.FederatedLoginRequest{
+			ID:           3,
+			StateHash:    crxpto.SHA256("some-state"),
+			Provider:     "google",
+			Nonce:        "some-nonce",
+			CodeVerifier: "some-verifier",
+			ExpiresAt:    now.Add(time.Minute),
+		}
+	}
+	identityClaims := func() *tools.IdentityClaims {
+		return &tools.IdentityClaims{
+			Issuer:        "https://accounts.google.com",
+			Subject:       "1076",
+			Email:         "john@example.com",
+			EmailVerified: true,
+			Nonce:         "some-nonce",
+		}
+	}
+	identity := func() *entity.FederatedIdentity {
+		return &entity.FederatedIdentity{
+			ID:       4,
+			UserID:   15,
+			Provider: "google",
+			Issuer:   "https://accounts.google.com",
+			Subject:  "1076",
+			Email:    "john@example.com",
+		}
+	}
+	activeUser := func() *entity.User {
+		return &entity.User{
+			ID:           15,
+			Status:       entity.UserStatusActive,
+			TokenVersion: 2,
+		}
+	}
+	tests := []struct {
+		name                    string
+		prepareFederationRepo   func(m *repository.MockFederationRepositoryInterface)
+		prepareProvider         func(m *tools.MockIdentityProviderInterface)
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareTwoFactorRepo    func(m *repository.MockTwoFactorRepositoryInterface)
+		want                    entity.LoginModuleResponse
+		wantErr                 error
+	}{
+		{
+			name: "unknown or used state",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "error consume login request",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "state of linking an account",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				req := loginRequest()
+				req.UserID = 15
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(req, nil)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "expired state",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				req := loginRequest()
+				req.ExpiresAt = now
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(req, nil)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "provider no longer configured",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				req := loginRequest()
+				req.Provider = "microsoft"
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(req, nil)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "error exchange",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(nil, assert.AnError)
+			},
+			wantErr: entity.Obscure(ErrInvalidFederatedLogin, assert.AnError),
+		},
+		{
+			name: "nonce of another login",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				claims := identityClaims()
+				claims.Nonce = "other-nonce"
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(claims, nil)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "account not linked",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, sql.ErrNoRows)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: ErrFederatedIdentityNotLinked,
+		},
+		{
+			name: "error get identity",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, assert.AnError)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "user not found",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(identity(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "error get user",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(identity(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "suspended user",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(identity(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := activeUser()
+				user.Status = entity.UserStatusSuspended
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:           15,
+					Status:       entity.UserStatusSuspended,
+					TokenVersion: 2,
+				},
+			},
+			wantErr: entity.Obscure(ErrInvalidFederatedLogin, entity.ErrUserSuspended),
+		},
+		{
+			name: "two-factor authentication enabled",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(identity(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("challenge-token", nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				confirmedAt := now.Add(-time.Hour)
+				m.EXPECT().GetTOTP(ctx, 15).Return(&entity.TOTP{
+					UserID:      15,
+					ConfirmedAt: &confirmedAt,
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginChallenges(ctx, now).Return(nil)
+				m.EXPECT().InsertLoginChallenge(ctx, &entity.LoginChallenge{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("challenge-token"),
+					ExpiresAt: now.Add(LoginChallengeTTL),
+				}).Return(1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User:           activeUser(),
+				ChallengeToken: "challenge-token",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(identity(), nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+				m.EXPECT().IncrementLoginCount(ctx, 15).Return(nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			want: entity.LoginModuleResponse{
+				User:         activeUser(),
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockFederationRepo := repository.NewMockFederationRepositoryInterface(ctrl)
+	mockProvider := tools.NewMockIdentityProviderInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareFederationRepo != nil {
+				tt.prepareFederationRepo(mockFederationRepo)
+			}
+			m.federationRepository = mockFederationRepo
+
+			if tt.prepareProvider != nil {
+				tt.prepareProvider(mockProvider)
+			}
+			m.identityProviders = map[string]tools.IdentityProviderInterface{
+				"google": mockProvider,
+			}
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			got, err := m.CompleteFederatedLogin(ctx, "some-state", "some-code")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_LinkFederatedIdentity(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	loginRequest := func() *entity.FederatedLoginRequest {
+		return &entity.FederatedLoginRequest{
+			ID:           3,
+			StateHash:    crxpto.SHA256("some-state"),
+			Provider:     "google",
+			Nonce:        "some-nonce",
+			CodeVerifier: "some-verifier",
+			UserID:       15,
+			ExpiresAt:    now.Add(time.Minute),
+		}
+	}
+	identityClaims := func() *tools.IdentityClaims {
+		return &tools.IdentityClaims{
+			Issuer:        "https://accounts.google.com",
+			Subject:       "1076",
+			Email:         "john@example.com",
+			EmailVerified: true,
+			Nonce:         "some-nonce",
+		}
+	}
+	tests := []struct {
+		name                  string
+		prepareFederationRepo func(m *repository.MockFederationRepositoryInterface)
+		prepareProvider       func(m *tools.MockIdentityProviderInterface)
+		want                  *entity.FederatedIdentity
+		wantErr               error
+	}{
+		{
+			name: "state of logging in",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				req := loginRequest()
+				req.UserID = 0
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(req, nil)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "state of another user",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				req := loginRequest()
+				req.UserID = 16
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(req, nil)
+			},
+			wantErr: ErrInvalidFederatedLogin,
+		},
+		{
+			name: "error get identity",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, assert.AnError)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "linked to another user",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(&entity.FederatedIdentity{
+					ID:     4,
+					UserID: 16,
+				}, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: ErrFederatedIdentityConflict,
+		},
+		{
+			name: "already linked to the user",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(&entity.FederatedIdentity{
+					ID:       4,
+					UserID:   15,
+					Provider: "google",
+					Issuer:   "https://accounts.google.com",
+					Subject:  "1076",
+					Email:    "john@example.com",
+				}, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			want: &entity.FederatedIdentity{
+				ID:       4,
+				UserID:   15,
+				Provider: "google",
+				Issuer:   "https://accounts.google.com",
+				Subject:  "1076",
+				Email:    "john@example.com",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "error insert identity",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, sql.ErrNoRows)
+				m.EXPECT().InsertIdentity(ctx, &entity.FederatedIdentity{
+					UserID:   15,
+					Provider: "google",
+					Issuer:   "https://accounts.google.com",
+					Subject:  "1076",
+					Email:    "john@example.com",
+				}).Return(0, assert.AnError)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error get identity linked at the same time",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, sql.ErrNoRows)
+				m.EXPECT().InsertIdentity(ctx, &entity.FederatedIdentity{
+					UserID:   15,
+					Provider: "google",
+					Issuer:   "https://accounts.google.com",
+					Subject:  "1076",
+					Email:    "john@example.com",
+				}).Return(0, sql.ErrNoRows)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, assert.AnError)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "linked to another user at the same time",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, sql.ErrNoRows)
+				m.EXPECT().InsertIdentity(ctx, &entity.FederatedIdentity{
+					UserID:   15,
+					Provider: "google",
+					Issuer:   "https://accounts.google.com",
+					Subject:  "1076",
+					Email:    "john@example.com",
+				}).Return(0, sql.ErrNoRows)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(&entity.FederatedIdentity{
+					ID:     4,
+					UserID: 16,
+				}, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			wantErr: ErrFederatedIdentityConflict,
+		},
+		{
+			name: "linked to the user at the same time",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, sql.ErrNoRows)
+				m.EXPECT().InsertIdentity(ctx, &entity.FederatedIdentity{
+					UserID:   15,
+					Provider: "google",
+					Issuer:   "https://accounts.google.com",
+					Subject:  "1076",
+					Email:    "john@example.com",
+				}).Return(0, sql.ErrNoRows)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(&entity.FederatedIdentity{
+					ID:     4,
+					UserID: 15,
+				}, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			want: &entity.FederatedIdentity{
+				ID:     4,
+				UserID: 15,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().ConsumeLoginRequest(ctx, crxpto.SHA256("some-state")).Return(loginRequest(), nil)
+				m.EXPECT().GetIdentity(ctx, "https://accounts.google.com", "1076").Return(nil, sql.ErrNoRows)
+				m.EXPECT().InsertIdentity(ctx, &entity.FederatedIdentity{
+					UserID:   15,
+					Provider: "google",
+					Issuer:   "https://accounts.google.com",
+					Subject:  "1076",
+					Email:    "john@example.com",
+				}).Return(4, nil)
+			},
+			prepareProvider: func(m *tools.MockIdentityProviderInterface) {
+				m.EXPECT().Exchange(ctx, "some-code", "some-verifier").Return(identityClaims(), nil)
+			},
+			want: &entity.FederatedIdentity{
+				UserID:   15,
+				Provider: "google",
+				Issuer:   "https://accounts.google.com",
+				Subject:  "1076",
+				Email:    "john@example.com",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockFederationRepo := repository.NewMockFederationRepositoryInterface(ctrl)
+	mockProvider := tools.NewMockIdentityProviderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareFederationRepo != nil {
+				tt.prepareFederationRepo(mockFederationRepo)
+			}
+			m.federationRepository = mockFederationRepo
+
+			if tt.prepareProvider != nil {
+				tt.prepareProvider(mockProvider)
+			}
+			m.identityProviders = map[string]tools.IdentityProviderInterface{
+				"google": mockProvider,
+			}
+
+			got, err := m.LinkFederatedIdentity(ctx, 15, "some-state", "some-code")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_ListFederatedIdentities(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockFederationRepo := repository.NewMockFederationRepositoryInterface(ctrl)
+	mockFederationRepo.EXPECT().GetIdentitiesByUserID(ctx, 15).Return([]*entity.FederatedIdentity{
+		{
+			ID:       4,
+			UserID:   15,
+			Provider: "google",
+		},
+	}, nil)
+	m := &UserModule{
+		federationRepository: mockFederationRepo,
+	}
+
+	got, err := m.ListFederatedIdentities(ctx, 15)
+	assert.NoError(t, err)
+	assert.Equal(t, []*entity.FederatedIdentity{
+		{
+			ID:       4,
+			UserID:   15,
+			Provider: "google",
+		},
+	}, got)
+}
+
+func TestUserModule_UnlinkFederatedIdentity(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	tests := []struct {
+		name                  string
+		prepareFederationRepo func(m *repository.MockFederationRepositoryInterface)
+		wantErr               error
+	}{
+		{
+			name: "error delete identity",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteIdentity(ctx, 15, 4).Return(false, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "not linked to the user",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteIdentity(ctx, 15, 4).Return(false, nil)
+			},
+			wantErr: ErrFederatedIdentityNotFound,
+		},
+		{
+			name: "success",
+			prepareFederationRepo: func(m *repository.MockFederationRepositoryInterface) {
+				m.EXPECT().DeleteIdentity(ctx, 15, 4).Return(true, nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockFederationRepo := repository.NewMockFederationRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareFederationRepo != nil {
+				tt.prepareFederationRepo(mockFederationRepo)
+			}
+			m.federationRepository = mockFederationRepo
+
+			err := m.UnlinkFederatedIdentity(ctx, 15, 4)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
+)
+
+const (
+	// MagicLinkTTL is how long a link sent to log in by email can be used. The link stops working
+	// earlier if the jwt it carries expires first, jwt lifetime is set by the jwt client.
+	MagicLinkTTL = time.Minute * 15
+	// magicLinkResendInterval limits how often a link is sent to the same email.
+	magicLinkResendInterval = time.Minute
+	// magicLinkPurpose is the purpose claim of the jwt carried by a magic link, so it is never taken for an access token.
+	magicLinkPurpose = "magic_link"
+	// magicLinkSubject is the subject of the email carrying the magic link.
+	magicLinkSubject = "Log in to your account"
+	// magicLinkBody is the text of the email, filled with the link and its lifetime in minutes.
+	magicLinkBody = "Open this link to log in:\n%s\n\nIt works once within %d minutes. If you did not ask to log in, ignore this message."
+)
+
+var (
+	// ErrInvalidMagicLink obscures whether the link is tampered with, expired, used, or belongs to a user who cannot log in.
+	ErrInvalidMagicLink = errors.New("login link is not valid")
+)
+
+// StartMagicLinkLogin sends a link logging in without password to the verified email of an active user.
+// It succeeds whether such user exists or not, to avoid revealing registered users. A throttled email
+// or client ip gets LoginThrottledError, the same limits apply as logging in with a password.
+func (m *UserModule) StartMagicLinkLogin(ctx context.Context, email, clientIP string) error {
+	email = validator.NormalizeEmail(email)
+
+	err := m.checkLoginThrottle(ctx, emailThrottleKey(email), clientIP)
+	if err != nil {
+		return err
+	}
+
+	// only verified emails are stored as email of a user
+	var user *entity.User
+	user, err = m.userRepository.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.Exist() || entity.CheckUserStatus(user.Status) != nil {
+		return nil
+	}
+
+	err = m.sendMagicLink(ctx, user)
+	if err != nil {
+		// failing only for registered emails would reveal them, so the error is logged instead
+		log.Printf("start magic link login: send link to user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// sendMagicLink emails the user a link carrying a jwt that logs them in.
+// Nothing is sent while the previous link is still recent.
+func (m *UserModule) sendMagicLink(ctx context.Context, user *entity.User) error {
+	now := m.timeNow()
+
+	// when a link was last sent is kept like a failed login, counting how many is of no use
+	sent, err := m.throttleRepository.GetLoginFailures(ctx, magicLinkThrottleKey(user.Email))
+	if err != nil {
+		return err
+	}
+	if now.Before(sent.LastFailedAt.Add(magicLinkResendInterval)) {
+		return nil
+	}
+
+	_, err = m.throttleRepository.RecordLoginFailure(ctx, magicLinkThrottleKey(user.Email), now, now.Add(-LoginFailureWindow))
+	if err != nil {
+		return err
+	}
+
+	// token version makes the link stop working once user changes password
+	var token string
+	token, err = m.jwt.Generate(tools.Claims{
+		Subject:      strconv.Itoa(user.ID),
+		TokenVersion: user.TokenVersion,
+		Purpose:      magicLinkPurpose,
+	})
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(m.magicLinkURL, "/") + "/" + url.PathEscape(token)
+	body := fmt.Sprintf(magicLinkBody, link, int(MagicLinkTTL.Minutes()))
+	return m.emailSender.Send(ctx, user.Email, magicLinkSubject, body)
+}
+
+// magicLinkThrottleKey returns the key the time a link was last sent to an email is kept under.
+func magicLinkThrottleKey(email string) string {
+	return "magic_link:" + email
+}
+
+// RedeemMagicLink logs in user with the token of a link sent by StartMagicLinkLogin, in place of the password.
+// The token is revoked as it is redeemed, so the link cannot be used again.
+func (m *UserModule) RedeemMagicLink(ctx context.Context, token string) (entity.LoginModuleResponse, error) {
+	var resp entity.LoginModuleResponse
+
+	claims, err := m.jwt.Validate(token)
+	if err != nil {
+		return resp, ErrInvalidMagicLink
+	}
+
+	if claims.Purpose != magicLinkPurpose || !m.timeNow().Before(claims.IssuedAt.Add(MagicLinkTTL)) {
+		return resp, ErrInvalidMagicLink
+	}
+
+	var userID int
+	userID, err = strconv.Atoi(claims.Subject)
+	if err != nil {
+		return resp, ErrInvalidMagicLink
+	}
+
+	resp.User, err = m.GetUser(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return resp, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	// user who became unable to log in after the link was sent cannot use it
+	err = entity.CheckUserStatus(resp.User.Status)
+	if err != nil {
+		log.Printf("redeem magic link: refuse user %d: %v", resp.User.ID, err)
+		return resp, entity.Obscure(ErrInvalidMagicLink, err)
+	}
+
+	if claims.TokenVersion != resp.User.TokenVersion {
+		return resp, ErrInvalidMagicLink
+	}
+
+	// a link is rejected for being expired by the time its redemption is deleted
+	err = m.revocationRepository.DeleteExpiredTokens(ctx, m.timeNow())
+	if err != nil {
+		return resp, err
+	}
+
+	var redeemed bool
+	redeemed, err = m.revocationRepository.RedeemToken(ctx, &entity.RevokedToken{
+		TokenID:   claims.ID,
+		UserID:    resp.User.ID,
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return resp, err
+	}
+	if !redeemed {
+		// link has been used, possibly by another request at the same time
+		return resp, ErrInvalidMagicLink
+	}
+
+	err = m.completeLogin(ctx, &resp)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
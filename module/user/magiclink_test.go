@@ -0,0 +1,576 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_StartMagicLinkLogin(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		magicLinkURL: "https://portal.example.com/login/magic/",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                string
+		prepareRepo         func(m *repository.MockUserRepositoryInterface)
+		prepareThrottleRepo func(m *repository.MockThrottleRepositoryInterface)
+		prepareJWT          func(m *tools.MockJWTInterface)
+		prepareEmail        func(m *tools.MockEmailSenderInterface)
+		wantErr             bool
+	}{
+		{
+			name: "throttled",
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key:          "email:john@example.com",
+					Failures:     10,
+					LastFailedAt: now.Add(-time.Minute),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "unregistered email",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "suspended user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:     15,
+					Email:  "john@example.com",
+					Status: entity.UserStatusSuspended,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error get last link is not revealed",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 2,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "magic_link:john@example.com").Return(entity.LoginFailures{}, assert.AnError)
+			},
+			wantErr: false,
+		},
+		{
+			name: "previous link is still recent",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 2,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "magic_link:john@example.com").Return(entity.LoginFailures{
+					Key:          "magic_link:john@example.com",
+					Failures:     1,
+					LastFailedAt: now.Add(-time.Second * 30),
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error record link is not revealed",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 2,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "magic_link:john@example.com").Return(entity.LoginFailures{
+					Key:          "magic_link:john@example.com",
+					Failures:     1,
+					LastFailedAt: now.Add(-time.Minute),
+				}, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "magic_link:john@example.com", now, now.Add(-LoginFailureWindow)).Return(0, assert.AnError)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error generate jwt is not revealed",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 2,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "magic_link:john@example.com").Return(entity.LoginFailures{
+					Key: "magic_link:john@example.com",
+				}, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "magic_link:john@example.com", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+					Purpose:      "magic_link",
+				}).Return("", assert.AnError)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error send link is not revealed",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 2,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "magic_link:john@example.com").Return(entity.LoginFailures{
+					Key: "magic_link:john@example.com",
+				}, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "magic_link:john@example.com", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+					Purpose:      "magic_link",
+				}).Return("header.payload.signature", nil)
+			},
+			prepareEmail: func(m *tools.MockEmailSenderInterface) {
+				m.EXPECT().Send(ctx, "john@example.com", "Log in to your account", gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 2,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "magic_link:john@example.com").Return(entity.LoginFailures{
+					Key: "magic_link:john@example.com",
+				}, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "magic_link:john@example.com", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+					Purpose:      "magic_link",
+				}).Return("header.payload.signature", nil)
+			},
+			prepareEmail: func(m *tools.MockEmailSenderInterface) {
+				m.EXPECT().Send(ctx, "john@example.com", "Log in to your account",
+					"Open this link to log in:\nhttps://portal.example.com/login/magic/header.payload.signature\n\n"+
+						"It works once within 15 minutes. If you did not ask to log in, ignore this message.").Return(nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockEmail := tools.NewMockEmailSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareEmail != nil {
+				tt.prepareEmail(mockEmail)
+			}
+			m.emailSender = mockEmail
+
+			err := m.StartMagicLinkLogin(ctx, " John@Example.com", "192.0.2.1")
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestUserModule_RedeemMagicLink(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		refreshTokenTTL: time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	magicLinkClaims := func() *tools.Claims {
+		return &tools.Claims{
+			ID:           "magic-link-id",
+			Subject:      "15",
+			IssuedAt:     now.Add(-time.Minute),
+			ExpiresAt:    now.Add(time.Minute * 14),
+			TokenVersion: 2,
+			Purpose:      "magic_link",
+		}
+	}
+	activeUser := func() *entity.User {
+		return &entity.User{
+			ID:           15,
+			Email:        "john@example.com",
+			Status:       entity.UserStatusActive,
+			TokenVersion: 2,
+		}
+	}
+	tests := []struct {
+		name                    string
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareRevocationRepo   func(m *repository.MockRevocationRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareTwoFactorRepo    func(m *repository.MockTwoFactorRepositoryInterface)
+		want                    entity.LoginModuleResponse
+		wantErr                 error
+	}{
+		{
+			name: "invalid jwt",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(nil, assert.AnError)
+			},
+			wantErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "access token",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				claims := magicLinkClaims()
+				claims.Purpose = ""
+				m.EXPECT().Validate("magic-link-token").Return(claims, nil)
+			},
+			wantErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "issued too long ago",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				claims := magicLinkClaims()
+				claims.IssuedAt = now.Add(-MagicLinkTTL)
+				m.EXPECT().Validate("magic-link-token").Return(claims, nil)
+			},
+			wantErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "user not found",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "error get user",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "suspended user",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := activeUser()
+				user.Status = entity.UserStatusSuspended
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusSuspended,
+					TokenVersion: 2,
+				},
+			},
+			wantErr: entity.Obscure(ErrInvalidMagicLink, entity.ErrUserSuspended),
+		},
+		{
+			name: "password changed after the link was sent",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := activeUser()
+				user.TokenVersion = 3
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:           15,
+					Email:        "john@example.com",
+					Status:       entity.UserStatusActive,
+					TokenVersion: 3,
+				},
+			},
+			wantErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "error delete expired tokens",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(assert.AnError)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error redeem token",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RedeemToken(ctx, &entity.RevokedToken{
+					TokenID:   "magic-link-id",
+					UserID:    15,
+					ExpiresAt: now.Add(time.Minute * 14),
+				}).Return(false, assert.AnError)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "link has been used",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RedeemToken(ctx, &entity.RevokedToken{
+					TokenID:   "magic-link-id",
+					UserID:    15,
+					ExpiresAt: now.Add(time.Minute * 14),
+				}).Return(false, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: ErrInvalidMagicLink,
+		},
+		{
+			name: "success",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("magic-link-token").Return(magicLinkClaims(), nil)
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+				}).Return("some jwt token", nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+				m.EXPECT().IncrementLoginCount(ctx, 15).Return(nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RedeemToken(ctx, &entity.RevokedToken{
+					TokenID:   "magic-link-id",
+					UserID:    15,
+					ExpiresAt: now.Add(time.Minute * 14),
+				}).Return(true, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			want: entity.LoginModuleResponse{
+				User:         activeUser(),
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareRevocationRepo != nil {
+				tt.prepareRevocationRepo(mockRevocationRepo)
+			}
+			m.revocationRepository = mockRevocationRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			got, err := m.RedeemMagicLink(ctx, "magic-link-token")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
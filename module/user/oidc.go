@@ -0,0 +1,420 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+)
+
+const (
+	// AuthorizationCodeTTL is how long a client has to exchange an authorization code for tokens.
+	AuthorizationCodeTTL = time.Minute * 5
+	// authorizationCodeSize is the number of random bytes of an authorization code.
+	authorizationCodeSize = 32
+	// oidcAccessTokenIDSize is the number of random bytes of the jti of an access token given to a client application.
+	oidcAccessTokenIDSize = 16
+	// oidcAccessTokenPurpose is the purpose claim of the access token given to a client application,
+	// it only works on /userinfo and never in place of an access token of this service.
+	oidcAccessTokenPurpose = "oidc_access"
+	// idTokenPurpose is the purpose claim of an id token, so it is never taken for an access token
+	// even when the audience of access tokens is not configured.
+	idTokenPurpose = "id_token"
+	// grantTypeAuthorizationCode is the only grant type accepted by ExchangeAuthorizationCode.
+	grantTypeAuthorizationCode = "authorization_code"
+	// responseTypeCode is the only response type accepted by Authorize.
+	responseTypeCode = "code"
+)
+
+// Error codes of RFC 6749 section 4.1.2.1 and 5.2.
+const (
+	OAuthErrorInvalidRequest          = "invalid_request"
+	OAuthErrorInvalidClient           = "invalid_client"
+	OAuthErrorInvalidGrant            = "invalid_grant"
+	OAuthErrorInvalidScope            = "invalid_scope"
+	OAuthErrorAccessDenied            = "access_denied"
+	OAuthErrorUnsupportedResponseType = "unsupported_response_type"
+	OAuthErrorUnsupportedGrantType    = "unsupported_grant_type"
+)
+
+var (
+	// ErrInvalidOAuthClient is returned when the client or its redirect uri cannot be trusted,
+	// the error is shown to user instead of being sent to an unknown redirect uri.
+	ErrInvalidOAuthClient = errors.New("client is not registered or redirect uri is not allowed")
+	// ErrInvalidOIDCAccessToken obscures whether the access token is tampered with, expired, or belongs to a user who cannot log in.
+	ErrInvalidOIDCAccessToken = errors.New("access token is not valid")
+
+	// errInvalidClient does not tell whether the client is unknown or its secret is wrong.
+	errInvalidClient = &OAuthError{
+		Code:        OAuthErrorInvalidClient,
+		Description: "client authentication failed",
+	}
+	// errInvalidGrant obscures whether the code is unknown, expired, used, issued to another client, or fails PKCE.
+	errInvalidGrant = &OAuthError{
+		Code:        OAuthErrorInvalidGrant,
+		Description: "authorization code is not valid",
+	}
+)
+
+// OAuthError is an error of the token endpoint that the client understands by its code, as defined by RFC 6749.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Description
+}
+
+// Authorize lets a client application log the user in, returning where the user should be redirected next.
+// Clients are registered by hand and trusted, so the user is not asked for consent.
+// The redirect carries a single-use code for /token, or an error when the request cannot be granted.
+// A user id of zero means the user is not logged in yet, who is sent to the login page along with the request.
+// ErrInvalidOAuthClient is returned instead of a redirect when the client is unknown
+// or the redirect uri is not registered for it.
+func (m *UserModule) Authorize(ctx context.Context, userID int, req entity.AuthorizationRequest) (string, error) {
+	client, err := m.oidcRepository.GetOAuthClient(ctx, req.ClientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalidOAuthClient
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return "", ErrInvalidOAuthClient
+	}
+
+	// the redirect uri is trusted from here, errors are sent to the client through it
+	if req.ResponseType != responseTypeCode {
+		return authorizationRedirect(req, url.Values{
+			"error":             {OAuthErrorUnsupportedResponseType},
+			"error_description": {"only code response type is supported"},
+		})
+	}
+	if !hasScope(req.Scope, entity.ScopeOpenID) {
+		return authorizationRedirect(req, url.Values{
+			"error":             {OAuthErrorInvalidScope},
+			"error_description": {"openid scope is required"},
+		})
+	}
+	// every client uses PKCE, a confidential client is protected even if its secret leaks
+	if req.CodeChallengeMethod != entity.CodeChallengeMethodS256 || !validCodeChallenge(req.CodeChallenge) {
+		return authorizationRedirect(req, url.Values{
+			"error":             {OAuthErrorInvalidRequest},
+			"error_description": {"code challenge with S256 method is required"},
+		})
+	}
+	if userID == 0 {
+		return m.authorizationLoginRedirect(req)
+	}
+
+	var user *entity.User
+	user, err = m.GetUser(ctx, userID)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return "", err
+	}
+	if err != nil || entity.CheckUserStatus(user.Status) != nil {
+		return authorizationRedirect(req, url.Values{
+			"error":             {OAuthErrorAccessDenied},
+			"error_description": {"user cannot log in"},
+		})
+	}
+
+	// a consumed code is kept as long as the access token it was exchanged for, so a replay can still revoke it
+	err = m.oidcRepository.DeleteExpiredAuthorizationCodes(ctx, m.timeNow().Add(-m.accessTokenTTL))
+	if err != nil {
+		return "", err
+	}
+
+	var code string
+	code, err = m.random.Token(authorizationCodeSize)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.oidcRepository.InsertAuthorizationCode(ctx, &entity.AuthorizationCode{
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		CodeHash:      crxpto.SHA256(code),
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     m.timeNow().Add(AuthorizationCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return authorizationRedirect(req, url.Values{
+		"code": {code},
+	})
+}
+
+// ExchangeAuthorizationCode gives a client the tokens of the user who approved its authorization request,
+// in exchange for the code issued by Authorize and the PKCE verifier of its challenge.
+// Anything the client did wrong is returned as *OAuthError.
+func (m *UserModule) ExchangeAuthorizationCode(ctx context.Context, req entity.TokenRequest) (entity.TokenModuleResponse, error) {
+	var resp entity.TokenModuleResponse
+
+	if req.GrantType != grantTypeAuthorizationCode {
+		return resp, &OAuthError{
+			Code:        OAuthErrorUnsupportedGrantType,
+			Description: "only authorization_code grant type is supported",
+		}
+	}
+
+	client, err := m.oidcRepository.GetOAuthClient(ctx, req.ClientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return resp, errInvalidClient
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	if !client.Public() && subtle.ConstantTimeCompare([]byte(crxpto.SHA256(req.ClientSecret)), []byte(client.SecretHash)) != 1 {
+		return resp, errInvalidClient
+	}
+
+	var code *entity.AuthorizationCode
+	code, err = m.oidcRepository.GetAuthorizationCodeByHash(ctx, crxpto.SHA256(req.Code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return resp, errInvalidGrant
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	// a replayed code may have been stolen, so the access token it was exchanged for is revoked (RFC 6749 section 4.1.2)
+	if code.Consumed() {
+		err = m.revokeAuthorizationCodeToken(ctx, code)
+		if err != nil {
+			return resp, err
+		}
+		return resp, errInvalidGrant
+	}
+
+	// a code only works for the client it was issued to, at the redirect uri it was sent to
+	if code.ClientID != client.ID || code.RedirectURI != req.RedirectURI || code.Expired(m.timeNow()) {
+		return resp, errInvalidGrant
+	}
+
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		return resp, errInvalidGrant
+	}
+
+	var user *entity.User
+	user, err = m.GetUser(ctx, code.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return resp, errInvalidGrant
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	// user who became unable to log in after approving the request cannot finish it
+	if entity.CheckUserStatus(user.Status) != nil {
+		return resp, errInvalidGrant
+	}
+
+	var accessTokenID string
+	accessTokenID, err = m.random.Token(oidcAccessTokenIDSize)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.AccessToken, err = m.jwt.Generate(tools.Claims{
+		ID:           accessTokenID,
+		Subject:      strconv.Itoa(user.ID),
+		TokenVersion: user.TokenVersion,
+		Purpose:      oidcAccessTokenPurpose,
+		Scope:        code.Scope,
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	resp.IDToken, err = m.jwt.Generate(tools.Claims{
+		Subject:  strconv.Itoa(user.ID),
+		Audience: []string{client.ID},
+		Nonce:    code.Nonce,
+		Purpose:  idTokenPurpose,
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	// tokens are only handed out once the code is consumed along with the jti to revoke on replay
+	var consumed bool
+	consumed, err = m.oidcRepository.ConsumeAuthorizationCode(ctx, code.ID, accessTokenID)
+	if err != nil {
+		return resp, err
+	}
+	if !consumed {
+		// another request managed to use the same code first
+		return resp, errInvalidGrant
+	}
+
+	resp.Scope = code.Scope
+
+	return resp, nil
+}
+
+// revokeAuthorizationCodeToken revokes the access token a consumed code was exchanged for.
+// The token cannot outlive the code by more than its own ttl, counted from when the code was consumed.
+func (m *UserModule) revokeAuthorizationCodeToken(ctx context.Context, code *entity.AuthorizationCode) error {
+	if code.AccessTokenID == "" {
+		return nil
+	}
+
+	return m.revocationRepository.RevokeToken(ctx, &entity.RevokedToken{
+		TokenID:   code.AccessTokenID,
+		UserID:    code.UserID,
+		ExpiresAt: code.ConsumedAt.Add(m.accessTokenTTL),
+	})
+}
+
+// GetUserInfo returns the user of an access token issued by ExchangeAuthorizationCode,
+// along with which of their claims the scope granted to the client allows sharing.
+// The token stops working like an access token of this service would, once user changes password,
+// logs out from all devices, or can no longer log in. It is also revoked once the code it was exchanged for is replayed.
+func (m *UserModule) GetUserInfo(ctx context.Context, accessToken string) (entity.UserInfoModuleResponse, error) {
+	var resp entity.UserInfoModuleResponse
+
+	claims, err := m.jwt.Validate(accessToken)
+	if err != nil || claims.Purpose != oidcAccessTokenPurpose {
+		return resp, ErrInvalidOIDCAccessToken
+	}
+
+	var userID int
+	userID, err = strconv.Atoi(claims.Subject)
+	if err != nil {
+		return resp, ErrInvalidOIDCAccessToken
+	}
+
+	var user *entity.User
+	user, err = m.GetUser(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return resp, ErrInvalidOIDCAccessToken
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	err = entity.CheckUserStatus(user.Status)
+	if err != nil {
+		log.Printf("get user info: refuse user %d: %v", user.ID, err)
+		return resp, entity.Obscure(ErrInvalidOIDCAccessToken, err)
+	}
+
+	// version is bumped on password change and on logout from all devices
+	if claims.TokenVersion != user.TokenVersion {
+		return resp, ErrInvalidOIDCAccessToken
+	}
+
+	// revoked when the authorization code it was exchanged for is replayed
+	var revoked bool
+	revoked, err = m.revocationRepository.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return resp, err
+	}
+	if revoked {
+		return resp, ErrInvalidOIDCAccessToken
+	}
+
+	resp.User = user
+	resp.Profile = hasScope(claims.Scope, entity.ScopeProfile)
+	resp.Phone = hasScope(claims.Scope, entity.ScopePhone)
+	return resp, nil
+}
+
+// authorizationRedirect returns the redirect uri of the request with the params and the state of the request added to its query.
+func authorizationRedirect(req entity.AuthorizationRequest, params url.Values) (string, error) {
+	redirectURI, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	query := redirectURI.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectURI.RawQuery = query.Encode()
+
+	return redirectURI.String(), nil
+}
+
+// authorizationLoginRedirect returns the login page with the request in its query, so the page can authorize it once user logs in.
+func (m *UserModule) authorizationLoginRedirect(req entity.AuthorizationRequest) (string, error) {
+	loginURL, err := url.Parse(m.authorizationLoginURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := loginURL.Query()
+	for key, value := range map[string]string{
+		"response_type":         req.ResponseType,
+		"client_id":             req.ClientID,
+		"redirect_uri":          req.RedirectURI,
+		"scope":                 req.Scope,
+		"state":                 req.State,
+		"nonce":                 req.Nonce,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": req.CodeChallengeMethod,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	loginURL.RawQuery = query.Encode()
+
+	return loginURL.String(), nil
+}
+
+// hasScope reports whether the space separated scope contains the value.
+func hasScope(scope, value string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validCodeChallenge reports whether the challenge looks like a base64url encoded sha256 digest without padding.
+func validCodeChallenge(challenge string) bool {
+	digest, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(digest) == sha256.Size
+}
+
+// verifyCodeChallenge reports whether the verifier is the one the S256 challenge was computed from, as defined by RFC 7636.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	// RFC 7636 section 4.1 requires 43 to 128 characters, enough entropy to resist guessing
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	computed := codeChallengeS256(verifier)
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// codeChallengeS256 returns the S256 challenge of a PKCE verifier, base64url encoded sha256 digest without padding.
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
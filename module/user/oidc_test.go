@@ -0,0 +1,973 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// testCodeVerifier and testCodeChallenge are a PKCE pair of S256 method.
+	testCodeVerifier  = "dBjftJeZ4CVP-mJ92K1Ugwq6Ap68KTxmCNbx9N3T4Ya"
+	testCodeChallenge = "0-jU3mxuhm2_pFNlPMSCemHaRsAaJYfGoWAEFO_aVIQ"
+)
+
+func TestUserModule_Authorize(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		authorizationLoginURL: "https://portal.example.com/login/authorize",
+		accessTokenTTL:        time.Minute * 15,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	client := func() *entity.OAuthClient {
+		return &entity.OAuthClient{
+			ID:           "wiki",
+			Name:         "Wiki",
+			RedirectURIs: []string{"https://wiki.example.com/callback"},
+		}
+	}
+	request := func() entity.AuthorizationRequest {
+		return entity.AuthorizationRequest{
+			ResponseType:        "code",
+			ClientID:            "wiki",
+			RedirectURI:         "https://wiki.example.com/callback",
+			Scope:               "openid profile",
+			State:               "some-state",
+			Nonce:               "some-nonce",
+			CodeChallenge:       testCodeChallenge,
+			CodeChallengeMethod: "S256",
+		}
+	}
+	tests := []struct {
+		name            string
+		userID          int
+		req             func() entity.AuthorizationRequest
+		prepareOIDCRepo func(m *repository.MockOIDCRepositoryInterface)
+		prepareRepo     func(m *repository.MockUserRepositoryInterface)
+		prepareRandom   func(m *tools.MockRandomInterface)
+		want            string
+		wantErr         error
+	}{
+		{
+			name:   "unknown client",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidOAuthClient,
+		},
+		{
+			name:   "error get client",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "redirect uri not registered",
+			userID: 15,
+			req: func() entity.AuthorizationRequest {
+				req := request()
+				req.RedirectURI = "https://evil.example.com/callback"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			wantErr: ErrInvalidOAuthClient,
+		},
+		{
+			name:   "unsupported response type",
+			userID: 15,
+			req: func() entity.AuthorizationRequest {
+				req := request()
+				req.ResponseType = "token"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			want:    "https://wiki.example.com/callback?error=unsupported_response_type&error_description=only+code+response+type+is+supported&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "missing openid scope",
+			userID: 15,
+			req: func() entity.AuthorizationRequest {
+				req := request()
+				req.Scope = "profile"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			want:    "https://wiki.example.com/callback?error=invalid_scope&error_description=openid+scope+is+required&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "plain code challenge",
+			userID: 15,
+			req: func() entity.AuthorizationRequest {
+				req := request()
+				req.CodeChallenge = testCodeVerifier
+				req.CodeChallengeMethod = "plain"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			want:    "https://wiki.example.com/callback?error=invalid_request&error_description=code+challenge+with+S256+method+is+required&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "malformed code challenge",
+			userID: 15,
+			req: func() entity.AuthorizationRequest {
+				req := request()
+				req.CodeChallenge = "too-short"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			want:    "https://wiki.example.com/callback?error=invalid_request&error_description=code+challenge+with+S256+method+is+required&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "not logged in",
+			userID: 0,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			want: "https://portal.example.com/login/authorize?client_id=wiki&code_challenge=" + testCodeChallenge +
+				"&code_challenge_method=S256&nonce=some-nonce&redirect_uri=https%3A%2F%2Fwiki.example.com%2Fcallback" +
+				"&response_type=code&scope=openid+profile&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "error get user",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "user not found",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			want:    "https://wiki.example.com/callback?error=access_denied&error_description=user+cannot+log+in&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "suspended user",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:     15,
+					Status: entity.UserStatusSuspended,
+				}, nil)
+			},
+			want:    "https://wiki.example.com/callback?error=access_denied&error_description=user+cannot+log+in&state=some-state",
+			wantErr: nil,
+		},
+		{
+			name:   "error delete expired codes",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().DeleteExpiredAuthorizationCodes(ctx, now.Add(-time.Minute*15)).Return(assert.AnError)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:     15,
+					Status: entity.UserStatusActive,
+				}, nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "error generate code",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().DeleteExpiredAuthorizationCodes(ctx, now.Add(-time.Minute*15)).Return(nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:     15,
+					Status: entity.UserStatusActive,
+				}, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(authorizationCodeSize).Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "error insert code",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().DeleteExpiredAuthorizationCodes(ctx, now.Add(-time.Minute*15)).Return(nil)
+				m.EXPECT().InsertAuthorizationCode(ctx, gomock.Any()).Return(0, assert.AnError)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:     15,
+					Status: entity.UserStatusActive,
+				}, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(authorizationCodeSize).Return("some-code", nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "success",
+			userID: 15,
+			req:    request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().DeleteExpiredAuthorizationCodes(ctx, now.Add(-time.Minute*15)).Return(nil)
+				m.EXPECT().InsertAuthorizationCode(ctx, &entity.AuthorizationCode{
+					ClientID:      "wiki",
+					UserID:        15,
+					CodeHash:      crxpto.SHA256("some-code"),
+					RedirectURI:   "https://wiki.example.com/callback",
+					Scope:         "openid profile",
+					Nonce:         "some-nonce",
+					CodeChallenge: testCodeChallenge,
+					ExpiresAt:     now.Add(AuthorizationCodeTTL),
+				}).Return(1, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:     15,
+					Status: entity.UserStatusActive,
+				}, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(authorizationCodeSize).Return("some-code", nil)
+			},
+			want:    "https://wiki.example.com/callback?code=some-code&state=some-state",
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOIDCRepo := repository.NewMockOIDCRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareOIDCRepo != nil {
+				tt.prepareOIDCRepo(mockOIDCRepo)
+			}
+			m.oidcRepository = mockOIDCRepo
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			got, err := m.Authorize(ctx, tt.userID, tt.req())
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_ExchangeAuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	consumedAt := now.Add(-time.Second)
+	m := &UserModule{
+		accessTokenTTL: time.Minute * 15,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	client := func() *entity.OAuthClient {
+		return &entity.OAuthClient{
+			ID:           "wiki",
+			Name:         "Wiki",
+			SecretHash:   crxpto.SHA256("some-secret"),
+			RedirectURIs: []string{"https://wiki.example.com/callback"},
+		}
+	}
+	code := func() *entity.AuthorizationCode {
+		return &entity.AuthorizationCode{
+			ID:            2,
+			ClientID:      "wiki",
+			UserID:        15,
+			CodeHash:      crxpto.SHA256("some-code"),
+			RedirectURI:   "https://wiki.example.com/callback",
+			Scope:         "openid profile",
+			Nonce:         "some-nonce",
+			CodeChallenge: testCodeChallenge,
+			ExpiresAt:     now.Add(time.Minute),
+		}
+	}
+	request := func() entity.TokenRequest {
+		return entity.TokenRequest{
+			GrantType:    "authorization_code",
+			Code:         "some-code",
+			RedirectURI:  "https://wiki.example.com/callback",
+			ClientID:     "wiki",
+			ClientSecret: "some-secret",
+			CodeVerifier: testCodeVerifier,
+		}
+	}
+	activeUser := func() *entity.User {
+		return &entity.User{
+			ID:           15,
+			Status:       entity.UserStatusActive,
+			TokenVersion: 2,
+		}
+	}
+	// validCode prepares the repository up to a code that passes every check
+	validCode := func(m *repository.MockOIDCRepositoryInterface) {
+		m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+		m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(code(), nil)
+	}
+	accessTokenClaims := tools.Claims{
+		ID:           "some-access-token-id",
+		Subject:      "15",
+		TokenVersion: 2,
+		Purpose:      "oidc_access",
+		Scope:        "openid profile",
+	}
+	idTokenClaims := tools.Claims{
+		Subject:  "15",
+		Audience: []string{"wiki"},
+		Nonce:    "some-nonce",
+		Purpose:  "id_token",
+	}
+	// validTokens prepares the tokens a valid code is exchanged for
+	validTokens := func(m *tools.MockJWTInterface) {
+		m.EXPECT().Generate(accessTokenClaims).Return("some-access-token", nil)
+		m.EXPECT().Generate(idTokenClaims).Return("some-id-token", nil)
+	}
+	tests := []struct {
+		name                  string
+		req                   func() entity.TokenRequest
+		prepareOIDCRepo       func(m *repository.MockOIDCRepositoryInterface)
+		prepareRepo           func(m *repository.MockUserRepositoryInterface)
+		prepareRandom         func(m *tools.MockRandomInterface)
+		prepareJWT            func(m *tools.MockJWTInterface)
+		prepareRevocationRepo func(m *repository.MockRevocationRepositoryInterface)
+		want                  entity.TokenModuleResponse
+		wantErr               error
+	}{
+		{
+			name: "unsupported grant type",
+			req: func() entity.TokenRequest {
+				req := request()
+				req.GrantType = "password"
+				return req
+			},
+			wantErr: &OAuthError{
+				Code:        OAuthErrorUnsupportedGrantType,
+				Description: "only authorization_code grant type is supported",
+			},
+		},
+		{
+			name: "unknown client",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(nil, sql.ErrNoRows)
+			},
+			wantErr: errInvalidClient,
+		},
+		{
+			name: "error get client",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "wrong client secret",
+			req: func() entity.TokenRequest {
+				req := request()
+				req.ClientSecret = "wrong-secret"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+			},
+			wantErr: errInvalidClient,
+		},
+		{
+			name: "unknown code",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "error get code",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "code of another client",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				other := code()
+				other.ClientID = "blog"
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(other, nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "different redirect uri",
+			req: func() entity.TokenRequest {
+				req := request()
+				req.RedirectURI = "https://wiki.example.com/other"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(code(), nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "consumed code before its access token was recorded",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				consumed := code()
+				consumed.ConsumedAt = &consumedAt
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(consumed, nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "error revoke access token of consumed code",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				consumed := code()
+				consumed.ConsumedAt = &consumedAt
+				consumed.AccessTokenID = "some-access-token-id"
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(consumed, nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().RevokeToken(ctx, gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "consumed code revokes its access token",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				consumed := code()
+				consumed.ConsumedAt = &consumedAt
+				consumed.AccessTokenID = "some-access-token-id"
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(consumed, nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "some-access-token-id",
+					UserID:    15,
+					ExpiresAt: consumedAt.Add(time.Minute * 15),
+				}).Return(nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "expired code",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				expired := code()
+				expired.ExpiresAt = now
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(expired, nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "wrong code verifier",
+			req: func() entity.TokenRequest {
+				req := request()
+				req.CodeVerifier = "wrong-verifier-long-enough-to-be-a-valid-pkce-verifier"
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(client(), nil)
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(code(), nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name:            "user not found",
+			req:             request,
+			prepareOIDCRepo: validCode,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name:            "error get user",
+			req:             request,
+			prepareOIDCRepo: validCode,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:            "suspended user",
+			req:             request,
+			prepareOIDCRepo: validCode,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := activeUser()
+				user.Status = entity.UserStatusSuspended
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name:            "error generate access token id",
+			req:             request,
+			prepareOIDCRepo: validCode,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("", assert.AnError)
+			},
+			want:    entity.TokenModuleResponse{},
+			wantErr: assert.AnError,
+		},
+		{
+			name:            "error generate access token",
+			req:             request,
+			prepareOIDCRepo: validCode,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("some-access-token-id", nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(accessTokenClaims).Return("", assert.AnError)
+			},
+			want:    entity.TokenModuleResponse{},
+			wantErr: assert.AnError,
+		},
+		{
+			name:            "error generate id token",
+			req:             request,
+			prepareOIDCRepo: validCode,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("some-access-token-id", nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(accessTokenClaims).Return("some-access-token", nil)
+				m.EXPECT().Generate(idTokenClaims).Return("", assert.AnError)
+			},
+			want: entity.TokenModuleResponse{
+				AccessToken: "some-access-token",
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error consume code",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeAuthorizationCode(ctx, 2, "some-access-token-id").Return(false, assert.AnError)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("some-access-token-id", nil)
+			},
+			prepareJWT: validTokens,
+			want: entity.TokenModuleResponse{
+				AccessToken: "some-access-token",
+				IDToken:     "some-id-token",
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "code consumed by another request",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeAuthorizationCode(ctx, 2, "some-access-token-id").Return(false, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("some-access-token-id", nil)
+			},
+			prepareJWT: validTokens,
+			want: entity.TokenModuleResponse{
+				AccessToken: "some-access-token",
+				IDToken:     "some-id-token",
+			},
+			wantErr: errInvalidGrant,
+		},
+		{
+			name: "success public client",
+			req: func() entity.TokenRequest {
+				req := request()
+				req.ClientSecret = ""
+				return req
+			},
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				public := client()
+				public.SecretHash = ""
+				m.EXPECT().GetOAuthClient(ctx, "wiki").Return(public, nil)
+				m.EXPECT().GetAuthorizationCodeByHash(ctx, crxpto.SHA256("some-code")).Return(code(), nil)
+				m.EXPECT().ConsumeAuthorizationCode(ctx, 2, "some-access-token-id").Return(true, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("some-access-token-id", nil)
+			},
+			prepareJWT: validTokens,
+			want: entity.TokenModuleResponse{
+				AccessToken: "some-access-token",
+				IDToken:     "some-id-token",
+				Scope:       "openid profile",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success",
+			req:  request,
+			prepareOIDCRepo: func(m *repository.MockOIDCRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeAuthorizationCode(ctx, 2, "some-access-token-id").Return(true, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(oidcAccessTokenIDSize).Return("some-access-token-id", nil)
+			},
+			prepareJWT: validTokens,
+			want: entity.TokenModuleResponse{
+				AccessToken: "some-access-token",
+				IDToken:     "some-id-token",
+				Scope:       "openid profile",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockOIDCRepo := repository.NewMockOIDCRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareOIDCRepo != nil {
+				tt.prepareOIDCRepo(mockOIDCRepo)
+			}
+			m.oidcRepository = mockOIDCRepo
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRevocationRepo != nil {
+				tt.prepareRevocationRepo(mockRevocationRepo)
+			}
+			m.revocationRepository = mockRevocationRepo
+
+			got, err := m.ExchangeAuthorizationCode(ctx, tt.req())
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_GetUserInfo(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{}
+	accessTokenClaims := func() *tools.Claims {
+		return &tools.Claims{
+			ID:           "access-token-id",
+			Subject:      "15",
+			IssuedAt:     now.Add(-time.Minute),
+			ExpiresAt:    now.Add(time.Minute * 14),
+			TokenVersion: 2,
+			Purpose:      "oidc_access",
+			Scope:        "openid profile",
+		}
+	}
+	activeUser := func() *entity.User {
+		return &entity.User{
+			ID:           15,
+			Fullname:     "John Doe",
+			PhoneNumber:  "+628123456789",
+			Status:       entity.UserStatusActive,
+			TokenVersion: 2,
+		}
+	}
+	tests := []struct {
+		name                  string
+		prepareJWT            func(m *tools.MockJWTInterface)
+		prepareRepo           func(m *repository.MockUserRepositoryInterface)
+		prepareRevocationRepo func(m *repository.MockRevocationRepositoryInterface)
+		want                  entity.UserInfoModuleResponse
+		wantErr               bool
+		wantIs                error
+	}{
+		{
+			name: "invalid jwt",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(nil, assert.AnError)
+			},
+			wantErr: true,
+			wantIs:  ErrInvalidOIDCAccessToken,
+		},
+		{
+			name: "access token of this service",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				claims := accessTokenClaims()
+				claims.Purpose = ""
+				m.EXPECT().Validate("some-access-token").Return(claims, nil)
+			},
+			wantErr: true,
+			wantIs:  ErrInvalidOIDCAccessToken,
+		},
+		{
+			name: "user not found",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: true,
+			wantIs:  ErrInvalidOIDCAccessToken,
+		},
+		{
+			name: "error get user",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: true,
+			wantIs:  assert.AnError,
+		},
+		{
+			name: "suspended user",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := activeUser()
+				user.Status = entity.UserStatusSuspended
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			wantErr: true,
+			wantIs:  ErrInvalidOIDCAccessToken,
+		},
+		{
+			name: "password changed or logged out from all devices",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := activeUser()
+				user.TokenVersion = 3
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			wantErr: true,
+			wantIs:  ErrInvalidOIDCAccessToken,
+		},
+		{
+			name: "error check token revoked",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "access-token-id").Return(false, assert.AnError)
+			},
+			wantErr: true,
+			wantIs:  assert.AnError,
+		},
+		{
+			name: "authorization code replayed",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "access-token-id").Return(true, nil)
+			},
+			wantErr: true,
+			wantIs:  ErrInvalidOIDCAccessToken,
+		},
+		{
+			name: "success",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("some-access-token").Return(accessTokenClaims(), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "access-token-id").Return(false, nil)
+			},
+			want: entity.UserInfoModuleResponse{
+				User:    activeUser(),
+				Profile: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "openid scope only",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				claims := accessTokenClaims()
+				claims.Scope = "openid"
+				m.EXPECT().Validate("some-access-token").Return(claims, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "access-token-id").Return(false, nil)
+			},
+			want: entity.UserInfoModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: false,
+		},
+		{
+			name: "phone scope",
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				claims := accessTokenClaims()
+				claims.Scope = "openid phone"
+				m.EXPECT().Validate("some-access-token").Return(claims, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "access-token-id").Return(false, nil)
+			},
+			want: entity.UserInfoModuleResponse{
+				User:  activeUser(),
+				Phone: true,
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareRevocationRepo != nil {
+				tt.prepareRevocationRepo(mockRevocationRepo)
+			}
+			m.revocationRepository = mockRevocationRepo
+
+			got, err := m.GetUserInfo(ctx, "some-access-token")
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.wantIs)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
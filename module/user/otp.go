@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+var (
+	// ErrOTPLoginFailed obscures whether the phone number is registered, or the code is wrong, expired, or used.
+	ErrOTPLoginFailed = errors.New("phone number or code is not correct")
+)
+
+// StartOTPLogin sends a login code over sms to the phone number of an active user whose phone number is verified.
+// It succeeds whether such user exists or not, to avoid revealing registered users. A throttled phone number
+// or client ip gets LoginThrottledError, the same limits apply as logging in with a password.
+func (m *UserModule) StartOTPLogin(ctx context.Context, phoneNumber, clientIP string) error {
+	phoneNumber = m.normalizePhoneNumber(phoneNumber)
+
+	err := m.checkLoginThrottle(ctx, phoneThrottleKey(phoneNumber), clientIP)
+	if err != nil {
+		return err
+	}
+
+	var user *entity.User
+	user, err = m.userRepository.GetUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// a phone number that has never been verified may belong to someone else
+	if !user.Exist() || user.PhoneVerifiedAt == nil || entity.CheckUserStatus(user.Status) != nil {
+		return nil
+	}
+
+	err = m.issueVerificationCode(ctx, phoneNumber, entity.VerificationPurposeLogin)
+	if err != nil {
+		// failing only for registered phone numbers would reveal them, so the error is logged instead
+		log.Printf("start otp login: issue code for user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// VerifyOTPLogin logs in user with the code sent by StartOTPLogin, in place of the password.
+// Wrong codes are counted as failed logins, so guessing codes and passwords share the same limits.
+func (m *UserModule) VerifyOTPLogin(ctx context.Context, phoneNumber, code, clientIP string) (entity.LoginModuleResponse, error) {
+	var (
+		resp       entity.LoginModuleResponse
+		accountKey string
+		err        error
+	)
+
+	phoneNumber = m.normalizePhoneNumber(phoneNumber)
+	accountKey = phoneThrottleKey(phoneNumber)
+
+	// throttled attempt is refused before the code is even compared, any other is counted as failed until it succeeds
+	err = m.startLoginAttempt(ctx, accountKey, clientIP)
+	var throttled *LoginThrottledError
+	if errors.As(err, &throttled) {
+		return resp, err
+	}
+	if err != nil {
+		return resp, ErrOTPLoginFailed
+	}
+
+	var verification *entity.VerificationCode
+	verification, err = m.checkCode(ctx, phoneNumber, entity.VerificationPurposeLogin, code)
+	if err != nil {
+		return resp, ErrOTPLoginFailed
+	}
+
+	resp.User, err = m.userRepository.GetUserByPhoneNumber(ctx, phoneNumber)
+	if err != nil {
+		return resp, ErrOTPLoginFailed
+	}
+
+	// phone number might have moved to another user since the code was sent
+	if !resp.User.Exist() || resp.User.PhoneVerifiedAt == nil {
+		return resp, ErrOTPLoginFailed
+	}
+
+	// user who became unable to log in after the code was sent cannot use it
+	err = entity.CheckUserStatus(resp.User.Status)
+	if err != nil {
+		log.Printf("verify otp login: refuse user %d: %v", resp.User.ID, err)
+		return resp, entity.Obscure(ErrOTPLoginFailed, err)
+	}
+
+	err = m.consumeCode(ctx, verification)
+	if err != nil {
+		return resp, ErrOTPLoginFailed
+	}
+
+	m.finishLoginAttempt(ctx, accountKey, clientIP)
+
+	err = m.completeLogin(ctx, &resp)
+	if err != nil {
+		return resp, ErrOTPLoginFailed
+	}
+
+	return resp, nil
+}
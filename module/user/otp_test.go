@@ -0,0 +1,618 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_StartOTPLogin(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                    string
+		phoneNumber             string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareSMS              func(m *tools.MockSMSSenderInterface)
+		wantThrottled           bool
+		wantErr                 bool
+	}{
+		{
+			name:        "throttled",
+			phoneNumber: "0812-345-678",
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key:          "phone:+62812345678",
+					Failures:     10,
+					LastFailedAt: now.Add(-time.Minute),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantThrottled: true,
+			wantErr:       true,
+		},
+		{
+			name:        "unregistered phone number",
+			phoneNumber: "0812-345-678",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "error get user",
+			phoneNumber: "0812-345-678",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(nil, assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "phone number not verified",
+			phoneNumber: "0812-345-678",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+62812345678",
+					Status:      entity.UserStatusActive,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "suspended user",
+			phoneNumber: "0812-345-678",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:              15,
+					PhoneNumber:     "+62812345678",
+					PhoneVerifiedAt: &now,
+					Status:          entity.UserStatusSuspended,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "error send code is not revealed",
+			phoneNumber: "0812-345-678",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:              15,
+					PhoneNumber:     "+62812345678",
+					PhoneVerifiedAt: &now,
+					Status:          entity.UserStatusActive,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, gomock.Any()).Return(1, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "+62812345678", gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "success",
+			phoneNumber: "0812-345-678",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:              15,
+					PhoneNumber:     "+62812345678",
+					PhoneVerifiedAt: &now,
+					Status:          entity.UserStatusActive,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, &entity.VerificationCode{
+					PhoneNumber: "+62812345678",
+					Purpose:     entity.VerificationPurposeLogin,
+					CodeHash:    "hashed code",
+					ExpiresAt:   now.Add(VerificationCodeTTL),
+				}).Return(1, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "+62812345678", "Your login code is 123456. It expires in 10 minutes, never share it with anyone.").Return(nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockSMS := tools.NewMockSMSSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareSMS != nil {
+				tt.prepareSMS(mockSMS)
+			}
+			m.smsSender = mockSMS
+
+			err := m.StartOTPLogin(ctx, tt.phoneNumber, "192.0.2.1")
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			var throttled *LoginThrottledError
+			assert.Equal(t, tt.wantThrottled, errors.As(err, &throttled))
+		})
+	}
+}
+
+func TestUserModule_VerifyOTPLogin(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+		refreshTokenTTL:    time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	activeCode := &entity.VerificationCode{
+		ID:          7,
+		PhoneNumber: "+62812345678",
+		Purpose:     entity.VerificationPurposeLogin,
+		CodeHash:    "hashed code",
+		ExpiresAt:   now.Add(time.Minute),
+	}
+	verifiedUser := func() *entity.User {
+		return &entity.User{
+			ID:              1,
+			Fullname:        "John Doe",
+			PhoneNumber:     "+62812345678",
+			PhoneVerifiedAt: &now,
+			Status:          entity.UserStatusActive,
+		}
+	}
+	tests := []struct {
+		name                    string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareTwoFactorRepo    func(m *repository.MockTwoFactorRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		want                    entity.LoginModuleResponse
+		wantErr                 error
+	}{
+		{
+			name: "error check throttle",
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{}, assert.AnError)
+			},
+			wantErr: ErrOTPLoginFailed,
+		},
+		{
+			name: "throttled",
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key:          "phone:+62812345678",
+					Failures:     10,
+					LastFailedAt: now.Add(-time.Minute),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			wantErr: &LoginThrottledError{
+				RetryAfter: LoginLockoutDuration - time.Minute,
+			},
+		},
+		{
+			name: "wrong code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(activeCode, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "code:login:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrOTPLoginFailed,
+		},
+		{
+			name: "no code has been sent",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrOTPLoginFailed,
+		},
+		{
+			name: "phone number moved to an unverified user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:          2,
+					PhoneNumber: "+62812345678",
+					Status:      entity.UserStatusActive,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(activeCode, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "code:login:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:          2,
+					PhoneNumber: "+62812345678",
+					Status:      entity.UserStatusActive,
+				},
+			},
+			wantErr: ErrOTPLoginFailed,
+		},
+		{
+			name: "suspended user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				user := verifiedUser()
+				user.Status = entity.UserStatusSuspended
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(user, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(activeCode, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "code:login:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:              1,
+					Fullname:        "John Doe",
+					PhoneNumber:     "+62812345678",
+					PhoneVerifiedAt: &now,
+					Status:          entity.UserStatusSuspended,
+				},
+			},
+			wantErr: entity.Obscure(ErrOTPLoginFailed, entity.ErrUserSuspended),
+		},
+		{
+			name: "code used by another request",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(verifiedUser(), nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(activeCode, nil)
+				m.EXPECT().ConsumeVerificationCode(ctx, 7).Return(false, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "code:login:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: verifiedUser(),
+			},
+			wantErr: ErrOTPLoginFailed,
+		},
+		{
+			name: "two-factor authentication enabled",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(verifiedUser(), nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(activeCode, nil)
+				m.EXPECT().ConsumeVerificationCode(ctx, 7).Return(true, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(&entity.TOTP{
+					UserID:      1,
+					ConfirmedAt: &now,
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginChallenges(ctx, now).Return(nil)
+				m.EXPECT().InsertLoginChallenge(ctx, &entity.LoginChallenge{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-challenge"),
+					ExpiresAt: now.Add(LoginChallengeTTL),
+				}).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("plain-challenge", nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "code:login:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "code:login:+62812345678").Return(nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User:           verifiedUser(),
+				ChallengeToken: "plain-challenge",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(verifiedUser(), nil)
+				m.EXPECT().IncrementLoginCount(ctx, 1).Return(nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposeLogin).Return(activeCode, nil)
+				m.EXPECT().ConsumeVerificationCode(ctx, 7).Return(true, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "code:login:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "code:login:+62812345678").Return(nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User:         verifiedUser(),
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			got, err := m.VerifyOTPLogin(ctx, "0812-345-678", "123456", "192.0.2.1")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
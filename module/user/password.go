@@ -0,0 +1,262 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
+)
+
+const (
+	// blockedPasswordMessage tells user to pick another password without saying which list it was found in.
+	blockedPasswordMessage = "password is too common or has appeared in a data breach, choose a different one"
+	// reusedPasswordMessage tells user the password is among the ones refused by the history depth of the policy.
+	reusedPasswordMessage = "password has been used recently, choose a different one"
+)
+
+// GetPasswordPolicy returns the policy every new password is validated against.
+func (m *UserModule) GetPasswordPolicy(ctx context.Context) validator.PasswordPolicy {
+	return m.passwordPolicy
+}
+
+// validatePassword checks the rules of the password policy, then whether the password is blocked.
+// The blocklist is only consulted for a password that follows the rules.
+func (m *UserModule) validatePassword(ctx context.Context, password string) ([]string, bool, error) {
+	messages, valid := m.passwordPolicy.Validate(password)
+	if !valid {
+		return messages, false, nil
+	}
+
+	blocked, err := m.passwordBlocklist.IsBlocked(ctx, password)
+	if err != nil {
+		return nil, false, err
+	}
+	if blocked {
+		return []string{blockedPasswordMessage}, false, nil
+	}
+
+	return messages, true, nil
+}
+
+// isPasswordReused tells whether password is one of the latest passwords of the user, as many as the history depth
+// of the policy with the current password counted first.
+func (m *UserModule) isPasswordReused(ctx context.Context, user *entity.User, password string) (bool, error) {
+	depth := m.passwordPolicy.HistoryDepth
+	if depth <= 0 {
+		return false, nil
+	}
+
+	if m.hash.ComparePassword([]byte(user.HashedPassword), password) == nil {
+		return true, nil
+	}
+	if depth == 1 {
+		return false, nil
+	}
+
+	history, err := m.userRepository.GetPasswordHistory(ctx, user.ID, depth-1)
+	if err != nil {
+		return false, err
+	}
+	for _, previous := range history {
+		if m.hash.ComparePassword([]byte(previous.HashedPassword), password) == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// updatePassword stores the new hashed password of the user, keeping just enough previous passwords
+// for isPasswordReused. It returns the new token version.
+func (m *UserModule) updatePassword(ctx context.Context, user *entity.User) (int, error) {
+	keepHistory := m.passwordPolicy.HistoryDepth - 1
+	if keepHistory < 0 {
+		keepHistory = 0
+	}
+
+	return m.userRepository.UpdatePassword(ctx, user.ID, user.HashedPassword, keepHistory)
+}
+
+// ChangePassword replaces password of a logged in user after checking the current one.
+// Every jwt and refresh token issued before stops working, except the new pair returned here.
+func (m *UserModule) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) (entity.ChangePasswordModuleResponse, error) {
+	resp := entity.ChangePasswordModuleResponse{
+		Valid:    true,
+		Messages: []string{},
+	}
+
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return resp, err
+	}
+
+	// validate request
+	err = m.hash.ComparePassword([]byte(user.HashedPassword), currentPassword)
+	if err != nil {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, "current password is not correct")
+	}
+	messages, valid, err := m.validatePassword(ctx, newPassword)
+	if err != nil {
+		return resp, err
+	}
+	if !valid {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, messages...)
+	}
+	if newPassword == currentPassword {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, "new password must be different from current password")
+	}
+
+	if !resp.Valid {
+		return resp, nil
+	}
+
+	var reused bool
+	reused, err = m.isPasswordReused(ctx, user, newPassword)
+	if err != nil {
+		return resp, err
+	}
+	if reused {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, reusedPasswordMessage)
+		return resp, nil
+	}
+
+	user.PlainPassword = newPassword
+	err = user.HashPassword(m.hash)
+	if err != nil {
+		return resp, err
+	}
+
+	// bumping token version invalidates every jwt issued before
+	user.TokenVersion, err = m.updatePassword(ctx, user)
+	if err != nil {
+		return resp, err
+	}
+
+	err = m.refreshTokenRepository.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.JWT, err = m.jwt.Generate(user.Claims())
+	if err != nil {
+		return resp, err
+	}
+
+	resp.RefreshToken, err = m.issueRefreshToken(ctx, user.ID, "")
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// ForgotPassword sends a password reset code to the phone number of an active user.
+// It succeeds whether the phone number is registered or not, to avoid revealing registered users.
+func (m *UserModule) ForgotPassword(ctx context.Context, phoneNumber string) error {
+	phoneNumber = m.normalizePhoneNumber(phoneNumber)
+
+	user, err := m.userRepository.GetUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.Exist() || entity.CheckUserStatus(user.Status) != nil {
+		return nil
+	}
+
+	err = m.issueVerificationCode(ctx, phoneNumber, entity.VerificationPurposePasswordReset)
+	if err != nil {
+		// failing only for registered phone numbers would reveal them, so the error is logged instead
+		log.Printf("forgot password: issue code for user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using the code sent by ForgotPassword.
+// Every jwt and refresh token issued before stops working, user has to log in again.
+func (m *UserModule) ResetPassword(ctx context.Context, phoneNumber, code, newPassword string) (entity.ResetPasswordModuleResponse, error) {
+	resp := entity.ResetPasswordModuleResponse{
+		Valid:    true,
+		Messages: []string{},
+	}
+
+	// validate request before the code is tried, so a rejected password does not use up an attempt
+	messages, valid, err := m.validatePassword(ctx, newPassword)
+	if err != nil {
+		return resp, err
+	}
+	if !valid {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, messages...)
+		return resp, nil
+	}
+
+	phoneNumber = m.normalizePhoneNumber(phoneNumber)
+
+	var verification *entity.VerificationCode
+	verification, err = m.checkCode(ctx, phoneNumber, entity.VerificationPurposePasswordReset, code)
+	if err != nil {
+		return resp, err
+	}
+
+	var user *entity.User
+	user, err = m.userRepository.GetUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return resp, ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	if !user.Exist() {
+		return resp, ErrInvalidVerificationCode
+	}
+
+	// user who became unable to log in after the code was sent cannot use it
+	err = entity.CheckUserStatus(user.Status)
+	if err != nil {
+		log.Printf("reset password: refuse user %d: %v", user.ID, err)
+		return resp, entity.Obscure(ErrInvalidVerificationCode, err)
+	}
+
+	// the code is left unused, so user can try again with a different password
+	var reused bool
+	reused, err = m.isPasswordReused(ctx, user, newPassword)
+	if err != nil {
+		return resp, err
+	}
+	if reused {
+		resp.Valid = false
+		resp.Messages = append(resp.Messages, reusedPasswordMessage)
+		return resp, nil
+	}
+
+	err = m.consumeCode(ctx, verification)
+	if err != nil {
+		return resp, err
+	}
+
+	user.PlainPassword = newPassword
+	err = user.HashPassword(m.hash)
+	if err != nil {
+		return resp, err
+	}
+
+	_, err = m.updatePassword(ctx, user)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, m.refreshTokenRepository.RevokeUserRefreshTokens(ctx, user.ID)
+}
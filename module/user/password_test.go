@@ -0,0 +1,1044 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_GetPasswordPolicy(t *testing.T) {
+	policy := validator.PasswordPolicy{
+		MinLength:    12,
+		MaxLength:    128,
+		MinStrength:  3,
+		HistoryDepth: 5,
+	}
+
+	m := New(NewUserModuleOptions{
+		PasswordPolicy: policy,
+	})
+	assert.Equal(t, policy, m.GetPasswordPolicy(context.Background()))
+
+	m = New(NewUserModuleOptions{})
+	assert.Equal(t, validator.DefaultPasswordPolicy(), m.GetPasswordPolicy(context.Background()))
+}
+
+func TestUserModule_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		passwordPolicy:  validator.DefaultPasswordPolicy(),
+		refreshTokenTTL: time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	currentUser := func() *entity.User {
+		return &entity.User{
+			ID:             15,
+			Fullname:       "John Doe",
+			HashedPassword: "old hashed password",
+			Status:         entity.UserStatusActive,
+			TokenVersion:   1,
+		}
+	}
+	tests := []struct {
+		name                    string
+		userID                  int
+		currentPassword         string
+		newPassword             string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareHash             func(m *tools.MockHashInterface)
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareBlocklist        func(m *tools.MockPasswordBlocklistInterface)
+		want                    entity.ChangePasswordModuleResponse
+		wantErr                 bool
+	}{
+		{
+			name:            "error get user",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "invalid request",
+			userID:          15,
+			currentPassword: "wrong",
+			newPassword:     "wrong",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "wrong").Return(assert.AnError)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"current password is not correct",
+					"password must be 6-64 characters",
+					"password must contain at least 1 uppercase letter, 1 number, and 1 special character",
+					"new password must be different from current password",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:            "error check password blocklist",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, assert.AnError)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "blocked password",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "Password1!",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Password1!").Return(true, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password is too common or has appeared in a data breach, choose a different one",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:            "error get password history",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return(nil, assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "previous password is reused",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password has been used recently, choose a different one",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:            "error hash password",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return(nil, assert.AnError)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "error update password",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+				m.EXPECT().UpdatePassword(ctx, 15, "new hashed password", 4).Return(0, assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return([]byte("new hashed password"), nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "error revoke refresh tokens",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+				m.EXPECT().UpdatePassword(ctx, 15, "new hashed password", 4).Return(2, nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return([]byte("new hashed password"), nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "error generate jwt",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+				m.EXPECT().UpdatePassword(ctx, 15, "new hashed password", 4).Return(2, nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return([]byte("new hashed password"), nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+				}).Return("", assert.AnError)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "success",
+			userID:          15,
+			currentPassword: "Old@123",
+			newPassword:     "New@123",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(currentUser(), nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+				m.EXPECT().UpdatePassword(ctx, 15, "new hashed password", 4).Return(2, nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(nil)
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("new-token"),
+					FamilyID:  "new-family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "Old@123").Return(nil)
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return([]byte("new hashed password"), nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject:      "15",
+					TokenVersion: 2,
+				}).Return("new jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("new-family", nil)
+				m.EXPECT().Token(32).Return("new-token", nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ChangePasswordModuleResponse{
+				Valid:        true,
+				Messages:     []string{},
+				JWT:          "new jwt token",
+				RefreshToken: "new-token",
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockHash := tools.NewMockHashInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockBlocklist := tools.NewMockPasswordBlocklistInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareHash != nil {
+				tt.prepareHash(mockHash)
+			}
+			m.hash = mockHash
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareBlocklist != nil {
+				tt.prepareBlocklist(mockBlocklist)
+			}
+			m.passwordBlocklist = mockBlocklist
+
+			got, err := m.ChangePassword(ctx, tt.userID, tt.currentPassword, tt.newPassword)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_ForgotPassword(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                    string
+		phoneNumber             string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareSMS              func(m *tools.MockSMSSenderInterface)
+		wantErr                 bool
+	}{
+		{
+			name:        "unregistered phone number",
+			phoneNumber: "628123456789",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(nil, sql.ErrNoRows)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "error get user",
+			phoneNumber: "628123456789",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(nil, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "suspended user",
+			phoneNumber: "628123456789",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+628123456789",
+					Status:      entity.UserStatusSuspended,
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "error send code is not revealed",
+			phoneNumber: "628123456789",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+628123456789",
+					Status:      entity.UserStatusActive,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, gomock.Any()).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "+628123456789", gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: false,
+		},
+		{
+			name:        "success",
+			phoneNumber: "628123456789",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+628123456789",
+					Status:      entity.UserStatusActive,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, &entity.VerificationCode{
+					PhoneNumber: "+628123456789",
+					Purpose:     entity.VerificationPurposePasswordReset,
+					CodeHash:    "hashed code",
+					ExpiresAt:   now.Add(VerificationCodeTTL),
+				}).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "+628123456789", gomock.Any()).Return(nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockSMS := tools.NewMockSMSSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareSMS != nil {
+				tt.prepareSMS(mockSMS)
+			}
+			m.smsSender = mockSMS
+
+			err := m.ForgotPassword(ctx, tt.phoneNumber)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestUserModule_ResetPassword(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		passwordPolicy:     validator.DefaultPasswordPolicy(),
+		defaultPhoneRegion: "ID",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	// a matching code that is not consumed yet
+	matchedCode := func(m *repository.MockVerificationRepositoryInterface) {
+		m.EXPECT().GetActiveVerificationCode(ctx, "+628123456789", entity.VerificationPurposePasswordReset).Return(&entity.VerificationCode{
+			ID:          1,
+			PhoneNumber: "+628123456789",
+			Purpose:     entity.VerificationPurposePasswordReset,
+			CodeHash:    "hashed code",
+			ExpiresAt:   now.Add(time.Minute),
+		}, nil)
+	}
+	// a matching code that is consumed successfully
+	validCode := func(m *repository.MockVerificationRepositoryInterface) {
+		matchedCode(m)
+		m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(true, nil)
+	}
+	// an attempt counted against codes sent for password reset
+	codeAttempt := func(m *repository.MockThrottleRepositoryInterface) {
+		m.EXPECT().RecordLoginFailure(ctx, "code:password_reset:+628123456789", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+	}
+	// attempts are forgotten once the code is consumed
+	codeUsed := func(m *repository.MockThrottleRepositoryInterface) {
+		codeAttempt(m)
+		m.EXPECT().ResetLoginFailures(ctx, "code:password_reset:+628123456789").Return(nil)
+	}
+	activeUser := func(m *repository.MockUserRepositoryInterface) {
+		m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(&entity.User{
+			ID:             15,
+			HashedPassword: "old hashed password",
+			Status:         entity.UserStatusActive,
+		}, nil)
+	}
+	tests := []struct {
+		name                    string
+		newPassword             string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		prepareHash             func(m *tools.MockHashInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareBlocklist        func(m *tools.MockPasswordBlocklistInterface)
+		want                    entity.ResetPasswordModuleResponse
+		wantErr                 error
+	}{
+		{
+			name:        "invalid password",
+			newPassword: "weak",
+			want: entity.ResetPasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password must be 6-64 characters",
+					"password must contain at least 1 uppercase letter, 1 number, and 1 special character",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "error check password blocklist",
+			newPassword: "New@123",
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, assert.AnError)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:        "blocked password",
+			newPassword: "Password1!",
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Password1!").Return(true, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password is too common or has appeared in a data breach, choose a different one",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "invalid code",
+			newPassword: "New@123",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name:                    "user no longer exists",
+			newPassword:             "New@123",
+			prepareVerificationRepo: matchedCode,
+			prepareThrottleRepo:     codeAttempt,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(nil, sql.ErrNoRows)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name:                    "error get user",
+			newPassword:             "New@123",
+			prepareVerificationRepo: matchedCode,
+			prepareThrottleRepo:     codeAttempt,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(nil, assert.AnError)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:                    "error get password history",
+			newPassword:             "New@123",
+			prepareVerificationRepo: matchedCode,
+			prepareThrottleRepo:     codeAttempt,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				activeUser(m)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return(nil, assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:                    "current password is reused",
+			newPassword:             "New@123",
+			prepareVerificationRepo: matchedCode,
+			prepareThrottleRepo:     codeAttempt,
+			prepareRepo:             activeUser,
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password has been used recently, choose a different one",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:                    "previous password is reused",
+			newPassword:             "New@123",
+			prepareVerificationRepo: matchedCode,
+			prepareThrottleRepo:     codeAttempt,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				activeUser(m)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password has been used recently, choose a different one",
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "error consume code",
+			newPassword: "New@123",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				matchedCode(m)
+				m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(false, assert.AnError)
+			},
+			prepareThrottleRepo: codeAttempt,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				activeUser(m)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:                    "error update password",
+			newPassword:             "New@123",
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(&entity.User{
+					ID:             15,
+					HashedPassword: "old hashed password",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+				m.EXPECT().UpdatePassword(ctx, 15, "new hashed password", 4).Return(0, assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return([]byte("new hashed password"), nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:                    "success",
+			newPassword:             "New@123",
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+628123456789").Return(&entity.User{
+					ID:             15,
+					HashedPassword: "old hashed password",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().GetPasswordHistory(ctx, 15, 4).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+				}, nil)
+				m.EXPECT().UpdatePassword(ctx, 15, "new hashed password", 4).Return(1, nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().HashPassword("New@123").Return([]byte("new hashed password"), nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "New@123").Return(false, nil)
+			},
+			want: entity.ResetPasswordModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	mockHash := tools.NewMockHashInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockBlocklist := tools.NewMockPasswordBlocklistInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			if tt.prepareHash != nil {
+				tt.prepareHash(mockHash)
+			}
+			m.hash = mockHash
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareBlocklist != nil {
+				tt.prepareBlocklist(mockBlocklist)
+			}
+			m.passwordBlocklist = mockBlocklist
+
+			got, err := m.ResetPassword(ctx, "0812-3456-789", "123456", tt.newPassword)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_isPasswordReused(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	user := &entity.User{
+		ID:             15,
+		HashedPassword: "old hashed password",
+	}
+	tests := []struct {
+		name         string
+		historyDepth int
+		prepareRepo  func(m *repository.MockUserRepositoryInterface)
+		prepareHash  func(m *tools.MockHashInterface)
+		want         bool
+		wantErr      bool
+	}{
+		{
+			name:         "history is disabled",
+			historyDepth: 0,
+			want:         false,
+			wantErr:      false,
+		},
+		{
+			name:         "only current password is checked",
+			historyDepth: 1,
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:         "not reused",
+			historyDepth: 3,
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetPasswordHistory(ctx, 15, 2).Return([]*entity.PasswordHistory{
+					{
+						ID:             3,
+						UserID:         15,
+						HashedPassword: "previous hashed password",
+					},
+					{
+						ID:             2,
+						UserID:         15,
+						HashedPassword: "older hashed password",
+					},
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("old hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("previous hashed password"), "New@123").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("older hashed password"), "New@123").Return(assert.AnError)
+			},
+			want:    false,
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockHash := tools.NewMockHashInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.passwordPolicy.HistoryDepth = tt.historyDepth
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareHash != nil {
+				tt.prepareHash(mockHash)
+			}
+			m.hash = mockHash
+
+			got, err := m.isPasswordReused(ctx, user, "New@123")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
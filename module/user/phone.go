@@ -0,0 +1,137 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+var (
+	// ErrPhoneNumberVerified is returned when user has no phone number waiting for verification.
+	ErrPhoneNumberVerified = errors.New("phone number has already been verified")
+	// ErrPhoneNumberConflict is returned when the phone number has been taken by another user.
+	ErrPhoneNumberConflict = errors.New("phone number already exist")
+)
+
+// RequestPhoneVerification sends a code to the phone number of user waiting for verification,
+// which is the pending phone number if user is changing it.
+func (m *UserModule) RequestPhoneVerification(ctx context.Context, userID int) error {
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	phoneNumber := user.PhoneNumberToVerify()
+	if phoneNumber == "" {
+		return ErrPhoneNumberVerified
+	}
+
+	return m.issueVerificationCode(ctx, phoneNumber, entity.VerificationPurposePhoneVerification)
+}
+
+// ConfirmPhoneVerification verifies the phone number with the code sent by RequestPhoneVerification,
+// returning the verified phone number. A pending phone number replaces the current one as login identifier.
+func (m *UserModule) ConfirmPhoneVerification(ctx context.Context, userID int, code string) (string, error) {
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	phoneNumber := user.PhoneNumberToVerify()
+	if phoneNumber == "" {
+		return "", ErrPhoneNumberVerified
+	}
+
+	// pending phone number might have been registered by another user in the meantime,
+	// checked before the code is tried so it is not used up
+	if phoneNumber != user.PhoneNumber && m.isPhoneNumberExist(ctx, phoneNumber) {
+		return "", ErrPhoneNumberConflict
+	}
+
+	err = m.verifyCode(ctx, phoneNumber, entity.VerificationPurposePhoneVerification, code)
+	if err != nil {
+		return "", err
+	}
+
+	var verified bool
+	verified, err = m.userRepository.VerifyPhoneNumber(ctx, user.ID, phoneNumber)
+	if err != nil {
+		return "", err
+	}
+	if !verified {
+		// user changed the pending phone number after the code was sent
+		return "", ErrInvalidVerificationCode
+	}
+
+	return phoneNumber, nil
+}
+
+// RequestActivation sends a new code to the phone number of a pending user, replacing the one sent by Register.
+// It succeeds whether a pending user has the phone number or not, to avoid revealing registered users.
+func (m *UserModule) RequestActivation(ctx context.Context, phoneNumber string) error {
+	phoneNumber = m.normalizePhoneNumber(phoneNumber)
+
+	user, err := m.userRepository.GetUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.Exist() || user.Status != entity.UserStatusPending {
+		return nil
+	}
+
+	err = m.issueVerificationCode(ctx, phoneNumber, entity.VerificationPurposePhoneVerification)
+	if err != nil {
+		// failing only for registered phone numbers would reveal them, so the error is logged instead
+		log.Printf("request activation: issue code for user %d: %v", user.ID, err)
+	}
+
+	return nil
+}
+
+// ActivateUser verifies the phone number of a pending user with the code sent by Register or RequestActivation,
+// after which the user can log in.
+func (m *UserModule) ActivateUser(ctx context.Context, phoneNumber, code string) error {
+	phoneNumber = m.normalizePhoneNumber(phoneNumber)
+
+	verification, err := m.checkCode(ctx, phoneNumber, entity.VerificationPurposePhoneVerification, code)
+	if err != nil {
+		return err
+	}
+
+	var user *entity.User
+	user, err = m.userRepository.GetUserByPhoneNumber(ctx, phoneNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return err
+	}
+
+	// any other user verifies their phone number with ConfirmPhoneVerification
+	if !user.Exist() || user.Status != entity.UserStatusPending {
+		return ErrInvalidVerificationCode
+	}
+
+	err = m.consumeCode(ctx, verification)
+	if err != nil {
+		return err
+	}
+
+	var verified bool
+	verified, err = m.userRepository.VerifyPhoneNumber(ctx, user.ID, phoneNumber)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrInvalidVerificationCode
+	}
+
+	return nil
+}
@@ -0,0 +1,556 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_RequestPhoneVerification(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                    string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareSMS              func(m *tools.MockSMSSenderInterface)
+		wantErr                 error
+	}{
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "phone number has been verified",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:              15,
+					PhoneNumber:     "628123456789",
+					PhoneVerifiedAt: &now,
+				}, nil)
+			},
+			wantErr: ErrPhoneNumberVerified,
+		},
+		{
+			name: "success pending phone number",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:                 15,
+					PhoneNumber:        "628123456789",
+					PhoneVerifiedAt:    &now,
+					PendingPhoneNumber: "628123456780",
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456780", entity.VerificationPurposePhoneVerification).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, &entity.VerificationCode{
+					PhoneNumber: "628123456780",
+					Purpose:     entity.VerificationPurposePhoneVerification,
+					CodeHash:    "hashed code",
+					ExpiresAt:   now.Add(VerificationCodeTTL),
+				}).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "628123456780", "Your phone verification code is 123456. It expires in 10 minutes, never share it with anyone.").Return(nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockSMS := tools.NewMockSMSSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareSMS != nil {
+				tt.prepareSMS(mockSMS)
+			}
+			m.smsSender = mockSMS
+
+			err := m.RequestPhoneVerification(ctx, 15)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestUserModule_ConfirmPhoneVerification(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	pendingUser := func() *entity.User {
+		return &entity.User{
+			ID:                 15,
+			PhoneNumber:        "628123456789",
+			PhoneVerifiedAt:    &now,
+			PendingPhoneNumber: "628123456780",
+		}
+	}
+	// a matching code that is consumed successfully
+	validCode := func(m *repository.MockVerificationRepositoryInterface) {
+		m.EXPECT().GetActiveVerificationCode(ctx, "628123456780", entity.VerificationPurposePhoneVerification).Return(&entity.VerificationCode{
+			ID:          1,
+			PhoneNumber: "628123456780",
+			Purpose:     entity.VerificationPurposePhoneVerification,
+			CodeHash:    "hashed code",
+			ExpiresAt:   now.Add(time.Minute),
+		}, nil)
+		m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(true, nil)
+	}
+	// attempts counted against codes sent for phone verification are forgotten once the code is consumed
+	codeUsed := func(m *repository.MockThrottleRepositoryInterface) {
+		m.EXPECT().RecordLoginFailure(ctx, "code:phone_verification:628123456780", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+		m.EXPECT().ResetLoginFailures(ctx, "code:phone_verification:628123456780").Return(nil)
+	}
+	tests := []struct {
+		name                    string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		want                    string
+		wantErr                 error
+	}{
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "phone number has been verified",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:              15,
+					PhoneNumber:     "628123456789",
+					PhoneVerifiedAt: &now,
+				}, nil)
+			},
+			wantErr: ErrPhoneNumberVerified,
+		},
+		{
+			name: "pending phone number has been taken",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(pendingUser(), nil)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "628123456780").Return(&entity.User{ID: 16}, nil)
+			},
+			wantErr: ErrPhoneNumberConflict,
+		},
+		{
+			name: "invalid code",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(pendingUser(), nil)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "628123456780").Return(nil, sql.ErrNoRows)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456780", entity.VerificationPurposePhoneVerification).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "error verify phone number",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(pendingUser(), nil)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "628123456780").Return(nil, sql.ErrNoRows)
+				m.EXPECT().VerifyPhoneNumber(ctx, 15, "628123456780").Return(false, assert.AnError)
+			},
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "pending phone number changed",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(pendingUser(), nil)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "628123456780").Return(nil, sql.ErrNoRows)
+				m.EXPECT().VerifyPhoneNumber(ctx, 15, "628123456780").Return(false, nil)
+			},
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(pendingUser(), nil)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "628123456780").Return(nil, sql.ErrNoRows)
+				m.EXPECT().VerifyPhoneNumber(ctx, 15, "628123456780").Return(true, nil)
+			},
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			want:    "628123456780",
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			got, err := m.ConfirmPhoneVerification(ctx, 15, "123456")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_RequestActivation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                    string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareSMS              func(m *tools.MockSMSSenderInterface)
+		wantErr                 error
+	}{
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "phone number not registered",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(nil, sql.ErrNoRows)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "user is not pending",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+6281234567890",
+					Status:      entity.UserStatusActive,
+				}, nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "error issue code is not reported",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+6281234567890",
+					Status:      entity.UserStatusPending,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+6281234567890", entity.VerificationPurposePhoneVerification).Return(nil, assert.AnError)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+6281234567890",
+					Status:      entity.UserStatusPending,
+				}, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+6281234567890", entity.VerificationPurposePhoneVerification).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, &entity.VerificationCode{
+					PhoneNumber: "+6281234567890",
+					Purpose:     entity.VerificationPurposePhoneVerification,
+					CodeHash:    "hashed code",
+					ExpiresAt:   now.Add(VerificationCodeTTL),
+				}).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "+6281234567890", "Your phone verification code is 123456. It expires in 10 minutes, never share it with anyone.").Return(nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockSMS := tools.NewMockSMSSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareSMS != nil {
+				tt.prepareSMS(mockSMS)
+			}
+			m.smsSender = mockSMS
+
+			err := m.RequestActivation(ctx, "081234567890")
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestUserModule_ActivateUser(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	pendingUser := func() *entity.User {
+		return &entity.User{
+			ID:          15,
+			PhoneNumber: "+6281234567890",
+			Status:      entity.UserStatusPending,
+		}
+	}
+	// a matching code, the attempt is counted before comparing
+	matchingCode := func(m *repository.MockVerificationRepositoryInterface) {
+		m.EXPECT().GetActiveVerificationCode(ctx, "+6281234567890", entity.VerificationPurposePhoneVerification).Return(&entity.VerificationCode{
+			ID:          1,
+			PhoneNumber: "+6281234567890",
+			Purpose:     entity.VerificationPurposePhoneVerification,
+			CodeHash:    "hashed code",
+			ExpiresAt:   now.Add(time.Minute),
+		}, nil)
+	}
+	codeTried := func(m *repository.MockThrottleRepositoryInterface) {
+		m.EXPECT().RecordLoginFailure(ctx, "code:phone_verification:+6281234567890", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+	}
+	codeMatches := func(m *tools.MockHashInterface) {
+		m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+	}
+	// a matching code that is consumed successfully
+	validCode := func(m *repository.MockVerificationRepositoryInterface) {
+		matchingCode(m)
+		m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(true, nil)
+	}
+	codeUsed := func(m *repository.MockThrottleRepositoryInterface) {
+		codeTried(m)
+		m.EXPECT().ResetLoginFailures(ctx, "code:phone_verification:+6281234567890").Return(nil)
+	}
+	tests := []struct {
+		name                    string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		wantErr                 error
+	}{
+		{
+			name: "invalid code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+6281234567890", entity.VerificationPurposePhoneVerification).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(nil, assert.AnError)
+			},
+			prepareVerificationRepo: matchingCode,
+			prepareThrottleRepo:     codeTried,
+			prepareCodeHash:         codeMatches,
+			wantErr:                 assert.AnError,
+		},
+		{
+			name: "user is not pending",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(&entity.User{
+					ID:          15,
+					PhoneNumber: "+6281234567890",
+					Status:      entity.UserStatusSuspended,
+				}, nil)
+			},
+			prepareVerificationRepo: matchingCode,
+			prepareThrottleRepo:     codeTried,
+			prepareCodeHash:         codeMatches,
+			wantErr:                 ErrInvalidVerificationCode,
+		},
+		{
+			name: "code used by another request",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(pendingUser(), nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				matchingCode(m)
+				m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(false, nil)
+			},
+			prepareThrottleRepo: codeTried,
+			prepareCodeHash:     codeMatches,
+			wantErr:             ErrInvalidVerificationCode,
+		},
+		{
+			name: "error verify phone number",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(pendingUser(), nil)
+				m.EXPECT().VerifyPhoneNumber(ctx, 15, "+6281234567890").Return(false, assert.AnError)
+			},
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareCodeHash:         codeMatches,
+			wantErr:                 assert.AnError,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+6281234567890").Return(pendingUser(), nil)
+				m.EXPECT().VerifyPhoneNumber(ctx, 15, "+6281234567890").Return(true, nil)
+			},
+			prepareVerificationRepo: validCode,
+			prepareThrottleRepo:     codeUsed,
+			prepareCodeHash:         codeMatches,
+			wantErr:                 nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			err := m.ActivateUser(ctx, "081234567890", "123456")
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
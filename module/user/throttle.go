@@ -0,0 +1,226 @@
+package user
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// LoginLockoutDuration is how long a phone number, email, or client ip stays locked after too many failed logins.
+	LoginLockoutDuration = time.Minute * 15
+	// LoginFailureWindow is how long a failed login is remembered, counting starts over after a quiet period this long.
+	LoginFailureWindow = time.Hour
+	// loginBackoffBase is the delay after the first failure that is not free, doubled on every failure after it.
+	loginBackoffBase = time.Second
+)
+
+// loginThrottleKey is a key failed logins are counted under along with its policy.
+type loginThrottleKey struct {
+	key    string
+	policy loginThrottlePolicy
+}
+
+// loginThrottlePolicy decides how long a key is refused after a number of failed logins.
+type loginThrottlePolicy struct {
+	// freeAttempts is the number of failures allowed without any delay.
+	freeAttempts int
+	// lockoutFailures is the number of failures that locks the key for LoginLockoutDuration.
+	lockoutFailures int
+}
+
+var (
+	// accountThrottlePolicy is strict, one account is rarely mistyped more than a few times.
+	accountThrottlePolicy = loginThrottlePolicy{
+		freeAttempts:    3,
+		lockoutFailures: 10,
+	}
+	// ipThrottlePolicy is loose, many users may share one address behind a nat.
+	ipThrottlePolicy = loginThrottlePolicy{
+		freeAttempts:    10,
+		lockoutFailures: 50,
+	}
+)
+
+// LoginThrottledError is returned by Login while the phone number, the email, or the client ip is throttled.
+type LoginThrottledError struct {
+	// RetryAfter is how long until the next attempt is allowed.
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// retryAfter returns the delay that follows the given number of failures, growing exponentially until the lockout.
+func (p loginThrottlePolicy) retryAfter(failures int) time.Duration {
+	if failures < p.freeAttempts {
+		return 0
+	}
+	if failures >= p.lockoutFailures {
+		return LoginLockoutDuration
+	}
+
+	// shifting is bounded so the delay never overflows before being capped
+	shift := failures - p.freeAttempts
+	if shift > 30 {
+		return LoginLockoutDuration
+	}
+	delay := loginBackoffBase << shift
+	if delay > LoginLockoutDuration {
+		return LoginLockoutDuration
+	}
+
+	return delay
+}
+
+// loginThrottleKeys returns the keys failed logins of the account and the client ip are counted under,
+// accountKey being either phoneThrottleKey or emailThrottleKey. Client ip is left out when unknown.
+func loginThrottleKeys(accountKey, clientIP string) []loginThrottleKey {
+	keys := []loginThrottleKey{
+		{
+			key:    accountKey,
+			policy: accountThrottlePolicy,
+		},
+	}
+	if clientIP != "" {
+		keys = append(keys, loginThrottleKey{
+			key:    ipThrottleKey(clientIP),
+			policy: ipThrottlePolicy,
+		})
+	}
+	return keys
+}
+
+// phoneThrottleKey returns the key failed logins of a phone number are counted under.
+func phoneThrottleKey(phoneNumber string) string {
+	return "phone:" + phoneNumber
+}
+
+// emailThrottleKey returns the key failed logins of an email are counted under.
+func emailThrottleKey(email string) string {
+	return "email:" + email
+}
+
+// ipThrottleKey returns the key failed logins from a client ip are counted under.
+func ipThrottleKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// checkLoginThrottle returns LoginThrottledError when any of the keys must still wait before the next attempt.
+func (m *UserModule) checkLoginThrottle(ctx context.Context, accountKey, clientIP string) error {
+	_, err := m.loginFailures(ctx, loginThrottleKeys(accountKey, clientIP))
+	return err
+}
+
+// loginFailures returns the number of failures still remembered under each of the keys,
+// or LoginThrottledError when any of them must still wait before the next attempt.
+func (m *UserModule) loginFailures(ctx context.Context, keys []loginThrottleKey) (map[string]int, error) {
+	var (
+		now    = m.timeNow()
+		wait   time.Duration
+		counts = make(map[string]int, len(keys))
+	)
+	for _, k := range keys {
+		failures, err := m.throttleRepository.GetLoginFailures(ctx, k.key)
+		if err != nil {
+			return nil, err
+		}
+
+		// failures older than the window are about to be forgotten
+		if failures.Failures == 0 || failures.LastFailedAt.Add(LoginFailureWindow).Before(now) {
+			continue
+		}
+		counts[k.key] = failures.Failures
+
+		remaining := failures.LastFailedAt.Add(k.policy.retryAfter(failures.Failures)).Sub(now)
+		if remaining > wait {
+			wait = remaining
+		}
+	}
+
+	if wait > 0 {
+		return nil, &LoginThrottledError{
+			RetryAfter: wait,
+		}
+	}
+
+	return counts, nil
+}
+
+// startLoginAttempt refuses a throttled attempt like checkLoginThrottle, then counts it as a failed login
+// under the account and the client ip before the password or code is compared. Attempts passing the check
+// at the same time all see the same failures, so the decision is made again on the counts they get back:
+// one counted past the failures that call for a delay is refused with LoginThrottledError.
+// An attempt that succeeds is taken back with finishLoginAttempt.
+func (m *UserModule) startLoginAttempt(ctx context.Context, accountKey, clientIP string) error {
+	keys := loginThrottleKeys(accountKey, clientIP)
+	seen, err := m.loginFailures(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	var (
+		now  = m.timeNow()
+		wait time.Duration
+	)
+
+	err = m.throttleRepository.DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow))
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		var failures int
+		failures, err = m.throttleRepository.RecordLoginFailure(ctx, k.key, now, now.Add(-LoginFailureWindow))
+		if err != nil {
+			return err
+		}
+
+		// failures before this attempt that it has not seen were counted by attempts running at the same time
+		before := failures - 1
+		if before > seen[k.key] {
+			if delay := k.policy.retryAfter(before); delay > wait {
+				wait = delay
+			}
+		}
+	}
+
+	if wait > 0 {
+		return &LoginThrottledError{
+			RetryAfter: wait,
+		}
+	}
+
+	return nil
+}
+
+// finishLoginAttempt forgets failed logins of the account once an attempt started by startLoginAttempt succeeds.
+// Failures of the client ip are kept, one correct password says nothing about the other attempts from there,
+// only the successful attempt itself is taken back. Failing to do so is not reported, the login has succeeded anyway.
+func (m *UserModule) finishLoginAttempt(ctx context.Context, accountKey, clientIP string) {
+	_ = m.throttleRepository.ResetLoginFailures(ctx, accountKey)
+	if clientIP != "" {
+		_ = m.throttleRepository.ForgiveLoginFailure(ctx, ipThrottleKey(clientIP))
+	}
+}
+
+// UnlockUser forgets failed logins of the user so they can try again right away.
+// Failures counted under client ips are left alone since they may belong to an attacker.
+func (m *UserModule) UnlockUser(ctx context.Context, userID int) error {
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// failures are counted under the phone number as normalized by Login
+	err = m.throttleRepository.ResetLoginFailures(ctx, phoneThrottleKey(m.normalizePhoneNumber(user.PhoneNumber)))
+	if err != nil {
+		return err
+	}
+
+	if user.Email == "" {
+		return nil
+	}
+
+	return m.throttleRepository.ResetLoginFailures(ctx, emailThrottleKey(user.Email))
+}
@@ -0,0 +1,390 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottlePolicy_retryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{
+			name:     "free attempt",
+			failures: 2,
+			want:     0,
+		},
+		{
+			name:     "first delay",
+			failures: 3,
+			want:     time.Second,
+		},
+		{
+			name:     "doubled delay",
+			failures: 6,
+			want:     time.Second * 8,
+		},
+		{
+			name:     "locked",
+			failures: 10,
+			want:     LoginLockoutDuration,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, accountThrottlePolicy.retryAfter(tt.failures))
+		})
+	}
+
+	// delay is capped even when lockout comes late
+	assert.Equal(t, LoginLockoutDuration, ipThrottlePolicy.retryAfter(45))
+}
+
+func TestUserModule_checkLoginThrottle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name        string
+		clientIP    string
+		prepareRepo func(m *repository.MockThrottleRepositoryInterface)
+		want        error
+	}{
+		{
+			name:     "error get phone failures",
+			clientIP: "192.0.2.1",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{}, assert.AnError)
+			},
+			want: assert.AnError,
+		},
+		{
+			name:     "unknown client ip",
+			clientIP: "",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     2,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+			},
+			want: nil,
+		},
+		{
+			name:     "delay has passed",
+			clientIP: "192.0.2.1",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     4,
+					LastFailedAt: now.Add(-time.Second * 2),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			want: nil,
+		},
+		{
+			name:     "lockout is forgotten after the window",
+			clientIP: "192.0.2.1",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     30,
+					LastFailedAt: now.Add(-LoginFailureWindow - time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			want: nil,
+		},
+		{
+			name:     "client ip is locked",
+			clientIP: "192.0.2.1",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     4,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key:          "ip:192.0.2.1",
+					Failures:     50,
+					LastFailedAt: now.Add(-time.Minute * 5),
+				}, nil)
+			},
+			want: &LoginThrottledError{
+				RetryAfter: time.Minute * 10,
+			},
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			err := m.checkLoginThrottle(ctx, phoneThrottleKey("62812345678"), tt.clientIP)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestUserModule_startLoginAttempt(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name        string
+		prepareRepo func(m *repository.MockThrottleRepositoryInterface)
+		want        error
+	}{
+		{
+			name: "throttled",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     10,
+					LastFailedAt: now.Add(-time.Minute * 5),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+			},
+			want: &LoginThrottledError{
+				RetryAfter: time.Minute * 10,
+			},
+		},
+		{
+			name: "error delete expired login failures",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key: "phone:62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(assert.AnError)
+			},
+			want: assert.AnError,
+		},
+		{
+			name: "error record login failure",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key: "phone:62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:62812345678", now, now.Add(-LoginFailureWindow)).Return(0, assert.AnError)
+			},
+			want: assert.AnError,
+		},
+		{
+			name: "attempts at the same time within free attempts",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     1,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:62812345678", now, now.Add(-LoginFailureWindow)).Return(3, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(2, nil)
+			},
+			want: nil,
+		},
+		{
+			name: "attempts at the same time past free attempts",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     1,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:62812345678", now, now.Add(-LoginFailureWindow)).Return(6, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(5, nil)
+			},
+			want: &LoginThrottledError{
+				RetryAfter: time.Second * 4,
+			},
+		},
+		{
+			name: "attempts at the same time past the lockout",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     1,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:62812345678", now, now.Add(-LoginFailureWindow)).Return(11, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(10, nil)
+			},
+			want: &LoginThrottledError{
+				RetryAfter: LoginLockoutDuration,
+			},
+		},
+		{
+			name: "delay has passed",
+			prepareRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:62812345678").Return(entity.LoginFailures{
+					Key:          "phone:62812345678",
+					Failures:     4,
+					LastFailedAt: now.Add(-time.Second * 2),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:62812345678", now, now.Add(-LoginFailureWindow)).Return(5, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			want: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			err := m.startLoginAttempt(ctx, phoneThrottleKey("62812345678"), "192.0.2.1")
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestUserModule_UnlockUser(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+	}
+	tests := []struct {
+		name                string
+		prepareRepo         func(m *repository.MockUserRepositoryInterface)
+		prepareThrottleRepo func(m *repository.MockThrottleRepositoryInterface)
+		want                error
+	}{
+		{
+			name: "user not found",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 2).Return(nil, sql.ErrNoRows)
+			},
+			want: ErrUserNotFound,
+		},
+		{
+			name: "error reset login failures",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 2).Return(&entity.User{
+					ID:          2,
+					PhoneNumber: "+62812345678",
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(assert.AnError)
+			},
+			want: assert.AnError,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 2).Return(&entity.User{
+					ID:          2,
+					PhoneNumber: "+62812345678",
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+			},
+			want: nil,
+		},
+		{
+			name: "phone number not stored normalized",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 2).Return(&entity.User{
+					ID:          2,
+					PhoneNumber: "0812-345-678",
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+			},
+			want: nil,
+		},
+		{
+			name: "success with email",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 2).Return(&entity.User{
+					ID:          2,
+					PhoneNumber: "+62812345678",
+					Email:       "john@example.com",
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ResetLoginFailures(ctx, "email:john@example.com").Return(nil)
+			},
+			want: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			err := m.UnlockUser(ctx, 2)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+)
+
+const (
+	// refreshTokenSize is the number of random bytes of an opaque refresh token.
+	refreshTokenSize = 32
+	// familyIDSize is the number of random bytes identifying a chain of rotated refresh tokens.
+	familyIDSize = 16
+)
+
+var (
+	// ErrInvalidRefreshToken obscures whether the refresh token is unknown, expired, or reused.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// RefreshToken exchanges a refresh token for a new jwt and a new refresh token.
+// The presented refresh token can only be used once, using it again revokes the whole family.
+func (m *UserModule) RefreshToken(ctx context.Context, refreshToken string) (entity.LoginModuleResponse, error) {
+	var resp entity.LoginModuleResponse
+
+	current, err := m.refreshTokenRepository.GetRefreshTokenByHash(ctx, crxpto.SHA256(refreshToken))
+	if err != nil {
+		return resp, ErrInvalidRefreshToken
+	}
+
+	if current.Revoked() {
+		// a rotated token being presented again means it has leaked,
+		// so every token descended from the same login is revoked
+		_ = m.refreshTokenRepository.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+		return resp, ErrInvalidRefreshToken
+	}
+
+	if current.Expired(m.timeNow()) {
+		return resp, ErrInvalidRefreshToken
+	}
+
+	var revoked bool
+	revoked, err = m.refreshTokenRepository.RevokeRefreshToken(ctx, current.ID)
+	if err != nil {
+		return resp, ErrInvalidRefreshToken
+	}
+	if !revoked {
+		// another request managed to use the same token first
+		_ = m.refreshTokenRepository.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+		return resp, ErrInvalidRefreshToken
+	}
+
+	resp.User, err = m.userRepository.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		return resp, ErrInvalidRefreshToken
+	}
+
+	err = entity.CheckUserStatus(resp.User.Status)
+	if err != nil {
+		log.Printf("refresh token: refuse user %d: %v", resp.User.ID, err)
+		return resp, entity.Obscure(ErrInvalidRefreshToken, err)
+	}
+
+	resp.JWT, err = m.jwt.Generate(resp.User.Claims())
+	if err != nil {
+		return resp, ErrInvalidRefreshToken
+	}
+
+	resp.RefreshToken, err = m.issueRefreshToken(ctx, resp.User.ID, current.FamilyID)
+	if err != nil {
+		return resp, ErrInvalidRefreshToken
+	}
+
+	return resp, nil
+}
+
+// Logout revokes the jwt of current session, along with its refresh token when given.
+func (m *UserModule) Logout(ctx context.Context, token *entity.RevokedToken, refreshToken string) error {
+	err := m.revocationRepository.DeleteExpiredTokens(ctx, m.timeNow())
+	if err != nil {
+		return err
+	}
+
+	err = m.revocationRepository.RevokeToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	var current *entity.RefreshToken
+	current, err = m.refreshTokenRepository.GetRefreshTokenByHash(ctx, crxpto.SHA256(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// never let a user revoke sessions of another user
+	if current.UserID != token.UserID {
+		return nil
+	}
+
+	return m.refreshTokenRepository.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+}
+
+// LogoutAll revokes every jwt and refresh token issued to a user so far.
+func (m *UserModule) LogoutAll(ctx context.Context, userID int) error {
+	err := m.refreshTokenRepository.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return m.userRepository.IncrementTokenVersion(ctx, userID)
+}
+
+// issueRefreshToken stores the hash of a new opaque token and returns its plain value.
+// Empty family id starts a new family.
+func (m *UserModule) issueRefreshToken(ctx context.Context, userID int, familyID string) (string, error) {
+	var err error
+	if familyID == "" {
+		familyID, err = m.random.Token(familyIDSize)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var plainToken string
+	plainToken, err = m.random.Token(refreshTokenSize)
+	if err != nil {
+		return "", err
+	}
+
+	// revoked tokens are kept until they expire, so reusing one still revokes its family
+	err = m.refreshTokenRepository.DeleteExpiredRefreshTokens(ctx, m.timeNow())
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.refreshTokenRepository.InsertRefreshToken(ctx, &entity.RefreshToken{
+		UserID:    userID,
+		TokenHash: crxpto.SHA256(plainToken),
+		FamilyID:  familyID,
+		ExpiresAt: m.timeNow().Add(m.refreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plainToken, nil
+}
@@ -0,0 +1,646 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_RefreshToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	revokedAt := now.Add(-time.Minute)
+	m := &UserModule{
+		refreshTokenTTL: time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                    string
+		refreshToken            string
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		want                    entity.LoginModuleResponse
+		wantErr                 bool
+	}{
+		{
+			name:         "error get refresh token",
+			refreshToken: "unknown-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("unknown-token")).Return(nil, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "reused token revokes family",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+					RevokedAt: &revokedAt,
+				}, nil)
+				m.EXPECT().RevokeRefreshTokenFamily(ctx, "family").Return(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "expired token",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(-time.Second),
+				}, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "error revoke refresh token",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(false, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "concurrent reuse revokes family",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(false, nil)
+				m.EXPECT().RevokeRefreshTokenFamily(ctx, "family").Return(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "error get user",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(true, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:         "suspended user",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(true, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusSuspended,
+				}, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusSuspended,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:         "error generate jwt",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(true, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusActive,
+				}, nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "15",
+				}).Return("", assert.AnError)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusActive,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:         "error issue refresh token",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(true, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusActive,
+				}, nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "15",
+				}).Return("new jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("", assert.AnError)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusActive,
+				},
+				JWT: "new jwt token",
+			},
+			wantErr: true,
+		},
+		{
+			name:         "success",
+			refreshToken: "old-token",
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("old-token")).Return(&entity.RefreshToken{
+					ID:        1,
+					UserID:    15,
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}, nil)
+				m.EXPECT().RevokeRefreshToken(ctx, 1).Return(true, nil)
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("new-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(2, nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusActive,
+				}, nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "15",
+				}).Return("new jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("new-token", nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:       15,
+					Fullname: "John Doe",
+					Status:   entity.UserStatusActive,
+				},
+				JWT:          "new jwt token",
+				RefreshToken: "new-token",
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			got, err := m.RefreshToken(ctx, tt.refreshToken)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_Logout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	expiresAt := now.Add(time.Minute * 15)
+	tests := []struct {
+		name                    string
+		token                   *entity.RevokedToken
+		refreshToken            string
+		prepareRevocationRepo   func(m *repository.MockRevocationRepositoryInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		wantErr                 bool
+	}{
+		{
+			name: "error delete expired tokens",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error revoke token",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "without refresh token",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}).Return(nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown refresh token",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			refreshToken: "refresh-token",
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}).Return(nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("refresh-token")).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error get refresh token",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			refreshToken: "refresh-token",
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}).Return(nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("refresh-token")).Return(nil, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "refresh token of another user",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			refreshToken: "refresh-token",
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}).Return(nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("refresh-token")).Return(&entity.RefreshToken{
+					ID:       1,
+					UserID:   16,
+					FamilyID: "family",
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "success with refresh token",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			refreshToken: "refresh-token",
+			prepareRevocationRepo: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().DeleteExpiredTokens(ctx, now).Return(nil)
+				m.EXPECT().RevokeToken(ctx, &entity.RevokedToken{
+					TokenID:   "token-id",
+					UserID:    15,
+					ExpiresAt: expiresAt,
+				}).Return(nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().GetRefreshTokenByHash(ctx, crxpto.SHA256("refresh-token")).Return(&entity.RefreshToken{
+					ID:       1,
+					UserID:   15,
+					FamilyID: "family",
+				}, nil)
+				m.EXPECT().RevokeRefreshTokenFamily(ctx, "family").Return(nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRevocationRepo != nil {
+				tt.prepareRevocationRepo(mockRevocationRepo)
+			}
+			m.revocationRepository = mockRevocationRepo
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			err := m.Logout(ctx, tt.token, tt.refreshToken)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestUserModule_LogoutAll(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	tests := []struct {
+		name                    string
+		userID                  int
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		wantErr                 bool
+	}{
+		{
+			name:   "error revoke refresh tokens",
+			userID: 15,
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error increment token version",
+			userID: 15,
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().IncrementTokenVersion(ctx, 15).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "success",
+			userID: 15,
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().RevokeUserRefreshTokens(ctx, 15).Return(nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().IncrementTokenVersion(ctx, 15).Return(nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockRepo)
+			}
+			m.userRepository = mockRepo
+
+			err := m.LogoutAll(ctx, tt.userID)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestUserModule_issueRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		refreshTokenTTL: time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	tests := []struct {
+		name                    string
+		userID                  int
+		familyID                string
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		want                    string
+		wantErr                 bool
+	}{
+		{
+			name:   "error generate family id",
+			userID: 15,
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("", assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error generate token",
+			userID:   15,
+			familyID: "family",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("", assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error delete expired refresh tokens",
+			userID:   15,
+			familyID: "family",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error insert refresh token",
+			userID:   15,
+			familyID: "family",
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(0, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "success new family",
+			userID: 15,
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("new-family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "new-family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			want:    "plain-token",
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			got, err := m.issueRefreshToken(ctx, tt.userID, tt.familyID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
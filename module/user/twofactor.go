@@ -0,0 +1,373 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+)
+
+const (
+	// LoginChallengeTTL is how long user has to enter the second factor after the password.
+	LoginChallengeTTL = time.Minute * 5
+	// MaxLoginChallengeAttempts is how many codes can be tried on one challenge before logging in again.
+	MaxLoginChallengeAttempts = 5
+	// MaxTwoFactorCodeAttempts is how many second-factor codes of a user can be tried, counted across every
+	// challenge until none is tried for LoginFailureWindow or one is accepted.
+	MaxTwoFactorCodeAttempts = 10
+	// loginChallengeSize is the number of random bytes of an opaque login challenge token.
+	loginChallengeSize = 32
+	// recoveryCodeCount is the number of recovery codes given when two-factor authentication is enabled.
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of digits of a recovery code, long enough to never be taken for a totp code.
+	recoveryCodeLength = 10
+)
+
+var (
+	// ErrTwoFactorEnabled is returned when enrolling a user who already has two-factor authentication.
+	ErrTwoFactorEnabled = errors.New("two-factor authentication has already been enabled")
+	// ErrTwoFactorNotEnrolled is returned when confirming without enrolling first.
+	ErrTwoFactorNotEnrolled = errors.New("two-factor authentication has not been enrolled")
+	// ErrTwoFactorNotEnabled is returned when a second factor is required from a user who has none.
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrInvalidTwoFactorCode obscures whether the code is wrong, replayed, or a used recovery code.
+	ErrInvalidTwoFactorCode = errors.New("two-factor code is not valid")
+	// ErrInvalidLoginChallenge obscures whether the challenge is unknown, expired, used, or tried too many times.
+	ErrInvalidLoginChallenge = errors.New("login challenge is not valid")
+)
+
+// EnrollTOTP generates a new totp secret for the user to add to an authenticator app.
+// Two-factor authentication stays disabled until the secret is confirmed by ConfirmTOTP.
+func (m *UserModule) EnrollTOTP(ctx context.Context, userID int) (entity.TOTPEnrollmentModuleResponse, error) {
+	var resp entity.TOTPEnrollmentModuleResponse
+
+	user, err := m.GetUser(ctx, userID)
+	if err != nil {
+		return resp, err
+	}
+
+	var secret string
+	secret, err = m.totp.GenerateSecret()
+	if err != nil {
+		return resp, err
+	}
+
+	// enrolling again before confirming replaces the secret that was never confirmed
+	var stored bool
+	stored, err = m.twoFactorRepository.UpsertTOTP(ctx, &entity.TOTP{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if err != nil {
+		return resp, err
+	}
+	if !stored {
+		return resp, ErrTwoFactorEnabled
+	}
+
+	resp.Secret = secret
+	resp.URI = m.totp.URI(secret, user.PhoneNumber)
+	return resp, nil
+}
+
+// ConfirmTOTP enables two-factor authentication with the first code of the authenticator app,
+// returning recovery codes that are shown to the user only this once. Wrong codes are counted by countTwoFactorAttempt.
+func (m *UserModule) ConfirmTOTP(ctx context.Context, userID int, code string) ([]string, error) {
+	current, err := m.twoFactorRepository.GetTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Enabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	err = m.countTwoFactorAttempt(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := m.totp.Validate(current.Secret, code)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	m.forgetTwoFactorAttempts(ctx, userID)
+
+	var (
+		codes  = make([]string, 0, recoveryCodeCount)
+		hashes = make([]string, 0, recoveryCodeCount)
+	)
+	for i := 0; i < recoveryCodeCount; i++ {
+		var recoveryCode string
+		recoveryCode, err = m.random.Digits(recoveryCodeLength)
+		if err != nil {
+			return nil, err
+		}
+
+		// recovery code is hashed, so a leaked database does not leak usable codes
+		var codeHash []byte
+		codeHash, err = m.codeHash.HashPassword(recoveryCode)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, formatRecoveryCode(recoveryCode))
+		hashes = append(hashes, string(codeHash))
+	}
+
+	var confirmed bool
+	confirmed, err = m.twoFactorRepository.ConfirmTOTP(ctx, userID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		// another request managed to confirm first
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking a totp or recovery code,
+// wrong codes are counted along with the ones tried on logging in.
+func (m *UserModule) DisableTOTP(ctx context.Context, userID int, code string) error {
+	err := m.verifySecondFactor(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+
+	return m.twoFactorRepository.DeleteTOTP(ctx, userID)
+}
+
+// LoginTwoFactor completes the login of a user with two-factor authentication,
+// exchanging the challenge token given by Login along with a totp or recovery code for a jwt.
+func (m *UserModule) LoginTwoFactor(ctx context.Context, challengeToken, code string) (entity.LoginModuleResponse, error) {
+	var resp entity.LoginModuleResponse
+
+	challenge, err := m.twoFactorRepository.GetLoginChallengeByHash(ctx, crxpto.SHA256(challengeToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return resp, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	if challenge.Consumed() || challenge.Expired(m.timeNow()) {
+		return resp, ErrInvalidLoginChallenge
+	}
+
+	// the attempt is counted before comparing, so concurrent guesses cannot exceed the limit
+	var attempts int
+	attempts, err = m.twoFactorRepository.IncrementLoginChallengeAttempts(ctx, challenge.ID)
+	if err != nil {
+		return resp, err
+	}
+	if attempts > MaxLoginChallengeAttempts {
+		return resp, ErrInvalidLoginChallenge
+	}
+
+	resp.User, err = m.GetUser(ctx, challenge.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return resp, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	// user who became unable to log in after the password step cannot finish it
+	err = entity.CheckUserStatus(resp.User.Status)
+	if err != nil {
+		log.Printf("two factor login: refuse user %d: %v", resp.User.ID, err)
+		return resp, entity.Obscure(ErrInvalidLoginChallenge, err)
+	}
+
+	err = m.verifySecondFactor(ctx, resp.User.ID, code)
+	if errors.Is(err, ErrTwoFactorNotEnabled) {
+		// two-factor authentication was turned off after the challenge was issued
+		return resp, ErrInvalidLoginChallenge
+	}
+	if err != nil {
+		return resp, err
+	}
+
+	var consumed bool
+	consumed, err = m.twoFactorRepository.ConsumeLoginChallenge(ctx, challenge.ID)
+	if err != nil {
+		return resp, err
+	}
+	if !consumed {
+		// another request managed to use the same challenge first
+		return resp, ErrInvalidLoginChallenge
+	}
+
+	err = m.startSession(ctx, &resp)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// isTwoFactorEnabled returns true if the user has confirmed a totp secret.
+func (m *UserModule) isTwoFactorEnabled(ctx context.Context, userID int) (bool, error) {
+	current, err := m.twoFactorRepository.GetTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return current.Enabled(), nil
+}
+
+// issueLoginChallenge stores the hash of a new challenge token and returns its plain value.
+func (m *UserModule) issueLoginChallenge(ctx context.Context, userID int) (string, error) {
+	plainToken, err := m.random.Token(loginChallengeSize)
+	if err != nil {
+		return "", err
+	}
+
+	err = m.twoFactorRepository.DeleteExpiredLoginChallenges(ctx, m.timeNow())
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.twoFactorRepository.InsertLoginChallenge(ctx, &entity.LoginChallenge{
+		UserID:    userID,
+		TokenHash: crxpto.SHA256(plainToken),
+		ExpiresAt: m.timeNow().Add(LoginChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return plainToken, nil
+}
+
+// verifySecondFactor accepts either a code of the authenticator app or an unused recovery code.
+// Each of them works only once, and wrong ones are counted by countTwoFactorAttempt.
+func (m *UserModule) verifySecondFactor(ctx context.Context, userID int, code string) error {
+	current, err := m.twoFactorRepository.GetTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTwoFactorNotEnabled
+	}
+	if err != nil {
+		return err
+	}
+
+	if !current.Enabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	err = m.countTwoFactorAttempt(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if recoveryCode := normalizeRecoveryCode(code); len(recoveryCode) == recoveryCodeLength {
+		err = m.useRecoveryCode(ctx, userID, recoveryCode)
+	} else {
+		err = m.useTOTPCode(ctx, current, code)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.forgetTwoFactorAttempts(ctx, userID)
+
+	return nil
+}
+
+// twoFactorThrottleKey returns the key attempts of second-factor codes of a user are counted under.
+func twoFactorThrottleKey(userID int) string {
+	return "2fa:" + strconv.Itoa(userID)
+}
+
+// countTwoFactorAttempt counts an attempt of a second-factor code before it is compared, so concurrent guesses
+// cannot exceed the limit, and per user instead of per challenge, so logging in again with the password does not
+// bring more guesses. ErrInvalidTwoFactorCode is returned once too many codes have been tried.
+func (m *UserModule) countTwoFactorAttempt(ctx context.Context, userID int) error {
+	now := m.timeNow()
+	attempts, err := m.throttleRepository.RecordLoginFailure(ctx, twoFactorThrottleKey(userID), now, now.Add(-LoginFailureWindow))
+	if err != nil {
+		return err
+	}
+	if attempts > MaxTwoFactorCodeAttempts {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// forgetTwoFactorAttempts forgets the attempts counted before a second-factor code is accepted.
+// Failing to do so is not reported, the attempts are forgotten after LoginFailureWindow anyway.
+func (m *UserModule) forgetTwoFactorAttempts(ctx context.Context, userID int) {
+	_ = m.throttleRepository.ResetLoginFailures(ctx, twoFactorThrottleKey(userID))
+}
+
+// useTOTPCode accepts a code of the authenticator app that has not been used in its time step yet.
+func (m *UserModule) useTOTPCode(ctx context.Context, current *entity.TOTP, code string) error {
+	step, ok := m.totp.Validate(current.Secret, code)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// a code stays valid for its whole time step, recording the step keeps it from being replayed
+	used, err := m.twoFactorRepository.UseTOTPStep(ctx, current.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// useRecoveryCode marks the unused recovery code of the user matching the given code as used.
+func (m *UserModule) useRecoveryCode(ctx context.Context, userID int, code string) error {
+	codes, err := m.twoFactorRepository.GetUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, recoveryCode := range codes {
+		if m.codeHash.ComparePassword([]byte(recoveryCode.CodeHash), code) != nil {
+			continue
+		}
+
+		var used bool
+		used, err = m.twoFactorRepository.UseRecoveryCode(ctx, recoveryCode.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			// another request managed to use the same code first
+			return ErrInvalidTwoFactorCode
+		}
+
+		return nil
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+// formatRecoveryCode splits the digits in half so the code is easier to write down.
+func formatRecoveryCode(code string) string {
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
+
+// normalizeRecoveryCode removes separators user might type along with the digits.
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,1003 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_EnrollTOTP(t *testing.T) {
+	ctx := context.Background()
+	m := &UserModule{}
+	user := &entity.User{
+		ID:          15,
+		PhoneNumber: "628123456789",
+	}
+	tests := []struct {
+		name                 string
+		prepareRepo          func(m *repository.MockUserRepositoryInterface)
+		prepareTwoFactorRepo func(m *repository.MockTwoFactorRepositoryInterface)
+		prepareTOTP          func(m *tools.MockTOTPInterface)
+		want                 entity.TOTPEnrollmentModuleResponse
+		wantErr              error
+	}{
+		{
+			name: "user not found",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "error generate secret",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().GenerateSecret().Return("", assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error upsert totp",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().GenerateSecret().Return("JBSWY3DPEHPK3PXP", nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().UpsertTOTP(ctx, &entity.TOTP{
+					UserID: 15,
+					Secret: "JBSWY3DPEHPK3PXP",
+				}).Return(false, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "already enabled",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().GenerateSecret().Return("JBSWY3DPEHPK3PXP", nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().UpsertTOTP(ctx, &entity.TOTP{
+					UserID: 15,
+					Secret: "JBSWY3DPEHPK3PXP",
+				}).Return(false, nil)
+			},
+			wantErr: ErrTwoFactorEnabled,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(user, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().GenerateSecret().Return("JBSWY3DPEHPK3PXP", nil)
+				m.EXPECT().URI("JBSWY3DPEHPK3PXP", "628123456789").Return("otpauth://totp/uri")
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().UpsertTOTP(ctx, &entity.TOTP{
+					UserID: 15,
+					Secret: "JBSWY3DPEHPK3PXP",
+				}).Return(true, nil)
+			},
+			want: entity.TOTPEnrollmentModuleResponse{
+				Secret: "JBSWY3DPEHPK3PXP",
+				URI:    "otpauth://totp/uri",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	mockTOTP := tools.NewMockTOTPInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			if tt.prepareTOTP != nil {
+				tt.prepareTOTP(mockTOTP)
+			}
+			m.totp = mockTOTP
+
+			got, err := m.EnrollTOTP(ctx, 15)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_ConfirmTOTP(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	pending := func() *entity.TOTP {
+		return &entity.TOTP{
+			UserID: 15,
+			Secret: "JBSWY3DPEHPK3PXP",
+		}
+	}
+	hashes := make([]string, recoveryCodeCount)
+	codes := make([]string, recoveryCodeCount)
+	for i := range hashes {
+		hashes[i] = "hashed recovery code"
+		codes[i] = "01234-56789"
+	}
+	tests := []struct {
+		name                 string
+		prepareTwoFactorRepo func(m *repository.MockTwoFactorRepositoryInterface)
+		prepareTOTP          func(m *tools.MockTOTPInterface)
+		prepareRandom        func(m *tools.MockRandomInterface)
+		prepareCodeHash      func(m *tools.MockHashInterface)
+		prepareThrottleRepo  func(m *repository.MockThrottleRepositoryInterface)
+		want                 []string
+		wantErr              error
+	}{
+		{
+			name: "not enrolled",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrTwoFactorNotEnrolled,
+		},
+		{
+			name: "error get totp",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "already enabled",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(&entity.TOTP{
+					UserID:      15,
+					Secret:      "JBSWY3DPEHPK3PXP",
+					ConfirmedAt: &now,
+				}, nil)
+			},
+			wantErr: ErrTwoFactorEnabled,
+		},
+		{
+			name: "error count attempt",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(0, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "too many codes tried",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(MaxTwoFactorCodeAttempts+1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "invalid code",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "000000").Return(int64(0), false)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "error generate recovery code",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "000000").Return(int64(56303497), true)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(10).Return("", assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error hash recovery code",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "000000").Return(int64(56303497), true)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(10).Return("0123456789", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("0123456789").Return(nil, assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "error confirm totp",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+				m.EXPECT().ConfirmTOTP(ctx, 15, int64(56303497), hashes).Return(false, assert.AnError)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "000000").Return(int64(56303497), true)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(10).Return("0123456789", nil).Times(recoveryCodeCount)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("0123456789").Return([]byte("hashed recovery code"), nil).Times(recoveryCodeCount)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "confirmed by another request",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+				m.EXPECT().ConfirmTOTP(ctx, 15, int64(56303497), hashes).Return(false, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "000000").Return(int64(56303497), true)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(10).Return("0123456789", nil).Times(recoveryCodeCount)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("0123456789").Return([]byte("hashed recovery code"), nil).Times(recoveryCodeCount)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: ErrTwoFactorEnabled,
+		},
+		{
+			name: "success",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(pending(), nil)
+				m.EXPECT().ConfirmTOTP(ctx, 15, int64(56303497), hashes).Return(true, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "000000").Return(int64(56303497), true)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(10).Return("0123456789", nil).Times(recoveryCodeCount)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("0123456789").Return([]byte("hashed recovery code"), nil).Times(recoveryCodeCount)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			want:    codes,
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	mockTOTP := tools.NewMockTOTPInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			if tt.prepareTOTP != nil {
+				tt.prepareTOTP(mockTOTP)
+			}
+			m.totp = mockTOTP
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			got, err := m.ConfirmTOTP(ctx, 15, "000000")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUserModule_DisableTOTP(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	enabled := func() *entity.TOTP {
+		return &entity.TOTP{
+			UserID:      15,
+			Secret:      "JBSWY3DPEHPK3PXP",
+			ConfirmedAt: &now,
+		}
+	}
+	recoveryCodes := []*entity.RecoveryCode{
+		{
+			ID:       1,
+			UserID:   15,
+			CodeHash: "hashed recovery code 1",
+		},
+		{
+			ID:       2,
+			UserID:   15,
+			CodeHash: "hashed recovery code 2",
+		},
+	}
+	tests := []struct {
+		name                 string
+		code                 string
+		prepareTwoFactorRepo func(m *repository.MockTwoFactorRepositoryInterface)
+		prepareTOTP          func(m *tools.MockTOTPInterface)
+		prepareCodeHash      func(m *tools.MockHashInterface)
+		prepareThrottleRepo  func(m *repository.MockThrottleRepositoryInterface)
+		wantErr              error
+	}{
+		{
+			name: "not enrolled",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrTwoFactorNotEnabled,
+		},
+		{
+			name: "error get totp",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "not confirmed",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(&entity.TOTP{
+					UserID: 15,
+					Secret: "JBSWY3DPEHPK3PXP",
+				}, nil)
+			},
+			wantErr: ErrTwoFactorNotEnabled,
+		},
+		{
+			name: "error count attempt",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(0, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "too many codes tried",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(MaxTwoFactorCodeAttempts+1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "invalid totp code",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(0), false)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "error use totp step",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().UseTOTPStep(ctx, 15, int64(56303497)).Return(false, assert.AnError)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(56303497), true)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "replayed totp code",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().UseTOTPStep(ctx, 15, int64(56303497)).Return(false, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(56303497), true)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "error get recovery codes",
+			code: "01234-56789",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().GetUnusedRecoveryCodes(ctx, 15).Return(nil, assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "recovery code does not match",
+			code: "01234-56789",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().GetUnusedRecoveryCodes(ctx, 15).Return(recoveryCodes, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 1"), "0123456789").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 2"), "0123456789").Return(assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "error use recovery code",
+			code: "01234-56789",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().GetUnusedRecoveryCodes(ctx, 15).Return(recoveryCodes, nil)
+				m.EXPECT().UseRecoveryCode(ctx, 2).Return(false, assert.AnError)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 1"), "0123456789").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 2"), "0123456789").Return(nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "recovery code used by another request",
+			code: "01234-56789",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().GetUnusedRecoveryCodes(ctx, 15).Return(recoveryCodes, nil)
+				m.EXPECT().UseRecoveryCode(ctx, 2).Return(false, nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 1"), "0123456789").Return(assert.AnError)
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 2"), "0123456789").Return(nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "error delete totp",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().UseTOTPStep(ctx, 15, int64(56303497)).Return(true, nil)
+				m.EXPECT().DeleteTOTP(ctx, 15).Return(assert.AnError)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(56303497), true)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "success with totp code",
+			code: "123456",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().UseTOTPStep(ctx, 15, int64(56303497)).Return(true, nil)
+				m.EXPECT().DeleteTOTP(ctx, 15).Return(nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(56303497), true)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success with recovery code",
+			code: "0123456789",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 15).Return(enabled(), nil)
+				m.EXPECT().GetUnusedRecoveryCodes(ctx, 15).Return(recoveryCodes, nil)
+				m.EXPECT().UseRecoveryCode(ctx, 1).Return(true, nil)
+				m.EXPECT().DeleteTOTP(ctx, 15).Return(nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed recovery code 1"), "0123456789").Return(nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	mockTOTP := tools.NewMockTOTPInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			if tt.prepareTOTP != nil {
+				tt.prepareTOTP(mockTOTP)
+			}
+			m.totp = mockTOTP
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			err := m.DisableTOTP(ctx, 15, tt.code)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestUserModule_LoginTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		refreshTokenTTL: time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	challengeHash := crxpto.SHA256("plain-challenge")
+	challenge := func() *entity.LoginChallenge {
+		return &entity.LoginChallenge{
+			ID:        3,
+			UserID:    15,
+			TokenHash: challengeHash,
+			ExpiresAt: now.Add(time.Minute),
+		}
+	}
+	activeUser := func() *entity.User {
+		return &entity.User{
+			ID:          15,
+			PhoneNumber: "628123456789",
+			Status:      entity.UserStatusActive,
+		}
+	}
+	// a totp code that is valid and used for the first time
+	validCode := func(m *repository.MockTwoFactorRepositoryInterface) {
+		m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+		m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+		m.EXPECT().GetTOTP(ctx, 15).Return(&entity.TOTP{
+			UserID:      15,
+			Secret:      "JBSWY3DPEHPK3PXP",
+			ConfirmedAt: &now,
+		}, nil)
+		m.EXPECT().UseTOTPStep(ctx, 15, int64(56303497)).Return(true, nil)
+	}
+	validTOTP := func(m *tools.MockTOTPInterface) {
+		m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(56303497), true)
+	}
+	tests := []struct {
+		name                    string
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareTwoFactorRepo    func(m *repository.MockTwoFactorRepositoryInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareTOTP             func(m *tools.MockTOTPInterface)
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		want                    entity.LoginModuleResponse
+		wantErr                 error
+	}{
+		{
+			name: "unknown challenge",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "error get challenge",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "expired challenge",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(&entity.LoginChallenge{
+					ID:        3,
+					UserID:    15,
+					ExpiresAt: now,
+				}, nil)
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "consumed challenge",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(&entity.LoginChallenge{
+					ID:         3,
+					UserID:     15,
+					ExpiresAt:  now.Add(time.Minute),
+					ConsumedAt: &now,
+				}, nil)
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "error increment attempts",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(0, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "too many attempts",
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(MaxLoginChallengeAttempts+1, nil)
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "user not found",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "error get user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(nil, assert.AnError)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "suspended user",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(&entity.User{
+					ID:     15,
+					Status: entity.UserStatusSuspended,
+				}, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:     15,
+					Status: entity.UserStatusSuspended,
+				},
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "two-factor authentication disabled after challenge",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+				m.EXPECT().GetTOTP(ctx, 15).Return(nil, sql.ErrNoRows)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "too many codes tried across challenges",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+				m.EXPECT().GetTOTP(ctx, 15).Return(&entity.TOTP{
+					UserID:      15,
+					Secret:      "JBSWY3DPEHPK3PXP",
+					ConfirmedAt: &now,
+				}, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(MaxTwoFactorCodeAttempts+1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "invalid code",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetLoginChallengeByHash(ctx, challengeHash).Return(challenge(), nil)
+				m.EXPECT().IncrementLoginChallengeAttempts(ctx, 3).Return(1, nil)
+				m.EXPECT().GetTOTP(ctx, 15).Return(&entity.TOTP{
+					UserID:      15,
+					Secret:      "JBSWY3DPEHPK3PXP",
+					ConfirmedAt: &now,
+				}, nil)
+			},
+			prepareTOTP: func(m *tools.MockTOTPInterface) {
+				m.EXPECT().Validate("JBSWY3DPEHPK3PXP", "123456").Return(int64(0), false)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "error consume challenge",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeLoginChallenge(ctx, 3).Return(false, assert.AnError)
+			},
+			prepareTOTP: validTOTP,
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "challenge consumed by another request",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeLoginChallenge(ctx, 3).Return(false, nil)
+			},
+			prepareTOTP: validTOTP,
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: ErrInvalidLoginChallenge,
+		},
+		{
+			name: "error generate jwt",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeLoginChallenge(ctx, 3).Return(true, nil)
+			},
+			prepareTOTP: validTOTP,
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "15",
+				}).Return("", assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: activeUser(),
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "success",
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 15).Return(activeUser(), nil)
+				m.EXPECT().IncrementLoginCount(ctx, 15).Return(nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				validCode(m)
+				m.EXPECT().ConsumeLoginChallenge(ctx, 3).Return(true, nil)
+			},
+			prepareTOTP: validTOTP,
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "15",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    15,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "2fa:15", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "2fa:15").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User:         activeUser(),
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockTOTP := tools.NewMockTOTPInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareTOTP != nil {
+				tt.prepareTOTP(mockTOTP)
+			}
+			m.totp = mockTOTP
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
+			}
+			m.jwt = mockJWT
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			got, err := m.LoginTwoFactor(ctx, "plain-challenge", "123456")
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -3,35 +3,169 @@ package user
 import (
 	"context"
 	"errors"
+	"log"
+	"time"
 
 	"github.com/leguminosa/profile-open-portal/entity"
 	"github.com/leguminosa/profile-open-portal/repository"
 	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/leguminosa/profile-open-portal/tools/phonenumber"
 	"github.com/leguminosa/profile-open-portal/tools/validator"
 )
 
 type UserModule struct {
-	userRepository repository.UserRepositoryInterface
-	hash           tools.HashInterface
-	jwt            tools.JWTInterface
+	userRepository         repository.UserRepositoryInterface
+	refreshTokenRepository repository.RefreshTokenRepositoryInterface
+	revocationRepository   repository.RevocationRepositoryInterface
+	verificationRepository repository.VerificationRepositoryInterface
+	twoFactorRepository    repository.TwoFactorRepositoryInterface
+	throttleRepository     repository.ThrottleRepositoryInterface
+	oidcRepository         repository.OIDCRepositoryInterface
+	federationRepository   repository.FederationRepositoryInterface
+	passwordBlocklist      tools.PasswordBlocklistInterface
+	passwordPolicy         validator.PasswordPolicy
+	hash                   tools.HashInterface
+	codeHash               tools.HashInterface
+	jwt                    tools.JWTInterface
+	random                 tools.RandomInterface
+	smsSender              tools.SMSSenderInterface
+	emailSender            tools.EmailSenderInterface
+	signer                 tools.SignerInterface
+	totp                   tools.TOTPInterface
+	identityProviders      map[string]tools.IdentityProviderInterface
+	defaultPhoneRegion     string
+	emailVerificationURL   string
+	magicLinkURL           string
+	authorizationLoginURL  string
+	accessTokenTTL         time.Duration
+	refreshTokenTTL        time.Duration
+	timeNow                func() time.Time
 }
 
 type NewUserModuleOptions struct {
-	UserRepository repository.UserRepositoryInterface
-	Hash           tools.HashInterface
-	JWT            tools.JWTInterface
+	UserRepository         repository.UserRepositoryInterface
+	RefreshTokenRepository repository.RefreshTokenRepositoryInterface
+	RevocationRepository   repository.RevocationRepositoryInterface
+	VerificationRepository repository.VerificationRepositoryInterface
+	TwoFactorRepository    repository.TwoFactorRepositoryInterface
+	ThrottleRepository     repository.ThrottleRepositoryInterface
+	OIDCRepository         repository.OIDCRepositoryInterface
+	FederationRepository   repository.FederationRepositoryInterface
+	PasswordBlocklist      tools.PasswordBlocklistInterface
+	Hash                   tools.HashInterface
+	JWT                    tools.JWTInterface
+	Random                 tools.RandomInterface
+	SMSSender              tools.SMSSenderInterface
+	EmailSender            tools.EmailSenderInterface
+	// CodeHash hashes verification codes sent over sms and recovery codes. It defaults to argon2id
+	// with crxpto.CodeArgon2idParams when not set, codes do not need the cost of a password hash.
+	CodeHash tools.HashInterface
+	// Signer signs the tokens of links sent to users, like verifying an email.
+	Signer tools.SignerInterface
+	TOTP   tools.TOTPInterface
+	// IdentityProviders are the external OpenID Connect providers users can log in with,
+	// keyed by the name clients ask for, like google. None are available when not set.
+	IdentityProviders map[string]tools.IdentityProviderInterface
+	// PasswordPolicy defaults to validator.DefaultPasswordPolicy when not set.
+	PasswordPolicy validator.PasswordPolicy
+	// DefaultPhoneRegion defaults to DefaultPhoneRegion when not set.
+	DefaultPhoneRegion string
+	// EmailVerificationURL defaults to DefaultEmailVerificationURL when not set.
+	EmailVerificationURL string
+	// MagicLinkURL defaults to DefaultMagicLinkURL when not set.
+	MagicLinkURL string
+	// AuthorizationLoginURL defaults to DefaultAuthorizationLoginURL when not set.
+	AuthorizationLoginURL string
+	// AccessTokenTTL must match the ttl of JWT, it defaults to DefaultAccessTokenTTL when not set.
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL defaults to DefaultRefreshTokenTTL when not set.
+	RefreshTokenTTL time.Duration
 }
 
+const (
+	// DefaultPhoneRegion is the country a phone number without country code is assumed to be from.
+	DefaultPhoneRegion = "ID"
+	// DefaultEmailVerificationURL is the page opened by the link sent to verify an email,
+	// expected to post the token query parameter to /email/verification/confirm.
+	DefaultEmailVerificationURL = "http://localhost:8080/email/verification"
+	// DefaultMagicLinkURL is where the token of a magic link is appended as the last path segment.
+	DefaultMagicLinkURL = "http://localhost:8080/login/magic"
+	// DefaultAuthorizationLoginURL is the page a user who is not logged in is sent to by /authorize,
+	// expected to log the user in and post the query parameters to /authorize.
+	DefaultAuthorizationLoginURL = "http://localhost:8080/login/authorize"
+	// DefaultAccessTokenTTL is how long an access token lasts when JWT keeps its default ttl.
+	DefaultAccessTokenTTL = time.Minute * 15
+	// DefaultRefreshTokenTTL is how long a user can stay logged in without using the app.
+	DefaultRefreshTokenTTL = time.Hour * 24 * 30
+)
+
 // New creates new user module.
 func New(opts NewUserModuleOptions) *UserModule {
+	accessTokenTTL := opts.AccessTokenTTL
+	if accessTokenTTL <= 0 {
+		accessTokenTTL = DefaultAccessTokenTTL
+	}
+	refreshTokenTTL := opts.RefreshTokenTTL
+	if refreshTokenTTL <= 0 {
+		refreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	defaultPhoneRegion := opts.DefaultPhoneRegion
+	if defaultPhoneRegion == "" {
+		defaultPhoneRegion = DefaultPhoneRegion
+	}
+	emailVerificationURL := opts.EmailVerificationURL
+	if emailVerificationURL == "" {
+		emailVerificationURL = DefaultEmailVerificationURL
+	}
+	magicLinkURL := opts.MagicLinkURL
+	if magicLinkURL == "" {
+		magicLinkURL = DefaultMagicLinkURL
+	}
+	authorizationLoginURL := opts.AuthorizationLoginURL
+	if authorizationLoginURL == "" {
+		authorizationLoginURL = DefaultAuthorizationLoginURL
+	}
+	codeHash := opts.CodeHash
+	if codeHash == nil {
+		codeHash = crxpto.NewArgon2idWithParams(crxpto.CodeArgon2idParams)
+	}
+	passwordPolicy := opts.PasswordPolicy
+	if passwordPolicy == (validator.PasswordPolicy{}) {
+		passwordPolicy = validator.DefaultPasswordPolicy()
+	}
+
 	return &UserModule{
-		userRepository: opts.UserRepository,
-		hash:           opts.Hash,
-		jwt:            opts.JWT,
+		userRepository:         opts.UserRepository,
+		refreshTokenRepository: opts.RefreshTokenRepository,
+		revocationRepository:   opts.RevocationRepository,
+		verificationRepository: opts.VerificationRepository,
+		twoFactorRepository:    opts.TwoFactorRepository,
+		throttleRepository:     opts.ThrottleRepository,
+		oidcRepository:         opts.OIDCRepository,
+		federationRepository:   opts.FederationRepository,
+		passwordBlocklist:      opts.PasswordBlocklist,
+		passwordPolicy:         passwordPolicy,
+		hash:                   opts.Hash,
+		codeHash:               codeHash,
+		jwt:                    opts.JWT,
+		random:                 opts.Random,
+		smsSender:              opts.SMSSender,
+		emailSender:            opts.EmailSender,
+		signer:                 opts.Signer,
+		totp:                   opts.TOTP,
+		identityProviders:      opts.IdentityProviders,
+		defaultPhoneRegion:     defaultPhoneRegion,
+		emailVerificationURL:   emailVerificationURL,
+		magicLinkURL:           magicLinkURL,
+		authorizationLoginURL:  authorizationLoginURL,
+		accessTokenTTL:         accessTokenTTL,
+		refreshTokenTTL:        refreshTokenTTL,
+		timeNow:                time.Now,
 	}
 }
 
-// Register creates new user after validating the request.
+// Register creates new user after validating the request, pending until the phone number is verified by ActivateUser.
 func (m *UserModule) Register(ctx context.Context, user *entity.User) (entity.RegisterModuleResponse, error) {
 	var (
 		resp = entity.RegisterModuleResponse{
@@ -44,18 +178,25 @@ func (m *UserModule) Register(ctx context.Context, user *entity.User) (entity.Re
 
 	// validate request
 	var (
-		messages []string
-		valid    bool
+		phoneNumber string
+		messages    []string
+		valid       bool
 	)
-	if messages, valid = validator.ValidatePhoneNumber(user.PhoneNumber); !valid {
+	if phoneNumber, messages, valid = validator.ValidatePhoneNumber(user.PhoneNumber, m.defaultPhoneRegion); !valid {
 		resp.Valid = false
 		resp.Messages = append(resp.Messages, messages...)
+	} else {
+		user.PhoneNumber = phoneNumber
 	}
 	if messages, valid = validator.ValidateFullName(user.Fullname); !valid {
 		resp.Valid = false
 		resp.Messages = append(resp.Messages, messages...)
 	}
-	if messages, valid = validator.ValidatePassword(user.PlainPassword); !valid {
+	messages, valid, err = m.validatePassword(ctx, user.PlainPassword)
+	if err != nil {
+		return resp, err
+	}
+	if !valid {
 		resp.Valid = false
 		resp.Messages = append(resp.Messages, messages...)
 	}
@@ -70,11 +211,20 @@ func (m *UserModule) Register(ctx context.Context, user *entity.User) (entity.Re
 		return resp, err
 	}
 
+	// user cannot log in until the phone number is verified with the code sent below
+	user.Status = entity.UserStatusPending
+
 	resp.User.ID, err = m.userRepository.InsertUser(ctx, user)
 	if err != nil {
 		return resp, err
 	}
 
+	// user is created anyway, a new code can be requested with RequestActivation
+	err = m.issueVerificationCode(ctx, user.PhoneNumber, entity.VerificationPurposePhoneVerification)
+	if err != nil {
+		log.Printf("register: issue code for user %d: %v", resp.User.ID, err)
+	}
+
 	resp.Valid = true
 	return resp, nil
 }
@@ -82,55 +232,169 @@ func (m *UserModule) Register(ctx context.Context, user *entity.User) (entity.Re
 var (
 	// ErrLoginFailed obscures the error message to prevent brute force attack
 	ErrLoginFailed = errors.New("phone number or password is not correct")
+	// ErrEmailLoginFailed is ErrLoginFailed of user logging in by email.
+	ErrEmailLoginFailed = errors.New("email or password is not correct")
 )
 
-// Login generate jwt and increment success login count on successful attempt.
-func (m *UserModule) Login(ctx context.Context, user *entity.User) (entity.LoginModuleResponse, error) {
+// Login generate jwt along with refresh token and increment success login count on successful attempt.
+// User logs in by a verified email when given, otherwise by phone number.
+// User with two-factor authentication only gets a challenge token, to be completed by LoginTwoFactor.
+// Failed attempts are counted per phone number or email and per client ip, refusing further attempts with LoginThrottledError.
+func (m *UserModule) Login(ctx context.Context, user *entity.User, clientIP string) (entity.LoginModuleResponse, error) {
 	var (
 		resp = entity.LoginModuleResponse{
 			User: user,
 		}
-		err error
+		errFailed  = ErrLoginFailed
+		accountKey string
+		err        error
 	)
 
+	if user.Email != "" {
+		user.Email = validator.NormalizeEmail(user.Email)
+		errFailed = ErrEmailLoginFailed
+		accountKey = emailThrottleKey(user.Email)
+	} else {
+		user.PhoneNumber = m.normalizePhoneNumber(user.PhoneNumber)
+		accountKey = phoneThrottleKey(user.PhoneNumber)
+	}
+
+	// throttled attempt is refused before the password is even compared, any other is counted as failed until it succeeds
+	err = m.startLoginAttempt(ctx, accountKey, clientIP)
+	var throttled *LoginThrottledError
+	if errors.As(err, &throttled) {
+		return resp, err
+	}
+	if err != nil {
+		return resp, errFailed
+	}
+
 	// get user from database
-	resp.User, err = m.userRepository.GetUserByPhoneNumber(ctx, user.PhoneNumber)
+	resp.User, err = m.loginUser(ctx, user)
 	if err != nil {
-		return resp, ErrLoginFailed
+		return resp, errFailed
 	}
 
-	// check whether user with requested phone number exist in database
+	// check whether user with requested phone number or email exist in database
 	if !resp.User.Exist() {
-		return resp, ErrLoginFailed
+		return resp, errFailed
 	}
 
 	// compare hashed password stored in database with user input
 	err = m.hash.ComparePassword([]byte(resp.User.HashedPassword), user.PlainPassword)
 	if err != nil {
-		return resp, ErrLoginFailed
+		return resp, errFailed
 	}
 
-	resp.JWT, err = m.jwt.Generate(resp.User)
+	// user who cannot log in is told the same thing to avoid confirming the password is correct,
+	// the actual reason is kept in the error for internal use
+	err = entity.CheckUserStatus(resp.User.Status)
 	if err != nil {
-		return resp, ErrLoginFailed
+		log.Printf("login: refuse user %d: %v", resp.User.ID, err)
+		return resp, entity.Obscure(errFailed, err)
 	}
 
-	err = m.userRepository.IncrementLoginCount(ctx, resp.User.ID)
+	m.finishLoginAttempt(ctx, accountKey, clientIP)
+
+	m.rehashPassword(ctx, resp.User, user.PlainPassword)
+
+	err = m.completeLogin(ctx, &resp)
 	if err != nil {
-		return resp, ErrLoginFailed
+		return resp, errFailed
 	}
 
 	return resp, nil
 }
 
+// loginUser returns the user logging in by email if given, otherwise by phone number.
+// Only a verified email is matched, a pending one is not a login identifier yet.
+func (m *UserModule) loginUser(ctx context.Context, user *entity.User) (*entity.User, error) {
+	if user.Email != "" {
+		return m.userRepository.GetUserByEmail(ctx, user.Email)
+	}
+	return m.userRepository.GetUserByPhoneNumber(ctx, user.PhoneNumber)
+}
+
+// rehashPassword upgrades the stored hash of a correct password whose algorithm or cost is out of date.
+// Failing to do so is not reported, the old hash keeps working and is upgraded on a later login.
+func (m *UserModule) rehashPassword(ctx context.Context, user *entity.User, plainPassword string) {
+	if !m.hash.NeedsRehash([]byte(user.HashedPassword)) {
+		return
+	}
+
+	hashedPassword, err := m.hash.HashPassword(plainPassword)
+	if err != nil {
+		return
+	}
+
+	_, _ = m.userRepository.RehashPassword(ctx, user.ID, user.HashedPassword, string(hashedPassword))
+}
+
+// completeLogin fills the response with a challenge token when user has two-factor authentication,
+// otherwise starts the session right away.
+func (m *UserModule) completeLogin(ctx context.Context, resp *entity.LoginModuleResponse) error {
+	twoFactor, err := m.isTwoFactorEnabled(ctx, resp.User.ID)
+	if err != nil {
+		return err
+	}
+	if twoFactor {
+		resp.ChallengeToken, err = m.issueLoginChallenge(ctx, resp.User.ID)
+		return err
+	}
+
+	return m.startSession(ctx, resp)
+}
+
+// startSession fills the response with a jwt and a refresh token of the user, counting a successful login.
+func (m *UserModule) startSession(ctx context.Context, resp *entity.LoginModuleResponse) error {
+	var err error
+	resp.JWT, err = m.jwt.Generate(resp.User.Claims())
+	if err != nil {
+		return err
+	}
+
+	// every login starts a new family of refresh tokens
+	resp.RefreshToken, err = m.issueRefreshToken(ctx, resp.User.ID, "")
+	if err != nil {
+		return err
+	}
+
+	return m.userRepository.IncrementLoginCount(ctx, resp.User.ID)
+}
+
 // GetProfile returns user profile.
 func (m *UserModule) GetProfile(ctx context.Context, userID int) (*entity.User, error) {
 	return m.userRepository.GetUserByID(ctx, userID)
 }
 
-// UpdateProfile only updates fullname and/or phone number if user input is not empty.
+// UpdateProfile only updates fullname, phone number, and/or email if user input is not empty.
+// A new phone number or email stays pending until it is verified, the current one remains the login identifier.
+// A link to verify a new pending email is sent right away.
 func (m *UserModule) UpdateProfile(ctx context.Context, user *entity.User) (entity.UpdateProfileModuleResponse, error) {
-	var resp entity.UpdateProfileModuleResponse
+	resp := entity.UpdateProfileModuleResponse{
+		Valid:    true,
+		Messages: []string{},
+	}
+
+	// validate request
+	if user.PhoneNumber != "" {
+		phoneNumber, messages, valid := validator.ValidatePhoneNumber(user.PhoneNumber, m.defaultPhoneRegion)
+		if !valid {
+			resp.Valid = false
+			resp.Messages = append(resp.Messages, messages...)
+			return resp, nil
+		}
+		user.PhoneNumber = phoneNumber
+	}
+	if user.Email != "" {
+		email, messages, valid := validator.ValidateEmail(user.Email)
+		if !valid {
+			resp.Valid = false
+			resp.Messages = append(resp.Messages, messages...)
+			return resp, nil
+		}
+		user.Email = email
+	}
 
 	// get user to db first to check whether user currentValue
 	currentValue, err := m.userRepository.GetUserByID(ctx, user.ID)
@@ -146,9 +410,12 @@ func (m *UserModule) UpdateProfile(ctx context.Context, user *entity.User) (enti
 	if user.Fullname != "" {
 		currentValue.Fullname = user.Fullname
 	}
-	if user.PhoneNumber != "" {
+	if user.PhoneNumber == currentValue.PhoneNumber {
+		// going back to the current phone number cancels the pending one
+		currentValue.PendingPhoneNumber = ""
+	} else if user.PhoneNumber != "" {
 		resp.Conflict = m.isPhoneNumberExist(ctx, user.PhoneNumber)
-		currentValue.PhoneNumber = user.PhoneNumber
+		currentValue.PendingPhoneNumber = user.PhoneNumber
 	}
 
 	if resp.Conflict {
@@ -157,7 +424,45 @@ func (m *UserModule) UpdateProfile(ctx context.Context, user *entity.User) (enti
 		return resp, nil
 	}
 
-	return resp, m.userRepository.UpdateUser(ctx, currentValue)
+	// unlike phone number, user may have no email at all, so empty input is never taken for the current one
+	var sendEmailVerification bool
+	if user.Email != "" && user.Email == currentValue.Email {
+		// going back to the current email cancels the pending one
+		currentValue.PendingEmail = ""
+	} else if user.Email != "" {
+		if m.isEmailExist(ctx, user.Email) {
+			// don't update if email already exist
+			resp.Conflict = true
+			resp.Message = ErrEmailConflict.Error()
+			return resp, nil
+		}
+		sendEmailVerification = user.Email != currentValue.PendingEmail
+		currentValue.PendingEmail = user.Email
+	}
+
+	err = m.userRepository.UpdateUser(ctx, currentValue)
+	if err != nil {
+		return resp, err
+	}
+
+	// failing to send is not reported, the profile has been updated and the link can be requested again
+	if sendEmailVerification {
+		_ = m.sendEmailVerification(ctx, currentValue.ID, currentValue.PendingEmail)
+	}
+
+	resp.PendingPhoneNumber = currentValue.PendingPhoneNumber
+	resp.PendingEmail = currentValue.PendingEmail
+	return resp, nil
+}
+
+// normalizePhoneNumber returns phone number in E.164 format, the only form phone numbers are stored in.
+// A phone number that cannot be normalized is returned as it is, it matches no user anyway.
+func (m *UserModule) normalizePhoneNumber(phoneNumber string) string {
+	normalized, err := phonenumber.Normalize(phoneNumber, m.defaultPhoneRegion)
+	if err != nil {
+		return phoneNumber
+	}
+	return normalized
 }
 
 func (m *UserModule) isPhoneNumberExist(ctx context.Context, phoneNumber string) bool {
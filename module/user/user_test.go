@@ -2,12 +2,16 @@ package user
 
 import (
 	"context"
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/leguminosa/profile-open-portal/entity"
 	"github.com/leguminosa/profile-open-portal/repository"
 	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+	"github.com/leguminosa/profile-open-portal/tools/validator"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,14 +28,27 @@ func TestNew(t *testing.T) {
 
 func TestUserModule_Register(t *testing.T) {
 	ctx := context.Background()
-	m := &UserModule{}
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		passwordPolicy:     validator.DefaultPasswordPolicy(),
+		defaultPhoneRegion: "ID",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
 	tests := []struct {
-		name        string
-		user        *entity.User
-		prepareHash func(m *tools.MockHashInterface)
-		prepareRepo func(m *repository.MockUserRepositoryInterface)
-		want        entity.RegisterModuleResponse
-		wantErr     bool
+		name             string
+		user             *entity.User
+		prepareHash      func(m *tools.MockHashInterface)
+		prepareBlocklist func(m *tools.MockPasswordBlocklistInterface)
+		prepareRepo      func(m *repository.MockUserRepositoryInterface)
+		// prepareVerificationRepo, prepareRandom, prepareCodeHash, and prepareSMS send the activation code
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareSMS              func(m *tools.MockSMSSenderInterface)
+		want                    entity.RegisterModuleResponse
+		wantErr                 bool
 	}{
 		{
 			name: "request is empty",
@@ -43,8 +60,7 @@ func TestUserModule_Register(t *testing.T) {
 			want: entity.RegisterModuleResponse{
 				Valid: false,
 				Messages: []string{
-					"phone number must be 10-13 digits",
-					"phone number must start with 62",
+					"phone number must not be empty",
 					"full name must be 3-60 characters",
 					"password must be 6-64 characters",
 					"password must contain at least 1 uppercase letter, 1 number, and 1 special character",
@@ -64,11 +80,13 @@ func TestUserModule_Register(t *testing.T) {
 				PhoneNumber:   "123456",
 				PlainPassword: "Abcde3#",
 			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
 			want: entity.RegisterModuleResponse{
 				Valid: false,
 				Messages: []string{
-					"phone number must be 10-13 digits",
-					"phone number must start with 62",
+					"phone number must have 8-12 digits after country code +62",
 				},
 				User: &entity.User{
 					Fullname:      "John Doe",
@@ -85,6 +103,9 @@ func TestUserModule_Register(t *testing.T) {
 				PhoneNumber:   "62812345678",
 				PlainPassword: "Abcde3#",
 			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
 			want: entity.RegisterModuleResponse{
 				Valid: false,
 				Messages: []string{
@@ -92,10 +113,54 @@ func TestUserModule_Register(t *testing.T) {
 				},
 				User: &entity.User{
 					Fullname:      "Jo",
-					PhoneNumber:   "62812345678",
+					PhoneNumber:   "+62812345678",
+					PlainPassword: "Abcde3#",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "error check password blocklist",
+			user: &entity.User{
+				Fullname:      "John Doe",
+				PhoneNumber:   "62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, assert.AnError)
+			},
+			want: entity.RegisterModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+				User: &entity.User{
+					Fullname:      "John Doe",
+					PhoneNumber:   "+62812345678",
 					PlainPassword: "Abcde3#",
 				},
 			},
+			wantErr: true,
+		},
+		{
+			name: "blocked password",
+			user: &entity.User{
+				Fullname:      "John Doe",
+				PhoneNumber:   "62812345678",
+				PlainPassword: "Password1!",
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Password1!").Return(true, nil)
+			},
+			want: entity.RegisterModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"password is too common or has appeared in a data breach, choose a different one",
+				},
+				User: &entity.User{
+					Fullname:      "John Doe",
+					PhoneNumber:   "+62812345678",
+					PlainPassword: "Password1!",
+				},
+			},
 			wantErr: false,
 		},
 		{
@@ -108,12 +173,15 @@ func TestUserModule_Register(t *testing.T) {
 			prepareHash: func(m *tools.MockHashInterface) {
 				m.EXPECT().HashPassword("Abcde3#").Return(nil, assert.AnError)
 			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
 			want: entity.RegisterModuleResponse{
 				Valid:    true,
 				Messages: []string{},
 				User: &entity.User{
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "",
 					PlainPassword:  "Abcde3#",
 				},
@@ -133,23 +201,67 @@ func TestUserModule_Register(t *testing.T) {
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
 				m.EXPECT().InsertUser(ctx, &entity.User{
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusPending,
 					PlainPassword:  "Abcde3#",
 				}).Return(0, assert.AnError)
 			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
 			want: entity.RegisterModuleResponse{
 				Valid:    true,
 				Messages: []string{},
 				User: &entity.User{
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusPending,
 					PlainPassword:  "Abcde3#",
 				},
 			},
 			wantErr: true,
 		},
+		{
+			name: "error send activation code",
+			user: &entity.User{
+				Fullname:      "John Doe",
+				PhoneNumber:   "62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("Abcde3#").Return([]byte("hashed something"), nil)
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().InsertUser(ctx, &entity.User{
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusPending,
+					PlainPassword:  "Abcde3#",
+				}).Return(1, nil)
+			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposePhoneVerification).Return(nil, assert.AnError)
+			},
+			want: entity.RegisterModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusPending,
+					PlainPassword:  "Abcde3#",
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "success",
 			user: &entity.User{
@@ -163,19 +275,43 @@ func TestUserModule_Register(t *testing.T) {
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
 				m.EXPECT().InsertUser(ctx, &entity.User{
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusPending,
 					PlainPassword:  "Abcde3#",
 				}).Return(1, nil)
 			},
+			prepareBlocklist: func(m *tools.MockPasswordBlocklistInterface) {
+				m.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "+62812345678", entity.VerificationPurposePhoneVerification).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, &entity.VerificationCode{
+					PhoneNumber: "+62812345678",
+					Purpose:     entity.VerificationPurposePhoneVerification,
+					CodeHash:    "hashed code",
+					ExpiresAt:   now.Add(VerificationCodeTTL),
+				}).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "+62812345678", gomock.Any()).Return(nil)
+			},
 			want: entity.RegisterModuleResponse{
 				Valid:    true,
 				Messages: []string{},
 				User: &entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusPending,
 					PlainPassword:  "Abcde3#",
 				},
 			},
@@ -186,6 +322,11 @@ func TestUserModule_Register(t *testing.T) {
 	defer ctrl.Finish()
 	mockHash := tools.NewMockHashInterface(ctrl)
 	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockBlocklist := tools.NewMockPasswordBlocklistInterface(ctrl)
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockSMS := tools.NewMockSMSSenderInterface(ctrl)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.prepareHash != nil {
@@ -198,6 +339,31 @@ func TestUserModule_Register(t *testing.T) {
 			}
 			m.userRepository = mockUserRepo
 
+			if tt.prepareBlocklist != nil {
+				tt.prepareBlocklist(mockBlocklist)
+			}
+			m.passwordBlocklist = mockBlocklist
+
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareSMS != nil {
+				tt.prepareSMS(mockSMS)
+			}
+			m.smsSender = mockSMS
+
 			got, err := m.Register(ctx, tt.user)
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
@@ -207,15 +373,26 @@ func TestUserModule_Register(t *testing.T) {
 
 func TestUserModule_Login(t *testing.T) {
 	ctx := context.Background()
-	m := &UserModule{}
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion: "ID",
+		refreshTokenTTL:    time.Hour,
+		timeNow: func() time.Time {
+			return now
+		},
+	}
 	tests := []struct {
-		name        string
-		user        *entity.User
-		prepareRepo func(m *repository.MockUserRepositoryInterface)
-		prepareHash func(m *tools.MockHashInterface)
-		prepareJWT  func(m *tools.MockJWTInterface)
-		want        entity.LoginModuleResponse
-		wantErr     bool
+		name                    string
+		user                    *entity.User
+		prepareRepo             func(m *repository.MockUserRepositoryInterface)
+		prepareHash             func(m *tools.MockHashInterface)
+		prepareJWT              func(m *tools.MockJWTInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareRefreshTokenRepo func(m *repository.MockRefreshTokenRepositoryInterface)
+		prepareTwoFactorRepo    func(m *repository.MockTwoFactorRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		want                    entity.LoginModuleResponse
+		wantErr                 bool
 	}{
 		{
 			name: "error get user",
@@ -225,6 +402,127 @@ func TestUserModule_Login(t *testing.T) {
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
 				m.EXPECT().GetUserByPhoneNumber(ctx, "99").Return(nil, assert.AnError)
 			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:99").Return(entity.LoginFailures{
+					Key: "phone:99",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:99", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error check throttle",
+			user: &entity.User{
+				PhoneNumber: "+62812345678",
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, assert.AnError)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					PhoneNumber: "+62812345678",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "throttled",
+			user: &entity.User{
+				PhoneNumber: "+62812345678",
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key:          "phone:+62812345678",
+					Failures:     4,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key:          "ip:192.0.2.1",
+					Failures:     4,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					PhoneNumber: "+62812345678",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "user not found",
+			user: &entity.User{
+				PhoneNumber: "0812-345-678",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error record login failure",
+			user: &entity.User{
+				PhoneNumber: "+62812345678",
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(0, assert.AnError)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					PhoneNumber: "+62812345678",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "throttled by attempts at the same time",
+			user: &entity.User{
+				PhoneNumber: "+62812345678",
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key:          "phone:+62812345678",
+					Failures:     2,
+					LastFailedAt: now.Add(-time.Second),
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(5, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(3, nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					PhoneNumber: "+62812345678",
+				},
+			},
 			wantErr: true,
 		},
 		{
@@ -235,6 +533,17 @@ func TestUserModule_Login(t *testing.T) {
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
 				m.EXPECT().GetUserByPhoneNumber(ctx, "99").Return(&entity.User{}, nil)
 			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:99").Return(entity.LoginFailures{
+					Key: "phone:99",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:99", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
 			want: entity.LoginModuleResponse{
 				User: &entity.User{},
 			},
@@ -243,164 +552,757 @@ func TestUserModule_Login(t *testing.T) {
 		{
 			name: "hash does not match",
 			user: &entity.User{
-				PhoneNumber:   "62812345678",
+				PhoneNumber:   "+62812345678",
 				PlainPassword: "wrong password",
 			},
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62812345678").Return(&entity.User{
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
 				}, nil)
 			},
 			prepareHash: func(m *tools.MockHashInterface) {
 				m.EXPECT().ComparePassword([]byte("hashed something"), "wrong password").Return(assert.AnError)
 			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
 			want: entity.LoginModuleResponse{
 				User: &entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
 				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "error generate jwt",
+			name: "suspended user",
 			user: &entity.User{
-				PhoneNumber:   "62812345678",
+				PhoneNumber:   "+62812345678",
 				PlainPassword: "Abcde3#",
 			},
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62812345678").Return(&entity.User{
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusSuspended,
 				}, nil)
 			},
 			prepareHash: func(m *tools.MockHashInterface) {
 				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
 			},
-			prepareJWT: func(m *tools.MockJWTInterface) {
-				m.EXPECT().Generate(&entity.User{
-					ID:             1,
-					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
-					HashedPassword: "hashed something",
-				}).Return("", assert.AnError)
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
 			},
 			want: entity.LoginModuleResponse{
 				User: &entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusSuspended,
 				},
-				JWT: "",
 			},
 			wantErr: true,
 		},
 		{
-			name: "error increment login count",
+			name: "error get totp",
 			user: &entity.User{
-				PhoneNumber:   "62812345678",
+				PhoneNumber:   "+62812345678",
 				PlainPassword: "Abcde3#",
 			},
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62812345678").Return(&entity.User{
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
 				}, nil)
-				m.EXPECT().IncrementLoginCount(ctx, 1).Return(assert.AnError)
 			},
 			prepareHash: func(m *tools.MockHashInterface) {
 				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
 			},
-			prepareJWT: func(m *tools.MockJWTInterface) {
-				m.EXPECT().Generate(&entity.User{
-					ID:             1,
-					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
-					HashedPassword: "hashed something",
-				}).Return("some jwt token", nil)
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
 			},
 			want: entity.LoginModuleResponse{
 				User: &entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
 				},
-				JWT: "some jwt token",
 			},
 			wantErr: true,
 		},
 		{
-			name: "success",
+			name: "error issue login challenge",
 			user: &entity.User{
-				PhoneNumber:   "62812345678",
+				PhoneNumber:   "+62812345678",
 				PlainPassword: "Abcde3#",
 			},
 			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62812345678").Return(&entity.User{
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
 				}, nil)
-				m.EXPECT().IncrementLoginCount(ctx, 1).Return(nil)
 			},
 			prepareHash: func(m *tools.MockHashInterface) {
 				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
 			},
-			prepareJWT: func(m *tools.MockJWTInterface) {
-				m.EXPECT().Generate(&entity.User{
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(&entity.TOTP{
+					UserID:      1,
+					ConfirmedAt: &now,
+				}, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("", assert.AnError)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
-				}).Return("some jwt token", nil)
+					Status:         entity.UserStatusActive,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "two-factor authentication enabled",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(&entity.TOTP{
+					UserID:      1,
+					ConfirmedAt: &now,
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginChallenges(ctx, now).Return(nil)
+				m.EXPECT().InsertLoginChallenge(ctx, &entity.LoginChallenge{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-challenge"),
+					ExpiresAt: now.Add(LoginChallengeTTL),
+				}).Return(1, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(32).Return("plain-challenge", nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
 			},
 			want: entity.LoginModuleResponse{
 				User: &entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
 				},
-				JWT: "some jwt token",
+				ChallengeToken: "plain-challenge",
 			},
 			wantErr: false,
 		},
-	}
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
-	mockHash := tools.NewMockHashInterface(ctrl)
-	mockJWT := tools.NewMockJWTInterface(ctrl)
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if tt.prepareRepo != nil {
-				tt.prepareRepo(mockUserRepo)
-			}
-			m.userRepository = mockUserRepo
-
-			if tt.prepareHash != nil {
-				tt.prepareHash(mockHash)
-			}
-			m.hash = mockHash
-
-			if tt.prepareJWT != nil {
-				tt.prepareJWT(mockJWT)
+		{
+			name: "error generate jwt",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("", assert.AnError)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT: "",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error issue refresh token",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("", assert.AnError)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT: "some jwt token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error increment login count",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().IncrementLoginCount(ctx, 1).Return(assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error hash outdated password",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().IncrementLoginCount(ctx, 1).Return(nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(true)
+				m.EXPECT().HashPassword("Abcde3#").Return(nil, assert.AnError)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: false,
+		},
+		{
+			name: "outdated hash is upgraded",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().IncrementLoginCount(ctx, 1).Return(nil)
+				m.EXPECT().RehashPassword(ctx, 1, "hashed something", "$argon2id$new hash").Return(false, assert.AnError)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(true)
+				m.EXPECT().HashPassword("Abcde3#").Return([]byte("$argon2id$new hash"), nil)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			user: &entity.User{
+				PhoneNumber:   "+62812345678",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62812345678").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().IncrementLoginCount(ctx, 1).Return(nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "phone:+62812345678").Return(entity.LoginFailures{
+					Key: "phone:+62812345678",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "phone:+62812345678", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "phone:+62812345678").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: false,
+		},
+		{
+			name: "email not found",
+			user: &entity.User{
+				PhoneNumber: "+62812345678",
+				Email:       " John@Example.com",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "email:john@example.com", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success by email",
+			user: &entity.User{
+				Email:         "John@Example.com",
+				PlainPassword: "Abcde3#",
+			},
+			prepareRepo: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(&entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					Email:          "john@example.com",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				}, nil)
+				m.EXPECT().IncrementLoginCount(ctx, 1).Return(nil)
+			},
+			prepareHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed something"), "Abcde3#").Return(nil)
+				m.EXPECT().NeedsRehash([]byte("hashed something")).Return(false)
+			},
+			prepareJWT: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Generate(tools.Claims{
+					Subject: "1",
+				}).Return("some jwt token", nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Token(16).Return("family", nil)
+				m.EXPECT().Token(32).Return("plain-token", nil)
+			},
+			prepareRefreshTokenRepo: func(m *repository.MockRefreshTokenRepositoryInterface) {
+				m.EXPECT().DeleteExpiredRefreshTokens(ctx, now).Return(nil)
+				m.EXPECT().InsertRefreshToken(ctx, &entity.RefreshToken{
+					UserID:    1,
+					TokenHash: crxpto.SHA256("plain-token"),
+					FamilyID:  "family",
+					ExpiresAt: now.Add(time.Hour),
+				}).Return(1, nil)
+			},
+			prepareTwoFactorRepo: func(m *repository.MockTwoFactorRepositoryInterface) {
+				m.EXPECT().GetTOTP(ctx, 1).Return(nil, sql.ErrNoRows)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().GetLoginFailures(ctx, "email:john@example.com").Return(entity.LoginFailures{
+					Key: "email:john@example.com",
+				}, nil)
+				m.EXPECT().GetLoginFailures(ctx, "ip:192.0.2.1").Return(entity.LoginFailures{
+					Key: "ip:192.0.2.1",
+				}, nil)
+				m.EXPECT().DeleteExpiredLoginFailures(ctx, now.Add(-LoginFailureWindow)).Return(nil)
+				m.EXPECT().RecordLoginFailure(ctx, "email:john@example.com", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "email:john@example.com").Return(nil)
+				m.EXPECT().ForgiveLoginFailure(ctx, "ip:192.0.2.1").Return(nil)
+			},
+			want: entity.LoginModuleResponse{
+				User: &entity.User{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
+					Email:          "john@example.com",
+					HashedPassword: "hashed something",
+					Status:         entity.UserStatusActive,
+				},
+				JWT:          "some jwt token",
+				RefreshToken: "plain-token",
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockHash := tools.NewMockHashInterface(ctrl)
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockRefreshTokenRepo := repository.NewMockRefreshTokenRepositoryInterface(ctrl)
+	mockTwoFactorRepo := repository.NewMockTwoFactorRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareRepo != nil {
+				tt.prepareRepo(mockUserRepo)
+			}
+			m.userRepository = mockUserRepo
+
+			if tt.prepareHash != nil {
+				tt.prepareHash(mockHash)
+			}
+			m.hash = mockHash
+
+			if tt.prepareJWT != nil {
+				tt.prepareJWT(mockJWT)
 			}
 			m.jwt = mockJWT
 
-			got, err := m.Login(ctx, tt.user)
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareRefreshTokenRepo != nil {
+				tt.prepareRefreshTokenRepo(mockRefreshTokenRepo)
+			}
+			m.refreshTokenRepository = mockRefreshTokenRepo
+
+			if tt.prepareTwoFactorRepo != nil {
+				tt.prepareTwoFactorRepo(mockTwoFactorRepo)
+			}
+			m.twoFactorRepository = mockTwoFactorRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			got, err := m.Login(ctx, tt.user, "192.0.2.1")
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
 		})
@@ -467,20 +1369,61 @@ func TestUserModule_GetProfile(t *testing.T) {
 
 func TestUserModule_UpdateProfile(t *testing.T) {
 	ctx := context.Background()
-	m := &UserModule{}
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		defaultPhoneRegion:   "ID",
+		emailVerificationURL: "https://portal.example.com/verify-email",
+		timeNow: func() time.Time {
+			return now
+		},
+	}
 	tests := []struct {
-		name    string
-		user    *entity.User
-		prepare func(m *repository.MockUserRepositoryInterface)
-		want    entity.UpdateProfileModuleResponse
-		wantErr bool
+		name          string
+		user          *entity.User
+		prepare       func(m *repository.MockUserRepositoryInterface)
+		prepareSigner func(m *tools.MockSignerInterface)
+		prepareEmail  func(m *tools.MockEmailSenderInterface)
+		want          entity.UpdateProfileModuleResponse
+		wantErr       bool
 	}{
+		{
+			name: "invalid phone number",
+			user: &entity.User{
+				ID:          1,
+				PhoneNumber: "62899",
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"phone number must have 8-12 digits after country code +62",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid email",
+			user: &entity.User{
+				ID:    1,
+				Email: "john@localhost",
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid: false,
+				Messages: []string{
+					"email must be a valid address like name@example.com",
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "error get user",
 			user: &entity.User{},
 			prepare: func(m *repository.MockUserRepositoryInterface) {
 				m.EXPECT().GetUserByID(ctx, 0).Return(nil, assert.AnError)
 			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
 			wantErr: true,
 		},
 		{
@@ -489,6 +1432,10 @@ func TestUserModule_UpdateProfile(t *testing.T) {
 			prepare: func(m *repository.MockUserRepositoryInterface) {
 				m.EXPECT().GetUserByID(ctx, 0).Return(&entity.User{}, nil)
 			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
 			wantErr: true,
 		},
 		{
@@ -502,17 +1449,22 @@ func TestUserModule_UpdateProfile(t *testing.T) {
 				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
 				}, nil)
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62899123123").Return(nil, assert.AnError)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62899123123").Return(nil, assert.AnError)
 				m.EXPECT().UpdateUser(ctx, &entity.User{
-					ID:             1,
-					Fullname:       "John Doe Updated",
-					PhoneNumber:    "62899123123",
-					HashedPassword: "hashed something",
+					ID:                 1,
+					Fullname:           "John Doe Updated",
+					PhoneNumber:        "+62812345678",
+					PendingPhoneNumber: "+62899123123",
+					HashedPassword:     "hashed something",
 				}).Return(assert.AnError)
 			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
 			wantErr: true,
 		},
 		{
@@ -525,17 +1477,19 @@ func TestUserModule_UpdateProfile(t *testing.T) {
 				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
 				}, nil)
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62899123123").Return(&entity.User{
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62899123123").Return(&entity.User{
 					ID:             2,
 					Fullname:       "John Doe 2",
-					PhoneNumber:    "62899123123",
+					PhoneNumber:    "+62899123123",
 					HashedPassword: "hashed something",
 				}, nil)
 			},
 			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
 				Conflict: true,
 				Message:  "phone number already exist",
 			},
@@ -552,23 +1506,200 @@ func TestUserModule_UpdateProfile(t *testing.T) {
 				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
 					ID:             1,
 					Fullname:       "John Doe",
-					PhoneNumber:    "62812345678",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
 				}, nil)
-				m.EXPECT().GetUserByPhoneNumber(ctx, "62899123123").Return(&entity.User{}, nil)
+				m.EXPECT().GetUserByPhoneNumber(ctx, "+62899123123").Return(&entity.User{}, nil)
+				m.EXPECT().UpdateUser(ctx, &entity.User{
+					ID:                 1,
+					Fullname:           "John Doe Updated",
+					PhoneNumber:        "+62812345678",
+					PendingPhoneNumber: "+62899123123",
+					HashedPassword:     "hashed something",
+				}).Return(nil)
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:              true,
+				Messages:           []string{},
+				PendingPhoneNumber: "+62899123123",
+			},
+			wantErr: false,
+		},
+		{
+			name: "cancel pending phone number",
+			user: &entity.User{
+				ID:          1,
+				PhoneNumber: "0812-345-678",
+			},
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
+					ID:                 1,
+					Fullname:           "John Doe",
+					PhoneNumber:        "+62812345678",
+					PendingPhoneNumber: "+62899123123",
+					HashedPassword:     "hashed something",
+				}, nil)
 				m.EXPECT().UpdateUser(ctx, &entity.User{
 					ID:             1,
-					Fullname:       "John Doe Updated",
-					PhoneNumber:    "62899123123",
+					Fullname:       "John Doe",
+					PhoneNumber:    "+62812345678",
 					HashedPassword: "hashed something",
 				}).Return(nil)
 			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "conflicting email",
+			user: &entity.User{
+				ID:    1,
+				Email: "Jane@Example.com",
+			},
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
+					ID:          1,
+					Fullname:    "John Doe",
+					PhoneNumber: "+62812345678",
+				}, nil)
+				m.EXPECT().GetUserByEmail(ctx, "jane@example.com").Return(&entity.User{
+					ID:    2,
+					Email: "jane@example.com",
+				}, nil)
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+				Conflict: true,
+				Message:  "email already exist",
+			},
+			wantErr: false,
+		},
+		{
+			name: "new email is pending and a link is sent",
+			user: &entity.User{
+				ID:    1,
+				Email: "John@Example.com",
+			},
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
+					ID:          1,
+					Fullname:    "John Doe",
+					PhoneNumber: "+62812345678",
+				}, nil)
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+				m.EXPECT().UpdateUser(ctx, &entity.User{
+					ID:           1,
+					Fullname:     "John Doe",
+					PhoneNumber:  "+62812345678",
+					PendingEmail: "john@example.com",
+				}).Return(nil)
+			},
+			prepareSigner: func(m *tools.MockSignerInterface) {
+				m.EXPECT().Sign("email_verification:1:john@example.com", now.Add(EmailVerificationTTL)).Return("signed.token", nil)
+			},
+			prepareEmail: func(m *tools.MockEmailSenderInterface) {
+				// failing to send does not fail the update
+				m.EXPECT().Send(ctx, "john@example.com", "Verify your email", gomock.Any()).Return(assert.AnError)
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:        true,
+				Messages:     []string{},
+				PendingEmail: "john@example.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "same pending email is not sent again",
+			user: &entity.User{
+				ID:       1,
+				Fullname: "John Doe Updated",
+				Email:    "john@example.com",
+			},
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
+					ID:           1,
+					Fullname:     "John Doe",
+					PhoneNumber:  "+62812345678",
+					PendingEmail: "john@example.com",
+				}, nil)
+				m.EXPECT().GetUserByEmail(ctx, "john@example.com").Return(nil, sql.ErrNoRows)
+				m.EXPECT().UpdateUser(ctx, &entity.User{
+					ID:           1,
+					Fullname:     "John Doe Updated",
+					PhoneNumber:  "+62812345678",
+					PendingEmail: "john@example.com",
+				}).Return(nil)
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:        true,
+				Messages:     []string{},
+				PendingEmail: "john@example.com",
+			},
+			wantErr: false,
+		},
+		{
+			name: "cancel pending email",
+			user: &entity.User{
+				ID:    1,
+				Email: "john@example.com",
+			},
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
+					ID:           1,
+					Fullname:     "John Doe",
+					PhoneNumber:  "+62812345678",
+					Email:        "john@example.com",
+					PendingEmail: "john.doe@example.com",
+				}, nil)
+				m.EXPECT().UpdateUser(ctx, &entity.User{
+					ID:          1,
+					Fullname:    "John Doe",
+					PhoneNumber: "+62812345678",
+					Email:       "john@example.com",
+				}).Return(nil)
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:    true,
+				Messages: []string{},
+			},
+			wantErr: false,
+		},
+		{
+			name: "pending email is kept when not given",
+			user: &entity.User{
+				ID:       1,
+				Fullname: "John Doe Updated",
+			},
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserByID(ctx, 1).Return(&entity.User{
+					ID:           1,
+					Fullname:     "John Doe",
+					PhoneNumber:  "+62812345678",
+					PendingEmail: "john@example.com",
+				}, nil)
+				m.EXPECT().UpdateUser(ctx, &entity.User{
+					ID:           1,
+					Fullname:     "John Doe Updated",
+					PhoneNumber:  "+62812345678",
+					PendingEmail: "john@example.com",
+				}).Return(nil)
+			},
+			want: entity.UpdateProfileModuleResponse{
+				Valid:        true,
+				Messages:     []string{},
+				PendingEmail: "john@example.com",
+			},
 			wantErr: false,
 		},
 	}
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockSigner := tools.NewMockSignerInterface(ctrl)
+	mockEmail := tools.NewMockEmailSenderInterface(ctrl)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.prepare != nil {
@@ -576,6 +1707,16 @@ func TestUserModule_UpdateProfile(t *testing.T) {
 			}
 			m.userRepository = mockUserRepo
 
+			if tt.prepareSigner != nil {
+				tt.prepareSigner(mockSigner)
+			}
+			m.signer = mockSigner
+
+			if tt.prepareEmail != nil {
+				tt.prepareEmail(mockEmail)
+			}
+			m.emailSender = mockEmail
+
 			got, err := m.UpdateProfile(ctx, tt.user)
 			assert.Equal(t, tt.wantErr, err != nil)
 			assert.Equal(t, tt.want, got)
@@ -0,0 +1,147 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+const (
+	// VerificationCodeTTL is how long a code sent over sms can be used.
+	VerificationCodeTTL = time.Minute * 10
+	// MaxVerificationCodeAttempts is how many times codes sent to a phone number can be tried for a purpose,
+	// counted across every code sent until none is tried for LoginFailureWindow or one is used.
+	MaxVerificationCodeAttempts = 5
+	// verificationCodeLength is the number of digits of a code sent over sms.
+	verificationCodeLength = 6
+	// verificationCodeResendInterval limits how often a code is sent to the same phone number.
+	verificationCodeResendInterval = time.Minute
+)
+
+var (
+	// ErrInvalidVerificationCode obscures whether the code is wrong, expired, used, or tried too many times.
+	ErrInvalidVerificationCode = errors.New("verification code is not valid")
+)
+
+// verificationMessages is the sms text of each purpose, filled with the code and its lifetime in minutes.
+var verificationMessages = map[string]string{
+	entity.VerificationPurposePasswordReset:     "Your password reset code is %s. It expires in %d minutes, never share it with anyone.",
+	entity.VerificationPurposePhoneVerification: "Your phone verification code is %s. It expires in %d minutes, never share it with anyone.",
+	entity.VerificationPurposeLogin:             "Your login code is %s. It expires in %d minutes, never share it with anyone.",
+}
+
+// issueVerificationCode sends a new code to the phone number, replacing the code sent before for the same purpose.
+// Nothing is sent while the previous code is still recent.
+func (m *UserModule) issueVerificationCode(ctx context.Context, phoneNumber, purpose string) error {
+	now := m.timeNow()
+
+	current, err := m.verificationRepository.GetActiveVerificationCode(ctx, phoneNumber, purpose)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil && now.Before(current.CreatedAt.Add(verificationCodeResendInterval)) {
+		return nil
+	}
+
+	var code string
+	code, err = m.random.Digits(verificationCodeLength)
+	if err != nil {
+		return err
+	}
+
+	// code is hashed, so a leaked database does not leak usable codes
+	var codeHash []byte
+	codeHash, err = m.codeHash.HashPassword(code)
+	if err != nil {
+		return err
+	}
+
+	err = m.verificationRepository.DeleteExpiredVerificationCodes(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.verificationRepository.InsertVerificationCode(ctx, &entity.VerificationCode{
+		PhoneNumber: phoneNumber,
+		Purpose:     purpose,
+		CodeHash:    string(codeHash),
+		ExpiresAt:   now.Add(VerificationCodeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf(verificationMessages[purpose], code, int(VerificationCodeTTL.Minutes()))
+	return m.smsSender.Send(ctx, phoneNumber, message)
+}
+
+// verificationThrottleKey returns the key attempts of codes sent to a phone number for a purpose are counted under.
+func verificationThrottleKey(phoneNumber, purpose string) string {
+	return "code:" + purpose + ":" + phoneNumber
+}
+
+// verifyCode consumes the active code of the phone number for the purpose if it matches the given code.
+func (m *UserModule) verifyCode(ctx context.Context, phoneNumber, purpose, code string) error {
+	current, err := m.checkCode(ctx, phoneNumber, purpose, code)
+	if err != nil {
+		return err
+	}
+
+	return m.consumeCode(ctx, current)
+}
+
+// checkCode returns the active code of the phone number for the purpose if it matches the given code.
+// The attempt is counted but the code is left for consumeCode, so it can still be used if the request is rejected for another reason.
+func (m *UserModule) checkCode(ctx context.Context, phoneNumber, purpose, code string) (*entity.VerificationCode, error) {
+	current, err := m.verificationRepository.GetActiveVerificationCode(ctx, phoneNumber, purpose)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.timeNow()
+	if current.Expired(now) {
+		return nil, ErrInvalidVerificationCode
+	}
+
+	// the attempt is counted before comparing, so concurrent guesses cannot exceed the limit,
+	// and per phone number instead of per code, so requesting a new code does not bring more guesses
+	var attempts int
+	attempts, err = m.throttleRepository.RecordLoginFailure(ctx, verificationThrottleKey(phoneNumber, purpose), now, now.Add(-LoginFailureWindow))
+	if err != nil {
+		return nil, err
+	}
+	if attempts > MaxVerificationCodeAttempts {
+		return nil, ErrInvalidVerificationCode
+	}
+
+	err = m.codeHash.ComparePassword([]byte(current.CodeHash), code)
+	if err != nil {
+		return nil, ErrInvalidVerificationCode
+	}
+
+	return current, nil
+}
+
+// consumeCode uses up a code matched by checkCode so it cannot be used again, and forgets the attempts counted before it.
+func (m *UserModule) consumeCode(ctx context.Context, code *entity.VerificationCode) error {
+	consumed, err := m.verificationRepository.ConsumeVerificationCode(ctx, code.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		// another request managed to use the same code first
+		return ErrInvalidVerificationCode
+	}
+
+	// failing to forget is not reported, the attempts are forgotten after LoginFailureWindow anyway
+	_ = m.throttleRepository.ResetLoginFailures(ctx, verificationThrottleKey(code.PhoneNumber, code.Purpose))
+
+	return nil
+}
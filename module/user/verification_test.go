@@ -0,0 +1,329 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserModule_issueVerificationCode(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	newCode := &entity.VerificationCode{
+		PhoneNumber: "628123456789",
+		Purpose:     entity.VerificationPurposePasswordReset,
+		CodeHash:    "hashed code",
+		ExpiresAt:   now.Add(VerificationCodeTTL),
+	}
+	tests := []struct {
+		name                    string
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareRandom           func(m *tools.MockRandomInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		prepareSMS              func(m *tools.MockSMSSenderInterface)
+		wantErr                 bool
+	}{
+		{
+			name: "error get active code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "previous code is still recent",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(&entity.VerificationCode{
+					ID:        1,
+					CreatedAt: now.Add(-time.Second * 30),
+				}, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "error generate code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("", assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error hash code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return(nil, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error delete expired codes",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(assert.AnError)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error insert code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, newCode).Return(0, assert.AnError)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error send sms",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, newCode).Return(2, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "628123456789", gomock.Any()).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success replacing old code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(&entity.VerificationCode{
+					ID:        1,
+					CreatedAt: now.Add(-time.Minute),
+				}, nil)
+				m.EXPECT().DeleteExpiredVerificationCodes(ctx, now).Return(nil)
+				m.EXPECT().InsertVerificationCode(ctx, newCode).Return(2, nil)
+			},
+			prepareRandom: func(m *tools.MockRandomInterface) {
+				m.EXPECT().Digits(6).Return("123456", nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().HashPassword("123456").Return([]byte("hashed code"), nil)
+			},
+			prepareSMS: func(m *tools.MockSMSSenderInterface) {
+				m.EXPECT().Send(ctx, "628123456789", "Your password reset code is 123456. It expires in 10 minutes, never share it with anyone.").Return(nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	mockSMS := tools.NewMockSMSSenderInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareRandom != nil {
+				tt.prepareRandom(mockRandom)
+			}
+			m.random = mockRandom
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			if tt.prepareSMS != nil {
+				tt.prepareSMS(mockSMS)
+			}
+			m.smsSender = mockSMS
+
+			err := m.issueVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestUserModule_verifyCode(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	m := &UserModule{
+		timeNow: func() time.Time {
+			return now
+		},
+	}
+	activeCode := &entity.VerificationCode{
+		ID:          1,
+		PhoneNumber: "628123456789",
+		Purpose:     entity.VerificationPurposePasswordReset,
+		CodeHash:    "hashed code",
+		ExpiresAt:   now.Add(time.Minute),
+	}
+	// attempt returns the number of attempts counted against codes of the phone number so far
+	attempt := func(attempts int) func(m *repository.MockThrottleRepositoryInterface) {
+		return func(m *repository.MockThrottleRepositoryInterface) {
+			m.EXPECT().RecordLoginFailure(ctx, "code:password_reset:628123456789", now, now.Add(-LoginFailureWindow)).Return(attempts, nil)
+		}
+	}
+	tests := []struct {
+		name                    string
+		prepareVerificationRepo func(m *repository.MockVerificationRepositoryInterface)
+		prepareThrottleRepo     func(m *repository.MockThrottleRepositoryInterface)
+		prepareCodeHash         func(m *tools.MockHashInterface)
+		wantErr                 error
+	}{
+		{
+			name: "no active code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, sql.ErrNoRows)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "error get active code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(nil, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "expired code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(&entity.VerificationCode{
+					ID:        1,
+					CodeHash:  "hashed code",
+					ExpiresAt: now,
+				}, nil)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "error count attempt",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(activeCode, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "code:password_reset:628123456789", now, now.Add(-LoginFailureWindow)).Return(0, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "too many attempts across codes of the phone number",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(activeCode, nil)
+			},
+			prepareThrottleRepo: attempt(6),
+			wantErr:             ErrInvalidVerificationCode,
+		},
+		{
+			name: "wrong code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(activeCode, nil)
+			},
+			prepareThrottleRepo: attempt(5),
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(assert.AnError)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "error consume code",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(activeCode, nil)
+				m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(false, assert.AnError)
+			},
+			prepareThrottleRepo: attempt(1),
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "code used by another request",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(activeCode, nil)
+				m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(false, nil)
+			},
+			prepareThrottleRepo: attempt(1),
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			wantErr: ErrInvalidVerificationCode,
+		},
+		{
+			name: "success",
+			prepareVerificationRepo: func(m *repository.MockVerificationRepositoryInterface) {
+				m.EXPECT().GetActiveVerificationCode(ctx, "628123456789", entity.VerificationPurposePasswordReset).Return(activeCode, nil)
+				m.EXPECT().ConsumeVerificationCode(ctx, 1).Return(true, nil)
+			},
+			prepareThrottleRepo: func(m *repository.MockThrottleRepositoryInterface) {
+				m.EXPECT().RecordLoginFailure(ctx, "code:password_reset:628123456789", now, now.Add(-LoginFailureWindow)).Return(1, nil)
+				m.EXPECT().ResetLoginFailures(ctx, "code:password_reset:628123456789").Return(nil)
+			},
+			prepareCodeHash: func(m *tools.MockHashInterface) {
+				m.EXPECT().ComparePassword([]byte("hashed code"), "123456").Return(nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockVerificationRepo := repository.NewMockVerificationRepositoryInterface(ctrl)
+	mockThrottleRepo := repository.NewMockThrottleRepositoryInterface(ctrl)
+	mockCodeHash := tools.NewMockHashInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepareVerificationRepo != nil {
+				tt.prepareVerificationRepo(mockVerificationRepo)
+			}
+			m.verificationRepository = mockVerificationRepo
+
+			if tt.prepareThrottleRepo != nil {
+				tt.prepareThrottleRepo(mockThrottleRepo)
+			}
+			m.throttleRepository = mockThrottleRepo
+
+			if tt.prepareCodeHash != nil {
+				tt.prepareCodeHash(mockCodeHash)
+			}
+			m.codeHash = mockCodeHash
+
+			err := m.verifyCode(ctx, "628123456789", entity.VerificationPurposePasswordReset, "123456")
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+package repository
+
+import "errors"
+
+// ErrDuplicate is returned when a write would store a value that must be unique, like an email,
+// which another row already holds.
+var ErrDuplicate = errors.New("duplicate value")
@@ -0,0 +1,2 @@
+// Package federation directly relates to federated_identities and federated_login_requests tables in database.
+package federation
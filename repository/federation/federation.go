@@ -0,0 +1,323 @@
+package federation
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+type FederationRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of FederationRepository.
+func New(opts NewRepositoryOptions) *FederationRepository {
+	return &FederationRepository{
+		db: opts.DB,
+	}
+}
+
+// InsertLoginRequest inserts a new login sent to an external provider, returning its id on success.
+func (r *FederationRepository) InsertLoginRequest(ctx context.Context, req *entity.FederatedLoginRequest) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// zero user id is stored as null, the request is not linking an account to anyone
+	query := `
+		INSERT INTO federated_login_requests (
+			state_hash,
+			provider,
+			nonce,
+			code_verifier,
+			user_id,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			NULLIF($5, 0),
+			$6
+		) RETURNING id;
+	`
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		req.StateHash,
+		req.Provider,
+		req.Nonce,
+		req.CodeVerifier,
+		req.UserID,
+		req.ExpiresAt,
+	).Scan(&req.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return req.ID, nil
+}
+
+// ConsumeLoginRequest marks the login request of the state hash as completed and returns it.
+// It returns sql.ErrNoRows if there is no such request or it has already been consumed by another request,
+// so a state can only be used once.
+func (r *FederationRepository) ConsumeLoginRequest(ctx context.Context, stateHash string) (*entity.FederatedLoginRequest, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE federated_login_requests
+		SET
+			consumed_at = now()
+		WHERE state_hash = $1
+			AND consumed_at IS NULL
+		RETURNING
+			id,
+			state_hash,
+			provider,
+			nonce,
+			code_verifier,
+			COALESCE(user_id, 0),
+			expires_at,
+			consumed_at,
+			created_at;
+	`
+	var req = &entity.FederatedLoginRequest{}
+	err = tx.QueryRowContext(ctx, query, stateHash).Scan(
+		&req.ID,
+		&req.StateHash,
+		&req.Provider,
+		&req.Nonce,
+		&req.CodeVerifier,
+		&req.UserID,
+		&req.ExpiresAt,
+		&req.ConsumedAt,
+		&req.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// DeleteExpiredLoginRequests removes requests that expired before the given time, consumed or not.
+func (r *FederationRepository) DeleteExpiredLoginRequests(ctx context.Context, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM federated_login_requests
+		WHERE expires_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetIdentity returns the linked account of the provider identified by issuer and subject.
+func (r *FederationRepository) GetIdentity(ctx context.Context, issuer, subject string) (*entity.FederatedIdentity, error) {
+	var identity = &entity.FederatedIdentity{}
+
+	query := `
+		SELECT
+			id,
+			user_id,
+			provider,
+			issuer,
+			subject,
+			email,
+			created_at
+		FROM federated_identities
+		WHERE issuer = $1
+			AND subject = $2;
+	`
+	err := r.db.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Issuer,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// GetIdentitiesByUserID returns every account linked to a user, oldest first.
+func (r *FederationRepository) GetIdentitiesByUserID(ctx context.Context, userID int) ([]*entity.FederatedIdentity, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			provider,
+			issuer,
+			subject,
+			email,
+			created_at
+		FROM federated_identities
+		WHERE user_id = $1
+		ORDER BY id;
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*entity.FederatedIdentity{}
+	for rows.Next() {
+		var identity = &entity.FederatedIdentity{}
+		err = rows.Scan(
+			&identity.ID,
+			&identity.UserID,
+			&identity.Provider,
+			&identity.Issuer,
+			&identity.Subject,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
+
+// InsertIdentity links an account of a provider to a user, returning its id on success.
+// It returns sql.ErrNoRows if the account is already linked to any user, even by a request running at the same time.
+func (r *FederationRepository) InsertIdentity(ctx context.Context, identity *entity.FederatedIdentity) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO federated_identities (
+			user_id,
+			provider,
+			issuer,
+			subject,
+			email
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		) ON CONFLICT (issuer, subject) DO NOTHING
+		RETURNING id;
+	`
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		identity.UserID,
+		identity.Provider,
+		identity.Issuer,
+		identity.Subject,
+		identity.Email,
+	).Scan(&identity.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return identity.ID, nil
+}
+
+// DeleteIdentity unlinks an account from the user.
+// It returns false if the user has no linked account with the id.
+func (r *FederationRepository) DeleteIdentity(ctx context.Context, userID, identityID int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM federated_identities
+		WHERE id = $1
+			AND user_id = $2;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, identityID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
@@ -0,0 +1,694 @@
+package federation
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestFederationRepository_InsertLoginRequest(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	expiresAt := time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC)
+	loginRequest := func() *entity.FederatedLoginRequest {
+		return &entity.FederatedLoginRequest{
+			StateHash:    "hashed-state",
+			Provider:     "google",
+			Nonce:        "some-nonce",
+			CodeVerifier: "some-verifier",
+			ExpiresAt:    expiresAt,
+		}
+	}
+	tests := []struct {
+		name    string
+		req     *entity.FederatedLoginRequest
+		prepare func(m sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			req:  loginRequest(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query row context",
+			req:  loginRequest(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_login_requests.*NULLIF\(\$5, 0\).*`).
+					WithArgs("hashed-state", "google", "some-nonce", "some-verifier", 0, expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			req:  loginRequest(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_login_requests.*NULLIF\(\$5, 0\).*`).
+					WithArgs("hashed-state", "google", "some-nonce", "some-verifier", 0, expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success linking",
+			req: func() *entity.FederatedLoginRequest {
+				req := loginRequest()
+				req.UserID = 15
+				return req
+			}(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_login_requests.*NULLIF\(\$5, 0\).*`).
+					WithArgs("hashed-state", "google", "some-nonce", "some-verifier", 15, expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    3,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.InsertLoginRequest(ctx, tt.req)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFederationRepository_ConsumeLoginRequest(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	consumedAt := time.Date(2023, 8, 5, 12, 36, 51, 900, time.UTC)
+	columns := []string{
+		"id",
+		"state_hash",
+		"provider",
+		"nonce",
+		"code_verifier",
+		"user_id",
+		"expires_at",
+		"consumed_at",
+		"created_at",
+	}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    *entity.FederatedLoginRequest
+		wantErr error
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "unknown or consumed",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`UPDATE federated_login_requests SET consumed_at = now\(\) WHERE state_hash = \$1 AND consumed_at IS NULL RETURNING.*`).
+					WithArgs("hashed-state").
+					WillReturnRows(sqlmock.NewRows(columns))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: sql.ErrNoRows,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`UPDATE federated_login_requests SET consumed_at = now\(\) WHERE state_hash = \$1 AND consumed_at IS NULL RETURNING.*`).
+					WithArgs("hashed-state").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						3,
+						"hashed-state",
+						"google",
+						"some-nonce",
+						"some-verifier",
+						0,
+						time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC),
+						consumedAt,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`UPDATE federated_login_requests SET consumed_at = now\(\) WHERE state_hash = \$1 AND consumed_at IS NULL RETURNING.*`).
+					WithArgs("hashed-state").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						3,
+						"hashed-state",
+						"google",
+						"some-nonce",
+						"some-verifier",
+						15,
+						time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC),
+						consumedAt,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want: &entity.FederatedLoginRequest{
+				ID:           3,
+				StateHash:    "hashed-state",
+				Provider:     "google",
+				Nonce:        "some-nonce",
+				CodeVerifier: "some-verifier",
+				UserID:       15,
+				ExpiresAt:    time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC),
+				ConsumedAt:   &consumedAt,
+				CreatedAt:    time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.ConsumeLoginRequest(ctx, "hashed-state")
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFederationRepository_DeleteExpiredLoginRequests(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	expiredBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_login_requests WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_login_requests WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_login_requests WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredLoginRequests(ctx, expiredBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFederationRepository_GetIdentity(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    *entity.FederatedIdentity
+		wantErr bool
+	}{
+		{
+			name: "error",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM federated_identities WHERE issuer = \$1 AND subject = \$2`).
+					WithArgs("https://accounts.google.com", "1076").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM federated_identities WHERE issuer = \$1 AND subject = \$2`).
+					WithArgs("https://accounts.google.com", "1076").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"provider",
+						"issuer",
+						"subject",
+						"email",
+						"created_at",
+					}).AddRow(
+						4,
+						15,
+						"google",
+						"https://accounts.google.com",
+						"1076",
+						"john@example.com",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.FederatedIdentity{
+				ID:        4,
+				UserID:    15,
+				Provider:  "google",
+				Issuer:    "https://accounts.google.com",
+				Subject:   "1076",
+				Email:     "john@example.com",
+				CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetIdentity(ctx, "https://accounts.google.com", "1076")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFederationRepository_GetIdentitiesByUserID(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	columns := []string{
+		"id",
+		"user_id",
+		"provider",
+		"issuer",
+		"subject",
+		"email",
+		"created_at",
+	}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    []*entity.FederatedIdentity
+		wantErr bool
+	}{
+		{
+			name: "error query context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM federated_identities WHERE user_id = \$1 ORDER BY id`).
+					WithArgs(15).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error scan",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM federated_identities WHERE user_id = \$1 ORDER BY id`).
+					WithArgs(15).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						"not-a-number",
+						15,
+						"google",
+						"https://accounts.google.com",
+						"1076",
+						"john@example.com",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM federated_identities WHERE user_id = \$1 ORDER BY id`).
+					WithArgs(15).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						4,
+						15,
+						"google",
+						"https://accounts.google.com",
+						"1076",
+						"john@example.com",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					).RowError(0, assert.AnError))
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM federated_identities WHERE user_id = \$1 ORDER BY id`).
+					WithArgs(15).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						4,
+						15,
+						"google",
+						"https://accounts.google.com",
+						"1076",
+						"john@example.com",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					).AddRow(
+						5,
+						15,
+						"microsoft",
+						"https://login.microsoftonline.com/some-tenant/v2.0",
+						"AAAAAAAAAAAAAAAAAAAAAIkzqFVrSaSaFHy782bbtaQ",
+						"john@example.onmicrosoft.com",
+						time.Date(2023, 8, 6, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: []*entity.FederatedIdentity{
+				{
+					ID:        4,
+					UserID:    15,
+					Provider:  "google",
+					Issuer:    "https://accounts.google.com",
+					Subject:   "1076",
+					Email:     "john@example.com",
+					CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				},
+				{
+					ID:        5,
+					UserID:    15,
+					Provider:  "microsoft",
+					Issuer:    "https://login.microsoftonline.com/some-tenant/v2.0",
+					Subject:   "AAAAAAAAAAAAAAAAAAAAAIkzqFVrSaSaFHy782bbtaQ",
+					Email:     "john@example.onmicrosoft.com",
+					CreatedAt: time.Date(2023, 8, 6, 12, 35, 51, 900, time.UTC),
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetIdentitiesByUserID(ctx, 15)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFederationRepository_InsertIdentity(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	identity := func() *entity.FederatedIdentity {
+		return &entity.FederatedIdentity{
+			UserID:   15,
+			Provider: "google",
+			Issuer:   "https://accounts.google.com",
+			Subject:  "1076",
+			Email:    "john@example.com",
+		}
+	}
+	tests := []struct {
+		name     string
+		identity *entity.FederatedIdentity
+		prepare  func(m sqlmock.Sqlmock)
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "error begin tx",
+			identity: identity(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error query row context",
+			identity: identity(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_identities.*`).
+					WithArgs(15, "google", "https://accounts.google.com", "1076", "john@example.com").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error already linked",
+			identity: identity(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_identities.*ON CONFLICT \(issuer, subject\) DO NOTHING`).
+					WithArgs(15, "google", "https://accounts.google.com", "1076", "john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error commit",
+			identity: identity(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_identities.*`).
+					WithArgs(15, "google", "https://accounts.google.com", "1076", "john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "success",
+			identity: identity(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO federated_identities.*`).
+					WithArgs(15, "google", "https://accounts.google.com", "1076", "john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    4,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.InsertIdentity(ctx, tt.identity)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFederationRepository_DeleteIdentity(t *testing.T) {
+	ctx := context.Background()
+	r := &FederationRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_identities WHERE id = \$1 AND user_id = \$2`).
+					WithArgs(4, 15).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_identities WHERE id = \$1 AND user_id = \$2`).
+					WithArgs(4, 15).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_identities WHERE id = \$1 AND user_id = \$2`).
+					WithArgs(4, 15).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "not linked to the user",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_identities WHERE id = \$1 AND user_id = \$2`).
+					WithArgs(4, 15).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM federated_identities WHERE id = \$1 AND user_id = \$2`).
+					WithArgs(4, 15).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.DeleteIdentity(ctx, 15, 4)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
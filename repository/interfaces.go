@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/leguminosa/profile-open-portal/entity"
 )
@@ -10,8 +11,82 @@ import (
 
 type UserRepositoryInterface interface {
 	GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entity.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*entity.User, error)
 	GetUserByID(ctx context.Context, userID int) (*entity.User, error)
 	InsertUser(ctx context.Context, user *entity.User) (int, error)
 	UpdateUser(ctx context.Context, user *entity.User) error
+	UpdatePassword(ctx context.Context, userID int, hashedPassword string, keepHistory int) (int, error)
+	GetPasswordHistory(ctx context.Context, userID, limit int) ([]*entity.PasswordHistory, error)
+	RehashPassword(ctx context.Context, userID int, oldHashedPassword, newHashedPassword string) (bool, error)
+	VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) (bool, error)
+	VerifyEmail(ctx context.Context, userID int, email string) (bool, error)
 	IncrementLoginCount(ctx context.Context, userID int) error
+	ListUsers(ctx context.Context, filter entity.ListUsersFilter) ([]*entity.User, error)
+	GetUserSessionState(ctx context.Context, userID int) (entity.UserSessionState, error)
+	UpdateUserStatus(ctx context.Context, userID int, from, to string) (bool, error)
+	IncrementTokenVersion(ctx context.Context, userID int) error
+}
+
+type RefreshTokenRepositoryInterface interface {
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error)
+	InsertRefreshToken(ctx context.Context, token *entity.RefreshToken) (int, error)
+	RevokeRefreshToken(ctx context.Context, tokenID int) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	DeleteExpiredRefreshTokens(ctx context.Context, expiredBefore time.Time) error
+}
+
+type RevocationRepositoryInterface interface {
+	RevokeToken(ctx context.Context, token *entity.RevokedToken) error
+	RedeemToken(ctx context.Context, token *entity.RevokedToken) (bool, error)
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context, expiredBefore time.Time) error
+}
+
+type VerificationRepositoryInterface interface {
+	GetActiveVerificationCode(ctx context.Context, phoneNumber, purpose string) (*entity.VerificationCode, error)
+	InsertVerificationCode(ctx context.Context, code *entity.VerificationCode) (int, error)
+	ConsumeVerificationCode(ctx context.Context, codeID int) (bool, error)
+	DeleteExpiredVerificationCodes(ctx context.Context, expiredBefore time.Time) error
+}
+
+type TwoFactorRepositoryInterface interface {
+	GetTOTP(ctx context.Context, userID int) (*entity.TOTP, error)
+	UpsertTOTP(ctx context.Context, totp *entity.TOTP) (bool, error)
+	ConfirmTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (bool, error)
+	UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userID int) error
+	GetUnusedRecoveryCodes(ctx context.Context, userID int) ([]*entity.RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, codeID int) (bool, error)
+	InsertLoginChallenge(ctx context.Context, challenge *entity.LoginChallenge) (int, error)
+	GetLoginChallengeByHash(ctx context.Context, tokenHash string) (*entity.LoginChallenge, error)
+	IncrementLoginChallengeAttempts(ctx context.Context, challengeID int) (int, error)
+	ConsumeLoginChallenge(ctx context.Context, challengeID int) (bool, error)
+	DeleteExpiredLoginChallenges(ctx context.Context, expiredBefore time.Time) error
+}
+
+type ThrottleRepositoryInterface interface {
+	GetLoginFailures(ctx context.Context, key string) (entity.LoginFailures, error)
+	RecordLoginFailure(ctx context.Context, key string, failedAt, resetBefore time.Time) (int, error)
+	ForgiveLoginFailure(ctx context.Context, key string) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	DeleteExpiredLoginFailures(ctx context.Context, resetBefore time.Time) error
+}
+
+type OIDCRepositoryInterface interface {
+	GetOAuthClient(ctx context.Context, clientID string) (*entity.OAuthClient, error)
+	InsertAuthorizationCode(ctx context.Context, code *entity.AuthorizationCode) (int, error)
+	GetAuthorizationCodeByHash(ctx context.Context, codeHash string) (*entity.AuthorizationCode, error)
+	ConsumeAuthorizationCode(ctx context.Context, codeID int, accessTokenID string) (bool, error)
+	DeleteExpiredAuthorizationCodes(ctx context.Context, expiredBefore time.Time) error
+}
+
+type FederationRepositoryInterface interface {
+	InsertLoginRequest(ctx context.Context, req *entity.FederatedLoginRequest) (int, error)
+	ConsumeLoginRequest(ctx context.Context, stateHash string) (*entity.FederatedLoginRequest, error)
+	DeleteExpiredLoginRequests(ctx context.Context, expiredBefore time.Time) error
+	GetIdentity(ctx context.Context, issuer, subject string) (*entity.FederatedIdentity, error)
+	GetIdentitiesByUserID(ctx context.Context, userID int) ([]*entity.FederatedIdentity, error)
+	InsertIdentity(ctx context.Context, identity *entity.FederatedIdentity) (int, error)
+	DeleteIdentity(ctx context.Context, userID, identityID int) (bool, error)
 }
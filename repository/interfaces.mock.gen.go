@@ -7,6 +7,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	entity "github.com/leguminosa/profile-open-portal/entity"
@@ -35,6 +36,36 @@ func (m *MockUserRepositoryInterface) EXPECT() *MockUserRepositoryInterfaceMockR
 	return m.recorder
 }
 
+// GetPasswordHistory mocks base method.
+func (m *MockUserRepositoryInterface) GetPasswordHistory(ctx context.Context, userID, limit int) ([]*entity.PasswordHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistory", ctx, userID, limit)
+	ret0, _ := ret[0].([]*entity.PasswordHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistory indicates an expected call of GetPasswordHistory.
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetPasswordHistory(ctx, userID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistory", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetPasswordHistory), ctx, userID, limit)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockUserRepositoryInterface) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(*entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetUserByEmail(ctx, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUserByEmail), ctx, email)
+}
+
 // GetUserByID mocks base method.
 func (m *MockUserRepositoryInterface) GetUserByID(ctx context.Context, userID int) (*entity.User, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +96,21 @@ func (mr *MockUserRepositoryInterfaceMockRecorder) GetUserByPhoneNumber(ctx, pho
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByPhoneNumber", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUserByPhoneNumber), ctx, phoneNumber)
 }
 
+// GetUserSessionState mocks base method.
+func (m *MockUserRepositoryInterface) GetUserSessionState(ctx context.Context, userID int) (entity.UserSessionState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserSessionState", ctx, userID)
+	ret0, _ := ret[0].(entity.UserSessionState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserSessionState indicates an expected call of GetUserSessionState.
+func (mr *MockUserRepositoryInterfaceMockRecorder) GetUserSessionState(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserSessionState", reflect.TypeOf((*MockUserRepositoryInterface)(nil).GetUserSessionState), ctx, userID)
+}
+
 // IncrementLoginCount mocks base method.
 func (m *MockUserRepositoryInterface) IncrementLoginCount(ctx context.Context, userID int) error {
 	m.ctrl.T.Helper()
@@ -79,6 +125,20 @@ func (mr *MockUserRepositoryInterfaceMockRecorder) IncrementLoginCount(ctx, user
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementLoginCount", reflect.TypeOf((*MockUserRepositoryInterface)(nil).IncrementLoginCount), ctx, userID)
 }
 
+// IncrementTokenVersion mocks base method.
+func (m *MockUserRepositoryInterface) IncrementTokenVersion(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementTokenVersion", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementTokenVersion indicates an expected call of IncrementTokenVersion.
+func (mr *MockUserRepositoryInterfaceMockRecorder) IncrementTokenVersion(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementTokenVersion", reflect.TypeOf((*MockUserRepositoryInterface)(nil).IncrementTokenVersion), ctx, userID)
+}
+
 // InsertUser mocks base method.
 func (m *MockUserRepositoryInterface) InsertUser(ctx context.Context, user *entity.User) (int, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +154,51 @@ func (mr *MockUserRepositoryInterfaceMockRecorder) InsertUser(ctx, user interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).InsertUser), ctx, user)
 }
 
+// ListUsers mocks base method.
+func (m *MockUserRepositoryInterface) ListUsers(ctx context.Context, filter entity.ListUsersFilter) ([]*entity.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, filter)
+	ret0, _ := ret[0].([]*entity.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockUserRepositoryInterfaceMockRecorder) ListUsers(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockUserRepositoryInterface)(nil).ListUsers), ctx, filter)
+}
+
+// RehashPassword mocks base method.
+func (m *MockUserRepositoryInterface) RehashPassword(ctx context.Context, userID int, oldHashedPassword, newHashedPassword string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RehashPassword", ctx, userID, oldHashedPassword, newHashedPassword)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RehashPassword indicates an expected call of RehashPassword.
+func (mr *MockUserRepositoryInterfaceMockRecorder) RehashPassword(ctx, userID, oldHashedPassword, newHashedPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RehashPassword", reflect.TypeOf((*MockUserRepositoryInterface)(nil).RehashPassword), ctx, userID, oldHashedPassword, newHashedPassword)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserRepositoryInterface) UpdatePassword(ctx context.Context, userID int, hashedPassword string, keepHistory int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, userID, hashedPassword, keepHistory)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserRepositoryInterfaceMockRecorder) UpdatePassword(ctx, userID, hashedPassword, keepHistory interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserRepositoryInterface)(nil).UpdatePassword), ctx, userID, hashedPassword, keepHistory)
+}
+
 // UpdateUser mocks base method.
 func (m *MockUserRepositoryInterface) UpdateUser(ctx context.Context, user *entity.User) error {
 	m.ctrl.T.Helper()
@@ -107,3 +212,841 @@ func (mr *MockUserRepositoryInterfaceMockRecorder) UpdateUser(ctx, user interfac
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserRepositoryInterface)(nil).UpdateUser), ctx, user)
 }
+
+// UpdateUserStatus mocks base method.
+func (m *MockUserRepositoryInterface) UpdateUserStatus(ctx context.Context, userID int, from, to string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserStatus", ctx, userID, from, to)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserStatus indicates an expected call of UpdateUserStatus.
+func (mr *MockUserRepositoryInterfaceMockRecorder) UpdateUserStatus(ctx, userID, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserStatus", reflect.TypeOf((*MockUserRepositoryInterface)(nil).UpdateUserStatus), ctx, userID, from, to)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUserRepositoryInterface) VerifyEmail(ctx context.Context, userID int, email string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, userID, email)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUserRepositoryInterfaceMockRecorder) VerifyEmail(ctx, userID, email interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUserRepositoryInterface)(nil).VerifyEmail), ctx, userID, email)
+}
+
+// VerifyPhoneNumber mocks base method.
+func (m *MockUserRepositoryInterface) VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyPhoneNumber", ctx, userID, phoneNumber)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyPhoneNumber indicates an expected call of VerifyPhoneNumber.
+func (mr *MockUserRepositoryInterfaceMockRecorder) VerifyPhoneNumber(ctx, userID, phoneNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyPhoneNumber", reflect.TypeOf((*MockUserRepositoryInterface)(nil).VerifyPhoneNumber), ctx, userID, phoneNumber)
+}
+
+// MockRefreshTokenRepositoryInterface is a mock of RefreshTokenRepositoryInterface interface.
+type MockRefreshTokenRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockRefreshTokenRepositoryInterfaceMockRecorder
+}
+
+// MockRefreshTokenRepositoryInterfaceMockRecorder is the mock recorder for MockRefreshTokenRepositoryInterface.
+type MockRefreshTokenRepositoryInterfaceMockRecorder struct {
+	mock *MockRefreshTokenRepositoryInterface
+}
+
+// NewMockRefreshTokenRepositoryInterface creates a new mock instance.
+func NewMockRefreshTokenRepositoryInterface(ctrl *gomock.Controller) *MockRefreshTokenRepositoryInterface {
+	mock := &MockRefreshTokenRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockRefreshTokenRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRefreshTokenRepositoryInterface) EXPECT() *MockRefreshTokenRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// DeleteExpiredRefreshTokens mocks base method.
+func (m *MockRefreshTokenRepositoryInterface) DeleteExpiredRefreshTokens(ctx context.Context, expiredBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRefreshTokens", ctx, expiredBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredRefreshTokens indicates an expected call of DeleteExpiredRefreshTokens.
+func (mr *MockRefreshTokenRepositoryInterfaceMockRecorder) DeleteExpiredRefreshTokens(ctx, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRefreshTokens", reflect.TypeOf((*MockRefreshTokenRepositoryInterface)(nil).DeleteExpiredRefreshTokens), ctx, expiredBefore)
+}
+
+// GetRefreshTokenByHash mocks base method.
+func (m *MockRefreshTokenRepositoryInterface) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshTokenByHash", ctx, tokenHash)
+	ret0, _ := ret[0].(*entity.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshTokenByHash indicates an expected call of GetRefreshTokenByHash.
+func (mr *MockRefreshTokenRepositoryInterfaceMockRecorder) GetRefreshTokenByHash(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshTokenByHash", reflect.TypeOf((*MockRefreshTokenRepositoryInterface)(nil).GetRefreshTokenByHash), ctx, tokenHash)
+}
+
+// InsertRefreshToken mocks base method.
+func (m *MockRefreshTokenRepositoryInterface) InsertRefreshToken(ctx context.Context, token *entity.RefreshToken) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertRefreshToken", ctx, token)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertRefreshToken indicates an expected call of InsertRefreshToken.
+func (mr *MockRefreshTokenRepositoryInterfaceMockRecorder) InsertRefreshToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertRefreshToken", reflect.TypeOf((*MockRefreshTokenRepositoryInterface)(nil).InsertRefreshToken), ctx, token)
+}
+
+// RevokeRefreshToken mocks base method.
+func (m *MockRefreshTokenRepositoryInterface) RevokeRefreshToken(ctx context.Context, tokenID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshToken", ctx, tokenID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeRefreshToken indicates an expected call of RevokeRefreshToken.
+func (mr *MockRefreshTokenRepositoryInterfaceMockRecorder) RevokeRefreshToken(ctx, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshToken", reflect.TypeOf((*MockRefreshTokenRepositoryInterface)(nil).RevokeRefreshToken), ctx, tokenID)
+}
+
+// RevokeRefreshTokenFamily mocks base method.
+func (m *MockRefreshTokenRepositoryInterface) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshTokenFamily", ctx, familyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshTokenFamily indicates an expected call of RevokeRefreshTokenFamily.
+func (mr *MockRefreshTokenRepositoryInterfaceMockRecorder) RevokeRefreshTokenFamily(ctx, familyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshTokenFamily", reflect.TypeOf((*MockRefreshTokenRepositoryInterface)(nil).RevokeRefreshTokenFamily), ctx, familyID)
+}
+
+// RevokeUserRefreshTokens mocks base method.
+func (m *MockRefreshTokenRepositoryInterface) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserRefreshTokens", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserRefreshTokens indicates an expected call of RevokeUserRefreshTokens.
+func (mr *MockRefreshTokenRepositoryInterfaceMockRecorder) RevokeUserRefreshTokens(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserRefreshTokens", reflect.TypeOf((*MockRefreshTokenRepositoryInterface)(nil).RevokeUserRefreshTokens), ctx, userID)
+}
+
+// MockRevocationRepositoryInterface is a mock of RevocationRepositoryInterface interface.
+type MockRevocationRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockRevocationRepositoryInterfaceMockRecorder
+}
+
+// MockRevocationRepositoryInterfaceMockRecorder is the mock recorder for MockRevocationRepositoryInterface.
+type MockRevocationRepositoryInterfaceMockRecorder struct {
+	mock *MockRevocationRepositoryInterface
+}
+
+// NewMockRevocationRepositoryInterface creates a new mock instance.
+func NewMockRevocationRepositoryInterface(ctrl *gomock.Controller) *MockRevocationRepositoryInterface {
+	mock := &MockRevocationRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockRevocationRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRevocationRepositoryInterface) EXPECT() *MockRevocationRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// DeleteExpiredTokens mocks base method.
+func (m *MockRevocationRepositoryInterface) DeleteExpiredTokens(ctx context.Context, expiredBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredTokens", ctx, expiredBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredTokens indicates an expected call of DeleteExpiredTokens.
+func (mr *MockRevocationRepositoryInterfaceMockRecorder) DeleteExpiredTokens(ctx, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredTokens", reflect.TypeOf((*MockRevocationRepositoryInterface)(nil).DeleteExpiredTokens), ctx, expiredBefore)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockRevocationRepositoryInterface) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", ctx, tokenID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockRevocationRepositoryInterfaceMockRecorder) IsTokenRevoked(ctx, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockRevocationRepositoryInterface)(nil).IsTokenRevoked), ctx, tokenID)
+}
+
+// RedeemToken mocks base method.
+func (m *MockRevocationRepositoryInterface) RedeemToken(ctx context.Context, token *entity.RevokedToken) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemToken", ctx, token)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RedeemToken indicates an expected call of RedeemToken.
+func (mr *MockRevocationRepositoryInterfaceMockRecorder) RedeemToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemToken", reflect.TypeOf((*MockRevocationRepositoryInterface)(nil).RedeemToken), ctx, token)
+}
+
+// RevokeToken mocks base method.
+func (m *MockRevocationRepositoryInterface) RevokeToken(ctx context.Context, token *entity.RevokedToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockRevocationRepositoryInterfaceMockRecorder) RevokeToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockRevocationRepositoryInterface)(nil).RevokeToken), ctx, token)
+}
+
+// MockVerificationRepositoryInterface is a mock of VerificationRepositoryInterface interface.
+type MockVerificationRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockVerificationRepositoryInterfaceMockRecorder
+}
+
+// MockVerificationRepositoryInterfaceMockRecorder is the mock recorder for MockVerificationRepositoryInterface.
+type MockVerificationRepositoryInterfaceMockRecorder struct {
+	mock *MockVerificationRepositoryInterface
+}
+
+// NewMockVerificationRepositoryInterface creates a new mock instance.
+func NewMockVerificationRepositoryInterface(ctrl *gomock.Controller) *MockVerificationRepositoryInterface {
+	mock := &MockVerificationRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockVerificationRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockVerificationRepositoryInterface) EXPECT() *MockVerificationRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// ConsumeVerificationCode mocks base method.
+func (m *MockVerificationRepositoryInterface) ConsumeVerificationCode(ctx context.Context, codeID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeVerificationCode", ctx, codeID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeVerificationCode indicates an expected call of ConsumeVerificationCode.
+func (mr *MockVerificationRepositoryInterfaceMockRecorder) ConsumeVerificationCode(ctx, codeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeVerificationCode", reflect.TypeOf((*MockVerificationRepositoryInterface)(nil).ConsumeVerificationCode), ctx, codeID)
+}
+
+// DeleteExpiredVerificationCodes mocks base method.
+func (m *MockVerificationRepositoryInterface) DeleteExpiredVerificationCodes(ctx context.Context, expiredBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredVerificationCodes", ctx, expiredBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredVerificationCodes indicates an expected call of DeleteExpiredVerificationCodes.
+func (mr *MockVerificationRepositoryInterfaceMockRecorder) DeleteExpiredVerificationCodes(ctx, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredVerificationCodes", reflect.TypeOf((*MockVerificationRepositoryInterface)(nil).DeleteExpiredVerificationCodes), ctx, expiredBefore)
+}
+
+// GetActiveVerificationCode mocks base method.
+func (m *MockVerificationRepositoryInterface) GetActiveVerificationCode(ctx context.Context, phoneNumber, purpose string) (*entity.VerificationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetActiveVerificationCode", ctx, phoneNumber, purpose)
+	ret0, _ := ret[0].(*entity.VerificationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetActiveVerificationCode indicates an expected call of GetActiveVerificationCode.
+func (mr *MockVerificationRepositoryInterfaceMockRecorder) GetActiveVerificationCode(ctx, phoneNumber, purpose interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetActiveVerificationCode", reflect.TypeOf((*MockVerificationRepositoryInterface)(nil).GetActiveVerificationCode), ctx, phoneNumber, purpose)
+}
+
+// InsertVerificationCode mocks base method.
+func (m *MockVerificationRepositoryInterface) InsertVerificationCode(ctx context.Context, code *entity.VerificationCode) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertVerificationCode", ctx, code)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertVerificationCode indicates an expected call of InsertVerificationCode.
+func (mr *MockVerificationRepositoryInterfaceMockRecorder) InsertVerificationCode(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertVerificationCode", reflect.TypeOf((*MockVerificationRepositoryInterface)(nil).InsertVerificationCode), ctx, code)
+}
+
+// MockTwoFactorRepositoryInterface is a mock of TwoFactorRepositoryInterface interface.
+type MockTwoFactorRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorRepositoryInterfaceMockRecorder
+}
+
+// MockTwoFactorRepositoryInterfaceMockRecorder is the mock recorder for MockTwoFactorRepositoryInterface.
+type MockTwoFactorRepositoryInterfaceMockRecorder struct {
+	mock *MockTwoFactorRepositoryInterface
+}
+
+// NewMockTwoFactorRepositoryInterface creates a new mock instance.
+func NewMockTwoFactorRepositoryInterface(ctrl *gomock.Controller) *MockTwoFactorRepositoryInterface {
+	mock := &MockTwoFactorRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactorRepositoryInterface) EXPECT() *MockTwoFactorRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockTwoFactorRepositoryInterface) ConfirmTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, userID, step, recoveryCodeHashes)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) ConfirmTOTP(ctx, userID, step, recoveryCodeHashes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).ConfirmTOTP), ctx, userID, step, recoveryCodeHashes)
+}
+
+// ConsumeLoginChallenge mocks base method.
+func (m *MockTwoFactorRepositoryInterface) ConsumeLoginChallenge(ctx context.Context, challengeID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeLoginChallenge", ctx, challengeID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeLoginChallenge indicates an expected call of ConsumeLoginChallenge.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) ConsumeLoginChallenge(ctx, challengeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeLoginChallenge", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).ConsumeLoginChallenge), ctx, challengeID)
+}
+
+// DeleteExpiredLoginChallenges mocks base method.
+func (m *MockTwoFactorRepositoryInterface) DeleteExpiredLoginChallenges(ctx context.Context, expiredBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredLoginChallenges", ctx, expiredBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredLoginChallenges indicates an expected call of DeleteExpiredLoginChallenges.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) DeleteExpiredLoginChallenges(ctx, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredLoginChallenges", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).DeleteExpiredLoginChallenges), ctx, expiredBefore)
+}
+
+// DeleteTOTP mocks base method.
+func (m *MockTwoFactorRepositoryInterface) DeleteTOTP(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTP indicates an expected call of DeleteTOTP.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) DeleteTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTP", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).DeleteTOTP), ctx, userID)
+}
+
+// GetLoginChallengeByHash mocks base method.
+func (m *MockTwoFactorRepositoryInterface) GetLoginChallengeByHash(ctx context.Context, tokenHash string) (*entity.LoginChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginChallengeByHash", ctx, tokenHash)
+	ret0, _ := ret[0].(*entity.LoginChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginChallengeByHash indicates an expected call of GetLoginChallengeByHash.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) GetLoginChallengeByHash(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginChallengeByHash", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).GetLoginChallengeByHash), ctx, tokenHash)
+}
+
+// GetTOTP mocks base method.
+func (m *MockTwoFactorRepositoryInterface) GetTOTP(ctx context.Context, userID int) (*entity.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", ctx, userID)
+	ret0, _ := ret[0].(*entity.TOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTOTP indicates an expected call of GetTOTP.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) GetTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).GetTOTP), ctx, userID)
+}
+
+// GetUnusedRecoveryCodes mocks base method.
+func (m *MockTwoFactorRepositoryInterface) GetUnusedRecoveryCodes(ctx context.Context, userID int) ([]*entity.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnusedRecoveryCodes", ctx, userID)
+	ret0, _ := ret[0].([]*entity.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnusedRecoveryCodes indicates an expected call of GetUnusedRecoveryCodes.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) GetUnusedRecoveryCodes(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnusedRecoveryCodes", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).GetUnusedRecoveryCodes), ctx, userID)
+}
+
+// IncrementLoginChallengeAttempts mocks base method.
+func (m *MockTwoFactorRepositoryInterface) IncrementLoginChallengeAttempts(ctx context.Context, challengeID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementLoginChallengeAttempts", ctx, challengeID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementLoginChallengeAttempts indicates an expected call of IncrementLoginChallengeAttempts.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) IncrementLoginChallengeAttempts(ctx, challengeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementLoginChallengeAttempts", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).IncrementLoginChallengeAttempts), ctx, challengeID)
+}
+
+// InsertLoginChallenge mocks base method.
+func (m *MockTwoFactorRepositoryInterface) InsertLoginChallenge(ctx context.Context, challenge *entity.LoginChallenge) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertLoginChallenge", ctx, challenge)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertLoginChallenge indicates an expected call of InsertLoginChallenge.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) InsertLoginChallenge(ctx, challenge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertLoginChallenge", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).InsertLoginChallenge), ctx, challenge)
+}
+
+// UpsertTOTP mocks base method.
+func (m *MockTwoFactorRepositoryInterface) UpsertTOTP(ctx context.Context, totp *entity.TOTP) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertTOTP", ctx, totp)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertTOTP indicates an expected call of UpsertTOTP.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) UpsertTOTP(ctx, totp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertTOTP", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).UpsertTOTP), ctx, totp)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockTwoFactorRepositoryInterface) UseRecoveryCode(ctx context.Context, codeID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, codeID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) UseRecoveryCode(ctx, codeID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).UseRecoveryCode), ctx, codeID)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockTwoFactorRepositoryInterface) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userID, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockTwoFactorRepositoryInterfaceMockRecorder) UseTOTPStep(ctx, userID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockTwoFactorRepositoryInterface)(nil).UseTOTPStep), ctx, userID, step)
+}
+
+// MockThrottleRepositoryInterface is a mock of ThrottleRepositoryInterface interface.
+type MockThrottleRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockThrottleRepositoryInterfaceMockRecorder
+}
+
+// MockThrottleRepositoryInterfaceMockRecorder is the mock recorder for MockThrottleRepositoryInterface.
+type MockThrottleRepositoryInterfaceMockRecorder struct {
+	mock *MockThrottleRepositoryInterface
+}
+
+// NewMockThrottleRepositoryInterface creates a new mock instance.
+func NewMockThrottleRepositoryInterface(ctrl *gomock.Controller) *MockThrottleRepositoryInterface {
+	mock := &MockThrottleRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockThrottleRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockThrottleRepositoryInterface) EXPECT() *MockThrottleRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// DeleteExpiredLoginFailures mocks base method.
+func (m *MockThrottleRepositoryInterface) DeleteExpiredLoginFailures(ctx context.Context, resetBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredLoginFailures", ctx, resetBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredLoginFailures indicates an expected call of DeleteExpiredLoginFailures.
+func (mr *MockThrottleRepositoryInterfaceMockRecorder) DeleteExpiredLoginFailures(ctx, resetBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredLoginFailures", reflect.TypeOf((*MockThrottleRepositoryInterface)(nil).DeleteExpiredLoginFailures), ctx, resetBefore)
+}
+
+// ForgiveLoginFailure mocks base method.
+func (m *MockThrottleRepositoryInterface) ForgiveLoginFailure(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgiveLoginFailure", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgiveLoginFailure indicates an expected call of ForgiveLoginFailure.
+func (mr *MockThrottleRepositoryInterfaceMockRecorder) ForgiveLoginFailure(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgiveLoginFailure", reflect.TypeOf((*MockThrottleRepositoryInterface)(nil).ForgiveLoginFailure), ctx, key)
+}
+
+// GetLoginFailures mocks base method.
+func (m *MockThrottleRepositoryInterface) GetLoginFailures(ctx context.Context, key string) (entity.LoginFailures, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginFailures", ctx, key)
+	ret0, _ := ret[0].(entity.LoginFailures)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginFailures indicates an expected call of GetLoginFailures.
+func (mr *MockThrottleRepositoryInterfaceMockRecorder) GetLoginFailures(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginFailures", reflect.TypeOf((*MockThrottleRepositoryInterface)(nil).GetLoginFailures), ctx, key)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockThrottleRepositoryInterface) RecordLoginFailure(ctx context.Context, key string, failedAt, resetBefore time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, key, failedAt, resetBefore)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockThrottleRepositoryInterfaceMockRecorder) RecordLoginFailure(ctx, key, failedAt, resetBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockThrottleRepositoryInterface)(nil).RecordLoginFailure), ctx, key, failedAt, resetBefore)
+}
+
+// ResetLoginFailures mocks base method.
+func (m *MockThrottleRepositoryInterface) ResetLoginFailures(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginFailures", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginFailures indicates an expected call of ResetLoginFailures.
+func (mr *MockThrottleRepositoryInterfaceMockRecorder) ResetLoginFailures(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginFailures", reflect.TypeOf((*MockThrottleRepositoryInterface)(nil).ResetLoginFailures), ctx, key)
+}
+
+// MockOIDCRepositoryInterface is a mock of OIDCRepositoryInterface interface.
+type MockOIDCRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockOIDCRepositoryInterfaceMockRecorder
+}
+
+// MockOIDCRepositoryInterfaceMockRecorder is the mock recorder for MockOIDCRepositoryInterface.
+type MockOIDCRepositoryInterfaceMockRecorder struct {
+	mock *MockOIDCRepositoryInterface
+}
+
+// NewMockOIDCRepositoryInterface creates a new mock instance.
+func NewMockOIDCRepositoryInterface(ctrl *gomock.Controller) *MockOIDCRepositoryInterface {
+	mock := &MockOIDCRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockOIDCRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOIDCRepositoryInterface) EXPECT() *MockOIDCRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// ConsumeAuthorizationCode mocks base method.
+func (m *MockOIDCRepositoryInterface) ConsumeAuthorizationCode(ctx context.Context, codeID int, accessTokenID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeAuthorizationCode", ctx, codeID, accessTokenID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeAuthorizationCode indicates an expected call of ConsumeAuthorizationCode.
+func (mr *MockOIDCRepositoryInterfaceMockRecorder) ConsumeAuthorizationCode(ctx, codeID, accessTokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeAuthorizationCode", reflect.TypeOf((*MockOIDCRepositoryInterface)(nil).ConsumeAuthorizationCode), ctx, codeID, accessTokenID)
+}
+
+// DeleteExpiredAuthorizationCodes mocks base method.
+func (m *MockOIDCRepositoryInterface) DeleteExpiredAuthorizationCodes(ctx context.Context, expiredBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredAuthorizationCodes", ctx, expiredBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredAuthorizationCodes indicates an expected call of DeleteExpiredAuthorizationCodes.
+func (mr *MockOIDCRepositoryInterfaceMockRecorder) DeleteExpiredAuthorizationCodes(ctx, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredAuthorizationCodes", reflect.TypeOf((*MockOIDCRepositoryInterface)(nil).DeleteExpiredAuthorizationCodes), ctx, expiredBefore)
+}
+
+// GetAuthorizationCodeByHash mocks base method.
+func (m *MockOIDCRepositoryInterface) GetAuthorizationCodeByHash(ctx context.Context, codeHash string) (*entity.AuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuthorizationCodeByHash", ctx, codeHash)
+	ret0, _ := ret[0].(*entity.AuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuthorizationCodeByHash indicates an expected call of GetAuthorizationCodeByHash.
+func (mr *MockOIDCRepositoryInterfaceMockRecorder) GetAuthorizationCodeByHash(ctx, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuthorizationCodeByHash", reflect.TypeOf((*MockOIDCRepositoryInterface)(nil).GetAuthorizationCodeByHash), ctx, codeHash)
+}
+
+// GetOAuthClient mocks base method.
+func (m *MockOIDCRepositoryInterface) GetOAuthClient(ctx context.Context, clientID string) (*entity.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClient", ctx, clientID)
+	ret0, _ := ret[0].(*entity.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClient indicates an expected call of GetOAuthClient.
+func (mr *MockOIDCRepositoryInterfaceMockRecorder) GetOAuthClient(ctx, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClient", reflect.TypeOf((*MockOIDCRepositoryInterface)(nil).GetOAuthClient), ctx, clientID)
+}
+
+// InsertAuthorizationCode mocks base method.
+func (m *MockOIDCRepositoryInterface) InsertAuthorizationCode(ctx context.Context, code *entity.AuthorizationCode) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertAuthorizationCode", ctx, code)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertAuthorizationCode indicates an expected call of InsertAuthorizationCode.
+func (mr *MockOIDCRepositoryInterfaceMockRecorder) InsertAuthorizationCode(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAuthorizationCode", reflect.TypeOf((*MockOIDCRepositoryInterface)(nil).InsertAuthorizationCode), ctx, code)
+}
+
+// MockFederationRepositoryInterface is a mock of FederationRepositoryInterface interface.
+type MockFederationRepositoryInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockFederationRepositoryInterfaceMockRecorder
+}
+
+// MockFederationRepositoryInterfaceMockRecorder is the mock recorder for MockFederationRepositoryInterface.
+type MockFederationRepositoryInterfaceMockRecorder struct {
+	mock *MockFederationRepositoryInterface
+}
+
+// NewMockFederationRepositoryInterface creates a new mock instance.
+func NewMockFederationRepositoryInterface(ctrl *gomock.Controller) *MockFederationRepositoryInterface {
+	mock := &MockFederationRepositoryInterface{ctrl: ctrl}
+	mock.recorder = &MockFederationRepositoryInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFederationRepositoryInterface) EXPECT() *MockFederationRepositoryInterfaceMockRecorder {
+	return m.recorder
+}
+
+// ConsumeLoginRequest mocks base method.
+func (m *MockFederationRepositoryInterface) ConsumeLoginRequest(ctx context.Context, stateHash string) (*entity.FederatedLoginRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeLoginRequest", ctx, stateHash)
+	ret0, _ := ret[0].(*entity.FederatedLoginRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeLoginRequest indicates an expected call of ConsumeLoginRequest.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) ConsumeLoginRequest(ctx, stateHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeLoginRequest", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).ConsumeLoginRequest), ctx, stateHash)
+}
+
+// DeleteExpiredLoginRequests mocks base method.
+func (m *MockFederationRepositoryInterface) DeleteExpiredLoginRequests(ctx context.Context, expiredBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredLoginRequests", ctx, expiredBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredLoginRequests indicates an expected call of DeleteExpiredLoginRequests.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) DeleteExpiredLoginRequests(ctx, expiredBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredLoginRequests", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).DeleteExpiredLoginRequests), ctx, expiredBefore)
+}
+
+// DeleteIdentity mocks base method.
+func (m *MockFederationRepositoryInterface) DeleteIdentity(ctx context.Context, userID, identityID int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdentity", ctx, userID, identityID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteIdentity indicates an expected call of DeleteIdentity.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) DeleteIdentity(ctx, userID, identityID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdentity", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).DeleteIdentity), ctx, userID, identityID)
+}
+
+// GetIdentitiesByUserID mocks base method.
+func (m *MockFederationRepositoryInterface) GetIdentitiesByUserID(ctx context.Context, userID int) ([]*entity.FederatedIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentitiesByUserID", ctx, userID)
+	ret0, _ := ret[0].([]*entity.FederatedIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentitiesByUserID indicates an expected call of GetIdentitiesByUserID.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) GetIdentitiesByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentitiesByUserID", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).GetIdentitiesByUserID), ctx, userID)
+}
+
+// GetIdentity mocks base method.
+func (m *MockFederationRepositoryInterface) GetIdentity(ctx context.Context, issuer, subject string) (*entity.FederatedIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentity", ctx, issuer, subject)
+	ret0, _ := ret[0].(*entity.FederatedIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentity indicates an expected call of GetIdentity.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) GetIdentity(ctx, issuer, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentity", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).GetIdentity), ctx, issuer, subject)
+}
+
+// InsertIdentity mocks base method.
+func (m *MockFederationRepositoryInterface) InsertIdentity(ctx context.Context, identity *entity.FederatedIdentity) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertIdentity", ctx, identity)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertIdentity indicates an expected call of InsertIdentity.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) InsertIdentity(ctx, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertIdentity", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).InsertIdentity), ctx, identity)
+}
+
+// InsertLoginRequest mocks base method.
+func (m *MockFederationRepositoryInterface) InsertLoginRequest(ctx context.Context, req *entity.FederatedLoginRequest) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertLoginRequest", ctx, req)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertLoginRequest indicates an expected call of InsertLoginRequest.
+func (mr *MockFederationRepositoryInterfaceMockRecorder) InsertLoginRequest(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertLoginRequest", reflect.TypeOf((*MockFederationRepositoryInterface)(nil).InsertLoginRequest), ctx, req)
+}
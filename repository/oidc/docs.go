@@ -0,0 +1,2 @@
+// Package oidc directly relates to oauth_clients and authorization_codes tables in database.
+package oidc
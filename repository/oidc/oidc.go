@@ -0,0 +1,217 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/lib/pq"
+)
+
+type OIDCRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of OIDCRepository.
+func New(opts NewRepositoryOptions) *OIDCRepository {
+	return &OIDCRepository{
+		db: opts.DB,
+	}
+}
+
+// GetOAuthClient returns a registered client application by its client id.
+func (r *OIDCRepository) GetOAuthClient(ctx context.Context, clientID string) (*entity.OAuthClient, error) {
+	var client = &entity.OAuthClient{}
+
+	query := `
+		SELECT
+			id,
+			name,
+			COALESCE(secret_hash, ''),
+			redirect_uris,
+			created_at
+		FROM oauth_clients
+		WHERE id = $1;
+	`
+	err := r.db.QueryRowContext(ctx, query, clientID).Scan(
+		&client.ID,
+		&client.Name,
+		&client.SecretHash,
+		pq.Array(&client.RedirectURIs),
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// InsertAuthorizationCode inserts a new code to database, returning its id on success.
+func (r *OIDCRepository) InsertAuthorizationCode(ctx context.Context, code *entity.AuthorizationCode) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO authorization_codes (
+			client_id,
+			user_id,
+			code_hash,
+			redirect_uri,
+			scope,
+			nonce,
+			code_challenge,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			$7,
+			$8
+		) RETURNING id;
+	`
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		code.ClientID,
+		code.UserID,
+		code.CodeHash,
+		code.RedirectURI,
+		code.Scope,
+		code.Nonce,
+		code.CodeChallenge,
+		code.ExpiresAt,
+	).Scan(&code.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return code.ID, nil
+}
+
+// GetAuthorizationCodeByHash returns authorization code by its sha256 hash.
+func (r *OIDCRepository) GetAuthorizationCodeByHash(ctx context.Context, codeHash string) (*entity.AuthorizationCode, error) {
+	var code = &entity.AuthorizationCode{}
+
+	query := `
+		SELECT
+			id,
+			client_id,
+			user_id,
+			code_hash,
+			redirect_uri,
+			scope,
+			nonce,
+			code_challenge,
+			expires_at,
+			consumed_at,
+			access_token_id,
+			created_at
+		FROM authorization_codes
+		WHERE code_hash = $1;
+	`
+	err := r.db.QueryRowContext(ctx, query, codeHash).Scan(
+		&code.ID,
+		&code.ClientID,
+		&code.UserID,
+		&code.CodeHash,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+		&code.ConsumedAt,
+		&code.AccessTokenID,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// ConsumeAuthorizationCode marks a code as exchanged for the access token with the given jti.
+// It returns false if the code has already been consumed by another request.
+func (r *OIDCRepository) ConsumeAuthorizationCode(ctx context.Context, codeID int, accessTokenID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE authorization_codes
+		SET
+			consumed_at = now(),
+			access_token_id = $2
+		WHERE id = $1
+			AND consumed_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, codeID, accessTokenID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// DeleteExpiredAuthorizationCodes removes codes that expired before the given time, consumed or not.
+func (r *OIDCRepository) DeleteExpiredAuthorizationCodes(ctx context.Context, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM authorization_codes
+		WHERE expires_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,505 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestOIDCRepository_GetOAuthClient(t *testing.T) {
+	ctx := context.Background()
+	r := &OIDCRepository{}
+	tests := []struct {
+		name     string
+		clientID string
+		prepare  func(m sqlmock.Sqlmock)
+		want     *entity.OAuthClient
+		wantErr  bool
+	}{
+		{
+			name:     "error",
+			clientID: "wiki",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM oauth_clients WHERE id = \$1`).
+					WithArgs("wiki").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "success",
+			clientID: "wiki",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM oauth_clients WHERE id = \$1`).
+					WithArgs("wiki").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"name",
+						"secret_hash",
+						"redirect_uris",
+						"created_at",
+					}).AddRow(
+						"wiki",
+						"Wiki",
+						"hashed-secret",
+						"{https://wiki.example.com/callback,http://localhost:3000/callback}",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.OAuthClient{
+				ID:         "wiki",
+				Name:       "Wiki",
+				SecretHash: "hashed-secret",
+				RedirectURIs: []string{
+					"https://wiki.example.com/callback",
+					"http://localhost:3000/callback",
+				},
+				CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetOAuthClient(ctx, tt.clientID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOIDCRepository_InsertAuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+	r := &OIDCRepository{}
+	expiresAt := time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC)
+	code := func() *entity.AuthorizationCode {
+		return &entity.AuthorizationCode{
+			ClientID:      "wiki",
+			UserID:        1,
+			CodeHash:      "hashed-code",
+			RedirectURI:   "https://wiki.example.com/callback",
+			Scope:         "openid profile",
+			Nonce:         "some-nonce",
+			CodeChallenge: "some-challenge",
+			ExpiresAt:     expiresAt,
+		}
+	}
+	tests := []struct {
+		name    string
+		code    *entity.AuthorizationCode
+		prepare func(m sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query row context",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO authorization_codes.*`).
+					WithArgs("wiki", 1, "hashed-code", "https://wiki.example.com/callback", "openid profile", "some-nonce", "some-challenge", expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO authorization_codes.*`).
+					WithArgs("wiki", 1, "hashed-code", "https://wiki.example.com/callback", "openid profile", "some-nonce", "some-challenge", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO authorization_codes.*`).
+					WithArgs("wiki", 1, "hashed-code", "https://wiki.example.com/callback", "openid profile", "some-nonce", "some-challenge", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    2,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.InsertAuthorizationCode(ctx, tt.code)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOIDCRepository_GetAuthorizationCodeByHash(t *testing.T) {
+	ctx := context.Background()
+	r := &OIDCRepository{}
+	consumedAt := time.Date(2023, 8, 5, 12, 36, 51, 900, time.UTC)
+	tests := []struct {
+		name     string
+		codeHash string
+		prepare  func(m sqlmock.Sqlmock)
+		want     *entity.AuthorizationCode
+		wantErr  bool
+	}{
+		{
+			name:     "error",
+			codeHash: "hashed-code",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM authorization_codes WHERE code_hash = \$1`).
+					WithArgs("hashed-code").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "success",
+			codeHash: "hashed-code",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM authorization_codes WHERE code_hash = \$1`).
+					WithArgs("hashed-code").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"client_id",
+						"user_id",
+						"code_hash",
+						"redirect_uri",
+						"scope",
+						"nonce",
+						"code_challenge",
+						"expires_at",
+						"consumed_at",
+						"access_token_id",
+						"created_at",
+					}).AddRow(
+						2,
+						"wiki",
+						1,
+						"hashed-code",
+						"https://wiki.example.com/callback",
+						"openid profile",
+						"some-nonce",
+						"some-challenge",
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						nil,
+						"",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.AuthorizationCode{
+				ID:            2,
+				ClientID:      "wiki",
+				UserID:        1,
+				CodeHash:      "hashed-code",
+				RedirectURI:   "https://wiki.example.com/callback",
+				Scope:         "openid profile",
+				Nonce:         "some-nonce",
+				CodeChallenge: "some-challenge",
+				ExpiresAt:     time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+				CreatedAt:     time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+		{
+			name:     "success consumed",
+			codeHash: "hashed-code",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM authorization_codes WHERE code_hash = \$1`).
+					WithArgs("hashed-code").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"client_id",
+						"user_id",
+						"code_hash",
+						"redirect_uri",
+						"scope",
+						"nonce",
+						"code_challenge",
+						"expires_at",
+						"consumed_at",
+						"access_token_id",
+						"created_at",
+					}).AddRow(
+						2,
+						"wiki",
+						1,
+						"hashed-code",
+						"https://wiki.example.com/callback",
+						"openid profile",
+						"some-nonce",
+						"some-challenge",
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						consumedAt,
+						"access-token-id",
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.AuthorizationCode{
+				ID:            2,
+				ClientID:      "wiki",
+				UserID:        1,
+				CodeHash:      "hashed-code",
+				RedirectURI:   "https://wiki.example.com/callback",
+				Scope:         "openid profile",
+				Nonce:         "some-nonce",
+				CodeChallenge: "some-challenge",
+				ExpiresAt:     time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+				ConsumedAt:    &consumedAt,
+				AccessTokenID: "access-token-id",
+				CreatedAt:     time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetAuthorizationCodeByHash(ctx, tt.codeHash)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOIDCRepository_ConsumeAuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+	r := &OIDCRepository{}
+	tests := []struct {
+		name          string
+		codeID        int
+		accessTokenID string
+		prepare       func(m sqlmock.Sqlmock)
+		want          bool
+		wantErr       bool
+	}{
+		{
+			name:          "error begin tx",
+			codeID:        2,
+			accessTokenID: "access-token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:          "error exec context",
+			codeID:        2,
+			accessTokenID: "access-token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE authorization_codes SET consumed_at = now\(\), access_token_id = \$2 WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(2, "access-token-id").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:          "error rows affected",
+			codeID:        2,
+			accessTokenID: "access-token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE authorization_codes SET consumed_at = now\(\), access_token_id = \$2 WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(2, "access-token-id").
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:          "error commit",
+			codeID:        2,
+			accessTokenID: "access-token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE authorization_codes SET consumed_at = now\(\), access_token_id = \$2 WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(2, "access-token-id").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:          "already consumed",
+			codeID:        2,
+			accessTokenID: "access-token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE authorization_codes SET consumed_at = now\(\), access_token_id = \$2 WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(2, "access-token-id").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:          "success",
+			codeID:        2,
+			accessTokenID: "access-token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE authorization_codes SET consumed_at = now\(\), access_token_id = \$2 WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(2, "access-token-id").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.ConsumeAuthorizationCode(ctx, tt.codeID, tt.accessTokenID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOIDCRepository_DeleteExpiredAuthorizationCodes(t *testing.T) {
+	ctx := context.Background()
+	r := &OIDCRepository{}
+	expiredBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM authorization_codes WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM authorization_codes WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM authorization_codes WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredAuthorizationCodes(ctx, expiredBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package refreshtoken directly relates to refresh_tokens table in database.
+package refreshtoken
@@ -0,0 +1,235 @@
+package refreshtoken
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of RefreshTokenRepository.
+func New(opts NewRepositoryOptions) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: opts.DB,
+	}
+}
+
+// GetRefreshTokenByHash returns a single refresh token because token hash is stored uniquely.
+func (r *RefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*entity.RefreshToken, error) {
+	var token = &entity.RefreshToken{}
+
+	query := `
+		SELECT
+			id,
+			user_id,
+			token_hash,
+			family_id,
+			expires_at,
+			revoked_at,
+			created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1;
+	`
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// InsertRefreshToken inserts a new refresh token to database, returning its id on success.
+func (r *RefreshTokenRepository) InsertRefreshToken(ctx context.Context, token *entity.RefreshToken) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO refresh_tokens (
+			user_id,
+			token_hash,
+			family_id,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4
+		) RETURNING id;
+	`
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.TokenHash,
+		token.FamilyID,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return token.ID, nil
+}
+
+// RevokeRefreshToken marks a single refresh token as used.
+// It returns false if the token has already been revoked by another request,
+// which lets the caller detect concurrent reuse of the same token.
+func (r *RefreshTokenRepository) RevokeRefreshToken(ctx context.Context, tokenID int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE refresh_tokens
+		SET
+			revoked_at = now()
+		WHERE id = $1
+			AND revoked_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// RevokeRefreshTokenFamily revokes every token rotated from the same login.
+func (r *RefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE refresh_tokens
+		SET
+			revoked_at = now()
+		WHERE family_id = $1
+			AND revoked_at IS NULL;
+	`
+	_, err = tx.ExecContext(ctx, query, familyID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every active refresh token owned by a user.
+func (r *RefreshTokenRepository) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE refresh_tokens
+		SET
+			revoked_at = now()
+		WHERE user_id = $1
+			AND revoked_at IS NULL;
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredRefreshTokens removes tokens that expired before the given time, revoked or not.
+func (r *RefreshTokenRepository) DeleteExpiredRefreshTokens(ctx context.Context, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM refresh_tokens
+		WHERE expires_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,543 @@
+package refreshtoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestRefreshTokenRepository_GetRefreshTokenByHash(t *testing.T) {
+	ctx := context.Background()
+	r := &RefreshTokenRepository{}
+	revokedAt := time.Date(2023, 8, 6, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name      string
+		tokenHash string
+		prepare   func(m sqlmock.Sqlmock)
+		want      *entity.RefreshToken
+		wantErr   bool
+	}{
+		{
+			name:      "error",
+			tokenHash: "hashed-token",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM refresh_tokens WHERE token_hash = \$1`).
+					WithArgs("hashed-token").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "success active token",
+			tokenHash: "hashed-token",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM refresh_tokens WHERE token_hash = \$1`).
+					WithArgs("hashed-token").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"token_hash",
+						"family_id",
+						"expires_at",
+						"revoked_at",
+						"created_at",
+					}).AddRow(
+						1,
+						15,
+						"hashed-token",
+						"family",
+						time.Date(2023, 9, 5, 12, 35, 51, 900, time.UTC),
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.RefreshToken{
+				ID:        1,
+				UserID:    15,
+				TokenHash: "hashed-token",
+				FamilyID:  "family",
+				ExpiresAt: time.Date(2023, 9, 5, 12, 35, 51, 900, time.UTC),
+				CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+		{
+			name:      "success revoked token",
+			tokenHash: "hashed-token",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM refresh_tokens WHERE token_hash = \$1`).
+					WithArgs("hashed-token").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"token_hash",
+						"family_id",
+						"expires_at",
+						"revoked_at",
+						"created_at",
+					}).AddRow(
+						1,
+						15,
+						"hashed-token",
+						"family",
+						time.Date(2023, 9, 5, 12, 35, 51, 900, time.UTC),
+						revokedAt,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.RefreshToken{
+				ID:        1,
+				UserID:    15,
+				TokenHash: "hashed-token",
+				FamilyID:  "family",
+				ExpiresAt: time.Date(2023, 9, 5, 12, 35, 51, 900, time.UTC),
+				RevokedAt: &revokedAt,
+				CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetRefreshTokenByHash(ctx, tt.tokenHash)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRefreshTokenRepository_InsertRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	r := &RefreshTokenRepository{}
+	expiresAt := time.Date(2023, 9, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name    string
+		token   *entity.RefreshToken
+		prepare func(m sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query row context",
+			token: &entity.RefreshToken{
+				UserID:    15,
+				TokenHash: "hashed-token",
+				FamilyID:  "family",
+				ExpiresAt: expiresAt,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO refresh_tokens.*`).
+					WithArgs(15, "hashed-token", "family", expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			token: &entity.RefreshToken{
+				UserID:    15,
+				TokenHash: "hashed-token",
+				FamilyID:  "family",
+				ExpiresAt: expiresAt,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO refresh_tokens.*`).
+					WithArgs(15, "hashed-token", "family", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			token: &entity.RefreshToken{
+				UserID:    15,
+				TokenHash: "hashed-token",
+				FamilyID:  "family",
+				ExpiresAt: expiresAt,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO refresh_tokens.*`).
+					WithArgs(15, "hashed-token", "family", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    1,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.InsertRefreshToken(ctx, tt.token)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRefreshTokenRepository_RevokeRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	r := &RefreshTokenRepository{}
+	tests := []struct {
+		name    string
+		tokenID int
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error exec context",
+			tokenID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error rows affected",
+			tokenID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "error commit",
+			tokenID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "already revoked",
+			tokenID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:    "success",
+			tokenID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.RevokeRefreshToken(ctx, tt.tokenID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRefreshTokenRepository_RevokeRefreshTokenFamily(t *testing.T) {
+	ctx := context.Background()
+	r := &RefreshTokenRepository{}
+	tests := []struct {
+		name     string
+		familyID string
+		prepare  func(m sqlmock.Sqlmock)
+		wantErr  bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error exec context",
+			familyID: "family",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs("family").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "error commit",
+			familyID: "family",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs("family").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:     "success",
+			familyID: "family",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs("family").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.RevokeRefreshTokenFamily(ctx, tt.familyID)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestRefreshTokenRepository_RevokeUserRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	r := &RefreshTokenRepository{}
+	tests := []struct {
+		name    string
+		userID  int
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error exec context",
+			userID: 15,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(15).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error commit",
+			userID: 15,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(15).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "success",
+			userID: 15,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE refresh_tokens.*`).
+					WithArgs(15).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.RevokeUserRefreshTokens(ctx, tt.userID)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestRefreshTokenRepository_DeleteExpiredRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	r := &RefreshTokenRepository{}
+	expiredBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM refresh_tokens WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM refresh_tokens WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM refresh_tokens WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredRefreshTokens(ctx, expiredBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
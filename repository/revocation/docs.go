@@ -0,0 +1,3 @@
+// Package revocation directly relates to revoked_tokens table in database.
+// It also provides an in-memory implementation for single instance deployment.
+package revocation
@@ -0,0 +1,94 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+// MemoryRevocationRepository keeps revocations in process memory.
+// Revocations are lost on restart and not shared between instances.
+type MemoryRevocationRepository struct {
+	mu         sync.RWMutex
+	tokens     map[string]time.Time
+	timeNow    func() time.Time
+	lastPruned time.Time
+}
+
+// NewMemory returns a new instance of MemoryRevocationRepository.
+func NewMemory() *MemoryRevocationRepository {
+	return &MemoryRevocationRepository{
+		tokens:  map[string]time.Time{},
+		timeNow: time.Now,
+	}
+}
+
+// pruneInterval limits how often expired tokens are removed from memory.
+const pruneInterval = time.Minute
+
+// RevokeToken stores a single jwt id so it is rejected until it expires.
+func (r *MemoryRevocationRepository) RevokeToken(ctx context.Context, token *entity.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.TokenID] = token.ExpiresAt
+	r.prune()
+
+	return nil
+}
+
+// RedeemToken revokes a single-use jwt id, returning false if it had been revoked already.
+func (r *MemoryRevocationRepository) RedeemToken(ctx context.Context, token *entity.RevokedToken) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, revoked := r.tokens[token.TokenID]; revoked {
+		return false, nil
+	}
+
+	r.tokens[token.TokenID] = token.ExpiresAt
+	r.prune()
+
+	return true, nil
+}
+
+// IsTokenRevoked returns true if the jwt id has been revoked.
+func (r *MemoryRevocationRepository) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, revoked := r.tokens[tokenID]
+	return revoked, nil
+}
+
+// prune removes tokens that would be rejected anyway because they are expired.
+// Caller must hold the write lock.
+func (r *MemoryRevocationRepository) prune() {
+	now := r.timeNow()
+	if now.Sub(r.lastPruned) < pruneInterval {
+		return
+	}
+	r.lastPruned = now
+
+	for tokenID, expiresAt := range r.tokens {
+		if !now.Before(expiresAt) {
+			delete(r.tokens, tokenID)
+		}
+	}
+}
+
+// DeleteExpiredTokens removes revoked jwt ids that expired before the given time, the jwt is rejected for being expired by then.
+func (r *MemoryRevocationRepository) DeleteExpiredTokens(ctx context.Context, expiredBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for tokenID, expiresAt := range r.tokens {
+		if expiresAt.Before(expiredBefore) {
+			delete(r.tokens, tokenID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMemory(t *testing.T) {
+	assert.NotEmpty(t, NewMemory())
+}
+
+func TestMemoryRevocationRepository_Token(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	r := NewMemory()
+	r.timeNow = func() time.Time {
+		return now
+	}
+
+	// unknown token
+	got, err := r.IsTokenRevoked(ctx, "token-id")
+	assert.NoError(t, err)
+	assert.False(t, got)
+
+	// revoked token
+	err = r.RevokeToken(ctx, &entity.RevokedToken{
+		TokenID:   "token-id",
+		UserID:    15,
+		ExpiresAt: now.Add(time.Minute),
+	})
+	assert.NoError(t, err)
+	got, err = r.IsTokenRevoked(ctx, "token-id")
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	// expired token is pruned on the next revocation
+	now = now.Add(time.Hour)
+	err = r.RevokeToken(ctx, &entity.RevokedToken{
+		TokenID:   "other-token-id",
+		UserID:    15,
+		ExpiresAt: now.Add(time.Minute),
+	})
+	assert.NoError(t, err)
+	got, err = r.IsTokenRevoked(ctx, "token-id")
+	assert.NoError(t, err)
+	assert.False(t, got)
+	got, err = r.IsTokenRevoked(ctx, "other-token-id")
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	// delete expired
+	err = r.DeleteExpiredTokens(ctx, now.Add(time.Minute*2))
+	assert.NoError(t, err)
+	got, err = r.IsTokenRevoked(ctx, "other-token-id")
+	assert.NoError(t, err)
+	assert.False(t, got)
+}
+
+func TestMemoryRevocationRepository_RedeemToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	r := NewMemory()
+	r.timeNow = func() time.Time {
+		return now
+	}
+	token := &entity.RevokedToken{
+		TokenID:   "token-id",
+		UserID:    15,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	// first redemption
+	got, err := r.RedeemToken(ctx, token)
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	// replayed token
+	got, err = r.RedeemToken(ctx, token)
+	assert.NoError(t, err)
+	assert.False(t, got)
+
+	revoked, err := r.IsTokenRevoked(ctx, "token-id")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
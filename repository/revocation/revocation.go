@@ -0,0 +1,164 @@
+package revocation
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+type RevocationRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of RevocationRepository.
+func New(opts NewRepositoryOptions) *RevocationRepository {
+	return &RevocationRepository{
+		db: opts.DB,
+	}
+}
+
+// RevokeToken stores a single jwt id so it is rejected until it expires.
+func (r *RevocationRepository) RevokeToken(ctx context.Context, token *entity.RevokedToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO revoked_tokens (
+			token_id,
+			user_id,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3
+		) ON CONFLICT (token_id) DO NOTHING;
+	`
+	_, err = tx.ExecContext(
+		ctx,
+		query,
+		token.TokenID,
+		token.UserID,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RedeemToken revokes a single-use jwt id, returning false if it had been revoked already.
+// Only one of concurrent redemptions of the same token succeeds.
+func (r *RevocationRepository) RedeemToken(ctx context.Context, token *entity.RevokedToken) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO revoked_tokens (
+			token_id,
+			user_id,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3
+		) ON CONFLICT (token_id) DO NOTHING;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(
+		ctx,
+		query,
+		token.TokenID,
+		token.UserID,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// IsTokenRevoked returns true if the jwt id has been revoked.
+func (r *RevocationRepository) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var revoked bool
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM revoked_tokens
+			WHERE token_id = $1
+		);
+	`
+	err := r.db.QueryRowContext(ctx, query, tokenID).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpiredTokens removes revoked jwt ids that expired before the given time, the jwt is rejected for being expired by then.
+func (r *RevocationRepository) DeleteExpiredTokens(ctx context.Context, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM revoked_tokens
+		WHERE expires_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestRevocationRepository_RevokeToken(t *testing.T) {
+	ctx := context.Background()
+	r := &RevocationRepository{}
+	expiresAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	tests := []struct {
+		name    string
+		token   *entity.RevokedToken
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			token: &entity.RevokedToken{
+				TokenID:   "token-id",
+				UserID:    15,
+				ExpiresAt: expiresAt,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.RevokeToken(ctx, tt.token)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestRevocationRepository_RedeemToken(t *testing.T) {
+	ctx := context.Background()
+	r := &RevocationRepository{}
+	expiresAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	token := &entity.RevokedToken{
+		TokenID:   "token-id",
+		UserID:    15,
+		ExpiresAt: expiresAt,
+	}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			want:    false,
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*ON CONFLICT`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*ON CONFLICT`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*ON CONFLICT`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: true,
+		},
+		{
+			name: "already redeemed",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*ON CONFLICT`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO revoked_tokens.*ON CONFLICT`).
+					WithArgs("token-id", 15, expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.RedeemToken(ctx, token)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRevocationRepository_IsTokenRevoked(t *testing.T) {
+	ctx := context.Background()
+	r := &RevocationRepository{}
+	tests := []struct {
+		name    string
+		tokenID string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "error",
+			tokenID: "token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT EXISTS.*FROM revoked_tokens WHERE token_id = \$1`).
+					WithArgs("token-id").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "not revoked",
+			tokenID: "token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT EXISTS.*FROM revoked_tokens WHERE token_id = \$1`).
+					WithArgs("token-id").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:    "revoked",
+			tokenID: "token-id",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT EXISTS.*FROM revoked_tokens WHERE token_id = \$1`).
+					WithArgs("token-id").
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.IsTokenRevoked(ctx, tt.tokenID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRevocationRepository_DeleteExpiredTokens(t *testing.T) {
+	ctx := context.Background()
+	r := &RevocationRepository{}
+	expiredBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM revoked_tokens WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM revoked_tokens WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM revoked_tokens WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredTokens(ctx, expiredBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package throttle directly relates to login_failures table in database.
+// It also provides an in-memory implementation for single instance deployment.
+package throttle
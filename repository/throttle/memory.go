@@ -0,0 +1,119 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+// MemoryThrottleRepository keeps failed login attempts in process memory.
+// Counters are lost on restart and not shared between instances.
+type MemoryThrottleRepository struct {
+	mu         sync.Mutex
+	failures   map[string]entity.LoginFailures
+	timeNow    func() time.Time
+	lastPruned time.Time
+}
+
+// NewMemory returns a new instance of MemoryThrottleRepository.
+func NewMemory() *MemoryThrottleRepository {
+	return &MemoryThrottleRepository{
+		failures: map[string]entity.LoginFailures{},
+		timeNow:  time.Now,
+	}
+}
+
+// pruneInterval limits how often forgotten counters are removed from memory.
+const pruneInterval = time.Minute
+
+// GetLoginFailures returns failed login attempts counted under the key, zero failures if there is none.
+func (r *MemoryThrottleRepository) GetLoginFailures(ctx context.Context, key string) (entity.LoginFailures, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures, ok := r.failures[key]
+	if !ok {
+		failures.Key = key
+	}
+
+	return failures, nil
+}
+
+// RecordLoginFailure counts a failed login attempt under the key, returning the number of failures so far.
+// Counting starts over when the previous failure happened before resetBefore.
+func (r *MemoryThrottleRepository) RecordLoginFailure(ctx context.Context, key string, failedAt, resetBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures, ok := r.failures[key]
+	if !ok || failures.LastFailedAt.Before(resetBefore) {
+		failures = entity.LoginFailures{
+			Key: key,
+		}
+	}
+	failures.Failures++
+	failures.LastFailedAt = failedAt
+	r.failures[key] = failures
+
+	r.prune(resetBefore)
+
+	return failures.Failures, nil
+}
+
+// ForgiveLoginFailure takes back one failed login attempt counted under the key, for an attempt
+// that was counted before it turned out to succeed. The time of the last failure is left as is.
+func (r *MemoryThrottleRepository) ForgiveLoginFailure(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failures, ok := r.failures[key]
+	if !ok || failures.Failures == 0 {
+		return nil
+	}
+	failures.Failures--
+	r.failures[key] = failures
+
+	return nil
+}
+
+// ResetLoginFailures forgets failed login attempts counted under the key.
+func (r *MemoryThrottleRepository) ResetLoginFailures(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.failures, key)
+
+	return nil
+}
+
+// prune removes counters whose last failure happened before resetBefore, they would start over anyway.
+// Caller must hold the lock.
+func (r *MemoryThrottleRepository) prune(resetBefore time.Time) {
+	now := r.timeNow()
+	if now.Sub(r.lastPruned) < pruneInterval {
+		return
+	}
+	r.lastPruned = now
+
+	for key, failures := range r.failures {
+		if failures.LastFailedAt.Before(resetBefore) {
+			delete(r.failures, key)
+		}
+	}
+}
+
+// DeleteExpiredLoginFailures removes counters whose last failure happened before resetBefore, counting would start over anyway.
+func (r *MemoryThrottleRepository) DeleteExpiredLoginFailures(ctx context.Context, resetBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, failures := range r.failures {
+		if failures.LastFailedAt.Before(resetBefore) {
+			delete(r.failures, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMemory(t *testing.T) {
+	assert.NotEmpty(t, NewMemory())
+}
+
+func TestMemoryThrottleRepository_LoginFailures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	r := NewMemory()
+	r.timeNow = func() time.Time {
+		return now
+	}
+
+	// never failed
+	got, err := r.GetLoginFailures(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.LoginFailures{
+		Key: "phone:628123456789",
+	}, got)
+
+	// failures are counted
+	failures, err := r.RecordLoginFailure(ctx, "phone:628123456789", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+	failures, err = r.RecordLoginFailure(ctx, "phone:628123456789", now.Add(time.Second), now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failures)
+	got, err = r.GetLoginFailures(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.LoginFailures{
+		Key:          "phone:628123456789",
+		Failures:     2,
+		LastFailedAt: now.Add(time.Second),
+	}, got)
+
+	// other key is unaffected
+	got, err = r.GetLoginFailures(ctx, "ip:192.0.2.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, got.Failures)
+
+	// counting starts over after a quiet period, and the forgotten key is pruned
+	failures, err = r.RecordLoginFailure(ctx, "ip:192.0.2.1", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+	now = now.Add(time.Hour * 2)
+	failures, err = r.RecordLoginFailure(ctx, "phone:628123456789", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+	assert.NotContains(t, r.failures, "ip:192.0.2.1")
+
+	// forgive
+	err = r.ForgiveLoginFailure(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	got, err = r.GetLoginFailures(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.LoginFailures{
+		Key:          "phone:628123456789",
+		LastFailedAt: now,
+	}, got)
+	err = r.ForgiveLoginFailure(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	got, err = r.GetLoginFailures(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, got.Failures)
+	err = r.ForgiveLoginFailure(ctx, "email:john@example.com")
+	assert.NoError(t, err)
+	assert.NotContains(t, r.failures, "email:john@example.com")
+
+	// reset
+	err = r.ResetLoginFailures(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	got, err = r.GetLoginFailures(ctx, "phone:628123456789")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, got.Failures)
+
+	// delete expired
+	_, err = r.RecordLoginFailure(ctx, "phone:628123456789", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	_, err = r.RecordLoginFailure(ctx, "ip:192.0.2.1", now.Add(time.Minute), now.Add(-time.Hour))
+	assert.NoError(t, err)
+	err = r.DeleteExpiredLoginFailures(ctx, now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.NotContains(t, r.failures, "phone:628123456789")
+	assert.Contains(t, r.failures, "ip:192.0.2.1")
+}
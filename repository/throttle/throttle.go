@@ -0,0 +1,186 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+type ThrottleRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of ThrottleRepository.
+func New(opts NewRepositoryOptions) *ThrottleRepository {
+	return &ThrottleRepository{
+		db: opts.DB,
+	}
+}
+
+// GetLoginFailures returns failed login attempts counted under the key, zero failures if there is none.
+func (r *ThrottleRepository) GetLoginFailures(ctx context.Context, key string) (entity.LoginFailures, error) {
+	var failures = entity.LoginFailures{
+		Key: key,
+	}
+
+	query := `
+		SELECT
+			failures,
+			last_failed_at
+		FROM login_failures
+		WHERE key = $1;
+	`
+	err := r.db.QueryRowContext(ctx, query, key).Scan(
+		&failures.Failures,
+		&failures.LastFailedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return failures, nil
+	}
+	if err != nil {
+		return failures, err
+	}
+
+	return failures, nil
+}
+
+// RecordLoginFailure counts a failed login attempt under the key, returning the number of failures so far.
+// Counting starts over when the previous failure happened before resetBefore.
+func (r *ThrottleRepository) RecordLoginFailure(ctx context.Context, key string, failedAt, resetBefore time.Time) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO login_failures (
+			key,
+			failures,
+			last_failed_at
+		) VALUES (
+			$1,
+			1,
+			$2
+		) ON CONFLICT (key) DO UPDATE
+		SET
+			failures = CASE
+				WHEN login_failures.last_failed_at < $3 THEN 1
+				ELSE login_failures.failures + 1
+			END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING failures;
+	`
+	var failures int
+	err = tx.QueryRowContext(ctx, query, key, failedAt, resetBefore).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+// ForgiveLoginFailure takes back one failed login attempt counted under the key, for an attempt
+// that was counted before it turned out to succeed. The time of the last failure is left as is.
+func (r *ThrottleRepository) ForgiveLoginFailure(ctx context.Context, key string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE login_failures
+		SET failures = failures - 1
+		WHERE key = $1 AND failures > 0;
+	`
+	_, err = tx.ExecContext(ctx, query, key)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ResetLoginFailures forgets failed login attempts counted under the key.
+func (r *ThrottleRepository) ResetLoginFailures(ctx context.Context, key string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM login_failures
+		WHERE key = $1;
+	`
+	_, err = tx.ExecContext(ctx, query, key)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredLoginFailures removes counters whose last failure happened before resetBefore, counting would start over anyway.
+func (r *ThrottleRepository) DeleteExpiredLoginFailures(ctx context.Context, resetBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM login_failures
+		WHERE last_failed_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, resetBefore)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestThrottleRepository_GetLoginFailures(t *testing.T) {
+	ctx := context.Background()
+	r := &ThrottleRepository{}
+	tests := []struct {
+		name    string
+		key     string
+		prepare func(m sqlmock.Sqlmock)
+		want    entity.LoginFailures
+		wantErr bool
+	}{
+		{
+			name: "error",
+			key:  "phone:628123456789",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM login_failures WHERE key = \$1`).
+					WithArgs("phone:628123456789").
+					WillReturnError(assert.AnError)
+			},
+			want: entity.LoginFailures{
+				Key: "phone:628123456789",
+			},
+			wantErr: true,
+		},
+		{
+			name: "never failed",
+			key:  "phone:628123456789",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM login_failures WHERE key = \$1`).
+					WithArgs("phone:628123456789").
+					WillReturnError(sql.ErrNoRows)
+			},
+			want: entity.LoginFailures{
+				Key: "phone:628123456789",
+			},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			key:  "phone:628123456789",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM login_failures WHERE key = \$1`).
+					WithArgs("phone:628123456789").
+					WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failed_at"}).
+						AddRow(4, time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)))
+			},
+			want: entity.LoginFailures{
+				Key:          "phone:628123456789",
+				Failures:     4,
+				LastFailedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetLoginFailures(ctx, tt.key)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestThrottleRepository_RecordLoginFailure(t *testing.T) {
+	ctx := context.Background()
+	r := &ThrottleRepository{}
+	failedAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	resetBefore := failedAt.Add(-time.Hour)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query row context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO login_failures.*ON CONFLICT \(key\) DO UPDATE.*RETURNING failures`).
+					WithArgs("ip:192.0.2.1", failedAt, resetBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO login_failures.*ON CONFLICT \(key\) DO UPDATE.*RETURNING failures`).
+					WithArgs("ip:192.0.2.1", failedAt, resetBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(5))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO login_failures.*ON CONFLICT \(key\) DO UPDATE.*RETURNING failures`).
+					WithArgs("ip:192.0.2.1", failedAt, resetBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(5))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    5,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.RecordLoginFailure(ctx, "ip:192.0.2.1", failedAt, resetBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestThrottleRepository_ForgiveLoginFailure(t *testing.T) {
+	ctx := context.Background()
+	r := &ThrottleRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_failures SET failures = failures - 1 WHERE key = \$1 AND failures > 0`).
+					WithArgs("phone:628123456789").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_failures SET failures = failures - 1 WHERE key = \$1 AND failures > 0`).
+					WithArgs("phone:628123456789").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_failures SET failures = failures - 1 WHERE key = \$1 AND failures > 0`).
+					WithArgs("phone:628123456789").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.ForgiveLoginFailure(ctx, "phone:628123456789")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestThrottleRepository_ResetLoginFailures(t *testing.T) {
+	ctx := context.Background()
+	r := &ThrottleRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_failures WHERE key = \$1`).
+					WithArgs("phone:628123456789").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_failures WHERE key = \$1`).
+					WithArgs("phone:628123456789").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_failures WHERE key = \$1`).
+					WithArgs("phone:628123456789").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.ResetLoginFailures(ctx, "phone:628123456789")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestThrottleRepository_DeleteExpiredLoginFailures(t *testing.T) {
+	ctx := context.Background()
+	r := &ThrottleRepository{}
+	resetBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_failures WHERE last_failed_at < \$1`).
+					WithArgs(resetBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_failures WHERE last_failed_at < \$1`).
+					WithArgs(resetBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_failures WHERE last_failed_at < \$1`).
+					WithArgs(resetBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredLoginFailures(ctx, resetBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package twofactor directly relates to user_totp, totp_recovery_codes and login_challenges tables in database.
+package twofactor
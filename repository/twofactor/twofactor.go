@@ -0,0 +1,504 @@
+package twofactor
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of TwoFactorRepository.
+func New(opts NewRepositoryOptions) *TwoFactorRepository {
+	return &TwoFactorRepository{
+		db: opts.DB,
+	}
+}
+
+// GetTOTP returns the totp secret of a user, confirmed or not.
+func (r *TwoFactorRepository) GetTOTP(ctx context.Context, userID int) (*entity.TOTP, error) {
+	var totp = &entity.TOTP{}
+
+	query := `
+		SELECT
+			user_id,
+			secret,
+			confirmed_at,
+			last_used_step,
+			created_at
+		FROM user_totp
+		WHERE user_id = $1;
+	`
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.ConfirmedAt,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return totp, nil
+}
+
+// UpsertTOTP stores a new unconfirmed secret of a user, replacing the one of an unfinished enrollment.
+// It returns false if the user has already confirmed a secret, which is never replaced here.
+func (r *TwoFactorRepository) UpsertTOTP(ctx context.Context, totp *entity.TOTP) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO user_totp (
+			user_id,
+			secret
+		) VALUES (
+			$1,
+			$2
+		) ON CONFLICT (user_id) DO UPDATE
+		SET
+			secret = EXCLUDED.secret,
+			last_used_step = 0,
+			created_at = now()
+		WHERE user_totp.confirmed_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, totp.UserID, totp.Secret)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// ConfirmTOTP enables two-factor authentication of a user along with a new set of recovery codes.
+// The step of the confirming code is recorded as used. It returns false if the secret has already been confirmed.
+func (r *TwoFactorRepository) ConfirmTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE user_totp
+		SET
+			confirmed_at = now(),
+			last_used_step = $2
+		WHERE user_id = $1
+			AND confirmed_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if affected == 0 {
+		return false, tx.Commit()
+	}
+
+	// recovery codes of an earlier enrollment never survive a new one
+	query = `
+		DELETE FROM totp_recovery_codes
+		WHERE user_id = $1;
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return false, err
+	}
+
+	query = `
+		INSERT INTO totp_recovery_codes (
+			user_id,
+			code_hash
+		) VALUES (
+			$1,
+			$2
+		);
+	`
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx, query, userID, codeHash)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UseTOTPStep records the time step of a code as used.
+// It returns false if the same or a later step has been used, meaning the code is being replayed.
+func (r *TwoFactorRepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE user_totp
+		SET
+			last_used_step = $2
+		WHERE user_id = $1
+			AND last_used_step < $2;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// DeleteTOTP disables two-factor authentication of a user, removing the secret and every recovery code.
+func (r *TwoFactorRepository) DeleteTOTP(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM totp_recovery_codes
+		WHERE user_id = $1;
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		DELETE FROM user_totp
+		WHERE user_id = $1;
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetUnusedRecoveryCodes returns recovery codes of a user that can still be used.
+func (r *TwoFactorRepository) GetUnusedRecoveryCodes(ctx context.Context, userID int) ([]*entity.RecoveryCode, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			code_hash,
+			used_at,
+			created_at
+		FROM totp_recovery_codes
+		WHERE user_id = $1
+			AND used_at IS NULL
+		ORDER BY id;
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []*entity.RecoveryCode{}
+	for rows.Next() {
+		var code = &entity.RecoveryCode{}
+		err = rows.Scan(
+			&code.ID,
+			&code.UserID,
+			&code.CodeHash,
+			&code.UsedAt,
+			&code.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode marks a recovery code as used.
+// It returns false if the code has already been used by another request.
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, codeID int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE totp_recovery_codes
+		SET
+			used_at = now()
+		WHERE id = $1
+			AND used_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, codeID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// InsertLoginChallenge inserts a new login challenge to database, returning its id on success.
+func (r *TwoFactorRepository) InsertLoginChallenge(ctx context.Context, challenge *entity.LoginChallenge) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO login_challenges (
+			user_id,
+			token_hash,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3
+		) RETURNING id;
+	`
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		challenge.UserID,
+		challenge.TokenHash,
+		challenge.ExpiresAt,
+	).Scan(&challenge.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return challenge.ID, nil
+}
+
+// GetLoginChallengeByHash returns login challenge by its sha256 hash.
+func (r *TwoFactorRepository) GetLoginChallengeByHash(ctx context.Context, tokenHash string) (*entity.LoginChallenge, error) {
+	var challenge = &entity.LoginChallenge{}
+
+	query := `
+		SELECT
+			id,
+			user_id,
+			token_hash,
+			attempts,
+			expires_at,
+			consumed_at,
+			created_at
+		FROM login_challenges
+		WHERE token_hash = $1;
+	`
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.TokenHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.ConsumedAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// IncrementLoginChallengeAttempts records an attempt to complete the challenge, returning the number of attempts so far.
+// The attempt is recorded before the code is compared so concurrent guesses are counted as well.
+func (r *TwoFactorRepository) IncrementLoginChallengeAttempts(ctx context.Context, challengeID int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE login_challenges
+		SET
+			attempts = attempts + 1
+		WHERE id = $1
+		RETURNING attempts;
+	`
+	var attempts int
+	err = tx.QueryRowContext(ctx, query, challengeID).Scan(&attempts)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+// ConsumeLoginChallenge marks a login challenge as completed.
+// It returns false if the challenge has already been consumed by another request.
+func (r *TwoFactorRepository) ConsumeLoginChallenge(ctx context.Context, challengeID int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE login_challenges
+		SET
+			consumed_at = now()
+		WHERE id = $1
+			AND consumed_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, challengeID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// DeleteExpiredLoginChallenges removes challenges that expired before the given time, consumed or not.
+func (r *TwoFactorRepository) DeleteExpiredLoginChallenges(ctx context.Context, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM login_challenges
+		WHERE expires_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,1126 @@
+package twofactor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestTwoFactorRepository_GetTOTP(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	confirmedAt := time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC)
+	tests := []struct {
+		name    string
+		userID  int
+		prepare func(m sqlmock.Sqlmock)
+		want    *entity.TOTP
+		wantErr bool
+	}{
+		{
+			name:   "error",
+			userID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM user_totp WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "success",
+			userID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM user_totp WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"user_id",
+						"secret",
+						"confirmed_at",
+						"last_used_step",
+						"created_at",
+					}).AddRow(
+						1,
+						"JBSWY3DPEHPK3PXP",
+						confirmedAt,
+						56303497,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.TOTP{
+				UserID:       1,
+				Secret:       "JBSWY3DPEHPK3PXP",
+				ConfirmedAt:  &confirmedAt,
+				LastUsedStep: 56303497,
+				CreatedAt:    time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetTOTP(ctx, tt.userID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTwoFactorRepository_UpsertTOTP(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	totp := &entity.TOTP{
+		UserID: 1,
+		Secret: "JBSWY3DPEHPK3PXP",
+	}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO user_totp.*ON CONFLICT \(user_id\) DO UPDATE.*WHERE user_totp.confirmed_at IS NULL`).
+					WithArgs(1, "JBSWY3DPEHPK3PXP").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO user_totp.*ON CONFLICT \(user_id\) DO UPDATE.*WHERE user_totp.confirmed_at IS NULL`).
+					WithArgs(1, "JBSWY3DPEHPK3PXP").
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO user_totp.*ON CONFLICT \(user_id\) DO UPDATE.*WHERE user_totp.confirmed_at IS NULL`).
+					WithArgs(1, "JBSWY3DPEHPK3PXP").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "already confirmed",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO user_totp.*ON CONFLICT \(user_id\) DO UPDATE.*WHERE user_totp.confirmed_at IS NULL`).
+					WithArgs(1, "JBSWY3DPEHPK3PXP").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO user_totp.*ON CONFLICT \(user_id\) DO UPDATE.*WHERE user_totp.confirmed_at IS NULL`).
+					WithArgs(1, "JBSWY3DPEHPK3PXP").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.UpsertTOTP(ctx, totp)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_ConfirmTOTP(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	hashes := []string{"hashed-code-1", "hashed-code-2"}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error confirm",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "already confirmed",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "error delete previous recovery codes",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error insert recovery code",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 10))
+				m.ExpectExec(`INSERT INTO totp_recovery_codes.*`).
+					WithArgs(1, "hashed-code-1").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectExec(`INSERT INTO totp_recovery_codes.*`).
+					WithArgs(1, "hashed-code-1").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectExec(`INSERT INTO totp_recovery_codes.*`).
+					WithArgs(1, "hashed-code-2").
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET confirmed_at = now\(\), last_used_step = \$2 WHERE user_id = \$1 AND confirmed_at IS NULL`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectExec(`INSERT INTO totp_recovery_codes.*`).
+					WithArgs(1, "hashed-code-1").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectExec(`INSERT INTO totp_recovery_codes.*`).
+					WithArgs(1, "hashed-code-2").
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.ConfirmTOTP(ctx, 1, 56303497, hashes)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_UseTOTPStep(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+					WithArgs(1, int64(56303497)).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "step already used",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE user_totp SET last_used_step = \$2 WHERE user_id = \$1 AND last_used_step < \$2`).
+					WithArgs(1, int64(56303497)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.UseTOTPStep(ctx, 1, 56303497)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_DeleteTOTP(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error delete recovery codes",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error delete secret",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 10))
+				m.ExpectExec(`DELETE FROM user_totp WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 10))
+				m.ExpectExec(`DELETE FROM user_totp WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM totp_recovery_codes WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 10))
+				m.ExpectExec(`DELETE FROM user_totp WHERE user_id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteTOTP(ctx, 1)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_GetUnusedRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	columns := []string{
+		"id",
+		"user_id",
+		"code_hash",
+		"used_at",
+		"created_at",
+	}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    []*entity.RecoveryCode
+		wantErr bool
+	}{
+		{
+			name: "error query",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM totp_recovery_codes WHERE user_id = \$1 AND used_at IS NULL`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error scan",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM totp_recovery_codes WHERE user_id = \$1 AND used_at IS NULL`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						"not-an-id",
+						1,
+						"hashed-code-1",
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM totp_recovery_codes WHERE user_id = \$1 AND used_at IS NULL`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						1,
+						1,
+						"hashed-code-1",
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					).RowError(0, assert.AnError))
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM totp_recovery_codes WHERE user_id = \$1 AND used_at IS NULL`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(
+						1,
+						1,
+						"hashed-code-1",
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					).AddRow(
+						2,
+						1,
+						"hashed-code-2",
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: []*entity.RecoveryCode{
+				{
+					ID:        1,
+					UserID:    1,
+					CodeHash:  "hashed-code-1",
+					CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				},
+				{
+					ID:        2,
+					UserID:    1,
+					CodeHash:  "hashed-code-2",
+					CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetUnusedRecoveryCodes(ctx, 1)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_UseRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE totp_recovery_codes SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+					WithArgs(2).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE totp_recovery_codes SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE totp_recovery_codes SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "already used",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE totp_recovery_codes SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE totp_recovery_codes SET used_at = now\(\) WHERE id = \$1 AND used_at IS NULL`).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.UseRecoveryCode(ctx, 2)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_InsertLoginChallenge(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	expiresAt := time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC)
+	challenge := func() *entity.LoginChallenge {
+		return &entity.LoginChallenge{
+			UserID:    1,
+			TokenHash: "hashed-token",
+			ExpiresAt: expiresAt,
+		}
+	}
+	tests := []struct {
+		name      string
+		challenge *entity.LoginChallenge
+		prepare   func(m sqlmock.Sqlmock)
+		want      int
+		wantErr   bool
+	}{
+		{
+			name:      "error begin tx",
+			challenge: challenge(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "error query row context",
+			challenge: challenge(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO login_challenges.*`).
+					WithArgs(1, "hashed-token", expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "error commit",
+			challenge: challenge(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO login_challenges.*`).
+					WithArgs(1, "hashed-token", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "success",
+			challenge: challenge(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO login_challenges.*`).
+					WithArgs(1, "hashed-token", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    3,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.InsertLoginChallenge(ctx, tt.challenge)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_GetLoginChallengeByHash(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	tests := []struct {
+		name      string
+		tokenHash string
+		prepare   func(m sqlmock.Sqlmock)
+		want      *entity.LoginChallenge
+		wantErr   bool
+	}{
+		{
+			name:      "error",
+			tokenHash: "hashed-token",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM login_challenges WHERE token_hash = \$1`).
+					WithArgs("hashed-token").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:      "success",
+			tokenHash: "hashed-token",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM login_challenges WHERE token_hash = \$1`).
+					WithArgs("hashed-token").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"user_id",
+						"token_hash",
+						"attempts",
+						"expires_at",
+						"consumed_at",
+						"created_at",
+					}).AddRow(
+						3,
+						1,
+						"hashed-token",
+						1,
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.LoginChallenge{
+				ID:        3,
+				UserID:    1,
+				TokenHash: "hashed-token",
+				Attempts:  1,
+				ExpiresAt: time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+				CreatedAt: time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetLoginChallengeByHash(ctx, tt.tokenHash)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTwoFactorRepository_IncrementLoginChallengeAttempts(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query row context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`UPDATE login_challenges SET attempts = attempts \+ 1 WHERE id = \$1 RETURNING attempts`).
+					WithArgs(3).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`UPDATE login_challenges SET attempts = attempts \+ 1 WHERE id = \$1 RETURNING attempts`).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"attempts"}).AddRow(2))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`UPDATE login_challenges SET attempts = attempts \+ 1 WHERE id = \$1 RETURNING attempts`).
+					WithArgs(3).
+					WillReturnRows(sqlmock.NewRows([]string{"attempts"}).AddRow(2))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    2,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.IncrementLoginChallengeAttempts(ctx, 3)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_ConsumeLoginChallenge(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_challenges SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(3).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_challenges SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_challenges SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "already consumed",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_challenges SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE login_challenges SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.ConsumeLoginChallenge(ctx, 3)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTwoFactorRepository_DeleteExpiredLoginChallenges(t *testing.T) {
+	ctx := context.Background()
+	r := &TwoFactorRepository{}
+	expiredBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_challenges WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_challenges WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM login_challenges WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredLoginChallenges(ctx, expiredBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -3,10 +3,19 @@ package user
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/lib/pq"
 )
 
+// uniqueViolation is the code postgres reports a violated unique constraint with.
+const uniqueViolation = pq.ErrorCode("23505")
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -22,23 +31,40 @@ func New(opts NewRepositoryOptions) *UserRepository {
 	}
 }
 
-// GetUserByPhoneNumber returns a single user because phone number is stored unqiuely.
-func (r *UserRepository) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entity.User, error) {
-	var user = &entity.User{}
-
-	query := `
-		SELECT
+// userColumns are the columns of a user scanned by scanUser, along with the roles and permissions of the user.
+const userColumns = `
 			id,
 			fullname,
 			phone_number,
 			password,
 			login_count,
 			created_at,
-			COALESCE(updated_at, created_at) AS updated_at
-		FROM users
-		WHERE phone_number = $1;
-	`
-	err := r.db.QueryRowContext(ctx, query, phoneNumber).Scan(
+			COALESCE(updated_at, created_at) AS updated_at,
+			status,
+			token_version,
+			phone_verified_at,
+			COALESCE(pending_phone_number, '') AS pending_phone_number,
+			COALESCE(email, '') AS email,
+			email_verified_at,
+			COALESCE(pending_email, '') AS pending_email,
+			ARRAY(
+				SELECT role
+				FROM user_roles
+				WHERE user_id = users.id
+				ORDER BY role
+			) AS roles,
+			ARRAY(
+				SELECT DISTINCT rp.permission
+				FROM user_roles ur
+				JOIN role_permissions rp ON rp.role = ur.role
+				WHERE ur.user_id = users.id
+				ORDER BY rp.permission
+			) AS permissions`
+
+// scanUser scans a row selected with userColumns.
+func scanUser(row *sql.Row) (*entity.User, error) {
+	var user = &entity.User{}
+	err := row.Scan(
 		&user.ID,
 		&user.Fullname,
 		&user.PhoneNumber,
@@ -46,6 +72,15 @@ func (r *UserRepository) GetUserByPhoneNumber(ctx context.Context, phoneNumber s
 		&user.LoginCount,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Status,
+		&user.TokenVersion,
+		&user.PhoneVerifiedAt,
+		&user.PendingPhoneNumber,
+		&user.Email,
+		&user.EmailVerifiedAt,
+		&user.PendingEmail,
+		pq.Array(&user.Roles),
+		pq.Array(&user.Permissions),
 	)
 	if err != nil {
 		return nil, err
@@ -54,39 +89,37 @@ func (r *UserRepository) GetUserByPhoneNumber(ctx context.Context, phoneNumber s
 	return user, nil
 }
 
+// GetUserByPhoneNumber returns a single user because phone number is stored unqiuely.
+func (r *UserRepository) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*entity.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE phone_number = $1;
+	`
+	return scanUser(r.db.QueryRowContext(ctx, query, phoneNumber))
+}
+
+// GetUserByEmail returns a single user by the verified email, pending emails are never matched.
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1;
+	`
+	return scanUser(r.db.QueryRowContext(ctx, query, email))
+}
+
 // GetUserByID returns a single user by its id.
 func (r *UserRepository) GetUserByID(ctx context.Context, userID int) (*entity.User, error) {
-	var user = &entity.User{}
-
 	query := `
-		SELECT
-			id,
-			fullname,
-			phone_number,
-			password,
-			login_count,
-			created_at,
-			COALESCE(updated_at, created_at) AS updated_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = $1;
 	`
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(
-		&user.ID,
-		&user.Fullname,
-		&user.PhoneNumber,
-		&user.HashedPassword,
-		&user.LoginCount,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
+	return scanUser(r.db.QueryRowContext(ctx, query, userID))
 }
 
-// InsertUser inserts a new user to database, returning its id on success.
+// InsertUser inserts a new user with the default role to database, returning its id on success.
 func (r *UserRepository) InsertUser(ctx context.Context, user *entity.User) (int, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -102,11 +135,13 @@ func (r *UserRepository) InsertUser(ctx context.Context, user *entity.User) (int
 		INSERT INTO users (
 			fullname,
 			phone_number,
-			password
+			password,
+			status
 		) VALUES (
 			$1,
 			$2,
-			$3
+			$3,
+			$4
 		) RETURNING id;
 	`
 	err = tx.QueryRowContext(
@@ -115,11 +150,27 @@ func (r *UserRepository) InsertUser(ctx context.Context, user *entity.User) (int
 		user.Fullname,
 		user.PhoneNumber,
 		user.HashedPassword,
+		user.Status,
 	).Scan(&user.ID)
 	if err != nil {
 		return 0, err
 	}
 
+	// every registered user starts with the default role
+	query = `
+		INSERT INTO user_roles (
+			user_id,
+			role
+		) VALUES (
+			$1,
+			$2
+		);
+	`
+	_, err = tx.ExecContext(ctx, query, user.ID, entity.RoleUser)
+	if err != nil {
+		return 0, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return 0, err
@@ -128,7 +179,8 @@ func (r *UserRepository) InsertUser(ctx context.Context, user *entity.User) (int
 	return user.ID, nil
 }
 
-// UpdateUser only updates fullname and phone number of a user with given id.
+// UpdateUser only updates fullname, pending phone number, and pending email of a user with given id.
+// Phone number and email themselves are only replaced by VerifyPhoneNumber and VerifyEmail.
 func (r *UserRepository) UpdateUser(ctx context.Context, user *entity.User) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -144,15 +196,17 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *entity.User) erro
 		UPDATE users
 		SET
 			fullname = $1,
-			phone_number = $2,
+			pending_phone_number = NULLIF($2, ''),
+			pending_email = NULLIF($3, ''),
 			updated_at = now()
-		WHERE id = $3;
+		WHERE id = $4;
 	`
 	_, err = tx.ExecContext(
 		ctx,
 		query,
 		user.Fullname,
-		user.PhoneNumber,
+		user.PendingPhoneNumber,
+		user.PendingEmail,
 		user.ID,
 	)
 	if err != nil {
@@ -167,6 +221,153 @@ func (r *UserRepository) UpdateUser(ctx context.Context, user *entity.User) erro
 	return nil
 }
 
+// UpdatePassword replaces the password of a user and bumps their token version
+// so that every jwt issued before stops working, returning the new token version.
+// The replaced password is moved to password_history, where only the latest keepHistory are kept.
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID int, hashedPassword string, keepHistory int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO password_history (user_id, hashed_password)
+		SELECT id, password
+		FROM users
+		WHERE id = $1;
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		UPDATE users
+		SET
+			password = $1,
+			token_version = token_version + 1,
+			updated_at = now()
+		WHERE id = $2
+		RETURNING token_version;
+	`
+	var tokenVersion int
+	err = tx.QueryRowContext(ctx, query, hashedPassword, userID).Scan(&tokenVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		DELETE FROM password_history
+		WHERE user_id = $1
+		AND id NOT IN (
+			SELECT id
+			FROM password_history
+			WHERE user_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		);
+	`
+	_, err = tx.ExecContext(ctx, query, userID, keepHistory)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return tokenVersion, nil
+}
+
+// GetPasswordHistory returns up to limit previous passwords of a user, the most recently replaced first.
+func (r *UserRepository) GetPasswordHistory(ctx context.Context, userID, limit int) ([]*entity.PasswordHistory, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			hashed_password,
+			created_at
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2;
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*entity.PasswordHistory{}
+	for rows.Next() {
+		var entry = &entity.PasswordHistory{}
+		err = rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.HashedPassword,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// RehashPassword replaces the hash of an unchanged password with one of a newer algorithm or cost.
+// Token version is kept since the password stays the same. It returns false if the password
+// has been changed in between, the newer password is never overwritten.
+func (r *UserRepository) RehashPassword(ctx context.Context, userID int, oldHashedPassword, newHashedPassword string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE users
+		SET
+			password = $1
+		WHERE id = $2
+			AND password = $3;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, newHashedPassword, userID, oldHashedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
 // IncrementLoginCount adds the value by 1 each time user logged in successfully.
 func (r *UserRepository) IncrementLoginCount(ctx context.Context, userID int) error {
 	tx, err := r.db.BeginTx(ctx, nil)
@@ -198,3 +399,318 @@ func (r *UserRepository) IncrementLoginCount(ctx context.Context, userID int) er
 
 	return nil
 }
+
+// userSortColumns whitelists the columns users can be sorted by.
+var userSortColumns = map[string]string{
+	entity.UserSortID:        "id",
+	entity.UserSortCreatedAt: "created_at",
+	entity.UserSortFullname:  "fullname",
+}
+
+// ListUsers returns a page of users using keyset pagination,
+// id breaks ties so that users sharing the same sorted value are never skipped.
+func (r *UserRepository) ListUsers(ctx context.Context, filter entity.ListUsersFilter) ([]*entity.User, error) {
+	column, ok := userSortColumns[filter.SortField]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field %q", filter.SortField)
+	}
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	// arg adds a query argument and returns its placeholder
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if filter.PhonePrefix != "" {
+		conditions = append(conditions, "phone_number LIKE "+arg(escapeLike(filter.PhonePrefix)+"%"))
+	}
+	if filter.Name != "" {
+		conditions = append(conditions, "fullname ILIKE "+arg("%"+escapeLike(filter.Name)+"%"))
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+arg(filter.Status))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(filter.CreatedBefore))
+	}
+
+	operator, direction := ">", "ASC"
+	if filter.Descending {
+		operator, direction = "<", "DESC"
+	}
+
+	if filter.After != nil {
+		switch filter.SortField {
+		case entity.UserSortCreatedAt:
+			conditions = append(conditions, fmt.Sprintf("(created_at, id) %s (%s, %s)", operator, arg(filter.After.CreatedAt), arg(filter.After.ID)))
+		case entity.UserSortFullname:
+			conditions = append(conditions, fmt.Sprintf("(fullname, id) %s (%s, %s)", operator, arg(filter.After.Fullname), arg(filter.After.ID)))
+		default:
+			conditions = append(conditions, fmt.Sprintf("id %s %s", operator, arg(filter.After.ID)))
+		}
+	}
+
+	orderBy := "id " + direction
+	if column != "id" {
+		orderBy = column + " " + direction + ", " + orderBy
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query := `
+		SELECT
+			id,
+			fullname,
+			phone_number,
+			password,
+			login_count,
+			created_at,
+			COALESCE(updated_at, created_at) AS updated_at,
+			status,
+			ARRAY(
+				SELECT role
+				FROM user_roles
+				WHERE user_id = users.id
+				ORDER BY role
+			) AS roles
+		FROM users
+		` + where + `
+		ORDER BY ` + orderBy + `
+		LIMIT ` + arg(filter.Limit) + `;
+	`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*entity.User{}
+	for rows.Next() {
+		var user = &entity.User{}
+		err = rows.Scan(
+			&user.ID,
+			&user.Fullname,
+			&user.PhoneNumber,
+			&user.HashedPassword,
+			&user.LoginCount,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Status,
+			pq.Array(&user.Roles),
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// escapeLike makes user input match literally inside LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetUserSessionState returns only what decides whether a jwt of the user is still honored,
+// it is cheap enough to be checked on every request.
+func (r *UserRepository) GetUserSessionState(ctx context.Context, userID int) (entity.UserSessionState, error) {
+	query := `
+		SELECT
+			status,
+			token_version
+		FROM users
+		WHERE id = $1;
+	`
+	var state entity.UserSessionState
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&state.Status,
+		&state.TokenVersion,
+	)
+	if err != nil {
+		return entity.UserSessionState{}, err
+	}
+
+	return state, nil
+}
+
+// UpdateUserStatus moves a user from one status to another,
+// returning false when the user is no longer in the expected status.
+func (r *UserRepository) UpdateUserStatus(ctx context.Context, userID int, from, to string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE users
+		SET
+			status = $1,
+			updated_at = now()
+		WHERE id = $2
+			AND status = $3;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, to, userID, from)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// IncrementTokenVersion rejects every jwt issued to a user so far, the ones issued afterwards carry the new version.
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE users
+		SET
+			token_version = token_version + 1,
+			updated_at = now()
+		WHERE id = $1;
+	`
+	_, err = tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// VerifyPhoneNumber marks phone number of a user as verified, replacing the current one if it was pending.
+// A user still pending activation becomes active.
+// It returns false if the phone number is neither the current nor the pending one of the user anymore.
+func (r *UserRepository) VerifyPhoneNumber(ctx context.Context, userID int, phoneNumber string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE users
+		SET
+			phone_number = $1,
+			pending_phone_number = NULL,
+			phone_verified_at = now(),
+			status = CASE WHEN status = 'pending' THEN 'active' ELSE status END,
+			updated_at = now()
+		WHERE id = $2
+			AND (phone_number = $1 OR pending_phone_number = $1);
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, phoneNumber, userID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// VerifyEmail replaces the email of a user with their pending email once it is verified.
+// It returns false if the email is not the pending one of the user anymore,
+// and repository.ErrDuplicate if another user has verified the same email first.
+func (r *UserRepository) VerifyEmail(ctx context.Context, userID int, email string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE users
+		SET
+			email = $1,
+			pending_email = NULL,
+			email_verified_at = now(),
+			updated_at = now()
+		WHERE id = $2
+			AND pending_email = $1;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, email, userID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return false, repository.ErrDuplicate
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,6 +28,7 @@ func TestNew(t *testing.T) {
 func TestUserRepository_GetUserByPhoneNumber(t *testing.T) {
 	ctx := context.Background()
 	r := &UserRepository{}
+	verifiedAt := time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC)
 	tests := []struct {
 		name        string
 		phoneNumber string
@@ -57,6 +60,15 @@ func TestUserRepository_GetUserByPhoneNumber(t *testing.T) {
 						"login_count",
 						"created_at",
 						"updated_at",
+						"status",
+						"token_version",
+						"phone_verified_at",
+						"pending_phone_number",
+						"email",
+						"email_verified_at",
+						"pending_email",
+						"roles",
+						"permissions",
 					}).AddRow(
 						1,
 						"John Doe",
@@ -65,16 +77,32 @@ func TestUserRepository_GetUserByPhoneNumber(t *testing.T) {
 						0,
 						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
 						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+						"active",
+						2,
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						"",
+						"john@example.com",
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						"",
+						"{user}",
+						"{profile:read,profile:write}",
 					))
 			},
 			want: &entity.User{
-				ID:             1,
-				Fullname:       "John Doe",
-				PhoneNumber:    "628123456789",
-				HashedPassword: "hashed-password",
-				LoginCount:     0,
-				CreatedAt:      time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
-				UpdatedAt:      time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				ID:              1,
+				Fullname:        "John Doe",
+				PhoneNumber:     "628123456789",
+				HashedPassword:  "hashed-password",
+				LoginCount:      0,
+				CreatedAt:       time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				UpdatedAt:       time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				Status:          entity.UserStatusActive,
+				TokenVersion:    2,
+				PhoneVerifiedAt: &verifiedAt,
+				Email:           "john@example.com",
+				EmailVerifiedAt: &verifiedAt,
+				Roles:           []string{"user"},
+				Permissions:     []string{"profile:read", "profile:write"},
 			},
 			wantErr: false,
 		},
@@ -99,6 +127,108 @@ func TestUserRepository_GetUserByPhoneNumber(t *testing.T) {
 	}
 }
 
+func TestUserRepository_GetUserByEmail(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	verifiedAt := time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC)
+	tests := []struct {
+		name    string
+		email   string
+		prepare func(m sqlmock.Sqlmock)
+		want    *entity.User
+		wantErr bool
+	}{
+		{
+			name:  "error",
+			email: "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users WHERE email = \$1`).
+					WithArgs("john@example.com").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "success",
+			email: "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users WHERE email = \$1`).
+					WithArgs("john@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"fullname",
+						"phone_number",
+						"password",
+						"login_count",
+						"created_at",
+						"updated_at",
+						"status",
+						"token_version",
+						"phone_verified_at",
+						"pending_phone_number",
+						"email",
+						"email_verified_at",
+						"pending_email",
+						"roles",
+						"permissions",
+					}).AddRow(
+						1,
+						"John Doe",
+						"628123456789",
+						"hashed-password",
+						0,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+						"active",
+						2,
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						"",
+						"john@example.com",
+						time.Date(2023, 8, 5, 12, 40, 51, 900, time.UTC),
+						"",
+						"{user}",
+						"{profile:read,profile:write}",
+					))
+			},
+			want: &entity.User{
+				ID:              1,
+				Fullname:        "John Doe",
+				PhoneNumber:     "628123456789",
+				HashedPassword:  "hashed-password",
+				LoginCount:      0,
+				CreatedAt:       time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				UpdatedAt:       time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				Status:          entity.UserStatusActive,
+				TokenVersion:    2,
+				PhoneVerifiedAt: &verifiedAt,
+				Email:           "john@example.com",
+				EmailVerifiedAt: &verifiedAt,
+				Roles:           []string{"user"},
+				Permissions:     []string{"profile:read", "profile:write"},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetUserByEmail(ctx, tt.email)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestUserRepository_GetUserByID(t *testing.T) {
 	ctx := context.Background()
 	r := &UserRepository{}
@@ -133,6 +263,15 @@ func TestUserRepository_GetUserByID(t *testing.T) {
 						"login_count",
 						"created_at",
 						"updated_at",
+						"status",
+						"token_version",
+						"phone_verified_at",
+						"pending_phone_number",
+						"email",
+						"email_verified_at",
+						"pending_email",
+						"roles",
+						"permissions",
 					}).AddRow(
 						1,
 						"John Doe",
@@ -141,16 +280,31 @@ func TestUserRepository_GetUserByID(t *testing.T) {
 						0,
 						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
 						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+						"active",
+						2,
+						nil,
+						"628123456780",
+						"",
+						nil,
+						"jane@example.com",
+						"{user}",
+						"{profile:read,profile:write}",
 					))
 			},
 			want: &entity.User{
-				ID:             1,
-				Fullname:       "John Doe",
-				PhoneNumber:    "628123456789",
-				HashedPassword: "hashed-password",
-				LoginCount:     0,
-				CreatedAt:      time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
-				UpdatedAt:      time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				ID:                 1,
+				Fullname:           "John Doe",
+				PhoneNumber:        "628123456789",
+				HashedPassword:     "hashed-password",
+				LoginCount:         0,
+				CreatedAt:          time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				UpdatedAt:          time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+				Status:             entity.UserStatusActive,
+				TokenVersion:       2,
+				PendingPhoneNumber: "628123456780",
+				PendingEmail:       "jane@example.com",
+				Roles:              []string{"user"},
+				Permissions:        []string{"profile:read", "profile:write"},
 			},
 			wantErr: false,
 		},
@@ -198,11 +352,32 @@ func TestUserRepository_InsertUser(t *testing.T) {
 				Fullname:       "John Doe",
 				PhoneNumber:    "628123456789",
 				HashedPassword: "hashed password",
+				Status:         entity.UserStatusActive,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectQuery(`INSERT INTO users.*`).
+					WithArgs("John Doe", "628123456789", "hashed password", "active").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error insert default role",
+			user: &entity.User{
+				Fullname:       "John Doe",
+				PhoneNumber:    "628123456789",
+				HashedPassword: "hashed password",
+				Status:         entity.UserStatusActive,
 			},
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
 				m.ExpectQuery(`INSERT INTO users.*`).
-					WithArgs("John Doe", "628123456789", "hashed password").
+					WithArgs("John Doe", "628123456789", "hashed password", "active").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				m.ExpectExec(`INSERT INTO user_roles.*`).
+					WithArgs(1, "user").
 					WillReturnError(assert.AnError)
 				m.ExpectRollback().WillReturnError(nil)
 			},
@@ -214,12 +389,16 @@ func TestUserRepository_InsertUser(t *testing.T) {
 				Fullname:       "John Doe",
 				PhoneNumber:    "628123456789",
 				HashedPassword: "hashed password",
+				Status:         entity.UserStatusActive,
 			},
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
 				m.ExpectQuery(`INSERT INTO users.*`).
-					WithArgs("John Doe", "628123456789", "hashed password").
+					WithArgs("John Doe", "628123456789", "hashed password", "active").
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				m.ExpectExec(`INSERT INTO user_roles.*`).
+					WithArgs(1, "user").
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				m.ExpectCommit().WillReturnError(assert.AnError)
 				m.ExpectRollback().WillReturnError(nil)
 			},
@@ -231,12 +410,16 @@ func TestUserRepository_InsertUser(t *testing.T) {
 				Fullname:       "John Doe",
 				PhoneNumber:    "628123456789",
 				HashedPassword: "hashed password",
+				Status:         entity.UserStatusActive,
 			},
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
 				m.ExpectQuery(`INSERT INTO users.*`).
-					WithArgs("John Doe", "628123456789", "hashed password").
+					WithArgs("John Doe", "628123456789", "hashed password", "active").
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				m.ExpectExec(`INSERT INTO user_roles.*`).
+					WithArgs(1, "user").
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				m.ExpectCommit().WillReturnError(nil)
 			},
 			want:    1,
@@ -282,14 +465,16 @@ func TestUserRepository_UpdateUser(t *testing.T) {
 		{
 			name: "error query row context",
 			user: &entity.User{
-				ID:          1,
-				Fullname:    "John Doe",
-				PhoneNumber: "628123456789",
+				ID:                 1,
+				Fullname:           "John Doe",
+				PhoneNumber:        "628123456789",
+				PendingPhoneNumber: "628123456780",
+				PendingEmail:       "john@example.com",
 			},
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
-				m.ExpectExec(`UPDATE users.*`).
-					WithArgs("John Doe", "628123456789", 1).
+				m.ExpectExec(`UPDATE users SET fullname = \$1, pending_phone_number = NULLIF\(\$2, ''\), pending_email = NULLIF\(\$3, ''\)`).
+					WithArgs("John Doe", "628123456780", "john@example.com", 1).
 					WillReturnError(assert.AnError)
 				m.ExpectRollback().WillReturnError(nil)
 			},
@@ -298,14 +483,16 @@ func TestUserRepository_UpdateUser(t *testing.T) {
 		{
 			name: "error commit",
 			user: &entity.User{
-				ID:          1,
-				Fullname:    "John Doe",
-				PhoneNumber: "628123456789",
+				ID:                 1,
+				Fullname:           "John Doe",
+				PhoneNumber:        "628123456789",
+				PendingPhoneNumber: "628123456780",
+				PendingEmail:       "john@example.com",
 			},
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
-				m.ExpectExec(`UPDATE users.*`).
-					WithArgs("John Doe", "628123456789", 1).
+				m.ExpectExec(`UPDATE users SET fullname = \$1, pending_phone_number = NULLIF\(\$2, ''\), pending_email = NULLIF\(\$3, ''\)`).
+					WithArgs("John Doe", "628123456780", "john@example.com", 1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 				m.ExpectCommit().WillReturnError(assert.AnError)
 				m.ExpectRollback().WillReturnError(nil)
@@ -315,14 +502,16 @@ func TestUserRepository_UpdateUser(t *testing.T) {
 		{
 			name: "success",
 			user: &entity.User{
-				ID:          1,
-				Fullname:    "John Doe",
-				PhoneNumber: "628123456789",
+				ID:                 1,
+				Fullname:           "John Doe",
+				PhoneNumber:        "628123456789",
+				PendingPhoneNumber: "628123456780",
+				PendingEmail:       "john@example.com",
 			},
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
-				m.ExpectExec(`UPDATE users.*`).
-					WithArgs("John Doe", "628123456789", 1).
+				m.ExpectExec(`UPDATE users SET fullname = \$1, pending_phone_number = NULLIF\(\$2, ''\), pending_email = NULLIF\(\$3, ''\)`).
+					WithArgs("John Doe", "628123456780", "john@example.com", 1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 				m.ExpectCommit().WillReturnError(nil)
 			},
@@ -348,7 +537,243 @@ func TestUserRepository_UpdateUser(t *testing.T) {
 	}
 }
 
-func TestUserRepository_IncrementLoginCount(t *testing.T) {
+func TestUserRepository_UpdatePassword(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name           string
+		userID         int
+		hashedPassword string
+		keepHistory    int
+		prepare        func(m sqlmock.Sqlmock)
+		want           int
+		wantErr        bool
+	}{
+		{
+			name:           "error begin tx",
+			userID:         1,
+			hashedPassword: "new hashed password",
+			keepHistory:    4,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:           "error insert password history",
+			userID:         1,
+			hashedPassword: "new hashed password",
+			keepHistory:    4,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO password_history \(user_id, hashed_password\) SELECT id, password FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:           "error query row context",
+			userID:         1,
+			hashedPassword: "new hashed password",
+			keepHistory:    4,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO password_history \(user_id, hashed_password\) SELECT id, password FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectQuery(`UPDATE users SET password = \$1, token_version = token_version \+ 1.*WHERE id = \$2 RETURNING token_version`).
+					WithArgs("new hashed password", 1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:           "error prune password history",
+			userID:         1,
+			hashedPassword: "new hashed password",
+			keepHistory:    4,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO password_history \(user_id, hashed_password\) SELECT id, password FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectQuery(`UPDATE users SET password = \$1, token_version = token_version \+ 1.*WHERE id = \$2 RETURNING token_version`).
+					WithArgs("new hashed password", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+				m.ExpectExec(`DELETE FROM password_history WHERE user_id = \$1 AND id NOT IN.*ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:           "error commit",
+			userID:         1,
+			hashedPassword: "new hashed password",
+			keepHistory:    4,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO password_history \(user_id, hashed_password\) SELECT id, password FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectQuery(`UPDATE users SET password = \$1, token_version = token_version \+ 1.*WHERE id = \$2 RETURNING token_version`).
+					WithArgs("new hashed password", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+				m.ExpectExec(`DELETE FROM password_history WHERE user_id = \$1 AND id NOT IN.*ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:           "success",
+			userID:         1,
+			hashedPassword: "new hashed password",
+			keepHistory:    4,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`INSERT INTO password_history \(user_id, hashed_password\) SELECT id, password FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				m.ExpectQuery(`UPDATE users SET password = \$1, token_version = token_version \+ 1.*WHERE id = \$2 RETURNING token_version`).
+					WithArgs("new hashed password", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(3))
+				m.ExpectExec(`DELETE FROM password_history WHERE user_id = \$1 AND id NOT IN.*ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    3,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.UpdatePassword(ctx, tt.userID, tt.hashedPassword, tt.keepHistory)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_GetPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	createdAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	columns := []string{
+		"id",
+		"user_id",
+		"hashed_password",
+		"created_at",
+	}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    []*entity.PasswordHistory
+		wantErr bool
+	}{
+		{
+			name: "error query",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM password_history WHERE user_id = \$1 ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error scan",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM password_history WHERE user_id = \$1 ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow("not-an-id", 1, "hashed password 1", createdAt))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM password_history WHERE user_id = \$1 ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(2, 1, "hashed password 2", createdAt).RowError(0, assert.AnError))
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty history",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM password_history WHERE user_id = \$1 ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:    []*entity.PasswordHistory{},
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM password_history WHERE user_id = \$1 ORDER BY id DESC LIMIT \$2`).
+					WithArgs(1, 4).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(2, 1, "hashed password 2", createdAt).
+						AddRow(1, 1, "hashed password 1", createdAt))
+			},
+			want: []*entity.PasswordHistory{
+				{
+					ID:             2,
+					UserID:         1,
+					HashedPassword: "hashed password 2",
+					CreatedAt:      createdAt,
+				},
+				{
+					ID:             1,
+					UserID:         1,
+					HashedPassword: "hashed password 1",
+					CreatedAt:      createdAt,
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetPasswordHistory(ctx, 1, 4)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_IncrementTokenVersion(t *testing.T) {
 	ctx := context.Background()
 	r := &UserRepository{}
 	tests := []struct {
@@ -358,36 +783,45 @@ func TestUserRepository_IncrementLoginCount(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "error begin tx",
+			name:   "error begin tx",
+			userID: 1,
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(assert.AnError)
 			},
 			wantErr: true,
 		},
 		{
-			name: "error exec context",
+			name:   "error exec",
+			userID: 1,
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
-				m.ExpectExec(`UPDATE users.*`).WillReturnError(assert.AnError)
+				m.ExpectExec(`UPDATE users SET token_version = token_version \+ 1, updated_at = now\(\) WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
 				m.ExpectRollback().WillReturnError(nil)
 			},
 			wantErr: true,
 		},
 		{
-			name: "error commit",
+			name:   "error commit",
+			userID: 1,
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
-				m.ExpectExec(`UPDATE users.*`).WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(`UPDATE users SET token_version = token_version \+ 1, updated_at = now\(\) WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				m.ExpectCommit().WillReturnError(assert.AnError)
-				m.ExpectRollback().WillReturnError(nil)
 			},
 			wantErr: true,
 		},
 		{
-			name: "success",
+			name:   "success",
+			userID: 1,
 			prepare: func(m sqlmock.Sqlmock) {
 				m.ExpectBegin().WillReturnError(nil)
-				m.ExpectExec(`UPDATE users.*`).WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectExec(`UPDATE users SET token_version = token_version \+ 1, updated_at = now\(\) WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
 				m.ExpectCommit().WillReturnError(nil)
 			},
 			wantErr: false,
@@ -406,8 +840,764 @@ func TestUserRepository_IncrementLoginCount(t *testing.T) {
 			}
 			r.db = mockDB
 
-			err = r.IncrementLoginCount(ctx, tt.userID)
+			err = r.IncrementTokenVersion(ctx, tt.userID)
 			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_VerifyPhoneNumber(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name        string
+		userID      int
+		phoneNumber string
+		prepare     func(m sqlmock.Sqlmock)
+		want        bool
+		wantErr     bool
+	}{
+		{
+			name:        "error begin tx",
+			userID:      1,
+			phoneNumber: "628123456780",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "error exec context",
+			userID:      1,
+			phoneNumber: "628123456780",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET phone_number = \$1, pending_phone_number = NULL, phone_verified_at = now\(\).*WHERE id = \$2 AND \(phone_number = \$1 OR pending_phone_number = \$1\)`).
+					WithArgs("628123456780", 1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "error rows affected",
+			userID:      1,
+			phoneNumber: "628123456780",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET phone_number = \$1, pending_phone_number = NULL, phone_verified_at = now\(\).*WHERE id = \$2 AND \(phone_number = \$1 OR pending_phone_number = \$1\)`).
+					WithArgs("628123456780", 1).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "error commit",
+			userID:      1,
+			phoneNumber: "628123456780",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET phone_number = \$1, pending_phone_number = NULL, phone_verified_at = now\(\).*WHERE id = \$2 AND \(phone_number = \$1 OR pending_phone_number = \$1\)`).
+					WithArgs("628123456780", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "phone number has changed",
+			userID:      1,
+			phoneNumber: "628123456780",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET phone_number = \$1, pending_phone_number = NULL, phone_verified_at = now\(\).*WHERE id = \$2 AND \(phone_number = \$1 OR pending_phone_number = \$1\)`).
+					WithArgs("628123456780", 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:        "success",
+			userID:      1,
+			phoneNumber: "628123456780",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET phone_number = \$1, pending_phone_number = NULL, phone_verified_at = now\(\).*WHERE id = \$2 AND \(phone_number = \$1 OR pending_phone_number = \$1\)`).
+					WithArgs("628123456780", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.VerifyPhoneNumber(ctx, tt.userID, tt.phoneNumber)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_VerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name    string
+		userID  int
+		email   string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+		// wantErrIs is checked on top of wantErr when set
+		wantErrIs error
+	}{
+		{
+			name:   "error begin tx",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error exec context",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET email = \$1, pending_email = NULL, email_verified_at = now\(\).*WHERE id = \$2 AND pending_email = \$1`).
+					WithArgs("john@example.com", 1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "email verified by another user",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET email = \$1, pending_email = NULL, email_verified_at = now\(\).*WHERE id = \$2 AND pending_email = \$1`).
+					WithArgs("john@example.com", 1).
+					WillReturnError(&pq.Error{Code: "23505"})
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr:   true,
+			wantErrIs: repository.ErrDuplicate,
+		},
+		{
+			name:   "error rows affected",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET email = \$1, pending_email = NULL, email_verified_at = now\(\).*WHERE id = \$2 AND pending_email = \$1`).
+					WithArgs("john@example.com", 1).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error commit",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET email = \$1, pending_email = NULL, email_verified_at = now\(\).*WHERE id = \$2 AND pending_email = \$1`).
+					WithArgs("john@example.com", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "email is no longer pending",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET email = \$1, pending_email = NULL, email_verified_at = now\(\).*WHERE id = \$2 AND pending_email = \$1`).
+					WithArgs("john@example.com", 1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:   "success",
+			userID: 1,
+			email:  "john@example.com",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET email = \$1, pending_email = NULL, email_verified_at = now\(\).*WHERE id = \$2 AND pending_email = \$1`).
+					WithArgs("john@example.com", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.VerifyEmail(ctx, tt.userID, tt.email)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantErrIs != nil {
+				assert.ErrorIs(t, err, tt.wantErrIs)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_RehashPassword(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2 AND password = \$3`).
+					WithArgs("new hash", 1, "old hash").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows affected",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2 AND password = \$3`).
+					WithArgs("new hash", 1, "old hash").
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2 AND password = \$3`).
+					WithArgs("new hash", 1, "old hash").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "password has been changed",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2 AND password = \$3`).
+					WithArgs("new hash", 1, "old hash").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET password = \$1 WHERE id = \$2 AND password = \$3`).
+					WithArgs("new hash", 1, "old hash").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.RehashPassword(ctx, 1, "old hash", "new hash")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_IncrementLoginCount(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name    string
+		userID  int
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users.*`).WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users.*`).WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users.*`).WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.IncrementLoginCount(ctx, tt.userID)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestUserRepository_ListUsers(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	createdAt := time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC)
+	columns := []string{
+		"id",
+		"fullname",
+		"phone_number",
+		"password",
+		"login_count",
+		"created_at",
+		"updated_at",
+		"status",
+		"roles",
+	}
+	tests := []struct {
+		name    string
+		filter  entity.ListUsersFilter
+		prepare func(m sqlmock.Sqlmock)
+		want    []*entity.User
+		wantErr bool
+	}{
+		{
+			name: "unsupported sort field",
+			filter: entity.ListUsersFilter{
+				SortField: "password",
+				Limit:     10,
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query",
+			filter: entity.ListUsersFilter{
+				SortField: entity.UserSortID,
+				Limit:     10,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users ORDER BY id ASC LIMIT \$1`).
+					WithArgs(10).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error scan",
+			filter: entity.ListUsersFilter{
+				SortField: entity.UserSortID,
+				Limit:     10,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users ORDER BY id ASC LIMIT \$1`).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error rows",
+			filter: entity.ListUsersFilter{
+				SortField: entity.UserSortID,
+				Limit:     10,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users ORDER BY id ASC LIMIT \$1`).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "John Doe", "628123456789", "hashed-password", 3, createdAt, createdAt, "active", "{user}").
+						RowError(0, assert.AnError))
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty result",
+			filter: entity.ListUsersFilter{
+				SortField: entity.UserSortID,
+				Limit:     10,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users ORDER BY id ASC LIMIT \$1`).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:    []*entity.User{},
+			wantErr: false,
+		},
+		{
+			name: "every filter on the next page sorted by newest",
+			filter: entity.ListUsersFilter{
+				PhonePrefix:   "62812",
+				Status:        entity.UserStatusSuspended,
+				Name:          "john_",
+				CreatedAfter:  createdAt.Add(-time.Hour),
+				CreatedBefore: createdAt.Add(time.Hour),
+				SortField:     entity.UserSortCreatedAt,
+				Descending:    true,
+				After: &entity.UserCursor{
+					ID:        5,
+					CreatedAt: createdAt.Add(time.Minute),
+				},
+				Limit: 2,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users `+
+					`WHERE phone_number LIKE \$1 AND fullname ILIKE \$2 AND status = \$3 AND created_at >= \$4 AND created_at < \$5 `+
+					`AND \(created_at, id\) < \(\$6, \$7\) `+
+					`ORDER BY created_at DESC, id DESC LIMIT \$8`).
+					WithArgs("62812%", `%john\_%`, "suspended", createdAt.Add(-time.Hour), createdAt.Add(time.Hour), createdAt.Add(time.Minute), 5, 2).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "John Doe", "628123456789", "hashed-password", 3, createdAt, createdAt, "suspended", "{admin,user}"))
+			},
+			want: []*entity.User{
+				{
+					ID:             1,
+					Fullname:       "John Doe",
+					PhoneNumber:    "628123456789",
+					HashedPassword: "hashed-password",
+					LoginCount:     3,
+					CreatedAt:      createdAt,
+					UpdatedAt:      createdAt,
+					Status:         entity.UserStatusSuspended,
+					Roles:          []string{"admin", "user"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "next page sorted by fullname",
+			filter: entity.ListUsersFilter{
+				SortField: entity.UserSortFullname,
+				After: &entity.UserCursor{
+					ID:       5,
+					Fullname: "John Doe",
+				},
+				Limit: 2,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users WHERE \(fullname, id\) > \(\$1, \$2\) ORDER BY fullname ASC, id ASC LIMIT \$3`).
+					WithArgs("John Doe", 5, 2).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:    []*entity.User{},
+			wantErr: false,
+		},
+		{
+			name: "next page sorted by id",
+			filter: entity.ListUsersFilter{
+				SortField:  entity.UserSortID,
+				Descending: true,
+				After: &entity.UserCursor{
+					ID: 5,
+				},
+				Limit: 2,
+			},
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM users WHERE id < \$1 ORDER BY id DESC LIMIT \$2`).
+					WithArgs(5, 2).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want:    []*entity.User{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.ListUsers(ctx, tt.filter)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\%\_sure\\`, escapeLike(`100%_sure\`))
+}
+
+func TestUserRepository_GetUserSessionState(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name    string
+		userID  int
+		prepare func(m sqlmock.Sqlmock)
+		want    entity.UserSessionState
+		wantErr bool
+	}{
+		{
+			name:   "error",
+			userID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT status, token_version FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "success",
+			userID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT status, token_version FROM users WHERE id = \$1`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"status", "token_version"}).AddRow("suspended", 2))
+			},
+			want: entity.UserSessionState{
+				Status:       entity.UserStatusSuspended,
+				TokenVersion: 2,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetUserSessionState(ctx, tt.userID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_UpdateUserStatus(t *testing.T) {
+	ctx := context.Background()
+	r := &UserRepository{}
+	tests := []struct {
+		name    string
+		userID  int
+		from    string
+		to      string
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "error begin tx",
+			userID: 1,
+			from:   entity.UserStatusActive,
+			to:     entity.UserStatusSuspended,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error exec",
+			userID: 1,
+			from:   entity.UserStatusActive,
+			to:     entity.UserStatusSuspended,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET status = \$1, updated_at = now\(\) WHERE id = \$2 AND status = \$3`).
+					WithArgs("suspended", 1, "active").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error rows affected",
+			userID: 1,
+			from:   entity.UserStatusActive,
+			to:     entity.UserStatusSuspended,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET status = \$1, updated_at = now\(\) WHERE id = \$2 AND status = \$3`).
+					WithArgs("suspended", 1, "active").
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error commit",
+			userID: 1,
+			from:   entity.UserStatusActive,
+			to:     entity.UserStatusSuspended,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET status = \$1, updated_at = now\(\) WHERE id = \$2 AND status = \$3`).
+					WithArgs("suspended", 1, "active").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "user no longer in expected status",
+			userID: 1,
+			from:   entity.UserStatusActive,
+			to:     entity.UserStatusSuspended,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET status = \$1, updated_at = now\(\) WHERE id = \$2 AND status = \$3`).
+					WithArgs("suspended", 1, "active").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:   "success",
+			userID: 1,
+			from:   entity.UserStatusActive,
+			to:     entity.UserStatusSuspended,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE users SET status = \$1, updated_at = now\(\) WHERE id = \$2 AND status = \$3`).
+					WithArgs("suspended", 1, "active").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.UpdateUserStatus(ctx, tt.userID, tt.from, tt.to)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
 		})
 	}
 }
@@ -0,0 +1,2 @@
+// Package verification directly relates to verification_codes table in database.
+package verification
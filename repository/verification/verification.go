@@ -0,0 +1,188 @@
+package verification
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/entity"
+)
+
+type VerificationRepository struct {
+	db *sql.DB
+}
+
+type NewRepositoryOptions struct {
+	DB *sql.DB
+}
+
+// New returns a new instance of VerificationRepository.
+func New(opts NewRepositoryOptions) *VerificationRepository {
+	return &VerificationRepository{
+		db: opts.DB,
+	}
+}
+
+// GetActiveVerificationCode returns the latest code of the phone number for the purpose that has not been consumed.
+func (r *VerificationRepository) GetActiveVerificationCode(ctx context.Context, phoneNumber, purpose string) (*entity.VerificationCode, error) {
+	var code = &entity.VerificationCode{}
+
+	query := `
+		SELECT
+			id,
+			phone_number,
+			purpose,
+			code_hash,
+			expires_at,
+			consumed_at,
+			created_at
+		FROM verification_codes
+		WHERE phone_number = $1
+			AND purpose = $2
+			AND consumed_at IS NULL
+		ORDER BY id DESC
+		LIMIT 1;
+	`
+	err := r.db.QueryRowContext(ctx, query, phoneNumber, purpose).Scan(
+		&code.ID,
+		&code.PhoneNumber,
+		&code.Purpose,
+		&code.CodeHash,
+		&code.ExpiresAt,
+		&code.ConsumedAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// InsertVerificationCode inserts a new code to database, returning its id on success.
+// Codes issued before for the same phone number and purpose are consumed, so only the latest one works.
+func (r *VerificationRepository) InsertVerificationCode(ctx context.Context, code *entity.VerificationCode) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE verification_codes
+		SET
+			consumed_at = now()
+		WHERE phone_number = $1
+			AND purpose = $2
+			AND consumed_at IS NULL;
+	`
+	_, err = tx.ExecContext(ctx, query, code.PhoneNumber, code.Purpose)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		INSERT INTO verification_codes (
+			phone_number,
+			purpose,
+			code_hash,
+			expires_at
+		) VALUES (
+			$1,
+			$2,
+			$3,
+			$4
+		) RETURNING id;
+	`
+	err = tx.QueryRowContext(
+		ctx,
+		query,
+		code.PhoneNumber,
+		code.Purpose,
+		code.CodeHash,
+		code.ExpiresAt,
+	).Scan(&code.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return code.ID, nil
+}
+
+// ConsumeVerificationCode marks a code as used.
+// It returns false if the code has already been consumed by another request.
+func (r *VerificationRepository) ConsumeVerificationCode(ctx context.Context, codeID int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		UPDATE verification_codes
+		SET
+			consumed_at = now()
+		WHERE id = $1
+			AND consumed_at IS NULL;
+	`
+	var result sql.Result
+	result, err = tx.ExecContext(ctx, query, codeID)
+	if err != nil {
+		return false, err
+	}
+
+	var affected int64
+	affected, err = result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// DeleteExpiredVerificationCodes removes codes that expired before the given time, consumed or not.
+func (r *VerificationRepository) DeleteExpiredVerificationCodes(ctx context.Context, expiredBefore time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	query := `
+		DELETE FROM verification_codes
+		WHERE expires_at < $1;
+	`
+	_, err = tx.ExecContext(ctx, query, expiredBefore)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,380 @@
+package verification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	mockDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Error(err)
+	}
+	defer mockDB.Close()
+
+	assert.NotEmpty(t, New(NewRepositoryOptions{
+		DB: mockDB,
+	}))
+}
+
+func TestVerificationRepository_GetActiveVerificationCode(t *testing.T) {
+	ctx := context.Background()
+	r := &VerificationRepository{}
+	tests := []struct {
+		name        string
+		phoneNumber string
+		purpose     string
+		prepare     func(m sqlmock.Sqlmock)
+		want        *entity.VerificationCode
+		wantErr     bool
+	}{
+		{
+			name:        "error",
+			phoneNumber: "628123456789",
+			purpose:     "password_reset",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM verification_codes WHERE phone_number = \$1 AND purpose = \$2 AND consumed_at IS NULL`).
+					WithArgs("628123456789", "password_reset").
+					WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:        "success",
+			phoneNumber: "628123456789",
+			purpose:     "password_reset",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectQuery(`SELECT.*FROM verification_codes WHERE phone_number = \$1 AND purpose = \$2 AND consumed_at IS NULL`).
+					WithArgs("628123456789", "password_reset").
+					WillReturnRows(sqlmock.NewRows([]string{
+						"id",
+						"phone_number",
+						"purpose",
+						"code_hash",
+						"expires_at",
+						"consumed_at",
+						"created_at",
+					}).AddRow(
+						1,
+						"628123456789",
+						"password_reset",
+						"hashed-code",
+						time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC),
+						nil,
+						time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+					))
+			},
+			want: &entity.VerificationCode{
+				ID:          1,
+				PhoneNumber: "628123456789",
+				Purpose:     "password_reset",
+				CodeHash:    "hashed-code",
+				ExpiresAt:   time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC),
+				CreatedAt:   time.Date(2023, 8, 5, 12, 35, 51, 900, time.UTC),
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.GetActiveVerificationCode(ctx, tt.phoneNumber, tt.purpose)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVerificationRepository_InsertVerificationCode(t *testing.T) {
+	ctx := context.Background()
+	r := &VerificationRepository{}
+	expiresAt := time.Date(2023, 8, 5, 12, 45, 51, 900, time.UTC)
+	code := func() *entity.VerificationCode {
+		return &entity.VerificationCode{
+			PhoneNumber: "628123456789",
+			Purpose:     "password_reset",
+			CodeHash:    "hashed-code",
+			ExpiresAt:   expiresAt,
+		}
+	}
+	tests := []struct {
+		name    string
+		code    *entity.VerificationCode
+		prepare func(m sqlmock.Sqlmock)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error consume previous codes",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE phone_number = \$1 AND purpose = \$2`).
+					WithArgs("628123456789", "password_reset").
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error query row context",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE phone_number = \$1 AND purpose = \$2`).
+					WithArgs("628123456789", "password_reset").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectQuery(`INSERT INTO verification_codes.*`).
+					WithArgs("628123456789", "password_reset", "hashed-code", expiresAt).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE phone_number = \$1 AND purpose = \$2`).
+					WithArgs("628123456789", "password_reset").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectQuery(`INSERT INTO verification_codes.*`).
+					WithArgs("628123456789", "password_reset", "hashed-code", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			code: code(),
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE phone_number = \$1 AND purpose = \$2`).
+					WithArgs("628123456789", "password_reset").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectQuery(`INSERT INTO verification_codes.*`).
+					WithArgs("628123456789", "password_reset", "hashed-code", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    2,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.InsertVerificationCode(ctx, tt.code)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestVerificationRepository_ConsumeVerificationCode(t *testing.T) {
+	ctx := context.Background()
+	r := &VerificationRepository{}
+	tests := []struct {
+		name    string
+		codeID  int
+		prepare func(m sqlmock.Sqlmock)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "error begin tx",
+			codeID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error exec context",
+			codeID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(1).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error rows affected",
+			codeID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewErrorResult(assert.AnError))
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "error commit",
+			codeID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:   "already consumed",
+			codeID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+		{
+			name:   "success",
+			codeID: 1,
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`UPDATE verification_codes SET consumed_at = now\(\) WHERE id = \$1 AND consumed_at IS NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			got, err := r.ConsumeVerificationCode(ctx, tt.codeID)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestVerificationRepository_DeleteExpiredVerificationCodes(t *testing.T) {
+	ctx := context.Background()
+	r := &VerificationRepository{}
+	expiredBefore := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		prepare func(m sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "error begin tx",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error exec context",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM verification_codes WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnError(assert.AnError)
+				m.ExpectRollback().WillReturnError(nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "error commit",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM verification_codes WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			prepare: func(m sqlmock.Sqlmock) {
+				m.ExpectBegin().WillReturnError(nil)
+				m.ExpectExec(`DELETE FROM verification_codes WHERE expires_at < \$1`).
+					WithArgs(expiredBefore).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				m.ExpectCommit().WillReturnError(nil)
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, err := sqlmock.New()
+			if err != nil {
+				t.Error(err)
+			}
+			defer mockDB.Close()
+
+			if tt.prepare != nil {
+				tt.prepare(mockSQL)
+			}
+			r.db = mockDB
+
+			err = r.DeleteExpiredVerificationCodes(ctx, expiredBefore)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -1,25 +1,36 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"log"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
 	"github.com/leguminosa/profile-open-portal/tools"
 	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
 )
 
 type Auth struct {
-	jwtClient tools.JWTInterface
+	jwtClient            tools.JWTInterface
+	userRepository       repository.UserRepositoryInterface
+	revocationRepository repository.RevocationRepositoryInterface
 }
 
 type NewAuthOptions struct {
-	JWT tools.JWTInterface
+	JWT                  tools.JWTInterface
+	UserRepository       repository.UserRepositoryInterface
+	RevocationRepository repository.RevocationRepositoryInterface
 }
 
 func New(opts NewAuthOptions) *Auth {
 	return &Auth{
-		jwtClient: opts.JWT,
+		jwtClient:            opts.JWT,
+		userRepository:       opts.UserRepository,
+		revocationRepository: opts.RevocationRepository,
 	}
 }
 
@@ -27,6 +38,10 @@ var (
 	// ErrNotAuthenticated obscures the error message
 	// to avoid brute force attack on authentication process
 	ErrNotAuthenticated = errors.New("not authenticated")
+	// ErrNotAuthorized is returned when an authenticated user lacks the required permission.
+	ErrNotAuthorized = errors.New("not authorized")
+
+	errStaleTokenVersion = errors.New("token was issued before password change or logout from all devices")
 )
 
 func (a *Auth) AuthenticateMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
@@ -40,30 +55,119 @@ func (a *Auth) AuthenticateMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	}
 }
 
+// Authenticate checks the jwt of the request once, a request authenticated before,
+// like by a rate limit counting it per user, is not checked again.
 func (a *Auth) Authenticate(c echo.Context) error {
+	if helper.ClaimsFromContext(c) != nil {
+		return nil
+	}
+
 	jwtToken, err := a.getJWTFromHeader(c)
 	if err != nil {
 		return ErrNotAuthenticated
 	}
 
-	var dat interface{}
-	dat, err = a.jwtClient.Validate(jwtToken)
+	claims, err := a.jwtClient.Validate(jwtToken)
 	if err != nil {
 		return ErrNotAuthenticated
 	}
 
-	user, ok := dat.(map[string]interface{})
-	if !ok {
+	// sub claim holds the user id
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID <= 0 {
+		return ErrNotAuthenticated
+	}
+
+	if claims.ID == "" {
 		return ErrNotAuthenticated
 	}
 
-	var userID interface{}
-	userID, ok = user["id"]
-	if !ok {
+	// tokens signed for anything else, like a magic link, are not access tokens
+	if claims.Purpose != "" {
 		return ErrNotAuthenticated
 	}
 
+	ctx := c.Request().Context()
+
+	err = a.checkRevocation(ctx, claims.ID)
+	if err != nil {
+		return ErrNotAuthenticated
+	}
+
+	// the actual reason a user is refused is kept for internal use only
+	err = a.checkUserSession(ctx, userID, claims.TokenVersion)
+	if err != nil {
+		log.Printf("authenticate: refuse user %d: %v", userID, err)
+		return entity.Obscure(ErrNotAuthenticated, err)
+	}
+
 	helper.SetUserIDToContext(c, userID)
+	helper.SetTokenIDToContext(c, claims.ID)
+	helper.SetTokenExpiresAtToContext(c, claims.ExpiresAt)
+	helper.SetPermissionsToContext(c, claims.Permissions)
+	helper.SetClaimsToContext(c, claims)
+	return nil
+}
+
+func (a *Auth) AuthorizeMiddleware(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := a.Authorize(c, permission)
+			if err != nil {
+				return helper.Forbidden(c, err.Error())
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Authorize relies on permissions claim, so a change of roles takes effect once the user gets a new jwt.
+func (a *Auth) Authorize(c echo.Context, permission string) error {
+	err := a.Authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range helper.PermissionsFromContext(c) {
+		if p == permission {
+			return nil
+		}
+	}
+
+	return ErrNotAuthorized
+}
+
+// checkRevocation rejects a token revoked by logout.
+func (a *Auth) checkRevocation(ctx context.Context, tokenID string) error {
+	revoked, err := a.revocationRepository.IsTokenRevoked(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("token has been revoked")
+	}
+
+	return nil
+}
+
+// checkUserSession rejects a token of user who is no longer active, or who changed password
+// or logged out from all devices after the token was issued, even before the token expires.
+func (a *Auth) checkUserSession(ctx context.Context, userID, tokenVersion int) error {
+	state, err := a.userRepository.GetUserSessionState(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = entity.CheckUserStatus(state.Status)
+	if err != nil {
+		return err
+	}
+
+	if tokenVersion != state.TokenVersion {
+		return errStaleTokenVersion
+	}
+
 	return nil
 }
 
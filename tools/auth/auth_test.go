@@ -1,14 +1,18 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/entity"
+	"github.com/leguminosa/profile-open-portal/repository"
 	"github.com/leguminosa/profile-open-portal/tools"
 	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
 	"github.com/stretchr/testify/assert"
@@ -19,20 +23,26 @@ func TestNew(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
 
 	assert.NotEmpty(t, New(NewAuthOptions{
-		JWT: mockJWT,
+		JWT:                  mockJWT,
+		UserRepository:       mockUserRepo,
+		RevocationRepository: mockRevocationRepo,
 	}))
 }
 
 func TestAuth_AuthenticateMiddleware(t *testing.T) {
 	a := &Auth{}
 	tests := []struct {
-		name       string
-		token      string
-		prepare    func(m *tools.MockJWTInterface)
-		wantCode   int
-		wantUserID int
+		name              string
+		token             string
+		prepare           func(m *tools.MockJWTInterface)
+		prepareRevocation func(m *repository.MockRevocationRepositoryInterface)
+		prepareUser       func(m *repository.MockUserRepositoryInterface)
+		wantCode          int
+		wantUserID        int
 	}{
 		{
 			name:       "missing authorization header",
@@ -50,22 +60,107 @@ func TestAuth_AuthenticateMiddleware(t *testing.T) {
 			wantUserID: 0,
 		},
 		{
-			name:  "malformed data",
+			name:  "subject is not a user id",
 			token: "Bearer valid_token",
 			prepare: func(m *tools.MockJWTInterface) {
-				m.EXPECT().Validate("valid_token").Return("invalid data", nil)
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:      "token-id",
+					Subject: "john",
+				}, nil)
+			},
+			wantCode:   http.StatusForbidden,
+			wantUserID: 0,
+		},
+		{
+			name:  "subject is not a positive user id",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:      "token-id",
+					Subject: "0",
+				}, nil)
+			},
+			wantCode:   http.StatusForbidden,
+			wantUserID: 0,
+		},
+		{
+			name:  "token id not found",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					Subject: "128",
+				}, nil)
+			},
+			wantCode:   http.StatusForbidden,
+			wantUserID: 0,
+		},
+		{
+			name:  "token is not an access token",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:      "token-id",
+					Subject: "128",
+					Purpose: "magic_link",
+				}, nil)
+			},
+			wantCode:   http.StatusForbidden,
+			wantUserID: 0,
+		},
+		{
+			name:  "revoked token",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:        "token-id",
+					Subject:   "128",
+					IssuedAt:  time.Unix(1691236800, 0),
+					ExpiresAt: time.Unix(1691237700, 0),
+				}, nil)
+			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(true, nil)
+			},
+			wantCode:   http.StatusForbidden,
+			wantUserID: 0,
+		},
+		{
+			name:  "error get user session state",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:        "token-id",
+					Subject:   "128",
+					IssuedAt:  time.Unix(1691236800, 0),
+					ExpiresAt: time.Unix(1691237700, 0),
+				}, nil)
+			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+			},
+			prepareUser: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{}, assert.AnError)
 			},
 			wantCode:   http.StatusForbidden,
 			wantUserID: 0,
 		},
 		{
-			name:  "user id not found",
+			name:  "suspended user",
 			token: "Bearer valid_token",
 			prepare: func(m *tools.MockJWTInterface) {
-				m.EXPECT().Validate("valid_token").Return(map[string]interface{}{
-					"some_key": 128,
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:        "token-id",
+					Subject:   "128",
+					IssuedAt:  time.Unix(1691236800, 0),
+					ExpiresAt: time.Unix(1691237700, 0),
 				}, nil)
 			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+			},
+			prepareUser: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "suspended"}, nil)
+			},
 			wantCode:   http.StatusForbidden,
 			wantUserID: 0,
 		},
@@ -73,10 +168,19 @@ func TestAuth_AuthenticateMiddleware(t *testing.T) {
 			name:  "success",
 			token: "Bearer valid_token",
 			prepare: func(m *tools.MockJWTInterface) {
-				m.EXPECT().Validate("valid_token").Return(map[string]interface{}{
-					"id": 128,
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:        "token-id",
+					Subject:   "128",
+					IssuedAt:  time.Unix(1691236800, 0),
+					ExpiresAt: time.Unix(1691237700, 0),
 				}, nil)
 			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+			},
+			prepareUser: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+			},
 			wantCode:   http.StatusOK,
 			wantUserID: 128,
 		},
@@ -90,6 +194,8 @@ func TestAuth_AuthenticateMiddleware(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.prepare != nil {
@@ -97,6 +203,16 @@ func TestAuth_AuthenticateMiddleware(t *testing.T) {
 			}
 			a.jwtClient = mockJWT
 
+			if tt.prepareRevocation != nil {
+				tt.prepareRevocation(mockRevocationRepo)
+			}
+			a.revocationRepository = mockRevocationRepo
+
+			if tt.prepareUser != nil {
+				tt.prepareUser(mockUserRepo)
+			}
+			a.userRepository = mockUserRepo
+
 			mockW := httptest.NewRecorder()
 			mockR := httptest.NewRequest("GET", "/", nil)
 
@@ -121,6 +237,316 @@ func TestAuth_AuthenticateMiddleware(t *testing.T) {
 	}
 }
 
+func TestAuth_Authenticate(t *testing.T) {
+	a := &Auth{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	a.jwtClient = mockJWT
+	a.revocationRepository = mockRevocationRepo
+	a.userRepository = mockUserRepo
+
+	mockJWT.EXPECT().Validate("valid_token").Return(&tools.Claims{
+		ID:          "token-id",
+		Subject:     "128",
+		IssuedAt:    time.Unix(1691236800, 0),
+		ExpiresAt:   time.Unix(1691237700, 0),
+		Permissions: []string{"profile:read"},
+	}, nil)
+	mockRevocationRepo.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+	mockUserRepo.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+
+	mockR := httptest.NewRequest("GET", "/", nil)
+	mockR.Header.Set("Authorization", "Bearer valid_token")
+	c := echo.New().NewContext(mockR, httptest.NewRecorder())
+
+	err := a.Authenticate(c)
+	assert.NoError(t, err)
+	assert.Equal(t, 128, helper.UserIDFromContext(c))
+	assert.Equal(t, "token-id", helper.TokenIDFromContext(c))
+	assert.Equal(t, time.Unix(1691237700, 0), helper.TokenExpiresAtFromContext(c))
+	assert.Equal(t, []string{"profile:read"}, helper.PermissionsFromContext(c))
+	assert.Equal(t, "token-id", helper.ClaimsFromContext(c).ID)
+
+	// authenticated again later in the same request, the jwt is not checked again
+	err = a.Authenticate(c)
+	assert.NoError(t, err)
+	assert.Equal(t, 128, helper.UserIDFromContext(c))
+}
+
+func TestAuth_AuthorizeMiddleware(t *testing.T) {
+	a := &Auth{}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	a.jwtClient = mockJWT
+	a.revocationRepository = mockRevocationRepo
+	a.userRepository = mockUserRepo
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return helper.OK(c, map[string]interface{}{
+			"user_id": helper.UserIDFromContext(c),
+		})
+	}, a.AuthorizeMiddleware("users:read"))
+
+	// missing permission
+	mockJWT.EXPECT().Validate("valid_token").Return(&tools.Claims{
+		ID:          "token-id",
+		Subject:     "128",
+		Permissions: []string{"profile:read"},
+	}, nil)
+	mockRevocationRepo.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+	mockUserRepo.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+
+	mockW := httptest.NewRecorder()
+	mockR := httptest.NewRequest("GET", "/", nil)
+	mockR.Header.Set("Authorization", "Bearer valid_token")
+	e.ServeHTTP(mockW, mockR)
+	assert.Equal(t, http.StatusForbidden, mockW.Code)
+	assert.Equal(t, "{\"message\":\"not authorized\"}\n", mockW.Body.String())
+
+	// granted permission
+	mockJWT.EXPECT().Validate("valid_token").Return(&tools.Claims{
+		ID:          "token-id",
+		Subject:     "128",
+		Permissions: []string{"users:read"},
+	}, nil)
+	mockRevocationRepo.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+	mockUserRepo.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+
+	mockW = httptest.NewRecorder()
+	mockR = httptest.NewRequest("GET", "/", nil)
+	mockR.Header.Set("Authorization", "Bearer valid_token")
+	e.ServeHTTP(mockW, mockR)
+	assert.Equal(t, http.StatusOK, mockW.Code)
+	assert.Equal(t, "{\"user_id\":128}\n", mockW.Body.String())
+}
+
+func TestAuth_Authorize(t *testing.T) {
+	a := &Auth{}
+	tests := []struct {
+		name              string
+		token             string
+		prepare           func(m *tools.MockJWTInterface)
+		prepareRevocation func(m *repository.MockRevocationRepositoryInterface)
+		prepareUser       func(m *repository.MockUserRepositoryInterface)
+		wantErr           error
+	}{
+		{
+			name:    "not authenticated",
+			token:   "",
+			wantErr: ErrNotAuthenticated,
+		},
+		{
+			name:  "no permission at all",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:      "token-id",
+					Subject: "128",
+				}, nil)
+			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+			},
+			prepareUser: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+			},
+			wantErr: ErrNotAuthorized,
+		},
+		{
+			name:  "missing permission",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:          "token-id",
+					Subject:     "128",
+					Permissions: []string{"profile:read", "profile:write"},
+				}, nil)
+			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+			},
+			prepareUser: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+			},
+			wantErr: ErrNotAuthorized,
+		},
+		{
+			name:  "granted permission",
+			token: "Bearer valid_token",
+			prepare: func(m *tools.MockJWTInterface) {
+				m.EXPECT().Validate("valid_token").Return(&tools.Claims{
+					ID:          "token-id",
+					Subject:     "128",
+					Permissions: []string{"profile:read", "users:write"},
+				}, nil)
+			},
+			prepareRevocation: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(gomock.Any(), "token-id").Return(false, nil)
+			},
+			prepareUser: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(gomock.Any(), 128).Return(entity.UserSessionState{Status: "active"}, nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockJWT := tools.NewMockJWTInterface(ctrl)
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(mockJWT)
+			}
+			a.jwtClient = mockJWT
+
+			if tt.prepareRevocation != nil {
+				tt.prepareRevocation(mockRevocationRepo)
+			}
+			a.revocationRepository = mockRevocationRepo
+
+			if tt.prepareUser != nil {
+				tt.prepareUser(mockUserRepo)
+			}
+			a.userRepository = mockUserRepo
+
+			mockR := httptest.NewRequest("GET", "/", nil)
+			mockR.Header.Set("Authorization", tt.token)
+			c := echo.New().NewContext(mockR, httptest.NewRecorder())
+
+			err := a.Authorize(c, "users:write")
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestAuth_checkRevocation(t *testing.T) {
+	ctx := context.Background()
+	a := &Auth{}
+	tests := []struct {
+		name    string
+		tokenID string
+		prepare func(m *repository.MockRevocationRepositoryInterface)
+		wantErr bool
+	}{
+		{
+			name:    "error is token revoked",
+			tokenID: "token-id",
+			prepare: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "token-id").Return(false, assert.AnError)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "token revoked",
+			tokenID: "token-id",
+			prepare: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "token-id").Return(true, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "never revoked",
+			tokenID: "token-id",
+			prepare: func(m *repository.MockRevocationRepositoryInterface) {
+				m.EXPECT().IsTokenRevoked(ctx, "token-id").Return(false, nil)
+			},
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRevocationRepo := repository.NewMockRevocationRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(mockRevocationRepo)
+			}
+			a.revocationRepository = mockRevocationRepo
+
+			err := a.checkRevocation(ctx, tt.tokenID)
+			assert.Equal(t, tt.wantErr, err != nil)
+		})
+	}
+}
+
+func TestAuth_checkUserSession(t *testing.T) {
+	ctx := context.Background()
+	a := &Auth{}
+	tests := []struct {
+		name         string
+		userID       int
+		tokenVersion int
+		prepare      func(m *repository.MockUserRepositoryInterface)
+		wantErr      error
+	}{
+		{
+			name:   "error get user session state",
+			userID: 15,
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(ctx, 15).Return(entity.UserSessionState{}, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		{
+			name:   "pending user",
+			userID: 15,
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(ctx, 15).Return(entity.UserSessionState{Status: "pending"}, nil)
+			},
+			wantErr: entity.ErrUserPending,
+		},
+		{
+			name:   "deleted user",
+			userID: 15,
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(ctx, 15).Return(entity.UserSessionState{Status: "deleted"}, nil)
+			},
+			wantErr: entity.ErrUserDeleted,
+		},
+		{
+			name:         "token issued before password change",
+			userID:       15,
+			tokenVersion: 1,
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(ctx, 15).Return(entity.UserSessionState{Status: "active", TokenVersion: 2}, nil)
+			},
+			wantErr: errStaleTokenVersion,
+		},
+		{
+			name:         "active user",
+			userID:       15,
+			tokenVersion: 2,
+			prepare: func(m *repository.MockUserRepositoryInterface) {
+				m.EXPECT().GetUserSessionState(ctx, 15).Return(entity.UserSessionState{Status: "active", TokenVersion: 2}, nil)
+			},
+			wantErr: nil,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockUserRepo := repository.NewMockUserRepositoryInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(mockUserRepo)
+			}
+			a.userRepository = mockUserRepo
+
+			err := a.checkUserSession(ctx, tt.userID, tt.tokenVersion)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
 func TestAuth_getJWTFromHeader(t *testing.T) {
 	a := &Auth{}
 	tests := []struct {
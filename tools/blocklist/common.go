@@ -0,0 +1,58 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"strings"
+)
+
+//go:embed common.txt
+var commonPasswords string
+
+// Common blocks the most common passwords shipped with the binary, ignoring case.
+// A common word decorated with trailing digits or symbols, like Password1!, is blocked as well.
+type Common struct {
+	passwords map[string]struct{}
+}
+
+// NewCommon returns a new Common instance using the embedded list.
+func NewCommon() *Common {
+	return newCommon(commonPasswords)
+}
+
+// newCommon parses a list of one password per line, skipping blank lines and comments.
+func newCommon(list string) *Common {
+	c := &Common{
+		passwords: map[string]struct{}{},
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		c.passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return c
+}
+
+// IsBlocked reports whether the password, or the word it starts with, is a common password.
+func (c *Common) IsBlocked(ctx context.Context, password string) (bool, error) {
+	password = strings.ToLower(password)
+	if _, ok := c.passwords[password]; ok {
+		return true, nil
+	}
+
+	// digits and symbols appended only to satisfy character requirements add little strength
+	base := strings.TrimRightFunc(password, func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	if _, ok := c.passwords[base]; ok && base != "" {
+		return true, nil
+	}
+
+	return false, nil
+}
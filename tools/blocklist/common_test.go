@@ -0,0 +1,62 @@
+package blocklist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCommon(t *testing.T) {
+	c := NewCommon()
+	assert.NotEmpty(t, c.passwords)
+	assert.NotContains(t, c.passwords, "# most common passwords, lowercase, one per line")
+}
+
+func TestCommon_IsBlocked(t *testing.T) {
+	ctx := context.Background()
+	c := newCommon("# comment\n\npassword\nqwerty123\n")
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{
+			name:     "exact",
+			password: "password",
+			want:     true,
+		},
+		{
+			name:     "different case",
+			password: "QWERTY123",
+			want:     true,
+		},
+		{
+			name:     "decorated common word",
+			password: "Password1!",
+			want:     true,
+		},
+		{
+			name:     "digits and symbols only",
+			password: "123456!",
+			want:     false,
+		},
+		{
+			name:     "common word in the middle",
+			password: "myPassword1!",
+			want:     false,
+		},
+		{
+			name:     "uncommon",
+			password: "Abcde3#",
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.IsBlocked(ctx, tt.password)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
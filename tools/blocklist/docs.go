@@ -0,0 +1,2 @@
+// Package blocklist rejects passwords that are too common or have appeared in a data breach.
+package blocklist
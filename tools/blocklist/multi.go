@@ -0,0 +1,25 @@
+package blocklist
+
+import (
+	"context"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+// Multi blocks a password blocked by any of its lists, checked in order.
+type Multi []tools.PasswordBlocklistInterface
+
+// IsBlocked stops at the first list blocking the password.
+func (m Multi) IsBlocked(ctx context.Context, password string) (bool, error) {
+	for _, list := range m {
+		blocked, err := list.IsBlocked(ctx, password)
+		if err != nil {
+			return false, err
+		}
+		if blocked {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package blocklist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMulti_IsBlocked(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		prepare func(first, second *tools.MockPasswordBlocklistInterface)
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "error first list",
+			prepare: func(first, second *tools.MockPasswordBlocklistInterface) {
+				first.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, assert.AnError)
+			},
+			want:    false,
+			wantErr: true,
+		},
+		{
+			name: "blocked by first list",
+			prepare: func(first, second *tools.MockPasswordBlocklistInterface) {
+				first.EXPECT().IsBlocked(ctx, "Abcde3#").Return(true, nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name: "blocked by second list",
+			prepare: func(first, second *tools.MockPasswordBlocklistInterface) {
+				first.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+				second.EXPECT().IsBlocked(ctx, "Abcde3#").Return(true, nil)
+			},
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name: "not blocked",
+			prepare: func(first, second *tools.MockPasswordBlocklistInterface) {
+				first.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+				second.EXPECT().IsBlocked(ctx, "Abcde3#").Return(false, nil)
+			},
+			want:    false,
+			wantErr: false,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	first := tools.NewMockPasswordBlocklistInterface(ctrl)
+	second := tools.NewMockPasswordBlocklistInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(first, second)
+			}
+
+			got, err := Multi{first, second}.IsBlocked(ctx, "Abcde3#")
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
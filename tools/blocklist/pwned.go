@@ -0,0 +1,83 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pwnedPrefixLength is the length of the sha-1 prefix a range file is named after.
+const pwnedPrefixLength = 5
+
+// PwnedRange blocks passwords found in an offline copy of the Have I Been Pwned password ranges.
+// The directory holds one file per sha-1 prefix, like 21BD1.txt, listing the rest of each hash
+// with the number of times it was seen in breaches, like 0018A45C4D1DEF81644B54AB7F969B88D65:3,
+// which is what the range api returns for that prefix.
+type PwnedRange struct {
+	dir string
+	// minCount is the number of breaches a password must have appeared in to be blocked.
+	minCount int
+}
+
+type NewPwnedRangeOptions struct {
+	Dir string
+	// MinCount defaults to 1, any password seen in a breach is blocked.
+	MinCount int
+}
+
+// NewPwnedRange returns a new PwnedRange instance reading range files from the directory.
+func NewPwnedRange(opts NewPwnedRangeOptions) *PwnedRange {
+	minCount := opts.MinCount
+	if minCount <= 0 {
+		minCount = 1
+	}
+
+	return &PwnedRange{
+		dir:      opts.Dir,
+		minCount: minCount,
+	}
+}
+
+// IsBlocked reports whether sha-1 of the password appears in its range file often enough.
+// Only the range file of the hash prefix is read, the password itself never leaves memory.
+// A missing range file means none of its hashes has been breached, so a partial copy can be used.
+func (p *PwnedRange) IsBlocked(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:pwnedPrefixLength], hash[pwnedPrefixLength:]
+
+	f, err := os.Open(filepath.Join(p.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, ":")
+		if i < 0 || !strings.EqualFold(line[:i], suffix) {
+			continue
+		}
+
+		var count int
+		count, err = strconv.Atoi(line[i+1:])
+		if err != nil {
+			return false, err
+		}
+
+		return count >= p.minCount, nil
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,74 @@
+package blocklist
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pwnedHash returns sha-1 of the password the way range files spell it.
+func pwnedHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestNewPwnedRange(t *testing.T) {
+	assert.Equal(t, &PwnedRange{
+		dir:      "pwned",
+		minCount: 1,
+	}, NewPwnedRange(NewPwnedRangeOptions{
+		Dir: "pwned",
+	}))
+}
+
+func TestPwnedRange_IsBlocked(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, pwnedHash("Password1!")[:5]+".txt"), []byte(
+		"0018A45C4D1DEF81644B54AB7F969B88D65:3\r\n"+
+			pwnedHash("Password1!")[5:]+":12\r\n",
+	), 0600)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, pwnedHash("Abcde3#")[:5]+".txt"), []byte(
+		pwnedHash("Abcde3#")[5:]+":not a number\n",
+	), 0600)
+	assert.NoError(t, err)
+
+	p := NewPwnedRange(NewPwnedRangeOptions{
+		Dir: dir,
+	})
+
+	// breached
+	got, err := p.IsBlocked(ctx, "Password1!")
+	assert.NoError(t, err)
+	assert.True(t, got)
+
+	// range file missing
+	got, err = p.IsBlocked(ctx, "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.False(t, got)
+
+	// malformed count
+	got, err = p.IsBlocked(ctx, "Abcde3#")
+	assert.Error(t, err)
+	assert.False(t, got)
+
+	// seen fewer times than required
+	p.minCount = 100
+	got, err = p.IsBlocked(ctx, "Password1!")
+	assert.NoError(t, err)
+	assert.False(t, got)
+
+	// directory cannot be read
+	p.dir = filepath.Join(dir, pwnedHash("Password1!")[:5]+".txt")
+	got, err = p.IsBlocked(ctx, "Password1!")
+	assert.Error(t, err)
+	assert.False(t, got)
+}
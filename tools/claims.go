@@ -0,0 +1,46 @@
+package tools
+
+import "time"
+
+// Claims are the registered claims (RFC 7519 section 4.1) of jwt issued by this service.
+// They only identify the user, profile data is served by the profile endpoint instead.
+type Claims struct {
+	// ID is the jti claim, unique per token so that it can be revoked.
+	ID string
+	// Subject is the sub claim, the id of the user.
+	Subject   string
+	Issuer    string
+	Audience  []string
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+	// Roles and Permissions are private claims used for authorization.
+	Roles       []string
+	Permissions []string
+	// TokenVersion is a private claim that must match the current token version of the user,
+	// bumping the version invalidates every jwt issued before.
+	TokenVersion int
+	// Purpose is a private claim of tokens that are not access tokens, like a magic link,
+	// so they are never accepted in place of one. Access tokens leave it empty.
+	Purpose string
+	// Nonce is the nonce claim of OpenID Connect id tokens, echoing the value sent by the client
+	// when asking for the authorization code so it can detect a replayed id token.
+	Nonce string
+	// Scope is the scope claim of RFC 9068, the space separated scopes a client application was granted,
+	// so its access token only reaches what user agreed to share. Other tokens leave it empty.
+	Scope string
+}
+
+// IdentityClaims are the claims of an id token issued by an external OpenID Connect provider,
+// only returned once its signature, issuer, audience, and lifetime have been verified.
+type IdentityClaims struct {
+	// Issuer and Subject together identify the account at the provider, subject alone is only unique per issuer.
+	Issuer  string
+	Subject string
+	// Email is informational, it is never used to find the user an account belongs to.
+	Email         string
+	EmailVerified bool
+	Name          string
+	// Nonce must match the value sent along with the authorization request.
+	Nonce string
+}
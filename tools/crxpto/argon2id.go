@@ -0,0 +1,165 @@
+package crxpto
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idPrefix starts every hash produced by Argon2id.
+const Argon2idPrefix = "$argon2id$"
+
+var (
+	// ErrMismatchedPassword is returned when the password does not match the hash.
+	ErrMismatchedPassword = errors.New("password does not match the hash")
+	// ErrInvalidHash is returned when the hash is not in the expected format.
+	ErrInvalidHash = errors.New("password hash is not valid")
+)
+
+// Argon2idParams are the cost parameters of Argon2id, stored along with every hash
+// so they can be raised later without breaking older hashes.
+type Argon2idParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follows the recommendation of golang.org/x/crypto/argon2 for interactive logins.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// CodeArgon2idParams are cheap enough to compare every recovery code of a user on each attempt.
+// They suit random codes only, which are limited in attempts and lifetime, never passwords.
+var CodeArgon2idParams = Argon2idParams{
+	Memory:      4 * 1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2id hashes passwords with argon2id into the PHC string format,
+// like $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>.
+type Argon2id struct {
+	params Argon2idParams
+	reader io.Reader
+}
+
+// NewArgon2id returns a new Argon2id instance using DefaultArgon2idParams.
+func NewArgon2id() *Argon2id {
+	return &Argon2id{
+		params: DefaultArgon2idParams,
+		reader: rand.Reader,
+	}
+}
+
+// NewArgon2idWithParams returns a new Argon2id instance using the given parameters.
+func NewArgon2idWithParams(params Argon2idParams) *Argon2id {
+	return &Argon2id{
+		params: params,
+		reader: rand.Reader,
+	}
+}
+
+// HashPassword hashes a password with a new random salt.
+func (a *Argon2id) HashPassword(password string) ([]byte, error) {
+	salt := make([]byte, a.params.SaltLength)
+	_, err := io.ReadFull(a.reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.params.Iterations, a.params.Memory, a.params.Parallelism, a.params.KeyLength)
+
+	return []byte(fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2idPrefix,
+		argon2.Version,
+		a.params.Memory,
+		a.params.Iterations,
+		a.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// ComparePassword hashes the password with the parameters and salt of the hashed password,
+// comparing the result in constant time.
+func (a *Argon2id) ComparePassword(hashedPassword []byte, password string) error {
+	params, salt, key, err := decodeArgon2id(string(hashedPassword))
+	if err != nil {
+		return err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+// NeedsRehash returns true if the hash was not produced by argon2id with the current parameters.
+func (a *Argon2id) NeedsRehash(hashedPassword []byte) bool {
+	params, _, _, err := decodeArgon2id(string(hashedPassword))
+	if err != nil {
+		return true
+	}
+
+	return params != a.params
+}
+
+// decodeArgon2id parses the parameters, salt, and key of a hash produced by Argon2id.
+func decodeArgon2id(hash string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	if !strings.HasPrefix(hash, Argon2idPrefix) {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	// "", "argon2id", version, parameters, salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var salt, key []byte
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,132 @@
+package crxpto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewArgon2id(t *testing.T) {
+	assert.NotEmpty(t, NewArgon2id())
+}
+
+func TestNewArgon2idWithParams(t *testing.T) {
+	a := NewArgon2idWithParams(CodeArgon2idParams)
+	got, err := a.HashPassword("123456")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(got), "$argon2id$v=19$m=4096,t=1,p=1$"))
+	assert.NoError(t, a.ComparePassword(got, "123456"))
+	assert.False(t, a.NeedsRehash(got))
+}
+
+// testArgon2idParams keeps tests fast, real hashes use DefaultArgon2idParams.
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2id_HashPassword(t *testing.T) {
+	a := &Argon2id{
+		params: testArgon2idParams,
+	}
+
+	// error read salt
+	a.reader = bytes.NewReader(nil)
+	got, err := a.HashPassword("Abcd9!")
+	assert.Error(t, err)
+	assert.Nil(t, got)
+
+	// fixed salt gives a fixed hash
+	a.reader = bytes.NewReader(bytes.Repeat([]byte{1}, 16))
+	got, err = a.HashPassword("Abcd9!")
+	assert.NoError(t, err)
+	assert.Equal(t, "$argon2id$v=19$m=1024,t=1,p=1$AQEBAQEBAQEBAQEBAQEBAQ$", string(got[:strings.LastIndex(string(got), "$")+1]))
+
+	// success, long passwords are not truncated like bcrypt does
+	a.reader = rand.Reader
+	got, err = a.HashPassword(strings.Repeat("a", 100))
+	assert.NoError(t, err)
+	assert.NoError(t, a.ComparePassword(got, strings.Repeat("a", 100)))
+	assert.ErrorIs(t, a.ComparePassword(got, strings.Repeat("a", 99)), ErrMismatchedPassword)
+}
+
+func TestArgon2id_ComparePassword(t *testing.T) {
+	a := &Argon2id{
+		params: testArgon2idParams,
+		reader: rand.Reader,
+	}
+	hash, _ := a.HashPassword("Abcd9!")
+	tests := []struct {
+		name           string
+		hashedPassword string
+		password       string
+		want           error
+	}{
+		{
+			name:           "not argon2id",
+			hashedPassword: "$2a$10$q5ZnlsdYtSjIdkgesnGFquAeKYZ55YU5f5on/s4KthnjD2pDBldYy",
+			password:       "Abcd9!",
+			want:           ErrInvalidHash,
+		},
+		{
+			name:           "missing part",
+			hashedPassword: "$argon2id$v=19$m=1024,t=1,p=1$AQEBAQEBAQEBAQEBAQEBAQ",
+			password:       "Abcd9!",
+			want:           ErrInvalidHash,
+		},
+		{
+			name:           "unknown version",
+			hashedPassword: strings.Replace(string(hash), "v=19", "v=16", 1),
+			password:       "Abcd9!",
+			want:           ErrInvalidHash,
+		},
+		{
+			name:           "invalid parameters",
+			hashedPassword: strings.Replace(string(hash), "t=1", "t=0", 1),
+			password:       "Abcd9!",
+			want:           ErrInvalidHash,
+		},
+		{
+			name:           "invalid salt",
+			hashedPassword: "$argon2id$v=19$m=1024,t=1,p=1$!!!$AQEBAQEBAQEBAQEBAQEBAQ",
+			password:       "Abcd9!",
+			want:           ErrInvalidHash,
+		},
+		{
+			name:           "password does not match",
+			hashedPassword: string(hash),
+			password:       "Abcd9?",
+			want:           ErrMismatchedPassword,
+		},
+		{
+			name:           "success",
+			hashedPassword: string(hash),
+			password:       "Abcd9!",
+			want:           nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.ComparePassword([]byte(tt.hashedPassword), tt.password)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestArgon2id_NeedsRehash(t *testing.T) {
+	a := &Argon2id{
+		params: testArgon2idParams,
+		reader: rand.Reader,
+	}
+	hash, _ := a.HashPassword("Abcd9!")
+
+	assert.False(t, a.NeedsRehash(hash))
+	assert.True(t, a.NeedsRehash([]byte(strings.Replace(string(hash), "m=1024", "m=512", 1))))
+	assert.True(t, a.NeedsRehash([]byte("$2a$10$q5ZnlsdYtSjIdkgesnGFquAeKYZ55YU5f5on/s4KthnjD2pDBldYy")))
+}
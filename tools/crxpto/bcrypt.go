@@ -25,3 +25,13 @@ func (b *Bcrypt) HashPassword(password string) ([]byte, error) {
 func (b *Bcrypt) ComparePassword(hashedPassword []byte, password string) error {
 	return bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
 }
+
+// NeedsRehash returns true if the hash was produced with a cost other than the current one.
+func (b *Bcrypt) NeedsRehash(hashedPassword []byte) bool {
+	cost, err := bcrypt.Cost(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	return cost != b.cost
+}
@@ -44,3 +44,19 @@ func TestBcrypt_ComparePassword(t *testing.T) {
 	err = b.ComparePassword([]byte("$2a$10$q5ZnlsdYtSjIdkgesnGFquAeKYZ55YU5f5on/s4KthnjD2pDBldYy"), "Abcd9!")
 	assert.NoError(t, err)
 }
+
+func TestBcrypt_NeedsRehash(t *testing.T) {
+	b := &Bcrypt{
+		cost: bcrypt.DefaultCost,
+	}
+
+	// not a bcrypt hash
+	assert.True(t, b.NeedsRehash([]byte("a")))
+
+	// same cost
+	assert.False(t, b.NeedsRehash([]byte("$2a$10$q5ZnlsdYtSjIdkgesnGFquAeKYZ55YU5f5on/s4KthnjD2pDBldYy")))
+
+	// cost has been raised since
+	b.cost = bcrypt.DefaultCost + 2
+	assert.True(t, b.NeedsRehash([]byte("$2a$10$q5ZnlsdYtSjIdkgesnGFquAeKYZ55YU5f5on/s4KthnjD2pDBldYy")))
+}
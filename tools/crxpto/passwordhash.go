@@ -0,0 +1,45 @@
+package crxpto
+
+import (
+	"strings"
+)
+
+// PasswordHash hashes new passwords with Argon2id while still verifying bcrypt hashes stored before,
+// picking the algorithm from the format of each hash.
+type PasswordHash struct {
+	argon2id *Argon2id
+	bcrypt   *Bcrypt
+}
+
+// NewPasswordHash returns a new PasswordHash instance.
+func NewPasswordHash() *PasswordHash {
+	return &PasswordHash{
+		argon2id: NewArgon2id(),
+		bcrypt:   NewBcrypt(),
+	}
+}
+
+// HashPassword hashes a password using argon2id.
+func (h *PasswordHash) HashPassword(password string) ([]byte, error) {
+	return h.argon2id.HashPassword(password)
+}
+
+// ComparePassword compares a hashed password of any supported algorithm with a plain password.
+func (h *PasswordHash) ComparePassword(hashedPassword []byte, password string) error {
+	if isBcrypt(hashedPassword) {
+		return h.bcrypt.ComparePassword(hashedPassword, password)
+	}
+
+	return h.argon2id.ComparePassword(hashedPassword, password)
+}
+
+// NeedsRehash returns true for every hash other than argon2id with the current parameters.
+func (h *PasswordHash) NeedsRehash(hashedPassword []byte) bool {
+	return h.argon2id.NeedsRehash(hashedPassword)
+}
+
+// isBcrypt recognizes the $2a$, $2b$, and $2y$ prefixes of bcrypt hashes.
+func isBcrypt(hashedPassword []byte) bool {
+	hash := string(hashedPassword)
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
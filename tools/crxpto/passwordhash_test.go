@@ -0,0 +1,43 @@
+package crxpto
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewPasswordHash(t *testing.T) {
+	assert.NotEmpty(t, NewPasswordHash())
+}
+
+func TestPasswordHash(t *testing.T) {
+	h := &PasswordHash{
+		argon2id: &Argon2id{
+			params: testArgon2idParams,
+			reader: rand.Reader,
+		},
+		bcrypt: &Bcrypt{
+			cost: bcrypt.DefaultCost,
+		},
+	}
+	legacyHash := []byte("$2a$10$q5ZnlsdYtSjIdkgesnGFquAeKYZ55YU5f5on/s4KthnjD2pDBldYy")
+
+	// new hash is argon2id
+	hash, err := h.HashPassword("Abcd9!")
+	assert.NoError(t, err)
+	assert.Contains(t, string(hash), Argon2idPrefix)
+	assert.NoError(t, h.ComparePassword(hash, "Abcd9!"))
+	assert.Error(t, h.ComparePassword(hash, "Abcd9?"))
+	assert.False(t, h.NeedsRehash(hash))
+
+	// legacy bcrypt hash is still verified, but should be replaced
+	assert.NoError(t, h.ComparePassword(legacyHash, "Abcd9!"))
+	assert.Error(t, h.ComparePassword(legacyHash, "Abcd9?"))
+	assert.True(t, h.NeedsRehash(legacyHash))
+
+	// unknown format
+	assert.Equal(t, ErrInvalidHash, h.ComparePassword([]byte("plain"), "plain"))
+	assert.True(t, h.NeedsRehash([]byte("plain")))
+}
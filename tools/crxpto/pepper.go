@@ -0,0 +1,125 @@
+package crxpto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+// PepperPrefix starts every hash produced by Peppered, followed by the pepper version and the hash itself,
+// like $pepper$1$argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>.
+const PepperPrefix = "$pepper$"
+
+// ErrUnknownPepper is returned when a hash was peppered with a version that is no longer configured.
+var ErrUnknownPepper = errors.New("password hash was peppered with an unknown version")
+
+// Pepper is a secret kept outside the database, mixed into every password before hashing.
+type Pepper struct {
+	// Version tells the pepper of a hash apart, it cannot contain "$".
+	Version string
+	Secret  []byte
+}
+
+// Peppered applies hmac-sha256 of the current pepper to passwords before the underlying hash,
+// so a leaked database alone is not enough to attack the hashes offline.
+// Hashes of retired peppers and hashes stored before peppering are still verified, and reported by NeedsRehash.
+type Peppered struct {
+	hash    tools.HashInterface
+	current Pepper
+	peppers map[string][]byte
+}
+
+type NewPepperedOptions struct {
+	Hash    tools.HashInterface
+	Current Pepper
+	// Retired peppers only verify hashes produced before a rotation.
+	Retired []Pepper
+}
+
+// NewPeppered returns a new Peppered instance, failing when a pepper is empty or its version is not valid.
+func NewPeppered(opts NewPepperedOptions) (*Peppered, error) {
+	p := &Peppered{
+		hash:    opts.Hash,
+		current: opts.Current,
+		peppers: map[string][]byte{},
+	}
+
+	for _, pepper := range append([]Pepper{opts.Current}, opts.Retired...) {
+		if pepper.Version == "" || strings.Contains(pepper.Version, "$") {
+			return nil, fmt.Errorf("pepper version %q is not valid", pepper.Version)
+		}
+		if len(pepper.Secret) == 0 {
+			return nil, fmt.Errorf("pepper version %q has no secret", pepper.Version)
+		}
+		if _, ok := p.peppers[pepper.Version]; ok {
+			return nil, fmt.Errorf("pepper version %q is duplicated", pepper.Version)
+		}
+		p.peppers[pepper.Version] = pepper.Secret
+	}
+
+	return p, nil
+}
+
+// HashPassword hashes a password peppered with the current pepper.
+func (p *Peppered) HashPassword(password string) ([]byte, error) {
+	hashedPassword, err := p.hash.HashPassword(pepper(p.current.Secret, password))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(PepperPrefix + p.current.Version + string(hashedPassword)), nil
+}
+
+// ComparePassword compares a hashed password with a plain password, peppered with the version of the hash.
+// Hash stored before peppering is compared with the plain password as is.
+func (p *Peppered) ComparePassword(hashedPassword []byte, password string) error {
+	version, hash, ok := splitPeppered(hashedPassword)
+	if !ok {
+		return p.hash.ComparePassword(hashedPassword, password)
+	}
+
+	secret, known := p.peppers[version]
+	if !known {
+		return ErrUnknownPepper
+	}
+
+	return p.hash.ComparePassword(hash, pepper(secret, password))
+}
+
+// NeedsRehash returns true if the hash was not peppered with the current pepper,
+// or the underlying hash itself is out of date.
+func (p *Peppered) NeedsRehash(hashedPassword []byte) bool {
+	version, hash, ok := splitPeppered(hashedPassword)
+	if !ok || version != p.current.Version {
+		return true
+	}
+
+	return p.hash.NeedsRehash(hash)
+}
+
+// splitPeppered returns the pepper version and the underlying hash of a hash produced by Peppered.
+func splitPeppered(hashedPassword []byte) (string, []byte, bool) {
+	rest := strings.TrimPrefix(string(hashedPassword), PepperPrefix)
+	if len(rest) == len(hashedPassword) {
+		return "", nil, false
+	}
+
+	i := strings.Index(rest, "$")
+	if i <= 0 {
+		return "", nil, false
+	}
+
+	return rest[:i], []byte(rest[i:]), true
+}
+
+// pepper returns hmac-sha256 of the password, base64 encoded so it fits the 72 bytes bcrypt can hash.
+func pepper(secret []byte, password string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(password))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,162 @@
+package crxpto
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewPeppered(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    NewPepperedOptions
+		wantErr bool
+	}{
+		{
+			name: "missing version",
+			opts: NewPepperedOptions{
+				Current: Pepper{
+					Secret: []byte("secret"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "version with separator",
+			opts: NewPepperedOptions{
+				Current: Pepper{
+					Version: "1$",
+					Secret:  []byte("secret"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retired pepper without secret",
+			opts: NewPepperedOptions{
+				Current: Pepper{
+					Version: "2",
+					Secret:  []byte("secret"),
+				},
+				Retired: []Pepper{
+					{
+						Version: "1",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicated version",
+			opts: NewPepperedOptions{
+				Current: Pepper{
+					Version: "1",
+					Secret:  []byte("secret"),
+				},
+				Retired: []Pepper{
+					{
+						Version: "1",
+						Secret:  []byte("old secret"),
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "success",
+			opts: NewPepperedOptions{
+				Current: Pepper{
+					Version: "2",
+					Secret:  []byte("secret"),
+				},
+				Retired: []Pepper{
+					{
+						Version: "1",
+						Secret:  []byte("old secret"),
+					},
+				},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPeppered(tt.opts)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantErr, got == nil)
+		})
+	}
+}
+
+func TestPeppered(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockHash := tools.NewMockHashInterface(ctrl)
+	p, err := NewPeppered(NewPepperedOptions{
+		Hash: mockHash,
+		Current: Pepper{
+			Version: "2",
+			Secret:  []byte("secret"),
+		},
+		Retired: []Pepper{
+			{
+				Version: "1",
+				Secret:  []byte("old secret"),
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// error hash password
+	mockHash.EXPECT().HashPassword(pepper([]byte("secret"), "Abcd9!")).Return(nil, assert.AnError)
+	got, err := p.HashPassword("Abcd9!")
+	assert.Equal(t, assert.AnError, err)
+	assert.Nil(t, got)
+
+	// new hash carries the current version
+	mockHash.EXPECT().HashPassword(pepper([]byte("secret"), "Abcd9!")).Return([]byte("$argon2id$hash"), nil)
+	got, err = p.HashPassword("Abcd9!")
+	assert.NoError(t, err)
+	assert.Equal(t, "$pepper$2$argon2id$hash", string(got))
+
+	// current pepper
+	mockHash.EXPECT().ComparePassword([]byte("$argon2id$hash"), pepper([]byte("secret"), "Abcd9!")).Return(nil)
+	assert.NoError(t, p.ComparePassword([]byte("$pepper$2$argon2id$hash"), "Abcd9!"))
+	mockHash.EXPECT().NeedsRehash([]byte("$argon2id$hash")).Return(false)
+	assert.False(t, p.NeedsRehash([]byte("$pepper$2$argon2id$hash")))
+
+	// retired pepper still verifies, but should be replaced
+	mockHash.EXPECT().ComparePassword([]byte("$argon2id$hash"), pepper([]byte("old secret"), "Abcd9!")).Return(nil)
+	assert.NoError(t, p.ComparePassword([]byte("$pepper$1$argon2id$hash"), "Abcd9!"))
+	assert.True(t, p.NeedsRehash([]byte("$pepper$1$argon2id$hash")))
+
+	// unknown pepper
+	assert.Equal(t, ErrUnknownPepper, p.ComparePassword([]byte("$pepper$3$argon2id$hash"), "Abcd9!"))
+
+	// hash stored before peppering
+	mockHash.EXPECT().ComparePassword([]byte("$2a$10$hash"), "Abcd9!").Return(nil)
+	assert.NoError(t, p.ComparePassword([]byte("$2a$10$hash"), "Abcd9!"))
+	assert.True(t, p.NeedsRehash([]byte("$2a$10$hash")))
+}
+
+func TestPeppered_bcrypt(t *testing.T) {
+	p, err := NewPeppered(NewPepperedOptions{
+		Hash: &Bcrypt{
+			cost: bcrypt.MinCost,
+		},
+		Current: Pepper{
+			Version: "1",
+			Secret:  []byte("secret"),
+		},
+	})
+	assert.NoError(t, err)
+
+	// peppered password always fits bcrypt, however long the password is
+	got, err := p.HashPassword(string(make([]byte, 100)))
+	assert.NoError(t, err)
+	assert.NoError(t, p.ComparePassword(got, string(make([]byte, 100))))
+	assert.Error(t, p.ComparePassword(got, string(make([]byte, 99))))
+}
@@ -0,0 +1,45 @@
+package crxpto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"math/big"
+)
+
+// Random wraps cryptographically secure random number generator.
+type Random struct {
+	reader io.Reader
+}
+
+// NewRandom returns a new Random instance.
+func NewRandom() *Random {
+	return &Random{
+		reader: rand.Reader,
+	}
+}
+
+// Token returns url-safe base64 encoded string built from size random bytes.
+func (r *Random) Token(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(r.reader, b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Digits returns a string of length decimal digits, each picked uniformly at random.
+func (r *Random) Digits(length int) (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(r.reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b[i] = byte('0' + n.Int64())
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,59 @@
+package crxpto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRandom(t *testing.T) {
+	assert.NotEmpty(t, NewRandom())
+}
+
+func TestRandom_Token(t *testing.T) {
+	r := &Random{}
+
+	// reader runs out of bytes
+	r.reader = bytes.NewReader([]byte{1, 2})
+	got, err := r.Token(4)
+	assert.Error(t, err)
+	assert.Empty(t, got)
+
+	// deterministic reader
+	r.reader = bytes.NewReader([]byte{0xfb, 0xff, 0x01})
+	got, err = r.Token(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "-_8B", got)
+
+	// success (can't assert the value exactly due to its non-deterministic nature)
+	r = NewRandom()
+	got, err = r.Token(32)
+	assert.NoError(t, err)
+	assert.Len(t, got, 43)
+	assert.False(t, strings.ContainsAny(got, "+/="))
+}
+
+func TestRandom_Digits(t *testing.T) {
+	r := &Random{}
+
+	// reader runs out of bytes
+	r.reader = bytes.NewReader([]byte{1})
+	got, err := r.Digits(2)
+	assert.Error(t, err)
+	assert.Empty(t, got)
+
+	// deterministic reader
+	r.reader = bytes.NewReader([]byte{3, 7, 0})
+	got, err = r.Digits(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "370", got)
+
+	// success (can't assert the value exactly due to its non-deterministic nature)
+	r = NewRandom()
+	got, err = r.Digits(6)
+	assert.NoError(t, err)
+	assert.Len(t, got, 6)
+	assert.Equal(t, "", strings.Trim(got, "0123456789"))
+}
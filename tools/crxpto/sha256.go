@@ -0,0 +1,13 @@
+package crxpto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SHA256 returns hex encoded sha256 digest of data.
+// Only suitable for high entropy secrets like random tokens, use HashInterface for passwords.
+func SHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
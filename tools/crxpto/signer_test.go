@@ -0,0 +1,93 @@
+package crxpto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHMACSigner(t *testing.T) {
+	_, err := NewHMACSigner(nil)
+	assert.Error(t, err)
+
+	s, err := NewHMACSigner([]byte("secret"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, s)
+}
+
+func TestHMACSigner(t *testing.T) {
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	s, err := NewHMACSigner([]byte("secret"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	s.timeNow = func() time.Time {
+		return now
+	}
+
+	token, err := s.Sign("email_verification:1:john@example.com", now.Add(time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "1691240400.ZW1haWxfdmVyaWZpY2F0aW9uOjE6am9obkBleGFtcGxlLmNvbQ.", token[:len(token)-43])
+
+	other, err := NewHMACSigner([]byte("other secret"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	otherToken, err := other.Sign("email_verification:1:john@example.com", now.Add(time.Hour))
+	if !assert.NoError(t, err) {
+		return
+	}
+	expiredToken, err := s.Sign("email_verification:1:john@example.com", now)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "empty token",
+			token:   "",
+			wantErr: ErrInvalidSignedToken,
+		},
+		{
+			name:    "malformed signature",
+			token:   "1691240400.ZW1haWw.!",
+			wantErr: ErrInvalidSignedToken,
+		},
+		{
+			name:    "signed by another secret",
+			token:   otherToken,
+			wantErr: ErrInvalidSignedToken,
+		},
+		{
+			name:    "expiry tampered with",
+			token:   "1791240400" + token[10:],
+			wantErr: ErrInvalidSignedToken,
+		},
+		{
+			name:    "expired",
+			token:   expiredToken,
+			wantErr: ErrInvalidSignedToken,
+		},
+		{
+			name:    "success",
+			token:   token,
+			want:    "email_verification:1:john@example.com",
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Verify(tt.token)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
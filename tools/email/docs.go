@@ -0,0 +1,2 @@
+// Package email delivers emails to addresses.
+package email
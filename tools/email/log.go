@@ -0,0 +1,33 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogSender is a fake sender for development, it writes every email to w instead of delivering it.
+type LogSender struct {
+	mu      sync.Mutex
+	w       io.Writer
+	timeNow func() time.Time
+}
+
+// NewLogSender returns a new LogSender writing to w, like os.Stdout or an opened file.
+func NewLogSender(w io.Writer) *LogSender {
+	return &LogSender{
+		w:       w,
+		timeNow: time.Now,
+	}
+}
+
+// Send writes a single line containing the time, the address, the subject, and the body.
+func (s *LogSender) Send(ctx context.Context, email, subject, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s email to %s: %q %q\n", s.timeNow().Format(time.RFC3339), email, subject, body)
+	return err
+}
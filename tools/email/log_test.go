@@ -0,0 +1,43 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, assert.AnError
+}
+
+func TestNewLogSender(t *testing.T) {
+	assert.NotEmpty(t, NewLogSender(&bytes.Buffer{}))
+}
+
+func TestLogSender_Send(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+
+	// error write
+	s := NewLogSender(errorWriter{})
+	err := s.Send(ctx, "john@example.com", "hello", "hello")
+	assert.Error(t, err)
+
+	// success
+	buf := &bytes.Buffer{}
+	s = NewLogSender(buf)
+	s.timeNow = func() time.Time {
+		return now
+	}
+	err = s.Send(ctx, "john@example.com", "Verify your email", "Open this link:\nhttps://example.com")
+	assert.NoError(t, err)
+	err = s.Send(ctx, "jane@example.com", "hello", "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "2023-08-05T12:00:00Z email to john@example.com: \"Verify your email\" \"Open this link:\\nhttps://example.com\"\n"+
+		"2023-08-05T12:00:00Z email to jane@example.com: \"hello\" \"hello\"\n", buf.String())
+}
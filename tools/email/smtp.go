@@ -0,0 +1,82 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPSender delivers emails through an smtp server, like a local stand-in such as MailHog during development.
+type SMTPSender struct {
+	addr     string
+	from     string
+	auth     smtp.Auth
+	timeNow  func() time.Time
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+type NewSMTPSenderOptions struct {
+	// Addr is host:port of the smtp server.
+	Addr string
+	// From is the address every email is sent from.
+	From string
+	// Username and Password authenticate with PLAIN mechanism when set,
+	// which is only allowed over tls or to localhost.
+	Username string
+	Password string
+}
+
+// NewSMTPSender returns a new SMTPSender, failing when the server address or the sender address is missing.
+func NewSMTPSender(opts NewSMTPSenderOptions) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.From == "" {
+		return nil, errors.New("smtp sender address must not be empty")
+	}
+
+	var auth smtp.Auth
+	if opts.Username != "" {
+		auth = smtp.PlainAuth("", opts.Username, opts.Password, host)
+	}
+
+	return &SMTPSender{
+		addr:     opts.Addr,
+		from:     opts.From,
+		auth:     auth,
+		timeNow:  time.Now,
+		sendMail: smtp.SendMail,
+	}, nil
+}
+
+// Send delivers a plain text email to the address.
+func (s *SMTPSender) Send(ctx context.Context, email, subject, body string) error {
+	// a line break in a header would let the rest of the value become headers of its own
+	if strings.ContainsAny(email, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("email address and subject must be a single line")
+	}
+
+	return s.sendMail(s.addr, s.auth, s.from, []string{email}, s.message(email, subject, body))
+}
+
+// message returns the email in the format expected by smtp, lines ending with CRLF.
+func (s *SMTPSender) message(email, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", s.timeNow().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,188 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpServer is a local stand-in for an smtp server, it accepts a single email and keeps what it received.
+type smtpServer struct {
+	listener net.Listener
+	// auth is the credential of AUTH PLAIN command, empty when the client did not authenticate.
+	auth     string
+	from     string
+	to       []string
+	data     string
+	received chan struct{}
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	s := &smtpServer{
+		listener: listener,
+		received: make(chan struct{}),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	defer close(s.received)
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"):
+			reply("250-localhost")
+			reply("250-8BITMIME")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(command, "AUTH PLAIN"):
+			s.auth = strings.TrimSpace(line[len("AUTH PLAIN"):])
+			reply("235 authenticated")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.from = smtpPath(line)
+			reply("250 ok")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.to = append(s.to, smtpPath(line))
+			reply("250 ok")
+		case command == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.data = data.String()
+			reply("250 queued")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// smtpPath returns the address between angle brackets of MAIL FROM and RCPT TO commands.
+func smtpPath(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func TestNewSMTPSender(t *testing.T) {
+	_, err := NewSMTPSender(NewSMTPSenderOptions{
+		Addr: "localhost",
+		From: "noreply@example.com",
+	})
+	assert.Error(t, err)
+
+	_, err = NewSMTPSender(NewSMTPSenderOptions{
+		Addr: "localhost:1025",
+	})
+	assert.Error(t, err)
+
+	s, err := NewSMTPSender(NewSMTPSenderOptions{
+		Addr:     "localhost:1025",
+		From:     "noreply@example.com",
+		Username: "portal",
+		Password: "secret",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, s.auth)
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+
+	// header injection
+	s, err := NewSMTPSender(NewSMTPSenderOptions{
+		Addr: "127.0.0.1:1",
+		From: "noreply@example.com",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = s.Send(ctx, "john@example.com\r\nBcc: jane@example.com", "hello", "hello")
+	assert.Error(t, err)
+
+	// server is not reachable
+	err = s.Send(ctx, "john@example.com", "hello", "hello")
+	assert.Error(t, err)
+
+	// success
+	server := newSMTPServer(t)
+	s, err = NewSMTPSender(NewSMTPSenderOptions{
+		Addr:     server.addr(),
+		From:     "noreply@example.com",
+		Username: "portal",
+		Password: "secret",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	s.timeNow = func() time.Time {
+		return now
+	}
+	err = s.Send(ctx, "john@example.com", "Log in to your account", "Open this link:\nhttps://example.com")
+	assert.NoError(t, err)
+
+	<-server.received
+	assert.Equal(t, "AHBvcnRhbABzZWNyZXQ=", server.auth)
+	assert.Equal(t, "noreply@example.com", server.from)
+	assert.Equal(t, []string{"john@example.com"}, server.to)
+	assert.Equal(t, "From: noreply@example.com\r\n"+
+		"To: john@example.com\r\n"+
+		"Subject: Log in to your account\r\n"+
+		"Date: Sat, 05 Aug 2023 12:00:00 +0000\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n"+
+		"Content-Transfer-Encoding: 8bit\r\n"+
+		"\r\n"+
+		"Open this link:\r\n"+
+		"https://example.com\r\n", server.data)
+}
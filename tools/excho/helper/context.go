@@ -1,7 +1,10 @@
 package helper
 
 import (
+	"time"
+
 	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/tools"
 	"github.com/leguminosa/profile-open-portal/tools/converter"
 )
 
@@ -12,3 +15,43 @@ func UserIDFromContext(c echo.Context) int {
 func SetUserIDToContext(c echo.Context, userID interface{}) {
 	c.Set("user_id", converter.ToInt(userID))
 }
+
+// TokenIDFromContext returns jti claim of the jwt used to authenticate current request.
+func TokenIDFromContext(c echo.Context) string {
+	tokenID, _ := c.Get("token_id").(string)
+	return tokenID
+}
+
+func SetTokenIDToContext(c echo.Context, tokenID string) {
+	c.Set("token_id", tokenID)
+}
+
+// TokenExpiresAtFromContext returns exp claim of the jwt used to authenticate current request.
+func TokenExpiresAtFromContext(c echo.Context) time.Time {
+	expiresAt, _ := c.Get("token_expires_at").(time.Time)
+	return expiresAt
+}
+
+func SetTokenExpiresAtToContext(c echo.Context, expiresAt time.Time) {
+	c.Set("token_expires_at", expiresAt)
+}
+
+// PermissionsFromContext returns permissions claim of the jwt used to authenticate current request.
+func PermissionsFromContext(c echo.Context) []string {
+	permissions, _ := c.Get("permissions").([]string)
+	return permissions
+}
+
+func SetPermissionsToContext(c echo.Context, permissions []string) {
+	c.Set("permissions", permissions)
+}
+
+// ClaimsFromContext returns claims of the jwt that authenticated current request, nil when it is not authenticated yet.
+func ClaimsFromContext(c echo.Context) *tools.Claims {
+	claims, _ := c.Get("claims").(*tools.Claims)
+	return claims
+}
+
+func SetClaimsToContext(c echo.Context, claims *tools.Claims) {
+	c.Set("claims", claims)
+}
@@ -2,8 +2,10 @@ package helper
 
 import (
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/tools"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,3 +37,120 @@ func TestSetUserIDToContext(t *testing.T) {
 
 	assert.Equal(t, 1, UserIDFromContext(c))
 }
+
+func TestTokenIDFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		c    echo.Context
+		want string
+	}{
+		{
+			name: "success",
+			c:    newMockEchoContext(nil),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TokenIDFromContext(tt.c)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetTokenIDToContext(t *testing.T) {
+	c := newMockEchoContext(nil)
+
+	SetTokenIDToContext(c, "token-id")
+
+	assert.Equal(t, "token-id", TokenIDFromContext(c))
+}
+
+func TestTokenExpiresAtFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		c    echo.Context
+		want time.Time
+	}{
+		{
+			name: "success",
+			c:    newMockEchoContext(nil),
+			want: time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TokenExpiresAtFromContext(tt.c)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetTokenExpiresAtToContext(t *testing.T) {
+	c := newMockEchoContext(nil)
+
+	expiresAt := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	SetTokenExpiresAtToContext(c, expiresAt)
+
+	assert.Equal(t, expiresAt, TokenExpiresAtFromContext(c))
+}
+
+func TestPermissionsFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		c    echo.Context
+		want []string
+	}{
+		{
+			name: "success",
+			c:    newMockEchoContext(nil),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PermissionsFromContext(tt.c)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetPermissionsToContext(t *testing.T) {
+	c := newMockEchoContext(nil)
+
+	SetPermissionsToContext(c, []string{"profile:read"})
+
+	assert.Equal(t, []string{"profile:read"}, PermissionsFromContext(c))
+}
+
+func TestClaimsFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		c    echo.Context
+		want *tools.Claims
+	}{
+		{
+			name: "not authenticated",
+			c:    newMockEchoContext(nil),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClaimsFromContext(tt.c)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetClaimsToContext(t *testing.T) {
+	c := newMockEchoContext(nil)
+
+	SetClaimsToContext(c, &tools.Claims{
+		ID: "token-id",
+	})
+
+	assert.Equal(t, &tools.Claims{
+		ID: "token-id",
+	}, ClaimsFromContext(c))
+}
@@ -10,6 +10,10 @@ func OK(c echo.Context, i interface{}) error {
 	return JSON(c, http.StatusOK, i)
 }
 
+func Accepted(c echo.Context, i interface{}) error {
+	return JSON(c, http.StatusAccepted, i)
+}
+
 func BadRequest(c echo.Context, message string) error {
 	return JSON(c, http.StatusBadRequest, map[string]interface{}{
 		"message": message,
@@ -22,12 +26,24 @@ func Forbidden(c echo.Context, message string) error {
 	})
 }
 
+func NotFound(c echo.Context, message string) error {
+	return JSON(c, http.StatusNotFound, map[string]interface{}{
+		"message": message,
+	})
+}
+
 func Conflict(c echo.Context, message string) error {
 	return JSON(c, http.StatusConflict, map[string]interface{}{
 		"message": message,
 	})
 }
 
+func TooManyRequests(c echo.Context, message string) error {
+	return JSON(c, http.StatusTooManyRequests, map[string]interface{}{
+		"message": message,
+	})
+}
+
 func InternalServerError(c echo.Context, message string) error {
 	return JSON(c, http.StatusInternalServerError, map[string]interface{}{
 		"message": message,
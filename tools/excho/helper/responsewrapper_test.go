@@ -36,6 +36,37 @@ func TestOK(t *testing.T) {
 	}
 }
 
+func TestAccepted(t *testing.T) {
+	c := newMockEchoContext(nil)
+	tests := []struct {
+		name    string
+		i       interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			i: map[string]interface{}{
+				"message": "accepted",
+			},
+			want:    "{\"message\":\"accepted\"}\n",
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Accepted(c, tt.i)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			assert.Equal(t, 202, c.Response().Status)
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
 func TestBadRequest(t *testing.T) {
 	c := newMockEchoContext(nil)
 	tests := []struct {
@@ -92,6 +123,34 @@ func TestForbidden(t *testing.T) {
 	}
 }
 
+func TestNotFound(t *testing.T) {
+	c := newMockEchoContext(nil)
+	tests := []struct {
+		name    string
+		message string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "success",
+			message: "not found",
+			want:    "{\"message\":\"not found\"}\n",
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NotFound(c, tt.message)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
 func TestConflict(t *testing.T) {
 	c := newMockEchoContext(nil)
 	tests := []struct {
@@ -120,6 +179,34 @@ func TestConflict(t *testing.T) {
 	}
 }
 
+func TestTooManyRequests(t *testing.T) {
+	c := newMockEchoContext(nil)
+	tests := []struct {
+		name    string
+		message string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "success",
+			message: "slow down",
+			want:    "{\"message\":\"slow down\"}\n",
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := TooManyRequests(c, tt.message)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			got := c.getResponseBody()
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
 func TestInternalServerError(t *testing.T) {
 	c := newMockEchoContext(nil)
 	tests := []struct {
@@ -0,0 +1,72 @@
+package federation
+
+import (
+	"encoding/json"
+)
+
+// idTokenClaims is the json payload of an id token.
+type idTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	IssuedAt        int64    `json:"iat"`
+	ExpiresAt       int64    `json:"exp"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   boolish  `json:"email_verified"`
+	Name            string   `json:"name"`
+}
+
+// Valid always passes, Provider.verifyIDToken checks the claims
+// against its own clock, issuer, and client id after the signature is verified.
+func (c *idTokenClaims) Valid() error {
+	return nil
+}
+
+// audience is either a single string or an array of strings in json as allowed by RFC 7519.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = audience(multiple)
+
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// boolish is a boolean that some providers send as a "true" or "false" string.
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = boolish(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*b = boolish(text == "true")
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package federation
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_idTokenClaims_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    idTokenClaims
+		wantErr bool
+	}{
+		{
+			name:    "invalid audience",
+			data:    `{"aud":1}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid email_verified",
+			data:    `{"email_verified":1}`,
+			wantErr: true,
+		},
+		{
+			name: "single audience with boolean email_verified",
+			data: `{"iss":"https://accounts.google.com","sub":"1076","aud":"portal","email_verified":true}`,
+			want: idTokenClaims{
+				Issuer:        "https://accounts.google.com",
+				Subject:       "1076",
+				Audience:      audience{"portal"},
+				EmailVerified: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "multiple audiences with string email_verified",
+			data: `{"aud":["portal","other-client"],"azp":"portal","email_verified":"true"}`,
+			want: idTokenClaims{
+				Audience:        audience{"portal", "other-client"},
+				AuthorizedParty: "portal",
+				EmailVerified:   true,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got idTokenClaims
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, got.Valid())
+		})
+	}
+}
+
+func Test_audience_contains(t *testing.T) {
+	a := audience{"portal", "other-client"}
+	assert.True(t, a.contains("portal"))
+	assert.False(t, a.contains("wiki"))
+}
@@ -0,0 +1,2 @@
+// Package federation logs users in with external OpenID Connect providers, like Google or Microsoft.
+package federation
@@ -0,0 +1,115 @@
+package federation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"testing"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseJSONWebKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32)))
+	y := base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32)))
+
+	tests := []struct {
+		name    string
+		jwk     tools.JSONWebKey
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name: "unsupported key type",
+			jwk: tools.JSONWebKey{
+				KeyType: "oct",
+			},
+			wantErr: true,
+		},
+		{
+			name: "rsa key without modulus",
+			jwk: tools.JSONWebKey{
+				KeyType: "RSA",
+				E:       "AQAB",
+			},
+			wantErr: true,
+		},
+		{
+			name: "rsa key with invalid exponent",
+			jwk: tools.JSONWebKey{
+				KeyType: "RSA",
+				N:       rsaJSONWebKey("", &rsaKey.PublicKey).N,
+				E:       "!",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "rsa key",
+			jwk:     rsaJSONWebKey("key-1", &rsaKey.PublicKey),
+			want:    &rsaKey.PublicKey,
+			wantErr: false,
+		},
+		{
+			name: "unsupported curve",
+			jwk: tools.JSONWebKey{
+				KeyType: "EC",
+				Curve:   "P-384",
+				X:       x,
+				Y:       y,
+			},
+			wantErr: true,
+		},
+		{
+			name: "ec key with short coordinate",
+			jwk: tools.JSONWebKey{
+				KeyType: "EC",
+				Curve:   "P-256",
+				X:       x,
+				Y:       "AQAB",
+			},
+			wantErr: true,
+		},
+		{
+			name: "ec key off the curve",
+			jwk: tools.JSONWebKey{
+				KeyType: "EC",
+				Curve:   "P-256",
+				X:       x,
+				Y:       x,
+			},
+			wantErr: true,
+		},
+		{
+			name: "ec key",
+			jwk: tools.JSONWebKey{
+				KeyType: "EC",
+				Curve:   "P-256",
+				X:       x,
+				Y:       y,
+			},
+			want:    &ecKey.PublicKey,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONWebKey(tt.jwk)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,405 @@
+package federation
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+const (
+	// defaultHTTPTimeout bounds every request to the provider, a login should not hang on a slow provider.
+	defaultHTTPTimeout = time.Second * 10
+	// keysRefreshInterval limits how often signing keys are fetched again for an unknown kid,
+	// so tokens signed by made up keys cannot flood the provider with requests.
+	keysRefreshInterval = time.Minute
+	// clockSkew is how far the clock of the provider may be off from ours.
+	clockSkew = time.Minute
+	// maxResponseSize bounds the body read from the provider.
+	maxResponseSize = 1 << 20
+)
+
+// DefaultScopes asks for the id token along with the email and name of the account.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+var (
+	// ErrInvalidIDToken is returned when the id token of the provider cannot be trusted.
+	ErrInvalidIDToken = errors.New("id token is not valid")
+
+	// validMethods are the algorithms id tokens may be signed with, the alg header picks one of them.
+	// Symmetric algorithms are left out, the client secret must never verify a token.
+	validMethods = []string{
+		jwt.SigningMethodRS256.Alg(),
+		jwt.SigningMethodES256.Alg(),
+	}
+)
+
+// Provider logs users in with an external OpenID Connect provider using the authorization code flow with PKCE.
+// Endpoints are discovered from the issuer on first use, and signing keys are fetched again
+// once an id token is signed by a key that is not known yet, so the provider can rotate its keys.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	httpClient   *http.Client
+	timeNow      func() time.Time
+
+	// mu guards the state below, it is never held while waiting on the provider
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+	keysFetch     *keysFetch
+}
+
+// keysFetch is a request for the signing keys in flight, shared by every caller looking for a key meanwhile.
+type keysFetch struct {
+	done chan struct{}
+	err  error
+}
+
+type NewProviderOptions struct {
+	// Issuer is the url the discovery document is read from, like https://accounts.google.com.
+	// It must match the issuer of the document and of every id token exactly.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the page the provider sends user back to, it must be registered at the provider.
+	RedirectURL string
+	// Scopes defaults to DefaultScopes when not set.
+	Scopes []string
+	// HTTPClient defaults to a client timing out after 10 seconds.
+	HTTPClient *http.Client
+}
+
+// metadata is the part of the discovery document of OpenID Connect Discovery 1.0 that is used.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type jwksResponse struct {
+	Keys []tools.JSONWebKey `json:"keys"`
+}
+
+// NewProvider returns a new Provider of the issuer. Nothing is requested from the provider until it is used.
+func NewProvider(opts NewProviderOptions) (*Provider, error) {
+	if opts.Issuer == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, errors.New("issuer, client id, and redirect url of identity provider are required")
+	}
+
+	scopes := opts.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: defaultHTTPTimeout,
+		}
+	}
+
+	return &Provider{
+		issuer:       opts.Issuer,
+		clientID:     opts.ClientID,
+		clientSecret: opts.ClientSecret,
+		redirectURL:  opts.RedirectURL,
+		scopes:       scopes,
+		httpClient:   httpClient,
+		timeNow:      time.Now,
+	}, nil
+}
+
+// AuthCodeURL returns the authorization endpoint of the provider carrying the request of RFC 6749 and RFC 7636.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var authURL *url.URL
+	authURL, err = url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.clientID)
+	query.Set("redirect_uri", p.redirectURL)
+	query.Set("scope", strings.Join(p.scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems the code at the token endpoint, authenticating with the client secret in the form when set.
+// Only the id token of the response is used, the provider is never called on behalf of user afterwards.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*tools.IdentityClaims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {codeVerifier},
+	}
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token tokenResponse
+	status, err := p.do(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint responded %d: %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token endpoint responded without id token")
+	}
+
+	return p.verifyIDToken(ctx, md, token.IDToken)
+}
+
+// verifyIDToken checks the id token as required by OpenID Connect Core 1.0 section 3.1.3.7,
+// except for the nonce, which is left to the caller who knows what was sent.
+func (p *Provider) verifyIDToken(ctx context.Context, md *metadata, rawIDToken string) (*tools.IdentityClaims, error) {
+	var (
+		claims = &idTokenClaims{}
+		parser = &jwt.Parser{
+			ValidMethods: validMethods,
+		}
+		keyErr error
+	)
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+
+		var key crypto.PublicKey
+		key, keyErr = p.publicKey(ctx, md, keyID)
+		return key, keyErr
+	})
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := p.timeNow()
+	if claims.Issuer != p.issuer || !claims.Audience.contains(p.clientID) {
+		return nil, ErrInvalidIDToken
+	}
+	// the authorized party must be us when the token is meant for others as well
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.clientID {
+		return nil, ErrInvalidIDToken
+	}
+	if claims.Subject == "" || !now.Before(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return nil, ErrInvalidIDToken
+	}
+	if claims.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, ErrInvalidIDToken
+	}
+
+	return &tools.IdentityClaims{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Nonce:         claims.Nonce,
+	}, nil
+}
+
+// discover returns the discovery document of the issuer, it is only fetched until a request for it succeeds.
+// Callers racing on first use may each fetch the document, the first one stored is kept.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	md := p.metadata
+	p.mu.Unlock()
+	if md != nil {
+		return md, nil
+	}
+
+	md = &metadata{}
+	err := p.getJSON(ctx, strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", md)
+	if err != nil {
+		return nil, err
+	}
+
+	// a document claiming another issuer could be serving someone else's keys
+	if md.Issuer != p.issuer {
+		return nil, fmt.Errorf("discovery document is of issuer %q instead of %q", md.Issuer, p.issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing an endpoint")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata == nil {
+		p.metadata = md
+	}
+	return p.metadata, nil
+}
+
+// publicKey returns the signing key of the provider with the key id, fetching the keys again if it is not known.
+// A token without kid is only accepted while the provider has a single key.
+func (p *Provider) publicKey(ctx context.Context, md *metadata, keyID string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	if key, ok := p.lookupKey(keyID); ok {
+		p.mu.Unlock()
+		return key, nil
+	}
+
+	fetch := p.keysFetch
+	if fetch == nil {
+		if p.keys != nil && p.timeNow().Before(p.keysFetchedAt.Add(keysRefreshInterval)) {
+			p.mu.Unlock()
+			return nil, ErrInvalidIDToken
+		}
+
+		fetch = &keysFetch{
+			done: make(chan struct{}),
+		}
+		p.keysFetch = fetch
+		p.mu.Unlock()
+
+		p.fetchKeys(ctx, md, fetch)
+	} else {
+		p.mu.Unlock()
+
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if fetch.err != nil {
+		return nil, fetch.err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(keyID); ok {
+		return key, nil
+	}
+	return nil, ErrInvalidIDToken
+}
+
+// fetchKeys requests the signing keys for the fetch without holding the lock, storing them once they arrive.
+// Keys are kept as they were when the request fails, so it can be tried again right away.
+func (p *Provider) fetchKeys(ctx context.Context, md *metadata, fetch *keysFetch) {
+	defer close(fetch.done)
+
+	var jwks jwksResponse
+	fetch.err = p.getJSON(ctx, md.JWKSURI, &jwks)
+
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		// keys of unsupported types are skipped, the provider may publish more than we verify with
+		key, keyErr := parseJSONWebKey(jwk)
+		if keyErr != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keysFetch = nil
+	if fetch.err == nil {
+		p.keys = keys
+		p.keysFetchedAt = p.timeNow()
+	}
+}
+
+// lookupKey returns the known key with the key id. Caller must hold the lock.
+func (p *Provider) lookupKey(keyID string) (crypto.PublicKey, bool) {
+	if keyID == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[keyID]
+	return key, ok
+}
+
+// getJSON decodes the response of a get request, failing on any status other than 200.
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	var status int
+	status, err = p.do(req, v)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%s responded %d", endpoint, status)
+	}
+
+	return nil
+}
+
+// do sends the request and decodes the json body into v whatever the status is,
+// so errors described by the provider can be read.
+func (p *Provider) do(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+	if err != nil && resp.StatusCode == http.StatusOK {
+		return 0, err
+	}
+
+	return resp.StatusCode, nil
+}
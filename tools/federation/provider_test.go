@@ -0,0 +1,580 @@
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testClientID     = "portal"
+	testClientSecret = "some-secret"
+	testRedirectURL  = "http://localhost:8080/login/federated/callback"
+	testVerifier     = "dBjftJeZ4CVP-mJ92K1Ugwq6Ap68KTxmCNbx9N3T4Ya"
+	testChallenge    = "0-jU3mxuhm2_pFNlPMSCemHaRsAaJYfGoWAEFO_aVIQ"
+)
+
+// identityProvider is a local stand-in for an OpenID Connect provider. Its authorization endpoint
+// logs in the account of subject without asking anything, redirecting back with a code right away.
+type identityProvider struct {
+	server *httptest.Server
+
+	mu sync.Mutex
+	// key signs id tokens and is published along with the retired keys.
+	key      *rsa.PrivateKey
+	keyID    string
+	retired  map[string]*rsa.PrivateKey
+	subject  string
+	email    string
+	codes    map[string]authorization
+	nextCode int
+	// claims lets a test alter the claims of the next id token.
+	claims func(claims jwt.MapClaims)
+	// forgedKey signs id tokens in place of key when set, still claiming the kid of key.
+	forgedKey *rsa.PrivateKey
+	// issuer of the discovery document, defaults to the url of the server.
+	issuer       string
+	jwksRequests int
+	// jwksHeld holds requests for the keys until it is closed, announcing each of them on jwksStarted.
+	jwksHeld    chan struct{}
+	jwksStarted chan struct{}
+}
+
+// authorization is what the stand-in remembers of an authorization request until its code is redeemed.
+type authorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+}
+
+func newIdentityProvider(t *testing.T) *identityProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &identityProvider{
+		key:     key,
+		keyID:   "key-1",
+		retired: map[string]*rsa.PrivateKey{},
+		subject: "10769150350006150715113082367",
+		email:   "john@example.com",
+		codes:   map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *identityProvider) url() string {
+	return idp.server.URL
+}
+
+// rotate signs id tokens with a new key, keeping the current one published as retired.
+func (idp *identityProvider) rotate(t *testing.T, keyID string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.retired[idp.keyID] = idp.key
+	idp.key = key
+	idp.keyID = keyID
+}
+
+func (idp *identityProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	issuer := idp.issuer
+	idp.mu.Unlock()
+	if issuer == "" {
+		issuer = idp.url()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                 issuer,
+		"authorization_endpoint": idp.url() + "/authorize",
+		"token_endpoint":         idp.url() + "/token",
+		"jwks_uri":               idp.url() + "/jwks",
+	})
+}
+
+func (idp *identityProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != testClientID ||
+		query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	idp.nextCode++
+	code := "code-" + strconv.Itoa(idp.nextCode)
+	idp.codes[code] = authorization{
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+	}
+	idp.mu.Unlock()
+
+	redirectURI, _ := url.Parse(query.Get("redirect_uri"))
+	redirectQuery := redirectURI.Query()
+	redirectQuery.Set("code", code)
+	redirectQuery.Set("state", query.Get("state"))
+	redirectURI.RawQuery = redirectQuery.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (idp *identityProvider) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	if r.PostFormValue("client_id") != testClientID || r.PostFormValue("client_secret") != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error": "invalid_client",
+		})
+		return
+	}
+
+	// codes are single-use and bound to the redirect uri and the PKCE challenge
+	auth, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != auth.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "code is not valid",
+		})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.url(),
+		"sub":            idp.subject,
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          auth.nonce,
+		"email":          idp.email,
+		"email_verified": true,
+		"name":           "John Doe",
+	}
+	if idp.claims != nil {
+		idp.claims(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.keyID
+	signingKey := idp.key
+	if idp.forgedKey != nil {
+		signingKey = idp.forgedKey
+	}
+	idToken, err := token.SignedString(signingKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "some-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (idp *identityProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	held, started := idp.jwksHeld, idp.jwksStarted
+	idp.mu.Unlock()
+	if held != nil {
+		started <- struct{}{}
+		<-held
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	idp.jwksRequests++
+	keys := []tools.JSONWebKey{
+		rsaJSONWebKey(idp.keyID, &idp.key.PublicKey),
+		// keys of other types and uses are published by real providers as well
+		{
+			KeyType: "oct",
+			Use:     "sig",
+			KeyID:   "symmetric",
+		},
+		{
+			KeyType: "RSA",
+			Use:     "enc",
+			KeyID:   "encryption",
+		},
+	}
+	for keyID, key := range idp.retired {
+		keys = append(keys, rsaJSONWebKey(keyID, &key.PublicKey))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": keys,
+	})
+}
+
+func rsaJSONWebKey(keyID string, key *rsa.PublicKey) tools.JSONWebKey {
+	return tools.JSONWebKey{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     keyID,
+		N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// login follows the authorization url like a browser would, returning the code and state of the redirect back.
+func login(t *testing.T, authURL string) (string, string) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func newTestProvider(t *testing.T, idp *identityProvider) *Provider {
+	p, err := NewProvider(NewProviderOptions{
+		Issuer:       idp.url(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestNewProvider(t *testing.T) {
+	_, err := NewProvider(NewProviderOptions{
+		Issuer:      "https://accounts.google.com",
+		RedirectURL: testRedirectURL,
+	})
+	assert.Error(t, err)
+
+	p, err := NewProvider(NewProviderOptions{
+		Issuer:      "https://accounts.google.com",
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultScopes, p.scopes)
+	assert.NotNil(t, p.httpClient)
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	ctx := context.Background()
+
+	// provider is not reachable
+	p, err := NewProvider(NewProviderOptions{
+		Issuer:      "http://127.0.0.1:1",
+		ClientID:    testClientID,
+		RedirectURL: testRedirectURL,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = p.AuthCodeURL(ctx, "some-state", "some-nonce", testChallenge)
+	assert.Error(t, err)
+
+	// discovery document of another issuer
+	idp := newIdentityProvider(t)
+	idp.issuer = "https://accounts.example.com"
+	p = newTestProvider(t, idp)
+	_, err = p.AuthCodeURL(ctx, "some-state", "some-nonce", testChallenge)
+	assert.Error(t, err)
+
+	// success
+	idp.issuer = ""
+	p.scopes = []string{"openid", "email"}
+	got, err := p.AuthCodeURL(ctx, "some-state", "some-nonce", testChallenge)
+	assert.NoError(t, err)
+	assert.Equal(t, idp.url()+"/authorize?"+
+		"client_id=portal&"+
+		"code_challenge=0-jU3mxuhm2_pFNlPMSCemHaRsAaJYfGoWAEFO_aVIQ&"+
+		"code_challenge_method=S256&"+
+		"nonce=some-nonce&"+
+		"redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Flogin%2Ffederated%2Fcallback&"+
+		"response_type=code&"+
+		"scope=openid+email&"+
+		"state=some-state", got)
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	ctx := context.Background()
+	idp := newIdentityProvider(t)
+	p := newTestProvider(t, idp)
+
+	authorizationCode := func(t *testing.T) string {
+		authURL, err := p.AuthCodeURL(ctx, "some-state", "some-nonce", testChallenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := login(t, authURL)
+		assert.Equal(t, "some-state", state)
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     func(t *testing.T) string
+		verifier string
+		claims   func(claims jwt.MapClaims)
+		prepare  func(t *testing.T)
+		want     *tools.IdentityClaims
+		wantErr  bool
+	}{
+		{
+			name: "unknown code",
+			code: func(t *testing.T) string {
+				return "some-code"
+			},
+			verifier: testVerifier,
+			wantErr:  true,
+		},
+		{
+			name:     "wrong code verifier",
+			code:     authorizationCode,
+			verifier: "some-other-verifier-that-is-long-enough-to-be-valid",
+			wantErr:  true,
+		},
+		{
+			name:     "id token of another issuer",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				claims["iss"] = "https://accounts.example.com"
+			},
+			wantErr: true,
+		},
+		{
+			name:     "id token of another client",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				claims["aud"] = "other-client"
+			},
+			wantErr: true,
+		},
+		{
+			name:     "id token of several clients authorized by another",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				claims["aud"] = []string{testClientID, "other-client"}
+				claims["azp"] = "other-client"
+			},
+			wantErr: true,
+		},
+		{
+			name:     "expired id token",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			wantErr: true,
+		},
+		{
+			name:     "id token issued in the future",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				claims["iat"] = time.Now().Add(time.Hour).Unix()
+			},
+			wantErr: true,
+		},
+		{
+			name:     "id token without subject",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				delete(claims, "sub")
+			},
+			wantErr: true,
+		},
+		{
+			name:     "success",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			want: &tools.IdentityClaims{
+				Issuer:        idp.url(),
+				Subject:       "10769150350006150715113082367",
+				Email:         "john@example.com",
+				EmailVerified: true,
+				Name:          "John Doe",
+				Nonce:         "some-nonce",
+			},
+			wantErr: false,
+		},
+		{
+			name:     "id token signed by a rotated key",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			claims: func(claims jwt.MapClaims) {
+				claims["aud"] = []string{testClientID, "other-client"}
+				claims["azp"] = testClientID
+				claims["email_verified"] = "false"
+			},
+			prepare: func(t *testing.T) {
+				idp.rotate(t, "key-2")
+				// the keys were fetched by the previous case, long enough ago to fetch them again
+				p.keysFetchedAt = time.Now().Add(-keysRefreshInterval)
+			},
+			want: &tools.IdentityClaims{
+				Issuer:        idp.url(),
+				Subject:       "10769150350006150715113082367",
+				Email:         "john@example.com",
+				EmailVerified: false,
+				Name:          "John Doe",
+				Nonce:         "some-nonce",
+			},
+			wantErr: false,
+		},
+		{
+			name:     "id token signed by an unknown key",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			prepare: func(t *testing.T) {
+				forgedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(err)
+				}
+				idp.forgedKey = forgedKey
+			},
+			wantErr: true,
+		},
+		{
+			name:     "id token with unknown kid right after keys were fetched",
+			code:     authorizationCode,
+			verifier: testVerifier,
+			prepare: func(t *testing.T) {
+				idp.forgedKey = nil
+				idp.rotate(t, "key-3")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(t)
+			}
+			code := tt.code(t)
+			idp.claims = tt.claims
+
+			got, err := p.Exchange(ctx, code, tt.verifier)
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// keys were only fetched on first use and once after the rotation
+	assert.Equal(t, 2, idp.jwksRequests)
+}
+
+func TestProvider_publicKey(t *testing.T) {
+	ctx := context.Background()
+	idp := newIdentityProvider(t)
+	p := newTestProvider(t, idp)
+
+	md, err := p.discover(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	idp.jwksHeld = make(chan struct{})
+	idp.jwksStarted = make(chan struct{}, 2)
+
+	// keys are looked up concurrently while the provider is slow to publish them
+	var (
+		wg   sync.WaitGroup
+		keys = make([]interface{}, 2)
+		errs = make([]error, 2)
+	)
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys[i], errs[i] = p.publicKey(ctx, md, "key-1")
+		}(i)
+	}
+	<-idp.jwksStarted
+
+	// the provider is not locked while waiting on the keys
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := p.AuthCodeURL(ctx, "some-state", "some-nonce", testChallenge)
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Error("provider is locked while fetching keys")
+	}
+
+	close(idp.jwksHeld)
+	wg.Wait()
+	for i := range keys {
+		assert.NoError(t, errs[i])
+		assert.Equal(t, &idp.key.PublicKey, keys[i])
+	}
+
+	// the lookups shared a single request
+	assert.Equal(t, 1, idp.jwksRequests)
+}
@@ -1,6 +1,9 @@
 package tools
 
 import (
+	"context"
+	"time"
+
 	"github.com/labstack/echo/v4"
 )
 
@@ -9,14 +12,77 @@ import (
 type AuthInterface interface {
 	AuthenticateMiddleware(next echo.HandlerFunc) echo.HandlerFunc
 	Authenticate(c echo.Context) error
+	// AuthorizeMiddleware returns a middleware that only lets through users granted the permission.
+	AuthorizeMiddleware(permission string) echo.MiddlewareFunc
+	// Authorize authenticates the request, then checks whether the user is granted the permission.
+	Authorize(c echo.Context, permission string) error
 }
 
 type HashInterface interface {
 	HashPassword(password string) ([]byte, error)
 	ComparePassword(hashedPassword []byte, password string) error
+	// NeedsRehash reports whether the hash was produced by an outdated algorithm or cost,
+	// and should be replaced by hashing the password again once it is known to be correct.
+	NeedsRehash(hashedPassword []byte) bool
 }
 
 type JWTInterface interface {
-	Generate(content interface{}) (string, error)
-	Validate(tokenString string) (interface{}, error)
+	// Generate signs the claims, filling every registered claim other than sub.
+	// Audience and jti are only filled when not set, like an id token meant for a client application.
+	Generate(claims Claims) (string, error)
+	Validate(tokenString string) (*Claims, error)
+	// JWKS returns public keys that can verify issued tokens.
+	JWKS() []JSONWebKey
+}
+
+type IdentityProviderInterface interface {
+	// AuthCodeURL returns the login page of the provider, which sends user back to the redirect url
+	// with the state and a code for Exchange.
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the code along with the PKCE verifier of its challenge, returning the verified claims of the id token.
+	Exchange(ctx context.Context, code, codeVerifier string) (*IdentityClaims, error)
+}
+
+type PasswordBlocklistInterface interface {
+	// IsBlocked reports whether the password is too common or has appeared in a data breach.
+	IsBlocked(ctx context.Context, password string) (bool, error)
+}
+
+type RandomInterface interface {
+	Token(size int) (string, error)
+	// Digits returns a numeric code of the given length, suitable for a code typed in by user.
+	Digits(length int) (string, error)
+}
+
+type TOTPInterface interface {
+	// GenerateSecret returns a new random base32 encoded secret shared with an authenticator app.
+	GenerateSecret() (string, error)
+	// URI returns otpauth:// uri of the secret, usually shown as qr code to the authenticator app.
+	URI(secret, accountName string) string
+	// Validate reports whether the code matches the secret at current time,
+	// returning the time step the code belongs to so it cannot be used twice.
+	Validate(secret, code string) (int64, bool)
+}
+
+type SMSSenderInterface interface {
+	// Send delivers a text message to the phone number.
+	Send(ctx context.Context, phoneNumber, message string) error
+}
+
+type EmailSenderInterface interface {
+	// Send delivers a plain text email to the address.
+	Send(ctx context.Context, email, subject, body string) error
+}
+
+type SignerInterface interface {
+	// Sign returns a token carrying the payload that cannot be altered without the secret, valid until expiresAt.
+	Sign(payload string, expiresAt time.Time) (string, error)
+	// Verify returns the payload of a token issued by Sign after checking its signature and lifetime.
+	Verify(token string) (string, error)
+}
+
+type RateLimiterInterface interface {
+	// Take spends one request from the bucket of the key, creating a full bucket for a new key.
+	// Implementation shared between instances lets every instance enforce the same limit.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
 }
@@ -5,10 +5,12 @@
 package tools
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
-	echo "github.com/labstack/echo/v4"
+	v4 "github.com/labstack/echo/v4"
 )
 
 // MockAuthInterface is a mock of AuthInterface interface.
@@ -35,7 +37,7 @@ func (m *MockAuthInterface) EXPECT() *MockAuthInterfaceMockRecorder {
 }
 
 // Authenticate mocks base method.
-func (m *MockAuthInterface) Authenticate(c echo.Context) error {
+func (m *MockAuthInterface) Authenticate(c v4.Context) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Authenticate", c)
 	ret0, _ := ret[0].(error)
@@ -49,10 +51,10 @@ func (mr *MockAuthInterfaceMockRecorder) Authenticate(c interface{}) *gomock.Cal
 }
 
 // AuthenticateMiddleware mocks base method.
-func (m *MockAuthInterface) AuthenticateMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+func (m *MockAuthInterface) AuthenticateMiddleware(next v4.HandlerFunc) v4.HandlerFunc {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "AuthenticateMiddleware", next)
-	ret0, _ := ret[0].(echo.HandlerFunc)
+	ret0, _ := ret[0].(v4.HandlerFunc)
 	return ret0
 }
 
@@ -62,6 +64,34 @@ func (mr *MockAuthInterfaceMockRecorder) AuthenticateMiddleware(next interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateMiddleware", reflect.TypeOf((*MockAuthInterface)(nil).AuthenticateMiddleware), next)
 }
 
+// Authorize mocks base method.
+func (m *MockAuthInterface) Authorize(c v4.Context, permission string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authorize", c, permission)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Authorize indicates an expected call of Authorize.
+func (mr *MockAuthInterfaceMockRecorder) Authorize(c, permission interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authorize", reflect.TypeOf((*MockAuthInterface)(nil).Authorize), c, permission)
+}
+
+// AuthorizeMiddleware mocks base method.
+func (m *MockAuthInterface) AuthorizeMiddleware(permission string) v4.MiddlewareFunc {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthorizeMiddleware", permission)
+	ret0, _ := ret[0].(v4.MiddlewareFunc)
+	return ret0
+}
+
+// AuthorizeMiddleware indicates an expected call of AuthorizeMiddleware.
+func (mr *MockAuthInterfaceMockRecorder) AuthorizeMiddleware(permission interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthorizeMiddleware", reflect.TypeOf((*MockAuthInterface)(nil).AuthorizeMiddleware), permission)
+}
+
 // MockHashInterface is a mock of HashInterface interface.
 type MockHashInterface struct {
 	ctrl     *gomock.Controller
@@ -114,6 +144,20 @@ func (mr *MockHashInterfaceMockRecorder) HashPassword(password interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashPassword", reflect.TypeOf((*MockHashInterface)(nil).HashPassword), password)
 }
 
+// NeedsRehash mocks base method.
+func (m *MockHashInterface) NeedsRehash(hashedPassword []byte) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NeedsRehash", hashedPassword)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// NeedsRehash indicates an expected call of NeedsRehash.
+func (mr *MockHashInterfaceMockRecorder) NeedsRehash(hashedPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NeedsRehash", reflect.TypeOf((*MockHashInterface)(nil).NeedsRehash), hashedPassword)
+}
+
 // MockJWTInterface is a mock of JWTInterface interface.
 type MockJWTInterface struct {
 	ctrl     *gomock.Controller
@@ -138,25 +182,39 @@ func (m *MockJWTInterface) EXPECT() *MockJWTInterfaceMockRecorder {
 }
 
 // Generate mocks base method.
-func (m *MockJWTInterface) Generate(content interface{}) (string, error) {
+func (m *MockJWTInterface) Generate(claims Claims) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Generate", content)
+	ret := m.ctrl.Call(m, "Generate", claims)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Generate indicates an expected call of Generate.
-func (mr *MockJWTInterfaceMockRecorder) Generate(content interface{}) *gomock.Call {
+func (mr *MockJWTInterfaceMockRecorder) Generate(claims interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockJWTInterface)(nil).Generate), content)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockJWTInterface)(nil).Generate), claims)
+}
+
+// JWKS mocks base method.
+func (m *MockJWTInterface) JWKS() []JSONWebKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].([]JSONWebKey)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockJWTInterfaceMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockJWTInterface)(nil).JWKS))
 }
 
 // Validate mocks base method.
-func (m *MockJWTInterface) Validate(tokenString string) (interface{}, error) {
+func (m *MockJWTInterface) Validate(tokenString string) (*Claims, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Validate", tokenString)
-	ret0, _ := ret[0].(interface{})
+	ret0, _ := ret[0].(*Claims)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -166,3 +224,379 @@ func (mr *MockJWTInterfaceMockRecorder) Validate(tokenString interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockJWTInterface)(nil).Validate), tokenString)
 }
+
+// MockIdentityProviderInterface is a mock of IdentityProviderInterface interface.
+type MockIdentityProviderInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdentityProviderInterfaceMockRecorder
+}
+
+// MockIdentityProviderInterfaceMockRecorder is the mock recorder for MockIdentityProviderInterface.
+type MockIdentityProviderInterfaceMockRecorder struct {
+	mock *MockIdentityProviderInterface
+}
+
+// NewMockIdentityProviderInterface creates a new mock instance.
+func NewMockIdentityProviderInterface(ctrl *gomock.Controller) *MockIdentityProviderInterface {
+	mock := &MockIdentityProviderInterface{ctrl: ctrl}
+	mock.recorder = &MockIdentityProviderInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdentityProviderInterface) EXPECT() *MockIdentityProviderInterfaceMockRecorder {
+	return m.recorder
+}
+
+// AuthCodeURL mocks base method.
+func (m *MockIdentityProviderInterface) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthCodeURL", ctx, state, nonce, codeChallenge)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthCodeURL indicates an expected call of AuthCodeURL.
+func (mr *MockIdentityProviderInterfaceMockRecorder) AuthCodeURL(ctx, state, nonce, codeChallenge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthCodeURL", reflect.TypeOf((*MockIdentityProviderInterface)(nil).AuthCodeURL), ctx, state, nonce, codeChallenge)
+}
+
+// Exchange mocks base method.
+func (m *MockIdentityProviderInterface) Exchange(ctx context.Context, code, codeVerifier string) (*IdentityClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exchange", ctx, code, codeVerifier)
+	ret0, _ := ret[0].(*IdentityClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exchange indicates an expected call of Exchange.
+func (mr *MockIdentityProviderInterfaceMockRecorder) Exchange(ctx, code, codeVerifier interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exchange", reflect.TypeOf((*MockIdentityProviderInterface)(nil).Exchange), ctx, code, codeVerifier)
+}
+
+// MockPasswordBlocklistInterface is a mock of PasswordBlocklistInterface interface.
+type MockPasswordBlocklistInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordBlocklistInterfaceMockRecorder
+}
+
+// MockPasswordBlocklistInterfaceMockRecorder is the mock recorder for MockPasswordBlocklistInterface.
+type MockPasswordBlocklistInterfaceMockRecorder struct {
+	mock *MockPasswordBlocklistInterface
+}
+
+// NewMockPasswordBlocklistInterface creates a new mock instance.
+func NewMockPasswordBlocklistInterface(ctrl *gomock.Controller) *MockPasswordBlocklistInterface {
+	mock := &MockPasswordBlocklistInterface{ctrl: ctrl}
+	mock.recorder = &MockPasswordBlocklistInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasswordBlocklistInterface) EXPECT() *MockPasswordBlocklistInterfaceMockRecorder {
+	return m.recorder
+}
+
+// IsBlocked mocks base method.
+func (m *MockPasswordBlocklistInterface) IsBlocked(ctx context.Context, password string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBlocked", ctx, password)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsBlocked indicates an expected call of IsBlocked.
+func (mr *MockPasswordBlocklistInterfaceMockRecorder) IsBlocked(ctx, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlocked", reflect.TypeOf((*MockPasswordBlocklistInterface)(nil).IsBlocked), ctx, password)
+}
+
+// MockRandomInterface is a mock of RandomInterface interface.
+type MockRandomInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockRandomInterfaceMockRecorder
+}
+
+// MockRandomInterfaceMockRecorder is the mock recorder for MockRandomInterface.
+type MockRandomInterfaceMockRecorder struct {
+	mock *MockRandomInterface
+}
+
+// NewMockRandomInterface creates a new mock instance.
+func NewMockRandomInterface(ctrl *gomock.Controller) *MockRandomInterface {
+	mock := &MockRandomInterface{ctrl: ctrl}
+	mock.recorder = &MockRandomInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRandomInterface) EXPECT() *MockRandomInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Digits mocks base method.
+func (m *MockRandomInterface) Digits(length int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Digits", length)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Digits indicates an expected call of Digits.
+func (mr *MockRandomInterfaceMockRecorder) Digits(length interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Digits", reflect.TypeOf((*MockRandomInterface)(nil).Digits), length)
+}
+
+// Token mocks base method.
+func (m *MockRandomInterface) Token(size int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Token", size)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Token indicates an expected call of Token.
+func (mr *MockRandomInterfaceMockRecorder) Token(size interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Token", reflect.TypeOf((*MockRandomInterface)(nil).Token), size)
+}
+
+// MockTOTPInterface is a mock of TOTPInterface interface.
+type MockTOTPInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockTOTPInterfaceMockRecorder
+}
+
+// MockTOTPInterfaceMockRecorder is the mock recorder for MockTOTPInterface.
+type MockTOTPInterfaceMockRecorder struct {
+	mock *MockTOTPInterface
+}
+
+// NewMockTOTPInterface creates a new mock instance.
+func NewMockTOTPInterface(ctrl *gomock.Controller) *MockTOTPInterface {
+	mock := &MockTOTPInterface{ctrl: ctrl}
+	mock.recorder = &MockTOTPInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTOTPInterface) EXPECT() *MockTOTPInterfaceMockRecorder {
+	return m.recorder
+}
+
+// GenerateSecret mocks base method.
+func (m *MockTOTPInterface) GenerateSecret() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateSecret")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateSecret indicates an expected call of GenerateSecret.
+func (mr *MockTOTPInterfaceMockRecorder) GenerateSecret() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateSecret", reflect.TypeOf((*MockTOTPInterface)(nil).GenerateSecret))
+}
+
+// URI mocks base method.
+func (m *MockTOTPInterface) URI(secret, accountName string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "URI", secret, accountName)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// URI indicates an expected call of URI.
+func (mr *MockTOTPInterfaceMockRecorder) URI(secret, accountName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "URI", reflect.TypeOf((*MockTOTPInterface)(nil).URI), secret, accountName)
+}
+
+// Validate mocks base method.
+func (m *MockTOTPInterface) Validate(secret, code string) (int64, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", secret, code)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockTOTPInterfaceMockRecorder) Validate(secret, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockTOTPInterface)(nil).Validate), secret, code)
+}
+
+// MockSMSSenderInterface is a mock of SMSSenderInterface interface.
+type MockSMSSenderInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockSMSSenderInterfaceMockRecorder
+}
+
+// MockSMSSenderInterfaceMockRecorder is the mock recorder for MockSMSSenderInterface.
+type MockSMSSenderInterfaceMockRecorder struct {
+	mock *MockSMSSenderInterface
+}
+
+// NewMockSMSSenderInterface creates a new mock instance.
+func NewMockSMSSenderInterface(ctrl *gomock.Controller) *MockSMSSenderInterface {
+	mock := &MockSMSSenderInterface{ctrl: ctrl}
+	mock.recorder = &MockSMSSenderInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSMSSenderInterface) EXPECT() *MockSMSSenderInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockSMSSenderInterface) Send(ctx context.Context, phoneNumber, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", ctx, phoneNumber, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockSMSSenderInterfaceMockRecorder) Send(ctx, phoneNumber, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSMSSenderInterface)(nil).Send), ctx, phoneNumber, message)
+}
+
+// MockEmailSenderInterface is a mock of EmailSenderInterface interface.
+type MockEmailSenderInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmailSenderInterfaceMockRecorder
+}
+
+// MockEmailSenderInterfaceMockRecorder is the mock recorder for MockEmailSenderInterface.
+type MockEmailSenderInterfaceMockRecorder struct {
+	mock *MockEmailSenderInterface
+}
+
+// NewMockEmailSenderInterface creates a new mock instance.
+func NewMockEmailSenderInterface(ctrl *gomock.Controller) *MockEmailSenderInterface {
+	mock := &MockEmailSenderInterface{ctrl: ctrl}
+	mock.recorder = &MockEmailSenderInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmailSenderInterface) EXPECT() *MockEmailSenderInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockEmailSenderInterface) Send(ctx context.Context, email, subject, body string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", ctx, email, subject, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockEmailSenderInterfaceMockRecorder) Send(ctx, email, subject, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockEmailSenderInterface)(nil).Send), ctx, email, subject, body)
+}
+
+// MockSignerInterface is a mock of SignerInterface interface.
+type MockSignerInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockSignerInterfaceMockRecorder
+}
+
+// MockSignerInterfaceMockRecorder is the mock recorder for MockSignerInterface.
+type MockSignerInterfaceMockRecorder struct {
+	mock *MockSignerInterface
+}
+
+// NewMockSignerInterface creates a new mock instance.
+func NewMockSignerInterface(ctrl *gomock.Controller) *MockSignerInterface {
+	mock := &MockSignerInterface{ctrl: ctrl}
+	mock.recorder = &MockSignerInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSignerInterface) EXPECT() *MockSignerInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Sign mocks base method.
+func (m *MockSignerInterface) Sign(payload string, expiresAt time.Time) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", payload, expiresAt)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sign indicates an expected call of Sign.
+func (mr *MockSignerInterfaceMockRecorder) Sign(payload, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockSignerInterface)(nil).Sign), payload, expiresAt)
+}
+
+// Verify mocks base method.
+func (m *MockSignerInterface) Verify(token string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", token)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockSignerInterfaceMockRecorder) Verify(token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockSignerInterface)(nil).Verify), token)
+}
+
+// MockRateLimiterInterface is a mock of RateLimiterInterface interface.
+type MockRateLimiterInterface struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimiterInterfaceMockRecorder
+}
+
+// MockRateLimiterInterfaceMockRecorder is the mock recorder for MockRateLimiterInterface.
+type MockRateLimiterInterfaceMockRecorder struct {
+	mock *MockRateLimiterInterface
+}
+
+// NewMockRateLimiterInterface creates a new mock instance.
+func NewMockRateLimiterInterface(ctrl *gomock.Controller) *MockRateLimiterInterface {
+	mock := &MockRateLimiterInterface{ctrl: ctrl}
+	mock.recorder = &MockRateLimiterInterfaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimiterInterface) EXPECT() *MockRateLimiterInterfaceMockRecorder {
+	return m.recorder
+}
+
+// Take mocks base method.
+func (m *MockRateLimiterInterface) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Take", ctx, key, limit)
+	ret0, _ := ret[0].(RateLimitResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Take indicates an expected call of Take.
+func (mr *MockRateLimiterInterfaceMockRecorder) Take(ctx, key, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Take", reflect.TypeOf((*MockRateLimiterInterface)(nil).Take), ctx, key, limit)
+}
@@ -0,0 +1,15 @@
+package tools
+
+// JSONWebKey is the public part of a signing key as described in RFC 7517.
+// RSA keys fill N and E, elliptic curve keys fill Curve, X, and Y (Ed25519 has no Y).
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
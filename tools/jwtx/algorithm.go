@@ -0,0 +1,150 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+const (
+	// AlgorithmRS256 is RSASSA-PKCS1-v1_5 using SHA-256.
+	AlgorithmRS256 = "RS256"
+	// AlgorithmES256 is ECDSA using P-256 and SHA-256, it is faster to verify and gives smaller tokens than RS256.
+	AlgorithmES256 = "ES256"
+	// AlgorithmEdDSA is Ed25519, it gives the smallest tokens.
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// algorithm parses PEM keys and describes public keys of one jwt signing algorithm.
+type algorithm interface {
+	signingMethod() jwt.SigningMethod
+	parsePrivateKey(data []byte) (crypto.PrivateKey, error)
+	parsePublicKey(data []byte) (crypto.PublicKey, error)
+	// jsonWebKey returns the public key without kid.
+	jsonWebKey(publicKey crypto.PublicKey) tools.JSONWebKey
+}
+
+var algorithms = map[string]algorithm{
+	AlgorithmRS256: rs256{},
+	AlgorithmES256: es256{},
+	AlgorithmEdDSA: eddsa{},
+}
+
+func getAlgorithm(name string) (algorithm, error) {
+	alg, ok := algorithms[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", name)
+	}
+
+	return alg, nil
+}
+
+type rs256 struct{}
+
+func (rs256) signingMethod() jwt.SigningMethod {
+	return jwt.SigningMethodRS256
+}
+
+func (rs256) parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	return jwt.ParseRSAPrivateKeyFromPEM(data)
+}
+
+func (rs256) parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	return jwt.ParseRSAPublicKeyFromPEM(data)
+}
+
+func (rs256) jsonWebKey(publicKey crypto.PublicKey) tools.JSONWebKey {
+	key := publicKey.(*rsa.PublicKey)
+
+	return tools.JSONWebKey{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: AlgorithmRS256,
+		N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+type es256 struct{}
+
+var errNotP256Key = errors.New("key is not on P-256 curve")
+
+func (es256) signingMethod() jwt.SigningMethod {
+	return jwt.SigningMethodES256
+}
+
+func (es256) parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, errNotP256Key
+	}
+
+	return key, nil
+}
+
+func (es256) parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	key, err := jwt.ParseECPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, errNotP256Key
+	}
+
+	return key, nil
+}
+
+func (es256) jsonWebKey(publicKey crypto.PublicKey) tools.JSONWebKey {
+	key := publicKey.(*ecdsa.PublicKey)
+
+	// coordinates are left padded to the curve size as required by RFC 7518
+	x := key.X.FillBytes(make([]byte, 32))
+	y := key.Y.FillBytes(make([]byte, 32))
+
+	return tools.JSONWebKey{
+		KeyType:   "EC",
+		Use:       "sig",
+		Algorithm: AlgorithmES256,
+		Curve:     "P-256",
+		X:         base64.RawURLEncoding.EncodeToString(x),
+		Y:         base64.RawURLEncoding.EncodeToString(y),
+	}
+}
+
+type eddsa struct{}
+
+func (eddsa) signingMethod() jwt.SigningMethod {
+	return jwt.SigningMethodEdDSA
+}
+
+func (eddsa) parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	return jwt.ParseEdPrivateKeyFromPEM(data)
+}
+
+func (eddsa) parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	return jwt.ParseEdPublicKeyFromPEM(data)
+}
+
+func (eddsa) jsonWebKey(publicKey crypto.PublicKey) tools.JSONWebKey {
+	key := publicKey.(ed25519.PublicKey)
+
+	return tools.JSONWebKey{
+		KeyType:   "OKP",
+		Use:       "sig",
+		Algorithm: AlgorithmEdDSA,
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(key),
+	}
+}
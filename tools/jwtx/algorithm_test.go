@@ -0,0 +1,119 @@
+package jwtx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAlgorithm(t *testing.T) {
+	got, err := getAlgorithm("HS256")
+	assert.Error(t, err)
+	assert.Nil(t, got)
+
+	for _, name := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		got, err = getAlgorithm(name)
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, name, got.signingMethod().Alg())
+		}
+	}
+}
+
+func TestAlgorithm_parseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		alg     algorithm
+		keyPair testKeyPair
+		wantErr bool
+	}{
+		{
+			name:    "rs256",
+			alg:     rs256{},
+			keyPair: rsaKeyPair,
+			wantErr: false,
+		},
+		{
+			name:    "rs256 with ec key",
+			alg:     rs256{},
+			keyPair: ecKeyPair,
+			wantErr: true,
+		},
+		{
+			name:    "es256",
+			alg:     es256{},
+			keyPair: ecKeyPair,
+			wantErr: false,
+		},
+		{
+			name:    "es256 with P-384 key",
+			alg:     es256{},
+			keyPair: p384KeyPair,
+			wantErr: true,
+		},
+		{
+			name:    "es256 with rsa key",
+			alg:     es256{},
+			keyPair: rsaKeyPair,
+			wantErr: true,
+		},
+		{
+			name:    "eddsa",
+			alg:     eddsa{},
+			keyPair: edKeyPair,
+			wantErr: false,
+		},
+		{
+			name:    "eddsa with ec key",
+			alg:     eddsa{},
+			keyPair: ecKeyPair,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privateKey, err := tt.alg.parsePrivateKey(tt.keyPair.privateKey)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			publicKey, err := tt.alg.parsePublicKey(tt.keyPair.publicKey)
+			assert.Equal(t, tt.wantErr, err != nil)
+
+			if !tt.wantErr {
+				assert.True(t, keyPairMatches(privateKey, publicKey))
+			}
+		})
+	}
+}
+
+func TestAlgorithm_jsonWebKey(t *testing.T) {
+	publicKey, err := rs256{}.parsePublicKey(rsaKeyPair.publicKey)
+	if assert.NoError(t, err) {
+		got := rs256{}.jsonWebKey(publicKey)
+		assert.Equal(t, "RSA", got.KeyType)
+		assert.Equal(t, "RS256", got.Algorithm)
+		assert.Equal(t, "AQAB", got.E)
+		assert.Len(t, got.N, 342)
+		assert.Empty(t, got.X)
+	}
+
+	publicKey, err = es256{}.parsePublicKey(ecKeyPair.publicKey)
+	if assert.NoError(t, err) {
+		got := es256{}.jsonWebKey(publicKey)
+		assert.Equal(t, "EC", got.KeyType)
+		assert.Equal(t, "ES256", got.Algorithm)
+		assert.Equal(t, "P-256", got.Curve)
+		assert.Len(t, got.X, 43)
+		assert.Len(t, got.Y, 43)
+		assert.Empty(t, got.N)
+	}
+
+	publicKey, err = eddsa{}.parsePublicKey(edKeyPair.publicKey)
+	if assert.NoError(t, err) {
+		got := eddsa{}.jsonWebKey(publicKey)
+		assert.Equal(t, "OKP", got.KeyType)
+		assert.Equal(t, "EdDSA", got.Algorithm)
+		assert.Equal(t, "Ed25519", got.Curve)
+		assert.Len(t, got.X, 43)
+		assert.Empty(t, got.Y)
+	}
+}
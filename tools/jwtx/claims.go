@@ -0,0 +1,103 @@
+package jwtx
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+// jwtClaims is the json payload of tools.Claims.
+type jwtClaims struct {
+	ID        string   `json:"jti"`
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf"`
+	ExpiresAt int64    `json:"exp"`
+
+	Roles        []string `json:"roles,omitempty"`
+	Permissions  []string `json:"permissions,omitempty"`
+	TokenVersion int      `json:"ver,omitempty"`
+	Purpose      string   `json:"pur,omitempty"`
+	Nonce        string   `json:"nonce,omitempty"`
+	Scope        string   `json:"scope,omitempty"`
+}
+
+func newJWTClaims(claims tools.Claims) *jwtClaims {
+	return &jwtClaims{
+		ID:           claims.ID,
+		Subject:      claims.Subject,
+		Issuer:       claims.Issuer,
+		Audience:     audience(claims.Audience),
+		IssuedAt:     claims.IssuedAt.Unix(),
+		NotBefore:    claims.NotBefore.Unix(),
+		ExpiresAt:    claims.ExpiresAt.Unix(),
+		Roles:        claims.Roles,
+		Permissions:  claims.Permissions,
+		TokenVersion: claims.TokenVersion,
+		Purpose:      claims.Purpose,
+		Nonce:        claims.Nonce,
+		Scope:        claims.Scope,
+	}
+}
+
+// Valid always passes, SigningMethod.validateClaims checks the claims
+// against its own clock, issuer, and audience after the signature is verified.
+func (c *jwtClaims) Valid() error {
+	return nil
+}
+
+func (c *jwtClaims) toClaims() *tools.Claims {
+	return &tools.Claims{
+		ID:           c.ID,
+		Subject:      c.Subject,
+		Issuer:       c.Issuer,
+		Audience:     []string(c.Audience),
+		IssuedAt:     time.Unix(c.IssuedAt, 0),
+		NotBefore:    time.Unix(c.NotBefore, 0),
+		ExpiresAt:    time.Unix(c.ExpiresAt, 0),
+		Roles:        c.Roles,
+		Permissions:  c.Permissions,
+		TokenVersion: c.TokenVersion,
+		Purpose:      c.Purpose,
+		Nonce:        c.Nonce,
+		Scope:        c.Scope,
+	}
+}
+
+// audience is either a single string or an array of strings in json as allowed by RFC 7519.
+type audience []string
+
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = audience(multiple)
+
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
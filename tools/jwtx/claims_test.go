@@ -0,0 +1,39 @@
+package jwtx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudience_MarshalJSON(t *testing.T) {
+	got, err := json.Marshal(audience{"portal"})
+	assert.NoError(t, err)
+	assert.Equal(t, `"portal"`, string(got))
+
+	got, err = json.Marshal(audience{"portal", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, `["portal","admin"]`, string(got))
+}
+
+func TestAudience_UnmarshalJSON(t *testing.T) {
+	var got audience
+
+	err := json.Unmarshal([]byte(`"portal"`), &got)
+	assert.NoError(t, err)
+	assert.Equal(t, audience{"portal"}, got)
+
+	err = json.Unmarshal([]byte(`["portal","admin"]`), &got)
+	assert.NoError(t, err)
+	assert.Equal(t, audience{"portal", "admin"}, got)
+
+	err = json.Unmarshal([]byte(`1`), &got)
+	assert.Error(t, err)
+}
+
+func TestAudience_contains(t *testing.T) {
+	a := audience{"portal", "admin"}
+	assert.True(t, a.contains("admin"))
+	assert.False(t, a.contains("billing"))
+}
@@ -0,0 +1,36 @@
+package jwtx
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+// thumbprint computes the RFC 7638 thumbprint of a public key,
+// a stable key id that does not need to be configured.
+func thumbprint(jwk tools.JSONWebKey) string {
+	// only the required members of each key type are hashed
+	members := map[string]string{
+		"kty": jwk.KeyType,
+	}
+	switch jwk.KeyType {
+	case "RSA":
+		members["e"] = jwk.E
+		members["n"] = jwk.N
+	case "EC":
+		members["crv"] = jwk.Curve
+		members["x"] = jwk.X
+		members["y"] = jwk.Y
+	case "OKP":
+		members["crv"] = jwk.Curve
+		members["x"] = jwk.X
+	}
+
+	// map keys are marshalled in lexicographic order without whitespace as required
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,46 @@
+package jwtx
+
+import (
+	"testing"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  tools.JSONWebKey
+		want string
+	}{
+		{
+			// example from RFC 7638 section 3.1
+			name: "rsa",
+			jwk: tools.JSONWebKey{
+				KeyType:   "RSA",
+				Algorithm: AlgorithmRS256,
+				KeyID:     "ignored",
+				N:         "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				E:         "AQAB",
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// example from RFC 8037 appendix A.3
+			name: "ed25519",
+			jwk: tools.JSONWebKey{
+				KeyType:   "OKP",
+				Algorithm: AlgorithmEdDSA,
+				Curve:     "Ed25519",
+				X:         "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+			},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := thumbprint(tt.jwk)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
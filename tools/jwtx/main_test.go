@@ -0,0 +1,102 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+)
+
+type testKeyPair struct {
+	privateKey []byte
+	publicKey  []byte
+}
+
+var (
+	rsaKeyPair, retiredRSAKeyPair testKeyPair
+	ecKeyPair, p384KeyPair        testKeyPair
+	edKeyPair                     testKeyPair
+)
+
+func TestMain(m *testing.M) {
+	rsaKeyPair = newRSAKeyPair()
+	retiredRSAKeyPair = newRSAKeyPair()
+	ecKeyPair = newECKeyPair(elliptic.P256())
+	p384KeyPair = newECKeyPair(elliptic.P384())
+	edKeyPair = newEdKeyPair()
+
+	os.Exit(m.Run())
+}
+
+func newRSAKeyPair() testKeyPair {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return testKeyPair{
+		privateKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}),
+		publicKey: encodePublicKey(&key.PublicKey),
+	}
+}
+
+func newECKeyPair(curve elliptic.Curve) testKeyPair {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	privateKey, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return testKeyPair{
+		privateKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "EC PRIVATE KEY",
+			Bytes: privateKey,
+		}),
+		publicKey: encodePublicKey(&key.PublicKey),
+	}
+}
+
+func newEdKeyPair() testKeyPair {
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	privateKey, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return testKeyPair{
+		privateKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: privateKey,
+		}),
+		publicKey: encodePublicKey(publicKey),
+	}
+}
+
+func encodePublicKey(key crypto.PublicKey) []byte {
+	publicKey, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: publicKey,
+	})
+}
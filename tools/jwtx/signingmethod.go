@@ -0,0 +1,319 @@
+package jwtx
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/crxpto"
+)
+
+// SigningMethod signs and verifies jwt on top of a key ring.
+// Tokens are signed by the active key and carry its id in the kid header,
+// retired keys are only kept to verify tokens they signed before a rotation.
+// Every key is pinned to one algorithm, the alg header of a token must match the key it points to.
+type SigningMethod struct {
+	signingKeyID string
+	signingKey   crypto.PrivateKey
+	keys         map[string]verificationKey
+	// keyIDs keeps the order of the key ring, active key first.
+	keyIDs   []string
+	issuer   string
+	audience []string
+	random   tools.RandomInterface
+	timeNow  func() time.Time
+	ttl      time.Duration
+}
+
+type verificationKey struct {
+	algorithm algorithm
+	publicKey crypto.PublicKey
+}
+
+// Key is a retired key of the key ring.
+type Key struct {
+	// ID defaults to the RFC 7638 thumbprint of PublicKey when not set.
+	ID string
+	// Algorithm is detected from the type of PublicKey when not set.
+	Algorithm string
+	PublicKey []byte
+}
+
+type NewSigningMethodOptions struct {
+	// Algorithm is one of AlgorithmRS256, AlgorithmES256, or AlgorithmEdDSA.
+	// It defaults to AlgorithmRS256 when not set.
+	Algorithm string
+	// PrivateKey and PublicKey are the active signing keypair.
+	PrivateKey []byte
+	PublicKey  []byte
+	// KeyID defaults to the RFC 7638 thumbprint of PublicKey when not set.
+	KeyID string
+	// VerificationKeys should be kept until every token they signed has expired.
+	VerificationKeys []Key
+	// Issuer is sent as iss claim, tokens of other issuers are rejected when set.
+	Issuer string
+	// Audience is sent as aud claim, tokens meant for none of them are rejected when set.
+	Audience []string
+	// TTL defaults to DefaultTTL when not set.
+	TTL time.Duration
+}
+
+// tokenIDSize is the number of random bytes of jti claim.
+const tokenIDSize = 16
+
+// DefaultTTL keeps access token short-lived, clients are expected to renew it using refresh token.
+const DefaultTTL = time.Minute * 15
+
+func NewSigningMethod(opts NewSigningMethodOptions) (*SigningMethod, error) {
+	algorithmName := opts.Algorithm
+	if algorithmName == "" {
+		algorithmName = AlgorithmRS256
+	}
+
+	alg, err := getAlgorithm(algorithmName)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := alg.parsePrivateKey(opts.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	j := &SigningMethod{
+		signingKey: privateKey,
+		keys:       make(map[string]verificationKey),
+		issuer:     opts.Issuer,
+		audience:   opts.Audience,
+		random:     crxpto.NewRandom(),
+		timeNow:    time.Now,
+		ttl:        ttl,
+	}
+
+	keys := append([]Key{{
+		ID:        opts.KeyID,
+		Algorithm: algorithmName,
+		PublicKey: opts.PublicKey,
+	}}, opts.VerificationKeys...)
+	for _, key := range keys {
+		err = j.addKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	j.signingKeyID = j.keyIDs[0]
+
+	if !keyPairMatches(privateKey, j.keys[j.signingKeyID].publicKey) {
+		return nil, errors.New("private key does not match public key")
+	}
+
+	return j, nil
+}
+
+func (j *SigningMethod) addKey(key Key) error {
+	alg, publicKey, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+
+	keyID := key.ID
+	if keyID == "" {
+		keyID = thumbprint(alg.jsonWebKey(publicKey))
+	}
+	if _, ok := j.keys[keyID]; ok {
+		return fmt.Errorf("duplicate key id %q", keyID)
+	}
+
+	j.keys[keyID] = verificationKey{
+		algorithm: alg,
+		publicKey: publicKey,
+	}
+	j.keyIDs = append(j.keyIDs, keyID)
+
+	return nil
+}
+
+func (j *SigningMethod) Generate(claims tools.Claims) (string, error) {
+	// jti makes every token individually revocable, a caller that has to remember it may pick its own
+	if claims.ID == "" {
+		tokenID, err := j.random.Token(tokenIDSize)
+		if err != nil {
+			return "", err
+		}
+		claims.ID = tokenID
+	}
+
+	now := j.timeNow()
+	claims.Issuer = j.issuer
+	// an id token is meant for the client application it is issued to instead
+	if len(claims.Audience) == 0 {
+		claims.Audience = j.audience
+	}
+	claims.IssuedAt = now
+	claims.NotBefore = now
+	claims.ExpiresAt = now.Add(j.ttl)
+
+	token := jwt.NewWithClaims(j.keys[j.signingKeyID].algorithm.signingMethod(), newJWTClaims(claims))
+	token.Header["kid"] = j.signingKeyID
+
+	return token.SignedString(j.signingKey)
+}
+
+var (
+	// ErrInvalidToken obscures the underlying error
+	// from the jwt library to avoid brute force attack.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Validate returns the claims of a token after checking its signature, lifetime, issuer, and audience.
+func (j *SigningMethod) Validate(tokenString string) (*tools.Claims, error) {
+	parser := &jwt.Parser{
+		SkipClaimsValidation: true,
+	}
+
+	claims := &jwtClaims{}
+	token, err := parser.ParseWithClaims(tokenString, claims, func(jwtToken *jwt.Token) (interface{}, error) {
+		key, err := j.verificationKey(jwtToken.Header["kid"])
+		if err != nil {
+			return nil, err
+		}
+
+		// never let the alg header choose how the signature is checked
+		if jwtToken.Method.Alg() != key.algorithm.signingMethod().Alg() {
+			return nil, ErrInvalidToken
+		}
+
+		return key.publicKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	err = j.validateClaims(claims)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return claims.toClaims(), nil
+}
+
+func (j *SigningMethod) validateClaims(claims *jwtClaims) error {
+	if claims.ID == "" || claims.Subject == "" {
+		return errors.New("missing jti or sub claim")
+	}
+
+	now := j.timeNow().Unix()
+	if now >= claims.ExpiresAt {
+		return errors.New("token is expired")
+	}
+	if now < claims.NotBefore {
+		return errors.New("token is not valid yet")
+	}
+
+	if j.issuer != "" && claims.Issuer != j.issuer {
+		return errors.New("unexpected issuer")
+	}
+
+	if len(j.audience) > 0 {
+		var accepted bool
+		for _, aud := range j.audience {
+			if claims.Audience.contains(aud) {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			return errors.New("unexpected audience")
+		}
+	}
+
+	return nil
+}
+
+// JWKS returns the public keys of the whole key ring, active key first.
+func (j *SigningMethod) JWKS() []tools.JSONWebKey {
+	keys := make([]tools.JSONWebKey, 0, len(j.keyIDs))
+	for _, keyID := range j.keyIDs {
+		key := j.keys[keyID]
+
+		jwk := key.algorithm.jsonWebKey(key.publicKey)
+		jwk.KeyID = keyID
+		keys = append(keys, jwk)
+	}
+
+	return keys
+}
+
+// verificationKey looks up the key by kid header.
+// Tokens without kid were issued before the key ring existed, so they are checked against the active key.
+func (j *SigningMethod) verificationKey(kid interface{}) (verificationKey, error) {
+	if kid == nil {
+		return j.keys[j.signingKeyID], nil
+	}
+
+	keyID, ok := kid.(string)
+	if !ok {
+		return verificationKey{}, ErrInvalidToken
+	}
+
+	key, ok := j.keys[keyID]
+	if !ok {
+		return verificationKey{}, ErrInvalidToken
+	}
+
+	return key, nil
+}
+
+// parsePublicKey parses the key with its algorithm,
+// or with the first algorithm that accepts it when the algorithm is not set.
+func parsePublicKey(key Key) (algorithm, crypto.PublicKey, error) {
+	if key.Algorithm != "" {
+		alg, err := getAlgorithm(key.Algorithm)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		publicKey, err := alg.parsePublicKey(key.PublicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return alg, publicKey, nil
+	}
+
+	for _, name := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		alg := algorithms[name]
+
+		publicKey, err := alg.parsePublicKey(key.PublicKey)
+		if err == nil {
+			return alg, publicKey, nil
+		}
+	}
+
+	return nil, nil, errors.New("unsupported public key")
+}
+
+// keyPairMatches reports whether the public key belongs to the private key.
+func keyPairMatches(privateKey crypto.PrivateKey, publicKey crypto.PublicKey) bool {
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return false
+	}
+
+	public, ok := signer.Public().(interface {
+		Equal(x crypto.PublicKey) bool
+	})
+	if !ok {
+		return false
+	}
+
+	return public.Equal(publicKey)
+}
@@ -0,0 +1,527 @@
+package jwtx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/golang/mock/gomock"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSigningMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    NewSigningMethodOptions
+		wantErr bool
+	}{
+		{
+			name: "unsupported algorithm",
+			opts: NewSigningMethodOptions{
+				Algorithm:  "HS256",
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid private key",
+			opts: NewSigningMethodOptions{
+				PrivateKey: []byte("invalid"),
+				PublicKey:  rsaKeyPair.publicKey,
+			},
+			wantErr: true,
+		},
+		{
+			name: "private key of another algorithm",
+			opts: NewSigningMethodOptions{
+				Algorithm:  AlgorithmES256,
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid verification key",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{PublicKey: []byte("invalid")},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported verification key algorithm",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{Algorithm: "none", PublicKey: retiredRSAKeyPair.publicKey},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "verification key of unsupported type",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{PublicKey: p384KeyPair.publicKey},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "verification key does not match its algorithm",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{Algorithm: AlgorithmEdDSA, PublicKey: retiredRSAKeyPair.publicKey},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate key id",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+				KeyID:      "key-1",
+				VerificationKeys: []Key{
+					{ID: "key-1", PublicKey: retiredRSAKeyPair.publicKey},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "private key from another keypair",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  retiredRSAKeyPair.publicKey,
+			},
+			wantErr: true,
+		},
+		{
+			name: "success rs256 by default",
+			opts: NewSigningMethodOptions{
+				PrivateKey: rsaKeyPair.privateKey,
+				PublicKey:  rsaKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{ID: "retired", PublicKey: retiredRSAKeyPair.publicKey},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success es256 with retired rs256 key",
+			opts: NewSigningMethodOptions{
+				Algorithm:  AlgorithmES256,
+				PrivateKey: ecKeyPair.privateKey,
+				PublicKey:  ecKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{ID: "retired", Algorithm: AlgorithmRS256, PublicKey: retiredRSAKeyPair.publicKey},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success eddsa with detected verification key algorithms",
+			opts: NewSigningMethodOptions{
+				Algorithm:  AlgorithmEdDSA,
+				PrivateKey: edKeyPair.privateKey,
+				PublicKey:  edKeyPair.publicKey,
+				VerificationKeys: []Key{
+					{PublicKey: retiredRSAKeyPair.publicKey},
+					{PublicKey: ecKeyPair.publicKey},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "success eddsa",
+			opts: NewSigningMethodOptions{
+				Algorithm:  AlgorithmEdDSA,
+				PrivateKey: edKeyPair.privateKey,
+				PublicKey:  edKeyPair.publicKey,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSigningMethod(tt.opts)
+			if !assert.Equal(t, tt.wantErr, err != nil) || tt.wantErr {
+				assert.Nil(t, got)
+				return
+			}
+
+			assert.Equal(t, DefaultTTL, got.ttl)
+			assert.Len(t, got.signingKeyID, 43)
+			assert.Equal(t, len(tt.opts.VerificationKeys)+1, len(got.keyIDs))
+			assert.Equal(t, got.signingKeyID, got.keyIDs[0])
+		})
+	}
+}
+
+func TestSigningMethod_GenerateValidate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		algorithm string
+		keyPair   testKeyPair
+	}{
+		{
+			name:      "rs256",
+			algorithm: AlgorithmRS256,
+			keyPair:   rsaKeyPair,
+		},
+		{
+			name:      "es256",
+			algorithm: AlgorithmES256,
+			keyPair:   ecKeyPair,
+		},
+		{
+			name:      "eddsa",
+			algorithm: AlgorithmEdDSA,
+			keyPair:   edKeyPair,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRandom := tools.NewMockRandomInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, err := NewSigningMethod(NewSigningMethodOptions{
+				Algorithm:  tt.algorithm,
+				PrivateKey: tt.keyPair.privateKey,
+				PublicKey:  tt.keyPair.publicKey,
+				KeyID:      "active",
+				Issuer:     "https://portal.example.com",
+				Audience:   []string{"portal"},
+				TTL:        time.Minute,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			j.random = mockRandom
+			j.timeNow = func() time.Time {
+				return now
+			}
+
+			// error generating jti
+			mockRandom.EXPECT().Token(tokenIDSize).Return("", assert.AnError)
+			got, err := j.Generate(tools.Claims{Subject: "1"})
+			assert.Error(t, err)
+			assert.Empty(t, got)
+
+			// token carries the algorithm and kid of the active key
+			mockRandom.EXPECT().Token(tokenIDSize).Return("token-id", nil)
+			got, err = j.Generate(tools.Claims{
+				Subject:      "1",
+				Roles:        []string{"user"},
+				Permissions:  []string{"profile:read"},
+				TokenVersion: 2,
+			})
+			assert.NoError(t, err)
+			token, _, err := new(jwt.Parser).ParseUnverified(got, jwt.MapClaims{})
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.algorithm, token.Header["alg"])
+				assert.Equal(t, "active", token.Header["kid"])
+				assert.Equal(t, jwt.MapClaims{
+					"jti":         "token-id",
+					"sub":         "1",
+					"iss":         "https://portal.example.com",
+					"aud":         "portal",
+					"iat":         float64(now.Unix()),
+					"nbf":         float64(now.Unix()),
+					"exp":         float64(now.Add(time.Minute).Unix()),
+					"roles":       []interface{}{"user"},
+					"permissions": []interface{}{"profile:read"},
+					"ver":         float64(2),
+				}, token.Claims)
+			}
+
+			claims, err := j.Validate(got)
+			assert.NoError(t, err)
+			assert.Equal(t, &tools.Claims{
+				ID:           "token-id",
+				Subject:      "1",
+				Issuer:       "https://portal.example.com",
+				Audience:     []string{"portal"},
+				IssuedAt:     time.Unix(now.Unix(), 0),
+				NotBefore:    time.Unix(now.Unix(), 0),
+				ExpiresAt:    time.Unix(now.Add(time.Minute).Unix(), 0),
+				Roles:        []string{"user"},
+				Permissions:  []string{"profile:read"},
+				TokenVersion: 2,
+			}, claims)
+
+			// purpose of a token that is not an access token survives the round trip
+			mockRandom.EXPECT().Token(tokenIDSize).Return("magic-link-id", nil)
+			got, err = j.Generate(tools.Claims{
+				Subject: "1",
+				Purpose: "magic_link",
+			})
+			assert.NoError(t, err)
+			claims, err = j.Validate(got)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "magic_link", claims.Purpose)
+			}
+
+			// scope granted to a client application survives the round trip
+			mockRandom.EXPECT().Token(tokenIDSize).Return("oidc-access-id", nil)
+			got, err = j.Generate(tools.Claims{
+				Subject: "1",
+				Purpose: "oidc_access",
+				Scope:   "openid phone",
+			})
+			assert.NoError(t, err)
+			claims, err = j.Validate(got)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "openid phone", claims.Scope)
+			}
+
+			// jti picked by the caller is kept, no random one is generated
+			got, err = j.Generate(tools.Claims{
+				ID:      "preset-id",
+				Subject: "1",
+			})
+			assert.NoError(t, err)
+			claims, err = j.Validate(got)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "preset-id", claims.ID)
+			}
+
+			// id token keeps the audience of the client along with the nonce, so it is rejected as access token
+			mockRandom.EXPECT().Token(tokenIDSize).Return("id-token-id", nil)
+			got, err = j.Generate(tools.Claims{
+				Subject:  "1",
+				Audience: []string{"some-client"},
+				Nonce:    "some-nonce",
+			})
+			assert.NoError(t, err)
+			token, _, err = new(jwt.Parser).ParseUnverified(got, jwt.MapClaims{})
+			if assert.NoError(t, err) {
+				assert.Equal(t, "some-client", token.Claims.(jwt.MapClaims)["aud"])
+				assert.Equal(t, "some-nonce", token.Claims.(jwt.MapClaims)["nonce"])
+			}
+			_, err = j.Validate(got)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSigningMethod_Validate(t *testing.T) {
+	now := time.Now()
+	j, err := NewSigningMethod(NewSigningMethodOptions{
+		Algorithm:  AlgorithmES256,
+		PrivateKey: ecKeyPair.privateKey,
+		PublicKey:  ecKeyPair.publicKey,
+		KeyID:      "active",
+		VerificationKeys: []Key{
+			{ID: "retired", Algorithm: AlgorithmRS256, PublicKey: retiredRSAKeyPair.publicKey},
+		},
+		Issuer:   "https://portal.example.com",
+		Audience: []string{"portal", "admin"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	j.timeNow = func() time.Time {
+		return now
+	}
+
+	claims := func(modify func(claims jwt.MapClaims)) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"jti": "token-id",
+			"sub": "1",
+			"iss": "https://portal.example.com",
+			"aud": []string{"portal"},
+			"iat": now.Unix(),
+			"nbf": now.Unix(),
+			"exp": now.Add(time.Minute).Unix(),
+		}
+		if modify != nil {
+			modify(claims)
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name        string
+		tokenString string
+		wantErr     bool
+	}{
+		{
+			name:        "malformed token",
+			tokenString: "invalid",
+			wantErr:     true,
+		},
+		{
+			name:        "signed by the active key",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(nil)),
+			wantErr:     false,
+		},
+		{
+			name:        "signed by a retired key of another algorithm",
+			tokenString: signTestToken(t, jwt.SigningMethodRS256, retiredRSAKeyPair.privateKey, "retired", claims(nil)),
+			wantErr:     false,
+		},
+		{
+			name:        "without kid checked against the active key",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, nil, claims(nil)),
+			wantErr:     false,
+		},
+		{
+			name:        "without kid signed by a retired key",
+			tokenString: signTestToken(t, jwt.SigningMethodRS256, retiredRSAKeyPair.privateKey, nil, claims(nil)),
+			wantErr:     true,
+		},
+		{
+			name:        "unknown kid",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "unknown", claims(nil)),
+			wantErr:     true,
+		},
+		{
+			name:        "kid is not a string",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, 1, claims(nil)),
+			wantErr:     true,
+		},
+		{
+			name:        "alg header does not match the key",
+			tokenString: signTestToken(t, jwt.SigningMethodRS256, retiredRSAKeyPair.privateKey, "active", claims(nil)),
+			wantErr:     true,
+		},
+		{
+			name:        "hmac signed with the public key",
+			tokenString: signTestToken(t, jwt.SigningMethodHS256, retiredRSAKeyPair.publicKey, "retired", claims(nil)),
+			wantErr:     true,
+		},
+		{
+			name: "missing jti",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				delete(claims, "jti")
+			})),
+			wantErr: true,
+		},
+		{
+			name: "missing sub",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				delete(claims, "sub")
+			})),
+			wantErr: true,
+		},
+		{
+			name: "expired token",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				claims["exp"] = now.Unix()
+			})),
+			wantErr: true,
+		},
+		{
+			name: "token not valid yet",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				claims["nbf"] = now.Add(time.Second).Unix()
+			})),
+			wantErr: true,
+		},
+		{
+			name: "another issuer",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				claims["iss"] = "https://evil.example.com"
+			})),
+			wantErr: true,
+		},
+		{
+			name: "another audience",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				claims["aud"] = "billing"
+			})),
+			wantErr: true,
+		},
+		{
+			name: "one of the accepted audiences as string",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				claims["aud"] = "admin"
+			})),
+			wantErr: false,
+		},
+		{
+			name: "malformed audience",
+			tokenString: signTestToken(t, jwt.SigningMethodES256, ecKeyPair.privateKey, "active", claims(func(claims jwt.MapClaims) {
+				claims["aud"] = 1
+			})),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := j.Validate(tt.tokenString)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				assert.Nil(t, got)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, got)
+		})
+	}
+}
+
+func TestSigningMethod_JWKS(t *testing.T) {
+	j, err := NewSigningMethod(NewSigningMethodOptions{
+		Algorithm:  AlgorithmEdDSA,
+		PrivateKey: edKeyPair.privateKey,
+		PublicKey:  edKeyPair.publicKey,
+		VerificationKeys: []Key{
+			{ID: "retired", PublicKey: retiredRSAKeyPair.publicKey},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	got := j.JWKS()
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, j.signingKeyID, got[0].KeyID)
+		assert.Equal(t, AlgorithmEdDSA, got[0].Algorithm)
+		assert.Equal(t, "retired", got[1].KeyID)
+		assert.Equal(t, AlgorithmRS256, got[1].Algorithm)
+	}
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, keyData []byte, kid interface{}, claims jwt.MapClaims) string {
+	var (
+		key interface{}
+		err error
+	)
+	switch method.Alg() {
+	case AlgorithmRS256:
+		key, err = jwt.ParseRSAPrivateKeyFromPEM(keyData)
+	case AlgorithmES256:
+		key, err = jwt.ParseECPrivateKeyFromPEM(keyData)
+	default:
+		key = keyData
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != nil {
+		token.Header["kid"] = kid
+	}
+
+	tokenString, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenString
+}
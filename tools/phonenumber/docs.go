@@ -0,0 +1,2 @@
+// Package phonenumber normalizes phone numbers to E.164 format using numbering rules embedded in the binary.
+package phonenumber
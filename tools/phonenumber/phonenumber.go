@@ -0,0 +1,209 @@
+package phonenumber
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//go:embed metadata.json
+var metadata []byte
+
+// maxCallingCodeLength is the longest country calling code, calling codes are prefix-free so the first match wins.
+const maxCallingCodeLength = 3
+
+var (
+	// ErrEmpty is returned for a phone number without any digit.
+	ErrEmpty = errors.New("phone number must not be empty")
+	// ErrInvalidCharacters is returned for a phone number with characters other than digits and common separators.
+	ErrInvalidCharacters = errors.New("phone number must only contain digits, spaces, dashes, dots, and parentheses, optionally starting with +")
+	// ErrUnknownCallingCode is returned for an international phone number of a country without numbering rules.
+	ErrUnknownCallingCode = errors.New("phone number country code is not supported")
+	// ErrUnknownRegion is returned when the default region has no numbering rules.
+	ErrUnknownRegion = errors.New("phone number region is not supported")
+)
+
+// LengthError is returned when the national number is too short or too long for its country.
+type LengthError struct {
+	CallingCode string
+	MinLength   int
+	MaxLength   int
+}
+
+func (e *LengthError) Error() string {
+	if e.MinLength == e.MaxLength {
+		return fmt.Sprintf("phone number must have %d digits after country code +%s", e.MinLength, e.CallingCode)
+	}
+	return fmt.Sprintf("phone number must have %d-%d digits after country code +%s", e.MinLength, e.MaxLength, e.CallingCode)
+}
+
+// Region holds the numbering rules of a country.
+type Region struct {
+	// Code is the ISO 3166-1 alpha-2 code of the country, like ID.
+	Code string `json:"region"`
+	// CallingCode is dialed before the national number from abroad, like 62.
+	CallingCode string `json:"calling_code"`
+	// TrunkPrefix is dialed before the national number from within the country, like 0. Empty if there is none.
+	TrunkPrefix string `json:"trunk_prefix"`
+	// MinLength and MaxLength bound the number of digits of the national number.
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+}
+
+var (
+	regionsByCode        map[string]Region
+	regionsByCallingCode map[string]Region
+)
+
+func init() {
+	regions, err := parseMetadata(metadata)
+	if err != nil {
+		panic(err)
+	}
+
+	regionsByCode = make(map[string]Region, len(regions))
+	regionsByCallingCode = make(map[string]Region, len(regions))
+	for _, region := range regions {
+		regionsByCode[region.Code] = region
+		// countries sharing a calling code share the rules as well, the first one listed is enough
+		if _, ok := regionsByCallingCode[region.CallingCode]; !ok {
+			regionsByCallingCode[region.CallingCode] = region
+		}
+	}
+}
+
+// parseMetadata reads the list of regions, rejecting rules that could never be met.
+func parseMetadata(data []byte) ([]Region, error) {
+	var regions []Region
+	err := json.Unmarshal(data, &regions)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, region := range regions {
+		if region.Code == "" || region.CallingCode == "" || len(region.CallingCode) > maxCallingCodeLength {
+			return nil, fmt.Errorf("phone number metadata of region %q is not valid", region.Code)
+		}
+		if region.MinLength < 1 || region.MaxLength < region.MinLength {
+			return nil, fmt.Errorf("phone number metadata of region %q has invalid length", region.Code)
+		}
+	}
+
+	return regions, nil
+}
+
+// LookupRegion returns the numbering rules of a country by its ISO 3166-1 alpha-2 code, like ID.
+func LookupRegion(code string) (Region, bool) {
+	region, ok := regionsByCode[strings.ToUpper(code)]
+	return region, ok
+}
+
+// Normalize returns phone number in E.164 format, like +628123456789.
+// Spaces, dashes, dots, and parentheses are ignored. A number starting with + carries its country code,
+// any other number is read as written in defaultRegion, with or without its trunk prefix or country code,
+// so 0812-3456-789 and 628123456789 both become +628123456789 for ID.
+func Normalize(phoneNumber, defaultRegion string) (string, error) {
+	digits, international, err := stripFormatting(phoneNumber)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		region   Region
+		national string
+	)
+	if international {
+		region, national, err = splitCallingCode(digits)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		var ok bool
+		region, ok = LookupRegion(defaultRegion)
+		if !ok {
+			return "", ErrUnknownRegion
+		}
+		national = region.stripCallingCode(digits)
+	}
+
+	// some people keep the trunk prefix after the country code, like +62 (0)812
+	national = region.stripTrunkPrefix(national)
+
+	if !region.validLength(national) {
+		return "", &LengthError{
+			CallingCode: region.CallingCode,
+			MinLength:   region.MinLength,
+			MaxLength:   region.MaxLength,
+		}
+	}
+
+	return "+" + region.CallingCode + national, nil
+}
+
+// stripFormatting returns only the digits of phone number and whether it starts with +.
+func stripFormatting(phoneNumber string) (string, bool, error) {
+	var (
+		trimmed       = strings.TrimSpace(phoneNumber)
+		international = strings.HasPrefix(trimmed, "+")
+		digits        strings.Builder
+	)
+	if international {
+		trimmed = trimmed[1:]
+	}
+
+	for _, char := range trimmed {
+		switch {
+		case char >= '0' && char <= '9':
+			digits.WriteRune(char)
+		case char == ' ', char == '-', char == '.', char == '(', char == ')':
+		default:
+			return "", false, ErrInvalidCharacters
+		}
+	}
+
+	if digits.Len() == 0 {
+		return "", false, ErrEmpty
+	}
+
+	return digits.String(), international, nil
+}
+
+// splitCallingCode separates the country calling code from the national number of an international number.
+func splitCallingCode(digits string) (Region, string, error) {
+	for n := 1; n <= maxCallingCodeLength && n <= len(digits); n++ {
+		if region, ok := regionsByCallingCode[digits[:n]]; ok {
+			return region, digits[n:], nil
+		}
+	}
+	return Region{}, "", ErrUnknownCallingCode
+}
+
+// stripCallingCode drops the calling code written without + in front of a number, like 628123456789.
+// It is only dropped when what remains is a valid national number, otherwise the digits are kept as they are.
+func (r Region) stripCallingCode(digits string) string {
+	if !strings.HasPrefix(digits, r.CallingCode) {
+		return digits
+	}
+
+	national := r.stripTrunkPrefix(digits[len(r.CallingCode):])
+	if !r.validLength(national) {
+		return digits
+	}
+
+	return national
+}
+
+// stripTrunkPrefix drops the trunk prefix in front of a national number.
+func (r Region) stripTrunkPrefix(national string) string {
+	if r.TrunkPrefix == "" {
+		return national
+	}
+	return strings.TrimPrefix(national, r.TrunkPrefix)
+}
+
+// validLength tells whether the national number has as many digits as the country allows.
+func (r Region) validLength(national string) bool {
+	return len(national) >= r.MinLength && len(national) <= r.MaxLength
+}
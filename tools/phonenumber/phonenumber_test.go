@@ -0,0 +1,208 @@
+package phonenumber
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name          string
+		phoneNumber   string
+		defaultRegion string
+		want          string
+		wantErr       error
+	}{
+		{
+			name:          "empty",
+			phoneNumber:   " ",
+			defaultRegion: "ID",
+			wantErr:       ErrEmpty,
+		},
+		{
+			name:          "only plus sign",
+			phoneNumber:   "+",
+			defaultRegion: "ID",
+			wantErr:       ErrEmpty,
+		},
+		{
+			name:          "letters",
+			phoneNumber:   "0812abc456789",
+			defaultRegion: "ID",
+			wantErr:       ErrInvalidCharacters,
+		},
+		{
+			name:          "plus sign in the middle",
+			phoneNumber:   "62+8123456789",
+			defaultRegion: "ID",
+			wantErr:       ErrInvalidCharacters,
+		},
+		{
+			name:          "unknown calling code",
+			phoneNumber:   "+999 123 456 789",
+			defaultRegion: "ID",
+			wantErr:       ErrUnknownCallingCode,
+		},
+		{
+			name:          "unknown default region",
+			phoneNumber:   "08123456789",
+			defaultRegion: "XX",
+			wantErr:       ErrUnknownRegion,
+		},
+		{
+			name:          "too short",
+			phoneNumber:   "+62 812",
+			defaultRegion: "ID",
+			wantErr: &LengthError{
+				CallingCode: "62",
+				MinLength:   8,
+				MaxLength:   12,
+			},
+		},
+		{
+			name:          "too long",
+			phoneNumber:   "+65 9123 45678",
+			defaultRegion: "ID",
+			wantErr: &LengthError{
+				CallingCode: "65",
+				MinLength:   8,
+				MaxLength:   8,
+			},
+		},
+		{
+			name:          "international format with separators",
+			phoneNumber:   "+62 812-3456-7890",
+			defaultRegion: "ID",
+			want:          "+6281234567890",
+		},
+		{
+			name:          "international format with trunk prefix",
+			phoneNumber:   "+62 (0)812 3456 789",
+			defaultRegion: "ID",
+			want:          "+628123456789",
+		},
+		{
+			name:          "national format",
+			phoneNumber:   "0812-3456-789",
+			defaultRegion: "ID",
+			want:          "+628123456789",
+		},
+		{
+			name:          "calling code without plus sign",
+			phoneNumber:   "628123456789",
+			defaultRegion: "ID",
+			want:          "+628123456789",
+		},
+		{
+			name:          "default region is case insensitive",
+			phoneNumber:   "0812.3456.789",
+			defaultRegion: "id",
+			want:          "+628123456789",
+		},
+		{
+			name:          "national number starting like the calling code",
+			phoneNumber:   "6512 3456",
+			defaultRegion: "SG",
+			want:          "+6565123456",
+		},
+		{
+			name:          "shared calling code",
+			phoneNumber:   "+1 (415) 555-2671",
+			defaultRegion: "ID",
+			want:          "+14155552671",
+		},
+		{
+			name:          "national format with trunk prefix of shared calling code",
+			phoneNumber:   "1-415-555-2671",
+			defaultRegion: "US",
+			want:          "+14155552671",
+		},
+		{
+			name:          "three digit calling code",
+			phoneNumber:   "+852 9123 4567",
+			defaultRegion: "ID",
+			want:          "+85291234567",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.phoneNumber, tt.defaultRegion)
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLengthError_Error(t *testing.T) {
+	assert.Equal(t, "phone number must have 8-12 digits after country code +62", (&LengthError{
+		CallingCode: "62",
+		MinLength:   8,
+		MaxLength:   12,
+	}).Error())
+	assert.Equal(t, "phone number must have 8 digits after country code +65", (&LengthError{
+		CallingCode: "65",
+		MinLength:   8,
+		MaxLength:   8,
+	}).Error())
+}
+
+func TestLookupRegion(t *testing.T) {
+	region, ok := LookupRegion("ID")
+	assert.True(t, ok)
+	assert.Equal(t, Region{
+		Code:        "ID",
+		CallingCode: "62",
+		TrunkPrefix: "0",
+		MinLength:   8,
+		MaxLength:   12,
+	}, region)
+
+	_, ok = LookupRegion("XX")
+	assert.False(t, ok)
+}
+
+func Test_parseMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantLen int
+		wantErr bool
+	}{
+		{
+			name:    "invalid json",
+			data:    "[",
+			wantErr: true,
+		},
+		{
+			name:    "missing calling code",
+			data:    `[{"region":"ID","min_length":8,"max_length":12}]`,
+			wantErr: true,
+		},
+		{
+			name:    "calling code too long",
+			data:    `[{"region":"ID","calling_code":"6262","min_length":8,"max_length":12}]`,
+			wantErr: true,
+		},
+		{
+			name:    "max length below min length",
+			data:    `[{"region":"ID","calling_code":"62","min_length":12,"max_length":8}]`,
+			wantErr: true,
+		},
+		{
+			name:    "embedded metadata",
+			data:    string(metadata),
+			wantLen: 21,
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMetadata([]byte(tt.data))
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+			assert.Len(t, got, tt.wantLen)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package tools
+
+import "time"
+
+// RateLimit is a token bucket holding up to Limit requests, refilled evenly over Period.
+// A quiet client can spend the whole bucket at once, then gets one more request every Period / Limit.
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimitResult is the state of a bucket after taking a request from it.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is how long until the next request is allowed, zero while there are requests remaining.
+	RetryAfter time.Duration
+}
@@ -0,0 +1,2 @@
+// Package ratelimit limits how often a client can call a route, using token buckets kept by a pluggable store.
+package ratelimit
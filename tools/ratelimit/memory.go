@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+)
+
+// MemoryLimiter keeps token buckets in process memory.
+// Buckets are lost on restart and not shared between instances.
+type MemoryLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	timeNow    func() time.Time
+	lastPruned time.Time
+}
+
+// bucket remembers the tokens left at the last time it was used, tokens refilled since are computed on demand.
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// fullAt is when the bucket would be full again, it can be forgotten afterwards.
+	fullAt time.Time
+}
+
+// NewMemory returns a new instance of MemoryLimiter.
+func NewMemory() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: map[string]*bucket{},
+		timeNow: time.Now,
+	}
+}
+
+// pruneInterval limits how often full buckets are removed from memory.
+const pruneInterval = time.Minute
+
+// Take spends one request from the bucket of the key, creating a full bucket for a new key.
+func (l *MemoryLimiter) Take(ctx context.Context, key string, limit tools.RateLimit) (tools.RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		now      = l.timeNow()
+		capacity = float64(limit.Limit)
+		// interval is how long it takes to refill one token
+		interval = limit.Period / time.Duration(limit.Limit)
+	)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens:    capacity,
+			updatedAt: now,
+		}
+		l.buckets[key] = b
+	}
+
+	b.tokens += float64(now.Sub(b.updatedAt)) / float64(interval)
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.updatedAt = now
+
+	result := tools.RateLimitResult{
+		Limit: limit.Limit,
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((capacity - b.tokens) * float64(interval))
+	b.fullAt = now.Add(result.ResetAfter)
+
+	l.prune(now)
+
+	return result, nil
+}
+
+// prune removes buckets that have been refilled, a new full bucket would be created for them anyway.
+// Caller must hold the lock.
+func (l *MemoryLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPruned) < pruneInterval {
+		return
+	}
+	l.lastPruned = now
+
+	for key, b := range l.buckets {
+		if !b.fullAt.After(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMemory(t *testing.T) {
+	assert.NotEmpty(t, NewMemory())
+}
+
+func TestMemoryLimiter_Take(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+	l := NewMemory()
+	l.timeNow = func() time.Time {
+		return now
+	}
+	limit := tools.RateLimit{
+		Limit:  3,
+		Period: time.Minute,
+	}
+
+	// a new key starts with a full bucket
+	got, err := l.Take(ctx, "ip:192.0.2.1", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, tools.RateLimitResult{
+		Allowed:    true,
+		Limit:      3,
+		Remaining:  2,
+		ResetAfter: time.Second * 20,
+	}, got)
+
+	_, _ = l.Take(ctx, "ip:192.0.2.1", limit)
+	_, _ = l.Take(ctx, "ip:192.0.2.1", limit)
+
+	// empty bucket refuses until a request is refilled
+	got, err = l.Take(ctx, "ip:192.0.2.1", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, tools.RateLimitResult{
+		Allowed:    false,
+		Limit:      3,
+		Remaining:  0,
+		ResetAfter: time.Minute,
+		RetryAfter: time.Second * 20,
+	}, got)
+
+	// other key is unaffected
+	got, err = l.Take(ctx, "ip:192.0.2.2", limit)
+	assert.NoError(t, err)
+	assert.True(t, got.Allowed)
+
+	// one request is refilled every period / limit
+	now = now.Add(time.Second * 20)
+	got, err = l.Take(ctx, "ip:192.0.2.1", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, tools.RateLimitResult{
+		Allowed:    true,
+		Limit:      3,
+		Remaining:  0,
+		ResetAfter: time.Minute,
+	}, got)
+
+	// bucket never holds more than the limit, and full buckets are pruned
+	now = now.Add(time.Hour)
+	got, err = l.Take(ctx, "ip:192.0.2.1", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got.Remaining)
+	assert.NotContains(t, l.buckets, "ip:192.0.2.2")
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+)
+
+const (
+	// HeaderRateLimitLimit is the number of requests a full bucket holds.
+	HeaderRateLimitLimit = "RateLimit-Limit"
+	// HeaderRateLimitRemaining is the number of requests left in the bucket.
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	// HeaderRateLimitReset is the number of seconds until the bucket is full again.
+	HeaderRateLimitReset = "RateLimit-Reset"
+)
+
+// KeyFunc returns the key requests of a client are counted under.
+type KeyFunc func(c echo.Context) string
+
+// Rule limits requests to one route, or to every route without a rule of its own when used as default.
+type Rule struct {
+	// Method and Path identify the route, Path is the route as registered, like /v1/admin/users/:id.
+	Method string
+	Path   string
+	Limit  tools.RateLimit
+	// Key defaults to KeyByIP when not set.
+	Key KeyFunc
+}
+
+type MiddlewareOptions struct {
+	Limiter tools.RateLimiterInterface
+	Rules   []Rule
+	// Default limits every route without a rule, sharing one bucket per client between those routes.
+	// Routes without a rule are not limited when it is not set.
+	Default *Rule
+}
+
+// Middleware returns a middleware limiting requests by the rule of the matched route,
+// failing when a rule does not allow any request or has no period to refill over.
+// It must be added with echo.Use so the route is known when it runs.
+func Middleware(opts MiddlewareOptions) (echo.MiddlewareFunc, error) {
+	rules := make(map[string]Rule, len(opts.Rules))
+	for _, rule := range opts.Rules {
+		err := validateLimit(rule.Method+" "+rule.Path, rule.Limit)
+		if err != nil {
+			return nil, err
+		}
+		rules[rule.Method+" "+rule.Path] = rule
+	}
+	if opts.Default != nil {
+		err := validateLimit("default", opts.Default.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scope := c.Request().Method + " " + c.Path()
+			rule, ok := rules[scope]
+			if !ok {
+				if opts.Default == nil {
+					return next(c)
+				}
+				rule, scope = *opts.Default, "default"
+			}
+
+			key := KeyByIP
+			if rule.Key != nil {
+				key = rule.Key
+			}
+
+			result, err := opts.Limiter.Take(c.Request().Context(), scope+"|"+key(c), rule.Limit)
+			if err != nil {
+				// an unavailable store should not take every route down with it
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			header.Set(HeaderRateLimitReset, seconds(result.ResetAfter))
+
+			if !result.Allowed {
+				header.Set(echo.HeaderRetryAfter, seconds(result.RetryAfter))
+				return helper.TooManyRequests(c, "too many requests, try again later")
+			}
+
+			return next(c)
+		}
+	}, nil
+}
+
+// validateLimit rejects a limit the limiter cannot refill a bucket with.
+func validateLimit(scope string, limit tools.RateLimit) error {
+	if limit.Limit <= 0 {
+		return fmt.Errorf("rate limit of %s must allow at least one request", scope)
+	}
+	if limit.Period <= 0 {
+		return fmt.Errorf("rate limit of %s must have a positive period", scope)
+	}
+	return nil
+}
+
+// KeyByIP counts requests per client ip, as extracted by echo.IPExtractor.
+func KeyByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// KeyByUserID counts requests per authenticated user, so it only takes effect after authentication.
+// Anonymous requests are counted per client ip.
+func KeyByUserID(c echo.Context) string {
+	userID := helper.UserIDFromContext(c)
+	if userID <= 0 {
+		return KeyByIP(c)
+	}
+	return "user:" + strconv.Itoa(userID)
+}
+
+// KeyByAuthenticatedUser authenticates the request ahead of its handler so it can be counted per user,
+// the handler reuses the claims kept in the context instead of checking the jwt again.
+// Failing to authenticate is left to the handler, such request is counted per client ip.
+func KeyByAuthenticatedUser(auth tools.AuthInterface) KeyFunc {
+	return func(c echo.Context) string {
+		if helper.UserIDFromContext(c) <= 0 {
+			_ = auth.Authenticate(c)
+		}
+		return KeyByUserID(c)
+	}
+}
+
+// seconds rounds partial seconds up so client never retries too early.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,251 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	"github.com/leguminosa/profile-open-portal/tools"
+	"github.com/leguminosa/profile-open-portal/tools/excho/helper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	registerLimit := tools.RateLimit{
+		Limit:  5,
+		Period: time.Hour,
+	}
+	defaultLimit := tools.RateLimit{
+		Limit:  100,
+		Period: time.Minute,
+	}
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		withDefault    bool
+		prepare        func(m *tools.MockRateLimiterInterface)
+		wantCode       int
+		wantBody       string
+		wantHeaders    map[string]string
+		wantNoHeaderOf string
+	}{
+		{
+			name:           "route without rule",
+			method:         http.MethodGet,
+			path:           "/v1/profile",
+			wantCode:       http.StatusOK,
+			wantBody:       "ok",
+			wantNoHeaderOf: HeaderRateLimitLimit,
+		},
+		{
+			name:        "route without rule falls back to default",
+			method:      http.MethodGet,
+			path:        "/v1/profile",
+			withDefault: true,
+			prepare: func(m *tools.MockRateLimiterInterface) {
+				m.EXPECT().Take(gomock.Any(), "default|ip:192.0.2.1", defaultLimit).Return(tools.RateLimitResult{
+					Allowed:    true,
+					Limit:      100,
+					Remaining:  99,
+					ResetAfter: time.Millisecond * 600,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+			wantHeaders: map[string]string{
+				HeaderRateLimitLimit:     "100",
+				HeaderRateLimitRemaining: "99",
+				HeaderRateLimitReset:     "1",
+			},
+		},
+		{
+			name:   "error take",
+			method: http.MethodPost,
+			path:   "/register",
+			prepare: func(m *tools.MockRateLimiterInterface) {
+				m.EXPECT().Take(gomock.Any(), "POST /register|ip:192.0.2.1", registerLimit).Return(tools.RateLimitResult{}, assert.AnError)
+			},
+			wantCode:       http.StatusOK,
+			wantBody:       "ok",
+			wantNoHeaderOf: HeaderRateLimitLimit,
+		},
+		{
+			name:   "limited",
+			method: http.MethodPost,
+			path:   "/register",
+			prepare: func(m *tools.MockRateLimiterInterface) {
+				m.EXPECT().Take(gomock.Any(), "POST /register|ip:192.0.2.1", registerLimit).Return(tools.RateLimitResult{
+					Allowed:    false,
+					Limit:      5,
+					Remaining:  0,
+					ResetAfter: time.Hour,
+					RetryAfter: time.Minute * 12,
+				}, nil)
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantBody: "{\"message\":\"too many requests, try again later\"}\n",
+			wantHeaders: map[string]string{
+				HeaderRateLimitLimit:     "5",
+				HeaderRateLimitRemaining: "0",
+				HeaderRateLimitReset:     "3600",
+				echo.HeaderRetryAfter:    "720",
+			},
+		},
+		{
+			name:   "allowed",
+			method: http.MethodPost,
+			path:   "/register",
+			prepare: func(m *tools.MockRateLimiterInterface) {
+				m.EXPECT().Take(gomock.Any(), "POST /register|ip:192.0.2.1", registerLimit).Return(tools.RateLimitResult{
+					Allowed:    true,
+					Limit:      5,
+					Remaining:  4,
+					ResetAfter: time.Minute * 12,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+			wantHeaders: map[string]string{
+				HeaderRateLimitLimit:     "5",
+				HeaderRateLimitRemaining: "4",
+				HeaderRateLimitReset:     "720",
+			},
+			wantNoHeaderOf: echo.HeaderRetryAfter,
+		},
+	}
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLimiter := tools.NewMockRateLimiterInterface(ctrl)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare(mockLimiter)
+			}
+
+			opts := MiddlewareOptions{
+				Limiter: mockLimiter,
+				Rules: []Rule{
+					{
+						Method: http.MethodPost,
+						Path:   "/register",
+						Limit:  registerLimit,
+					},
+				},
+			}
+			if tt.withDefault {
+				opts.Default = &Rule{
+					Limit: defaultLimit,
+				}
+			}
+
+			middleware, err := Middleware(opts)
+			assert.NoError(t, err)
+
+			e := echo.New()
+			e.Use(middleware)
+			ok := func(c echo.Context) error {
+				return c.String(http.StatusOK, "ok")
+			}
+			e.POST("/register", ok)
+			e.GET("/v1/profile", ok)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			for key, value := range tt.wantHeaders {
+				assert.Equal(t, value, rec.Header().Get(key), key)
+			}
+			if tt.wantNoHeaderOf != "" {
+				assert.Empty(t, rec.Header().Get(tt.wantNoHeaderOf))
+			}
+		})
+	}
+}
+
+func TestMiddleware_invalidLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts MiddlewareOptions
+	}{
+		{
+			name: "rule without limit",
+			opts: MiddlewareOptions{
+				Rules: []Rule{
+					{
+						Method: http.MethodPost,
+						Path:   "/register",
+						Limit: tools.RateLimit{
+							Period: time.Hour,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "rule without period",
+			opts: MiddlewareOptions{
+				Rules: []Rule{
+					{
+						Method: http.MethodPost,
+						Path:   "/register",
+						Limit: tools.RateLimit{
+							Limit: 5,
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "default without limit",
+			opts: MiddlewareOptions{
+				Default: &Rule{
+					Limit: tools.RateLimit{
+						Period: time.Minute,
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Middleware(tt.opts)
+			assert.Error(t, err)
+			assert.Nil(t, got)
+		})
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAuth := tools.NewMockAuthInterface(ctrl)
+	e := echo.New()
+	newContext := func() echo.Context {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	c := newContext()
+	assert.Equal(t, "ip:192.0.2.1", KeyByIP(c))
+	assert.Equal(t, "ip:192.0.2.1", KeyByUserID(c))
+	mockAuth.EXPECT().Authenticate(c).Return(assert.AnError)
+	assert.Equal(t, "ip:192.0.2.1", KeyByAuthenticatedUser(mockAuth)(c))
+
+	c = newContext()
+	mockAuth.EXPECT().Authenticate(c).DoAndReturn(func(c echo.Context) error {
+		helper.SetUserIDToContext(c, 15)
+		return nil
+	})
+	assert.Equal(t, "user:15", KeyByAuthenticatedUser(mockAuth)(c))
+
+	c = newContext()
+	helper.SetUserIDToContext(c, 15)
+	assert.Equal(t, "user:15", KeyByUserID(c))
+	assert.Equal(t, "user:15", KeyByAuthenticatedUser(mockAuth)(c))
+}
@@ -0,0 +1,2 @@
+// Package sms delivers text messages to phone numbers.
+package sms
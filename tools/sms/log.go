@@ -0,0 +1,33 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogSender is a fake sender for development, it writes every message to w instead of delivering it.
+type LogSender struct {
+	mu      sync.Mutex
+	w       io.Writer
+	timeNow func() time.Time
+}
+
+// NewLogSender returns a new LogSender writing to w, like os.Stdout or an opened file.
+func NewLogSender(w io.Writer) *LogSender {
+	return &LogSender{
+		w:       w,
+		timeNow: time.Now,
+	}
+}
+
+// Send writes a single line containing the time, the phone number, and the message.
+func (s *LogSender) Send(ctx context.Context, phoneNumber, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s sms to %s: %q\n", s.timeNow().Format(time.RFC3339), phoneNumber, message)
+	return err
+}
@@ -0,0 +1,43 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type errorWriter struct{}
+
+func (errorWriter) Write(p []byte) (int, error) {
+	return 0, assert.AnError
+}
+
+func TestNewLogSender(t *testing.T) {
+	assert.NotEmpty(t, NewLogSender(&bytes.Buffer{}))
+}
+
+func TestLogSender_Send(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 8, 5, 12, 0, 0, 0, time.UTC)
+
+	// error write
+	s := NewLogSender(errorWriter{})
+	err := s.Send(ctx, "628123456789", "hello")
+	assert.Error(t, err)
+
+	// success
+	buf := &bytes.Buffer{}
+	s = NewLogSender(buf)
+	s.timeNow = func() time.Time {
+		return now
+	}
+	err = s.Send(ctx, "628123456789", "your code is 123456")
+	assert.NoError(t, err)
+	err = s.Send(ctx, "628123456780", "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "2023-08-05T12:00:00Z sms to 628123456789: \"your code is 123456\"\n"+
+		"2023-08-05T12:00:00Z sms to 628123456780: \"hello\"\n", buf.String())
+}
@@ -0,0 +1,2 @@
+// Package totp implements time-based one-time password of RFC 6238, compatible with common authenticator apps.
+package totp
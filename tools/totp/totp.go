@@ -0,0 +1,130 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultIssuer is shown in authenticator apps when no issuer is set.
+	DefaultIssuer = "Profile Open Portal"
+	// period is how long each code is valid, most authenticator apps only support 30 seconds.
+	period = 30
+	// digits is the length of each code.
+	digits = 6
+	// secretSize is the number of random bytes of a secret, 160 bits as recommended for HMAC-SHA1.
+	secretSize = 20
+	// skew is the number of steps before and after current one still accepted, to tolerate clock drift.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP generates and validates codes of authenticator apps.
+type TOTP struct {
+	issuer  string
+	reader  io.Reader
+	timeNow func() time.Time
+}
+
+type NewTOTPOptions struct {
+	// Issuer defaults to DefaultIssuer when not set.
+	Issuer string
+	// TimeNow defaults to time.Now, it lets tests control the clock.
+	TimeNow func() time.Time
+}
+
+// New returns a new TOTP instance.
+func New(opts NewTOTPOptions) *TOTP {
+	issuer := opts.Issuer
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+
+	timeNow := opts.TimeNow
+	if timeNow == nil {
+		timeNow = time.Now
+	}
+
+	return &TOTP{
+		issuer:  issuer,
+		reader:  rand.Reader,
+		timeNow: timeNow,
+	}
+}
+
+// GenerateSecret returns a new random secret encoded in unpadded base32.
+func (t *TOTP) GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	_, err := io.ReadFull(t.reader, b)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns otpauth:// uri following the key uri format understood by authenticator apps.
+func (t *TOTP) URI(secret, accountName string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", t.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(digits))
+	query.Set("period", strconv.Itoa(period))
+
+	label := url.PathEscape(t.issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Validate compares the code against the codes of current time step and the steps next to it.
+func (t *TOTP) Validate(secret, code string) (int64, bool) {
+	if len(code) != digits {
+		return 0, false
+	}
+
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	current := t.timeNow().Unix() / period
+	for step := current - skew; step <= current+skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// hotp returns the code of a counter as described in RFC 4226.
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation picks 4 bytes at the offset given by the last nibble
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,139 @@
+package totp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the base32 encoding of "12345678901234567890", the sha1 secret of RFC 6238 test vectors.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestNew(t *testing.T) {
+	got := New(NewTOTPOptions{})
+	assert.Equal(t, DefaultIssuer, got.issuer)
+	assert.NotNil(t, got.timeNow)
+
+	got = New(NewTOTPOptions{
+		Issuer: "Acme",
+	})
+	assert.Equal(t, "Acme", got.issuer)
+}
+
+func TestTOTP_GenerateSecret(t *testing.T) {
+	tp := New(NewTOTPOptions{})
+
+	// reader runs out of bytes
+	tp.reader = bytes.NewReader([]byte{1, 2})
+	got, err := tp.GenerateSecret()
+	assert.Error(t, err)
+	assert.Empty(t, got)
+
+	// deterministic reader
+	tp.reader = bytes.NewReader([]byte("12345678901234567890"))
+	got, err = tp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, rfcSecret, got)
+
+	// success (can't assert the value exactly due to its non-deterministic nature)
+	tp = New(NewTOTPOptions{})
+	got, err = tp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, got, 32)
+}
+
+func TestTOTP_URI(t *testing.T) {
+	tp := New(NewTOTPOptions{
+		Issuer: "Profile Portal",
+	})
+
+	got := tp.URI(rfcSecret, "628123456789")
+	assert.Equal(t, "otpauth://totp/Profile%20Portal:628123456789?algorithm=SHA1&digits=6&issuer=Profile+Portal&period=30&secret="+rfcSecret, got)
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{
+			name:   "code length is not valid",
+			now:    time.Unix(59, 0),
+			secret: rfcSecret,
+			code:   "94287082",
+			wantOK: false,
+		},
+		{
+			name:   "secret is not valid base32",
+			now:    time.Unix(59, 0),
+			secret: "not base32!",
+			code:   "287082",
+			wantOK: false,
+		},
+		{
+			name:     "rfc test vector at 59",
+			now:      time.Unix(59, 0),
+			secret:   rfcSecret,
+			code:     "287082",
+			wantStep: 1,
+			wantOK:   true,
+		},
+		{
+			name:     "rfc test vector at 1111111109",
+			now:      time.Unix(1111111109, 0),
+			secret:   rfcSecret,
+			code:     "081804",
+			wantStep: 37037036,
+			wantOK:   true,
+		},
+		{
+			name:     "lowercase secret",
+			now:      time.Unix(1234567890, 0),
+			secret:   "gezdgnbvgy3tqojqgezdgnbvgy3tqojq",
+			code:     "005924",
+			wantStep: 41152263,
+			wantOK:   true,
+		},
+		{
+			name:     "previous step is accepted",
+			now:      time.Unix(89, 0),
+			secret:   rfcSecret,
+			code:     "287082",
+			wantStep: 1,
+			wantOK:   true,
+		},
+		{
+			name:   "older step is rejected",
+			now:    time.Unix(90, 0),
+			secret: rfcSecret,
+			code:   "287082",
+			wantOK: false,
+		},
+		{
+			name:   "wrong code",
+			now:    time.Unix(59, 0),
+			secret: rfcSecret,
+			code:   "123456",
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := New(NewTOTPOptions{
+				TimeNow: func() time.Time {
+					return tt.now
+				},
+			})
+
+			gotStep, gotOK := tp.Validate(tt.secret, tt.code)
+			assert.Equal(t, tt.wantStep, gotStep)
+			assert.Equal(t, tt.wantOK, gotOK)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// weakPasswordMessage is returned when a password follows every rule but is still easy to guess.
+const weakPasswordMessage = "password is too easy to guess, make it longer or less predictable"
+
+// PasswordPolicy decides which passwords are accepted.
+// It is safe to share with clients so they can validate before submitting.
+type PasswordPolicy struct {
+	// MinLength and MaxLength bound the number of characters, not bytes.
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+	// RequireUppercase, RequireLowercase, RequireNumber, and RequireSpecial each require at least 1 character of the class.
+	RequireUppercase bool `json:"require_uppercase"`
+	RequireLowercase bool `json:"require_lowercase"`
+	RequireNumber    bool `json:"require_number"`
+	RequireSpecial   bool `json:"require_special"`
+	// SpecialCharacters lists the characters counted as special.
+	// When empty, any Unicode punctuation or symbol is special.
+	SpecialCharacters string `json:"special_characters"`
+	// MinStrength is the lowest PasswordStrength score accepted, from 0 to 4. 0 accepts any password that follows the rules.
+	MinStrength int `json:"min_strength"`
+	// HistoryDepth is the number of latest passwords of a user that cannot be reused, the current one included.
+	// 0 allows reusing any password.
+	HistoryDepth int `json:"history_depth"`
+}
+
+// DefaultPasswordPolicy returns the policy used when none is configured.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:        6,
+		MaxLength:        64,
+		RequireUppercase: true,
+		RequireNumber:    true,
+		RequireSpecial:   true,
+		MinStrength:      2,
+		HistoryDepth:     5,
+	}
+}
+
+// ParsePasswordPolicy reads a policy from JSON, fields left out keep their value from DefaultPasswordPolicy.
+func ParsePasswordPolicy(data []byte) (PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy()
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&policy)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	err = policy.check()
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	return policy, nil
+}
+
+// check rejects a policy no password could ever follow or that makes no sense.
+func (p PasswordPolicy) check() error {
+	switch {
+	case p.MinLength < 1:
+		return errors.New("password policy min_length must be at least 1")
+	case p.MaxLength < p.MinLength:
+		return fmt.Errorf("password policy max_length must be at least %d", p.MinLength)
+	case p.MinStrength < 0 || p.MinStrength > 4:
+		return errors.New("password policy min_strength must be 0-4")
+	case p.HistoryDepth < 0:
+		return errors.New("password policy history_depth must not be negative")
+	}
+
+	return nil
+}
+
+// IsSpecial tells whether the character counts as special under the policy.
+func (p PasswordPolicy) IsSpecial(char rune) bool {
+	if p.SpecialCharacters != "" {
+		return strings.ContainsRune(p.SpecialCharacters, char)
+	}
+	return unicode.IsPunct(char) || unicode.IsSymbol(char)
+}
+
+// Validate validates password against the policy.
+// Strength is only scored once every other rule is followed, so the user is not told to fix too much at once.
+func (p PasswordPolicy) Validate(password string) (messages []string, valid bool) {
+	messages = []string{}
+	valid = true
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength || length > p.MaxLength {
+		messages = append(messages, fmt.Sprintf("password must be %d-%d characters", p.MinLength, p.MaxLength))
+		valid = false
+	}
+
+	hasUppercase := false
+	hasLowercase := false
+	hasNumber := false
+	hasSpecialChar := false
+
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUppercase = true
+		case unicode.IsLower(char):
+			hasLowercase = true
+		case unicode.IsDigit(char):
+			hasNumber = true
+		case p.IsSpecial(char):
+			hasSpecialChar = true
+		}
+	}
+
+	// every required class is listed in the message once any of them is missing
+	var (
+		required []string
+		missing  bool
+	)
+	if p.RequireUppercase {
+		required = append(required, "1 uppercase letter")
+		missing = missing || !hasUppercase
+	}
+	if p.RequireLowercase {
+		required = append(required, "1 lowercase letter")
+		missing = missing || !hasLowercase
+	}
+	if p.RequireNumber {
+		required = append(required, "1 number")
+		missing = missing || !hasNumber
+	}
+	if p.RequireSpecial {
+		required = append(required, "1 special character")
+		missing = missing || !hasSpecialChar
+	}
+
+	if missing {
+		messages = append(messages, "password must contain at least "+joinList(required))
+		valid = false
+	}
+
+	if valid && PasswordStrength(password) < p.MinStrength {
+		messages = append(messages, weakPasswordMessage)
+		valid = false
+	}
+
+	return
+}
+
+// joinList joins items into an english list like "a, b, and c".
+func joinList(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " and " + items[1]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + ", and " + items[len(items)-1]
+}
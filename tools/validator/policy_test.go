@@ -0,0 +1,259 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePasswordPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    PasswordPolicy
+		wantErr bool
+	}{
+		{
+			name:    "invalid json",
+			data:    "{",
+			want:    PasswordPolicy{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			data:    `{"min_lenght":8}`,
+			want:    PasswordPolicy{},
+			wantErr: true,
+		},
+		{
+			name:    "min length is zero",
+			data:    `{"min_length":0}`,
+			want:    PasswordPolicy{},
+			wantErr: true,
+		},
+		{
+			name:    "max length is below min length",
+			data:    `{"min_length":12,"max_length":8}`,
+			want:    PasswordPolicy{},
+			wantErr: true,
+		},
+		{
+			name:    "min strength is out of range",
+			data:    `{"min_strength":5}`,
+			want:    PasswordPolicy{},
+			wantErr: true,
+		},
+		{
+			name:    "history depth is negative",
+			data:    `{"history_depth":-1}`,
+			want:    PasswordPolicy{},
+			wantErr: true,
+		},
+		{
+			name:    "empty policy keeps defaults",
+			data:    `{}`,
+			want:    DefaultPasswordPolicy(),
+			wantErr: false,
+		},
+		{
+			name: "success",
+			data: `{"min_length":12,"max_length":128,"require_lowercase":true,"require_special":false,"special_characters":"*-_","min_strength":3,"history_depth":5}`,
+			want: PasswordPolicy{
+				MinLength:         12,
+				MaxLength:         128,
+				RequireUppercase:  true,
+				RequireLowercase:  true,
+				RequireNumber:     true,
+				RequireSpecial:    false,
+				SpecialCharacters: "*-_",
+				MinStrength:       3,
+				HistoryDepth:      5,
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePasswordPolicy([]byte(tt.data))
+			if !assert.Equal(t, tt.wantErr, err != nil) {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPasswordPolicy_IsSpecial(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy PasswordPolicy
+		char   rune
+		want   bool
+	}{
+		{
+			name:   "ascii punctuation",
+			policy: PasswordPolicy{},
+			char:   '-',
+			want:   true,
+		},
+		{
+			name:   "ascii symbol",
+			policy: PasswordPolicy{},
+			char:   '*',
+			want:   true,
+		},
+		{
+			name:   "unicode symbol",
+			policy: PasswordPolicy{},
+			char:   '€',
+			want:   true,
+		},
+		{
+			name:   "unicode punctuation",
+			policy: PasswordPolicy{},
+			char:   '¿',
+			want:   true,
+		},
+		{
+			name:   "letter",
+			policy: PasswordPolicy{},
+			char:   'é',
+			want:   false,
+		},
+		{
+			name:   "space",
+			policy: PasswordPolicy{},
+			char:   ' ',
+			want:   false,
+		},
+		{
+			name: "listed character",
+			policy: PasswordPolicy{
+				SpecialCharacters: "!@#",
+			},
+			char: '@',
+			want: true,
+		},
+		{
+			name: "character not listed",
+			policy: PasswordPolicy{
+				SpecialCharacters: "!@#",
+			},
+			char: '*',
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.IsSpecial(tt.char))
+		})
+	}
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       PasswordPolicy
+		password     string
+		wantMessages []string
+		wantValid    bool
+	}{
+		{
+			name: "length counts characters instead of bytes",
+			policy: PasswordPolicy{
+				MinLength: 4,
+				MaxLength: 4,
+			},
+			password:     "ñöüé",
+			wantMessages: []string{},
+			wantValid:    true,
+		},
+		{
+			name: "password is too long",
+			policy: PasswordPolicy{
+				MinLength: 4,
+				MaxLength: 8,
+			},
+			password: "abcdefghi",
+			wantMessages: []string{
+				"password must be 4-8 characters",
+			},
+			wantValid: false,
+		},
+		{
+			name: "only the required classes are listed",
+			policy: PasswordPolicy{
+				MinLength:        4,
+				MaxLength:        64,
+				RequireLowercase: true,
+				RequireNumber:    true,
+			},
+			password: "ABCDEFG",
+			wantMessages: []string{
+				"password must contain at least 1 lowercase letter and 1 number",
+			},
+			wantValid: false,
+		},
+		{
+			name:         "asterisk and dash are special characters",
+			policy:       DefaultPasswordPolicy(),
+			password:     "Kite9*zebra-",
+			wantMessages: []string{},
+			wantValid:    true,
+		},
+		{
+			name:         "unicode symbol is a special character",
+			policy:       DefaultPasswordPolicy(),
+			password:     "Kite9€zebra",
+			wantMessages: []string{},
+			wantValid:    true,
+		},
+		{
+			name: "character not listed as special",
+			policy: PasswordPolicy{
+				MinLength:         4,
+				MaxLength:         64,
+				RequireSpecial:    true,
+				SpecialCharacters: "!@#",
+			},
+			password: "kite9*zebra",
+			wantMessages: []string{
+				"password must contain at least 1 special character",
+			},
+			wantValid: false,
+		},
+		{
+			name: "password is too easy to guess",
+			policy: PasswordPolicy{
+				MinLength:   4,
+				MaxLength:   64,
+				MinStrength: 2,
+			},
+			password: "aaaaaaaa",
+			wantMessages: []string{
+				"password is too easy to guess, make it longer or less predictable",
+			},
+			wantValid: false,
+		},
+		{
+			name: "strength is not scored while rules are broken",
+			policy: PasswordPolicy{
+				MinLength:   10,
+				MaxLength:   64,
+				MinStrength: 2,
+			},
+			password: "aaaa",
+			wantMessages: []string{
+				"password must be 10-64 characters",
+			},
+			wantValid: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMessages, gotValid := tt.policy.Validate(tt.password)
+			assert.Equal(t, tt.wantValid, gotValid)
+			assert.Equal(t, tt.wantMessages, gotMessages)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"math"
+	"unicode"
+)
+
+// minSequenceLength is the shortest run of repeated or sequential characters counted as a pattern, like "aaa" or "123".
+const minSequenceLength = 3
+
+// PasswordStrength scores how hard password is to guess from 0 (too guessable) to 4 (very unguessable),
+// following the thresholds of zxcvbn. Guesses are roughly estimated from the kind of each character,
+// runs of repeated or sequential characters count as little more than their first one.
+// Dictionary words are not recognized here, they are left to the password blocklist.
+func PasswordStrength(password string) int {
+	guesses := estimateGuessesLog10(password)
+
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	}
+	return 4
+}
+
+// estimateGuessesLog10 returns the estimated number of guesses to find password, in log10 to stay clear of overflow.
+func estimateGuessesLog10(password string) float64 {
+	var (
+		chars   = []rune(password)
+		guesses float64
+	)
+
+	for i := 0; i < len(chars); {
+		cardinality := float64(charCardinality(chars[i]))
+
+		// repeated characters like "aaaa" only need the character and the count to be guessed
+		n := runLength(chars, i, 0)
+		if n >= minSequenceLength {
+			guesses += math.Log10(cardinality * float64(n))
+			i += n
+			continue
+		}
+
+		// sequential characters like "abcd" or "4321" also need the direction to be guessed
+		if i+1 < len(chars) {
+			step := chars[i+1] - chars[i]
+			if step == 1 || step == -1 {
+				n = runLength(chars, i, step)
+				if n >= minSequenceLength {
+					guesses += math.Log10(cardinality * float64(n) * 2)
+					i += n
+					continue
+				}
+			}
+		}
+
+		guesses += math.Log10(cardinality)
+		i++
+	}
+
+	return guesses
+}
+
+// runLength returns how many characters from start follow each other by step.
+func runLength(chars []rune, start int, step rune) int {
+	n := 1
+	for start+n < len(chars) && chars[start+n]-chars[start+n-1] == step {
+		n++
+	}
+	return n
+}
+
+// charCardinality returns the number of characters an attacker would try for a character of the same kind.
+func charCardinality(char rune) int {
+	switch {
+	case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z':
+		return 26
+	case char >= '0' && char <= '9':
+		return 10
+	case char <= unicode.MaxASCII:
+		return 33
+	}
+	return 100
+}
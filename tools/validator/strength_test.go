@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     int
+	}{
+		{
+			name:     "empty password",
+			password: "",
+			want:     0,
+		},
+		{
+			name:     "repeated characters",
+			password: "aaaaaaaaaa",
+			want:     0,
+		},
+		{
+			name:     "sequential digits",
+			password: "123456789",
+			want:     0,
+		},
+		{
+			name:     "descending sequence",
+			password: "zyxwvu",
+			want:     0,
+		},
+		{
+			name:     "short lowercase word",
+			password: "kite",
+			want:     1,
+		},
+		{
+			name:     "sequence with a few extra characters",
+			password: "Abcde3#",
+			want:     2,
+		},
+		{
+			name:     "mixed characters",
+			password: "Ab9!abab",
+			want:     4,
+		},
+		{
+			name:     "long passphrase",
+			password: "correct horse battery staple",
+			want:     4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PasswordStrength(tt.password))
+		})
+	}
+}
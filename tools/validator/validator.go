@@ -1,28 +1,58 @@
 package validator
 
-// ValidatePhoneNumber validates phone number field based off certain criteria.
-func ValidatePhoneNumber(phoneNumber string) (messages []string, valid bool) {
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/leguminosa/profile-open-portal/tools/phonenumber"
+)
+
+// maxEmailLength is the longest address that fits the path of an smtp command (RFC 5321 section 4.5.3.1.3).
+const maxEmailLength = 254
+
+// ValidatePhoneNumber validates phone number field and normalizes it to E.164 format, like +628123456789.
+// Number without country code is read as written in defaultRegion.
+func ValidatePhoneNumber(phoneNumber, defaultRegion string) (normalized string, messages []string, valid bool) {
 	messages = []string{}
 	valid = true
 
-	// phone number must be numeric
-	for _, char := range phoneNumber {
-		if char < '0' || char > '9' {
-			messages = append(messages, "phone number must be numeric")
-			valid = false
-		}
+	normalized, err := phonenumber.Normalize(phoneNumber, defaultRegion)
+	if err != nil {
+		messages = append(messages, err.Error())
+		valid = false
 	}
 
-	// phone number must be 10-13 digits
-	if len(phoneNumber) < 10 || len(phoneNumber) > 13 {
-		messages = append(messages, "phone number must be 10-13 digits")
-		valid = false
+	return
+}
+
+// NormalizeEmail returns email trimmed and lowercased, the only form emails are stored in.
+// Local part is technically case-sensitive, but no mail provider in use treats it that way.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail validates email field and normalizes it with NormalizeEmail.
+// Only a bare address is accepted, without display name or angle brackets.
+func ValidateEmail(email string) (normalized string, messages []string, valid bool) {
+	messages = []string{}
+	valid = true
+
+	normalized = NormalizeEmail(email)
+	if normalized == "" {
+		messages = append(messages, "email must not be empty")
+		return "", messages, false
+	}
+	if len(normalized) > maxEmailLength {
+		messages = append(messages, "email must be at most 254 characters")
+		return "", messages, false
 	}
 
-	// phone number must start with 62
-	if len(phoneNumber) < 2 || phoneNumber[0:2] != "62" {
-		messages = append(messages, "phone number must start with 62")
-		valid = false
+	// a domain without dot like localhost is valid in RFC 5322, but never reachable from outside
+	address, err := mail.ParseAddress(normalized)
+	if err != nil || address.Name != "" || address.Address != normalized ||
+		!strings.Contains(normalized[strings.LastIndex(normalized, "@")+1:], ".") {
+		messages = append(messages, "email must be a valid address like name@example.com")
+		return "", messages, false
 	}
 
 	return
@@ -42,37 +72,7 @@ func ValidateFullName(fullName string) (messages []string, valid bool) {
 	return
 }
 
-// ValidatePassword validates password field based off certain criteria.
+// ValidatePassword validates password field against DefaultPasswordPolicy.
 func ValidatePassword(password string) (messages []string, valid bool) {
-	messages = []string{}
-	valid = true
-
-	// password must be 6-64 characters
-	if len(password) < 6 || len(password) > 64 {
-		messages = append(messages, "password must be 6-64 characters")
-		valid = false
-	}
-
-	// password must contain at least 1 uppercase letter, 1 number, and 1 special character
-	hasUppercase := false
-	hasNumber := false
-	hasSpecialChar := false
-
-	for _, char := range password {
-		switch {
-		case char >= 'A' && char <= 'Z':
-			hasUppercase = true
-		case char >= '0' && char <= '9':
-			hasNumber = true
-		case char == '!' || char == '@' || char == '#' || char == '$' || char == '%' || char == '^' || char == '&':
-			hasSpecialChar = true
-		}
-	}
-
-	if !hasUppercase || !hasNumber || !hasSpecialChar {
-		messages = append(messages, "password must contain at least 1 uppercase letter, 1 number, and 1 special character")
-		valid = false
-	}
-
-	return
+	return DefaultPasswordPolicy().Validate(password)
 }
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -8,26 +9,25 @@ import (
 
 func TestValidatePhoneNumber(t *testing.T) {
 	tests := []struct {
-		name         string
-		phoneNumber  string
-		wantMessages []string
-		wantValid    bool
+		name           string
+		phoneNumber    string
+		wantNormalized string
+		wantMessages   []string
+		wantValid      bool
 	}{
 		{
-			name:        "invalid phone number",
-			phoneNumber: "a",
+			name:        "phone number is empty",
+			phoneNumber: "",
 			wantMessages: []string{
-				"phone number must be numeric",
-				"phone number must be 10-13 digits",
-				"phone number must start with 62",
+				"phone number must not be empty",
 			},
 			wantValid: false,
 		},
 		{
-			name:        "number contains non-numeric character",
-			phoneNumber: "628123456789a",
+			name:        "number contains non-numeric characters",
+			phoneNumber: "62812abc6789",
 			wantMessages: []string{
-				"phone number must be numeric",
+				"phone number must only contain digits, spaces, dashes, dots, and parentheses, optionally starting with +",
 			},
 			wantValid: false,
 		},
@@ -35,37 +35,115 @@ func TestValidatePhoneNumber(t *testing.T) {
 			name:        "number is too short",
 			phoneNumber: "62812",
 			wantMessages: []string{
-				"phone number must be 10-13 digits",
+				"phone number must have 8-12 digits after country code +62",
+			},
+			wantValid: false,
+		},
+		{
+			name:        "country code is not supported",
+			phoneNumber: "+999123456789",
+			wantMessages: []string{
+				"phone number country code is not supported",
+			},
+			wantValid: false,
+		},
+		{
+			name:           "national format",
+			phoneNumber:    "0812-3456-789",
+			wantNormalized: "+628123456789",
+			wantMessages:   []string{},
+			wantValid:      true,
+		},
+		{
+			name:           "international format",
+			phoneNumber:    "+65 9123 4567",
+			wantNormalized: "+6591234567",
+			wantMessages:   []string{},
+			wantValid:      true,
+		},
+		{
+			name:           "valid phone number",
+			phoneNumber:    "628123456789",
+			wantNormalized: "+628123456789",
+			wantMessages:   []string{},
+			wantValid:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNormalized, gotMessages, gotValid := ValidatePhoneNumber(tt.phoneNumber, "ID")
+			assert.Equal(t, tt.wantValid, gotValid)
+			assert.Equal(t, tt.wantNormalized, gotNormalized)
+			assert.Equal(t, tt.wantMessages, gotMessages)
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	assert.Equal(t, "john.doe@example.com", NormalizeEmail("  John.Doe@Example.COM "))
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		name           string
+		email          string
+		wantNormalized string
+		wantMessages   []string
+		wantValid      bool
+	}{
+		{
+			name:  "email is empty",
+			email: " ",
+			wantMessages: []string{
+				"email must not be empty",
 			},
 			wantValid: false,
 		},
 		{
-			name:        "number is too long",
-			phoneNumber: "6281299231855678",
+			name:  "email is too long",
+			email: strings.Repeat("a", 243) + "@example.com",
 			wantMessages: []string{
-				"phone number must be 10-13 digits",
+				"email must be at most 254 characters",
 			},
 			wantValid: false,
 		},
 		{
-			name:        "number does not start with 62",
-			phoneNumber: "08123456789",
+			name:  "missing at sign",
+			email: "john.example.com",
 			wantMessages: []string{
-				"phone number must start with 62",
+				"email must be a valid address like name@example.com",
 			},
 			wantValid: false,
 		},
 		{
-			name:         "valid phone number",
-			phoneNumber:  "628123456789",
-			wantMessages: []string{},
-			wantValid:    true,
+			name:  "display name",
+			email: "John <john@example.com>",
+			wantMessages: []string{
+				"email must be a valid address like name@example.com",
+			},
+			wantValid: false,
+		},
+		{
+			name:  "domain without dot",
+			email: "john@localhost",
+			wantMessages: []string{
+				"email must be a valid address like name@example.com",
+			},
+			wantValid: false,
+		},
+		{
+			name:           "valid email is normalized",
+			email:          " John.Doe+portal@Example.com",
+			wantNormalized: "john.doe+portal@example.com",
+			wantMessages:   []string{},
+			wantValid:      true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotMessages, gotValid := ValidatePhoneNumber(tt.phoneNumber)
+			gotNormalized, gotMessages, gotValid := ValidateEmail(tt.email)
 			assert.Equal(t, tt.wantValid, gotValid)
+			assert.Equal(t, tt.wantNormalized, gotNormalized)
 			assert.Equal(t, tt.wantMessages, gotMessages)
 		})
 	}